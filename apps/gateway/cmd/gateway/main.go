@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,6 +16,7 @@ import (
 
 	"nextai/apps/gateway/internal/app"
 	"nextai/apps/gateway/internal/config"
+	"nextai/apps/gateway/internal/observability"
 )
 
 const (
@@ -48,14 +50,48 @@ func main() {
 }
 
 func run() error {
-	if path, loaded, err := loadEnvFile(); err != nil {
-		log.Printf("load env file failed: path=%s err=%v", path, err)
-	} else if loaded > 0 {
-		log.Printf("loaded %d env values from %s", loaded, path)
+	envPath, envLoaded, envErr := loadEnvFile()
+	cfg := config.Load()
+	logger, err := observability.NewLogger(observability.LogConfig{
+		Level:   cfg.LogLevel,
+		Format:  cfg.LogFormat,
+		Secrets: []string{cfg.APIKey, cfg.MasterKey},
+	})
+	if err != nil {
+		return fmt.Errorf("init logger failed: %w", err)
+	}
+	observability.SetDefaultLogger(logger)
+	if envErr != nil {
+		logger.Warn("load env file failed", "path", envPath, "err", envErr)
+	} else if envLoaded > 0 {
+		logger.Info("loaded env values", "count", envLoaded, "path", envPath)
 	}
 
-	cfg := config.Load()
-	srv, err := app.NewServer(cfg)
+	if len(os.Args) > 1 && os.Args[1] == commandRotateMasterKey {
+		return runRotateMasterKey(cfg, os.Args[2:], os.Stderr)
+	}
+	traceExporter, err := observability.NewTraceExporter(observability.TraceExporterConfig{
+		Exporter:     cfg.TraceExporter,
+		OTLPEndpoint: cfg.TraceOTLPEndpoint,
+		OTLPHeaders:  cfg.TraceOTLPHeaders,
+		File:         cfg.TraceFile,
+	})
+	if err != nil {
+		return fmt.Errorf("init trace exporter failed: %w", err)
+	}
+	stopTracer := observability.StartTracer(traceExporter)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := stopTracer(ctx); err != nil {
+			logger.Warn("trace exporter shutdown failed", "err", err)
+		}
+	}()
+	if traceExporter != nil {
+		logger.Info("tracing enabled", "exporter", cfg.TraceExporter)
+	}
+
+	srv, err := app.NewServer(cfg, app.WithLogger(logger))
 	if err != nil {
 		return fmt.Errorf("init server failed: %w", err)
 	}
@@ -64,24 +100,36 @@ func run() error {
 	addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
 	runtimeCfg := loadHTTPRuntimeConfig()
 	httpServer := newHTTPServer(addr, srv.Handler(), runtimeCfg)
+	tlsEnabled := cfg.TLSCertFile != "" || cfg.TLSKeyFile != ""
+	if tlsEnabled {
+		certs, tlsErr := newCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile, logger)
+		if tlsErr != nil {
+			return fmt.Errorf("init tls failed: %w", tlsErr)
+		}
+		httpServer.TLSConfig = certs.tlsConfig()
+	}
 
 	errCh := make(chan error, 1)
 	go func() {
-		if listenErr := httpServer.ListenAndServe(); listenErr != nil && !errors.Is(listenErr, http.ErrServerClosed) {
+		serve := httpServer.ListenAndServe
+		if tlsEnabled {
+			serve = func() error { return httpServer.ListenAndServeTLS("", "") }
+		}
+		if listenErr := serve(); listenErr != nil && !errors.Is(listenErr, http.ErrServerClosed) {
 			errCh <- listenErr
 			return
 		}
 		errCh <- nil
 	}()
 
-	log.Printf(
-		"gateway listening on %s (read_header_timeout=%s read_timeout=%s write_timeout=%s idle_timeout=%s shutdown_timeout=%s)",
-		addr,
-		runtimeCfg.readHeaderTimeout,
-		runtimeCfg.readTimeout,
-		runtimeCfg.writeTimeout,
-		runtimeCfg.idleTimeout,
-		runtimeCfg.shutdownTimeout,
+	logger.Info("gateway listening",
+		"addr", addr,
+		"tls", tlsEnabled,
+		"read_header_timeout", runtimeCfg.readHeaderTimeout,
+		"read_timeout", runtimeCfg.readTimeout,
+		"write_timeout", runtimeCfg.writeTimeout,
+		"idle_timeout", runtimeCfg.idleTimeout,
+		"shutdown_timeout", runtimeCfg.shutdownTimeout,
 	)
 
 	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -94,7 +142,7 @@ func run() error {
 		}
 		return nil
 	case <-signalCtx.Done():
-		log.Printf("shutdown signal received, draining in-flight requests (timeout=%s)", runtimeCfg.shutdownTimeout)
+		logger.Info("shutdown signal received, draining in-flight requests", "timeout", runtimeCfg.shutdownTimeout)
 	}
 
 	timedOut, shutdownErr := shutdownHTTPServer(httpServer, runtimeCfg.shutdownTimeout)
@@ -102,9 +150,9 @@ func run() error {
 		return shutdownErr
 	}
 	if timedOut {
-		log.Printf("gateway shutdown degraded: in-flight requests exceeded timeout=%s, forced close", runtimeCfg.shutdownTimeout)
+		logger.Warn("gateway shutdown degraded: in-flight requests exceeded timeout, forced close", "timeout", runtimeCfg.shutdownTimeout)
 	} else {
-		log.Printf("gateway shutdown complete")
+		logger.Info("gateway shutdown complete")
 	}
 
 	if listenErr := <-errCh; listenErr != nil {
@@ -158,15 +206,15 @@ func readDurationSecondsEnv(key string, fallback time.Duration, allowZero bool)
 
 	seconds, err := strconv.Atoi(raw)
 	if err != nil {
-		log.Printf("invalid %s=%q, fallback to %s", key, raw, fallback)
+		slog.Warn("invalid duration env, using fallback", "key", key, "value", raw, "fallback", fallback)
 		return fallback
 	}
 	if seconds < 0 {
-		log.Printf("invalid %s=%q, fallback to %s", key, raw, fallback)
+		slog.Warn("invalid duration env, using fallback", "key", key, "value", raw, "fallback", fallback)
 		return fallback
 	}
 	if seconds == 0 && !allowZero {
-		log.Printf("invalid %s=%q, fallback to %s", key, raw, fallback)
+		slog.Warn("invalid duration env, using fallback", "key", key, "value", raw, "fallback", fallback)
 		return fallback
 	}
 	return time.Duration(seconds) * time.Second
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"nextai/apps/gateway/internal/config"
+	"nextai/apps/gateway/internal/repo"
+	"nextai/apps/gateway/internal/secrets"
+)
+
+const (
+	commandRotateMasterKey = "rotate-master-key"
+
+	envNewMasterKey     = "NEXTAI_NEW_MASTER_KEY"
+	envNewMasterKeyFile = "NEXTAI_NEW_MASTER_KEY_FILE"
+)
+
+// runRotateMasterKey re-encrypts every secret in state.json with a new master key.
+// The gateway must be stopped while it runs, since both processes write state.json.
+func runRotateMasterKey(cfg config.Config, args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet(commandRotateMasterKey, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	newKey := flags.String("new-key", os.Getenv(envNewMasterKey), "new master key (hex or base64, 32 bytes)")
+	newKeyFile := flags.String("new-key-file", os.Getenv(envNewMasterKeyFile), "file containing the new master key")
+	generate := flags.String("generate", "", "generate a new master key and write it to this file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if path := strings.TrimSpace(*generate); path != "" {
+		if strings.TrimSpace(*newKey) != "" || strings.TrimSpace(*newKeyFile) != "" {
+			return errors.New("-generate cannot be combined with -new-key or -new-key-file")
+		}
+		key, err := secrets.GenerateMasterKey()
+		if err != nil {
+			return fmt.Errorf("generate master key: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+			return fmt.Errorf("write master key file: %w", err)
+		}
+		*newKeyFile = path
+	}
+
+	next, err := secrets.LoadCipher(*newKey, *newKeyFile)
+	if err != nil {
+		return fmt.Errorf("load new master key: %w", err)
+	}
+	if next == nil {
+		return fmt.Errorf("new master key is required (-new-key, -new-key-file, -generate or %s)", envNewMasterKey)
+	}
+	current, err := secrets.LoadCipher(cfg.MasterKey, cfg.MasterKeyFile)
+	if err != nil {
+		return fmt.Errorf("load current master key: %w", err)
+	}
+
+	store, err := repo.NewStore(cfg.DataDir, repo.WithCipher(current))
+	if err != nil {
+		return fmt.Errorf("open state with current master key: %w", err)
+	}
+	if err := store.RotateCipher(next); err != nil {
+		return fmt.Errorf("re-encrypt state: %w", err)
+	}
+	slog.Info("master key rotated", "data_dir", cfg.DataDir, "previous_key_id", current.KeyID(), "new_key_id", next.KeyID())
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const tlsReloadCheckInterval = 5 * time.Second
+
+var errTLSConfigIncomplete = errors.New("NEXTAI_TLS_CERT_FILE and NEXTAI_TLS_KEY_FILE must be set together")
+
+// certReloader serves the key pair from disk and reloads it when either file's
+// modification time changes, so renewed certificates apply without a restart.
+// A failed reload keeps serving the previous certificate.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+	now      func() time.Time
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+}
+
+func newCertReloader(certFile, keyFile string, logger *slog.Logger) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errTLSConfigIncomplete
+	}
+	r := &certReloader{certFile: certFile, keyFile: keyFile, logger: logger, now: time.Now}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) load() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("stat tls cert failed: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("stat tls key failed: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls key pair failed: %w", err)
+	}
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if now.Sub(r.lastCheck) < tlsReloadCheckInterval {
+		return r.cert, nil
+	}
+	r.lastCheck = now
+	if !r.changedLocked() {
+		return r.cert, nil
+	}
+	if err := r.load(); err != nil {
+		r.logger.Warn("tls certificate reload failed, keeping previous certificate", "err", err)
+		return r.cert, nil
+	}
+	r.logger.Info("tls certificate reloaded", "cert_file", r.certFile)
+	return r.cert, nil
+}
+
+func (r *certReloader) changedLocked() bool {
+	certInfo, certErr := os.Stat(r.certFile)
+	keyInfo, keyErr := os.Stat(r.keyFile)
+	if certErr != nil || keyErr != nil {
+		return false
+	}
+	return !certInfo.ModTime().Equal(r.certModTime) || !keyInfo.ModTime().Equal(r.keyModTime)
+}
+
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
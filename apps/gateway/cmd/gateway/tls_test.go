@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestKeyPair(t *testing.T, certFile, keyFile string, serial int64, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "gateway.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"gateway.test"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func certSerial(t *testing.T, r *certReloader) int64 {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("get certificate failed: %v", err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.SerialNumber.Int64()
+}
+
+func TestCertReloaderReloadsChangedKeyPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	base := time.Now().Add(-time.Hour)
+	writeTestKeyPair(t, certFile, keyFile, 1, base)
+
+	reloader, err := newCertReloader(certFile, keyFile, slog.Default())
+	if err != nil {
+		t.Fatalf("new reloader failed: %v", err)
+	}
+	now := time.Now()
+	reloader.now = func() time.Time { return now }
+	if got := certSerial(t, reloader); got != 1 {
+		t.Fatalf("serial=%d want=1", got)
+	}
+
+	writeTestKeyPair(t, certFile, keyFile, 2, base.Add(time.Minute))
+	if got := certSerial(t, reloader); got != 1 {
+		t.Fatalf("expected reload to wait for the check interval, serial=%d", got)
+	}
+	now = now.Add(tlsReloadCheckInterval)
+	if got := certSerial(t, reloader); got != 2 {
+		t.Fatalf("serial=%d want=2 after reload", got)
+	}
+
+	if err := os.WriteFile(certFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(tlsReloadCheckInterval)
+	if got := certSerial(t, reloader); got != 2 {
+		t.Fatalf("expected previous certificate after failed reload, serial=%d", got)
+	}
+}
+
+func TestNewCertReloaderRequiresBothFiles(t *testing.T) {
+	if _, err := newCertReloader("cert.pem", "", slog.Default()); !errors.Is(err, errTLSConfigIncomplete) {
+		t.Fatalf("expected incomplete config error, got=%v", err)
+	}
+	if _, err := newCertReloader(filepath.Join(t.TempDir(), "missing.crt"), "missing.key", slog.Default()); err == nil {
+		t.Fatal("expected missing file error")
+	}
+}
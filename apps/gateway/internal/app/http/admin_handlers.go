@@ -4,6 +4,9 @@ import (
 	stdhttp "net/http"
 
 	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 )
 
 type AdminHandlers struct {
@@ -37,9 +40,16 @@ type AdminHandlers struct {
 	PutChannels        stdhttp.HandlerFunc
 	GetChannel         stdhttp.HandlerFunc
 	PutChannel         stdhttp.HandlerFunc
+	GetMetrics         stdhttp.HandlerFunc
+	ListAudit          stdhttp.HandlerFunc
+	ExportAudit        stdhttp.HandlerFunc
+	GetRateLimits      stdhttp.HandlerFunc
+	GetStatus          stdhttp.HandlerFunc
 }
 
 func registerAdminRoutes(api chi.Router, handlers AdminHandlers) {
+	api = api.With(observability.RequireMethodScope(domain.APIKeyScopeAdminRead, domain.APIKeyScopeAdminWrite))
+
 	api.Route("/models", func(r chi.Router) {
 		r.Get("/", mustHandler("list-providers", handlers.ListProviders))
 		r.Get("/catalog", mustHandler("get-model-catalog", handlers.GetModelCatalog))
@@ -84,4 +94,10 @@ func registerAdminRoutes(api chi.Router, handlers AdminHandlers) {
 		r.Get("/channels/{channel_name}", mustHandler("get-channel", handlers.GetChannel))
 		r.Put("/channels/{channel_name}", mustHandler("put-channel", handlers.PutChannel))
 	})
+
+	api.Get("/metrics", mustHandler("get-metrics", handlers.GetMetrics))
+	api.Get("/audit", mustHandler("list-audit", handlers.ListAudit))
+	api.Get("/audit/export", mustHandler("export-audit", handlers.ExportAudit))
+	api.Get("/rate-limits", mustHandler("get-rate-limits", handlers.GetRateLimits))
+	api.Get("/status", mustHandler("get-status", handlers.GetStatus))
 }
@@ -4,15 +4,24 @@ import (
 	stdhttp "net/http"
 
 	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 )
 
 type AgentHandlers struct {
 	ListChats             stdhttp.HandlerFunc
+	SearchChats           stdhttp.HandlerFunc
 	CreateChat            stdhttp.HandlerFunc
 	BatchDeleteChats      stdhttp.HandlerFunc
 	GetChat               stdhttp.HandlerFunc
 	UpdateChat            stdhttp.HandlerFunc
 	DeleteChat            stdhttp.HandlerFunc
+	GetChatMessageTree    stdhttp.HandlerFunc
+	EditChatMessage       stdhttp.HandlerFunc
+	ActivateChatMessage   stdhttp.HandlerFunc
+	RegenerateChatMessage stdhttp.HandlerFunc
+	ForkChatMessage       stdhttp.HandlerFunc
 	ProcessAgent          stdhttp.HandlerFunc
 	GetAgentSystemLayers  stdhttp.HandlerFunc
 	BootstrapSession      stdhttp.HandlerFunc
@@ -22,25 +31,42 @@ type AgentHandlers struct {
 	SubmitToolInputAnswer stdhttp.HandlerFunc
 	ProcessQQInbound      stdhttp.HandlerFunc
 	GetQQInboundState     stdhttp.HandlerFunc
+	GetTurnTrace          stdhttp.HandlerFunc
+	ReplayTurn            stdhttp.HandlerFunc
 }
 
 func registerAgentRoutes(api chi.Router, handlers AgentHandlers) {
-	api.Route("/chats", func(r chi.Router) {
+	chat := api.With(observability.RequireScope(domain.APIKeyScopeChat))
+	chat.Route("/chats", func(r chi.Router) {
 		r.Get("/", mustHandler("list-chats", handlers.ListChats))
 		r.Post("/", mustHandler("create-chat", handlers.CreateChat))
+		r.Get("/search", mustHandler("search-chats", handlers.SearchChats))
 		r.Post("/batch-delete", mustHandler("batch-delete-chats", handlers.BatchDeleteChats))
 		r.Get("/{chat_id}", mustHandler("get-chat", handlers.GetChat))
 		r.Put("/{chat_id}", mustHandler("update-chat", handlers.UpdateChat))
 		r.Delete("/{chat_id}", mustHandler("delete-chat", handlers.DeleteChat))
+		r.Get("/{chat_id}/messages", mustHandler("get-chat-message-tree", handlers.GetChatMessageTree))
+		r.Put("/{chat_id}/messages/{message_id}", mustHandler("edit-chat-message", handlers.EditChatMessage))
+		r.Post("/{chat_id}/messages/{message_id}/activate", mustHandler("activate-chat-message", handlers.ActivateChatMessage))
+		r.Post("/{chat_id}/messages/{message_id}/regenerate", mustHandler("regenerate-chat-message", handlers.RegenerateChatMessage))
+		r.Post("/{chat_id}/messages/{message_id}/fork", mustHandler("fork-chat-message", handlers.ForkChatMessage))
 	})
 
-	api.Post("/agent/process", mustHandler("process-agent", handlers.ProcessAgent))
-	api.Get("/agent/system-layers", mustHandler("get-agent-system-layers", handlers.GetAgentSystemLayers))
-	api.Post("/agent/self/sessions/bootstrap", mustHandler("selfops-bootstrap-session", handlers.BootstrapSession))
-	api.Put("/agent/self/sessions/{session_id}/model", mustHandler("selfops-set-session-model", handlers.SetSessionModel))
-	api.Post("/agent/self/config-mutations/preview", mustHandler("selfops-preview-mutation", handlers.PreviewMutation))
-	api.Post("/agent/self/config-mutations/apply", mustHandler("selfops-apply-mutation", handlers.ApplyMutation))
-	api.Post("/agent/tool-input-answer", mustHandler("agent-tool-input-answer", handlers.SubmitToolInputAnswer))
-	api.Post("/channels/qq/inbound", mustHandler("process-qq-inbound", handlers.ProcessQQInbound))
-	api.Get("/channels/qq/state", mustHandler("get-qq-inbound-state", handlers.GetQQInboundState))
+	chat.Post("/agent/process", mustHandler("process-agent", handlers.ProcessAgent))
+	chat.Get("/agent/system-layers", mustHandler("get-agent-system-layers", handlers.GetAgentSystemLayers))
+	chat.Post("/agent/tool-input-answer", mustHandler("agent-tool-input-answer", handlers.SubmitToolInputAnswer))
+	chat.Post("/channels/qq/inbound", mustHandler("process-qq-inbound", handlers.ProcessQQInbound))
+
+	selfops := api.With(observability.RequireScope(domain.APIKeyScopeSelfOps))
+	selfops.Post("/agent/self/sessions/bootstrap", mustHandler("selfops-bootstrap-session", handlers.BootstrapSession))
+	selfops.Put("/agent/self/sessions/{session_id}/model", mustHandler("selfops-set-session-model", handlers.SetSessionModel))
+	selfops.Post("/agent/self/config-mutations/preview", mustHandler("selfops-preview-mutation", handlers.PreviewMutation))
+	selfops.Post("/agent/self/config-mutations/apply", mustHandler("selfops-apply-mutation", handlers.ApplyMutation))
+
+	api.With(observability.RequireScope(domain.APIKeyScopeAdminRead)).
+		Get("/channels/qq/state", mustHandler("get-qq-inbound-state", handlers.GetQQInboundState))
+	api.With(observability.RequireScope(domain.APIKeyScopeAdminRead)).
+		Get("/agent/turns/{turn_id}/trace", mustHandler("get-turn-trace", handlers.GetTurnTrace))
+	api.With(observability.RequireScope(domain.APIKeyScopeAdminWrite)).
+		Post("/agent/turns/{turn_id}/replay", mustHandler("replay-turn", handlers.ReplayTurn))
 }
@@ -0,0 +1,29 @@
+package transport
+
+import (
+	stdhttp "net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+)
+
+type AuthHandlers struct {
+	ListAPIKeys  stdhttp.HandlerFunc
+	CreateAPIKey stdhttp.HandlerFunc
+	GetAPIKey    stdhttp.HandlerFunc
+	UpdateAPIKey stdhttp.HandlerFunc
+	DeleteAPIKey stdhttp.HandlerFunc
+}
+
+func registerAuthRoutes(api chi.Router, handlers AuthHandlers) {
+	api.Route("/auth/keys", func(r chi.Router) {
+		r.Use(observability.RequireMethodScope(domain.APIKeyScopeAdminRead, domain.APIKeyScopeAdminWrite))
+		r.Get("/", mustHandler("list-api-keys", handlers.ListAPIKeys))
+		r.Post("/", mustHandler("create-api-key", handlers.CreateAPIKey))
+		r.Get("/{key_id}", mustHandler("get-api-key", handlers.GetAPIKey))
+		r.Put("/{key_id}", mustHandler("update-api-key", handlers.UpdateAPIKey))
+		r.Delete("/{key_id}", mustHandler("delete-api-key", handlers.DeleteAPIKey))
+	})
+}
@@ -4,22 +4,32 @@ import (
 	stdhttp "net/http"
 
 	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 )
 
 type CronHandlers struct {
-	ListCronJobs  stdhttp.HandlerFunc
-	CreateCronJob stdhttp.HandlerFunc
-	GetCronJob    stdhttp.HandlerFunc
-	UpdateCronJob stdhttp.HandlerFunc
-	DeleteCronJob stdhttp.HandlerFunc
-	PauseCronJob  stdhttp.HandlerFunc
-	ResumeCronJob stdhttp.HandlerFunc
-	RunCronJob    stdhttp.HandlerFunc
-	GetCronState  stdhttp.HandlerFunc
+	ListCronJobs   stdhttp.HandlerFunc
+	CreateCronJob  stdhttp.HandlerFunc
+	GetCronJob     stdhttp.HandlerFunc
+	UpdateCronJob  stdhttp.HandlerFunc
+	DeleteCronJob  stdhttp.HandlerFunc
+	PauseCronJob   stdhttp.HandlerFunc
+	ResumeCronJob  stdhttp.HandlerFunc
+	RunCronJob     stdhttp.HandlerFunc
+	GetCronState   stdhttp.HandlerFunc
+	ListCronRuns   stdhttp.HandlerFunc
+	GetCronRun     stdhttp.HandlerFunc
+	PreviewCronJob stdhttp.HandlerFunc
+	DryRunCronJob  stdhttp.HandlerFunc
+
+	TriggerCronWebhook stdhttp.HandlerFunc
 }
 
 func registerCronRoutes(api chi.Router, handlers CronHandlers) {
 	api.Route("/cron", func(r chi.Router) {
+		r.Use(observability.RequireScope(domain.APIKeyScopeCron))
 		r.Get("/jobs", mustHandler("list-cron-jobs", handlers.ListCronJobs))
 		r.Post("/jobs", mustHandler("create-cron-job", handlers.CreateCronJob))
 		r.Get("/jobs/{job_id}", mustHandler("get-cron-job", handlers.GetCronJob))
@@ -29,5 +39,13 @@ func registerCronRoutes(api chi.Router, handlers CronHandlers) {
 		r.Post("/jobs/{job_id}/resume", mustHandler("resume-cron-job", handlers.ResumeCronJob))
 		r.Post("/jobs/{job_id}/run", mustHandler("run-cron-job", handlers.RunCronJob))
 		r.Get("/jobs/{job_id}/state", mustHandler("get-cron-job-state", handlers.GetCronState))
+		r.Get("/jobs/{job_id}/runs", mustHandler("list-cron-job-runs", handlers.ListCronRuns))
+		r.Get("/jobs/{job_id}/runs/{run_id}", mustHandler("get-cron-job-run", handlers.GetCronRun))
+		r.Get("/jobs/{job_id}/preview", mustHandler("preview-cron-job", handlers.PreviewCronJob))
+		r.Post("/jobs/{job_id}/dry-run", mustHandler("dry-run-cron-job", handlers.DryRunCronJob))
 	})
 }
+
+func registerCronWebhookRoutes(r chi.Router, handlers CronHandlers) {
+	r.Post("/hooks/cron/{job_id}", mustHandler("trigger-cron-webhook", handlers.TriggerCronWebhook))
+}
@@ -0,0 +1,23 @@
+package transport
+
+import (
+	stdhttp "net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+)
+
+type OpenAIHandlers struct {
+	ChatCompletions stdhttp.HandlerFunc
+	ListModels      stdhttp.HandlerFunc
+}
+
+func registerOpenAIRoutes(api chi.Router, handlers OpenAIHandlers) {
+	api.Route("/v1", func(r chi.Router) {
+		r.Use(observability.RequireScope(domain.APIKeyScopeChat))
+		r.Post("/chat/completions", mustHandler("openai-chat-completions", handlers.ChatCompletions))
+		r.Get("/models", mustHandler("openai-list-models", handlers.ListModels))
+	})
+}
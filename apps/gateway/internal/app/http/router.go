@@ -2,10 +2,12 @@ package transport
 
 import (
 	"fmt"
+	"log/slog"
 	stdhttp "net/http"
+	"net/netip"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 
 	"nextai/apps/gateway/internal/observability"
 )
@@ -13,6 +15,7 @@ import (
 type PublicHandlers struct {
 	Version       stdhttp.HandlerFunc
 	Healthz       stdhttp.HandlerFunc
+	Readyz        stdhttp.HandlerFunc
 	RuntimeConfig stdhttp.HandlerFunc
 }
 
@@ -21,23 +24,49 @@ type Handlers struct {
 	Agent  AgentHandlers
 	Cron   CronHandlers
 	Admin  AdminHandlers
+	OpenAI OpenAIHandlers
+	Auth   AuthHandlers
+
+	KeyResolver    observability.KeyResolver
+	Logger         *slog.Logger
+	CORS           CORSConfig
+	TrustedProxies []netip.Prefix
+}
+
+// CORSConfig lists what cross-origin browsers may use. Empty fields fall back
+// to the defaults below; an origin of "*" allows any origin.
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
 }
 
+var (
+	DefaultCORSAllowedOrigins = []string{"*"}
+	DefaultCORSAllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	DefaultCORSAllowedHeaders = []string{"Content-Type", "Authorization", "X-Request-Id", "traceparent", "X-NextAI-Source", "X-NextAI-Session-Id"}
+)
+
 func NewRouter(apiKey string, handlers Handlers, webHandler stdhttp.HandlerFunc) stdhttp.Handler {
 	r := chi.NewRouter()
-	r.Use(middleware.RealIP)
+	r.Use(observability.ClientIP(handlers.TrustedProxies))
 	r.Use(observability.RequestID)
-	r.Use(observability.Logging)
-	r.Use(cors)
+	r.Use(observability.Tracing)
+	r.Use(observability.Logging(handlers.Logger))
+	r.Use(observability.HTTPMetrics)
+	r.Use(cors(handlers.CORS))
 
 	registerPublicRoutes(r, handlers.Public)
+	registerCronWebhookRoutes(r, handlers.Cron)
 
 	r.Group(func(api chi.Router) {
-		api.Use(observability.APIKey(apiKey))
+		api.Use(observability.Authenticate(apiKey, handlers.KeyResolver))
 
 		registerAgentRoutes(api, handlers.Agent)
 		registerCronRoutes(api, handlers.Cron)
 		registerAdminRoutes(api, handlers.Admin)
+		registerOpenAIRoutes(api, handlers.OpenAI)
+		registerAuthRoutes(api, handlers.Auth)
 	})
 
 	if webHandler != nil {
@@ -50,20 +79,58 @@ func NewRouter(apiKey string, handlers Handlers, webHandler stdhttp.HandlerFunc)
 func registerPublicRoutes(r chi.Router, handlers PublicHandlers) {
 	r.Get("/version", mustHandler("version", handlers.Version))
 	r.Get("/healthz", mustHandler("healthz", handlers.Healthz))
+	r.Get("/readyz", mustHandler("readyz", handlers.Readyz))
 	r.Get("/runtime-config", mustHandler("runtime-config", handlers.RuntimeConfig))
 }
 
-func cors(next stdhttp.Handler) stdhttp.Handler {
-	return stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization,X-Request-Id,X-NextAI-Source")
-		if r.Method == stdhttp.MethodOptions {
-			w.WriteHeader(stdhttp.StatusNoContent)
-			return
+func cors(cfg CORSConfig) func(stdhttp.Handler) stdhttp.Handler {
+	origins := cfg.AllowedOrigins
+	if len(origins) == 0 {
+		origins = DefaultCORSAllowedOrigins
+	}
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = DefaultCORSAllowedMethods
+	}
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = DefaultCORSAllowedHeaders
+	}
+	anyOrigin := false
+	allowed := map[string]bool{}
+	for _, origin := range origins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "*" {
+			anyOrigin = true
 		}
-		next.ServeHTTP(w, r)
-	})
+		allowed[strings.ToLower(origin)] = true
+	}
+	allowMethods := strings.Join(methods, ",")
+	allowHeaders := strings.Join(headers, ",")
+
+	return func(next stdhttp.Handler) stdhttp.Handler {
+		return stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			origin := r.Header.Get("Origin")
+			switch {
+			case anyOrigin:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && allowed[strings.ToLower(origin)]:
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if !anyOrigin {
+				w.Header().Add("Vary", "Origin")
+			}
+			if w.Header().Get("Access-Control-Allow-Origin") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+				w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			if r.Method == stdhttp.MethodOptions {
+				w.WriteHeader(stdhttp.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func mustHandler(name string, handler stdhttp.HandlerFunc) stdhttp.HandlerFunc {
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSDefaultsAllowAnyOrigin(t *testing.T) {
+	t.Parallel()
+
+	handler := cors(CORSConfig{})(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, _ *stdhttp.Request) {
+		w.WriteHeader(stdhttp.StatusOK)
+	}))
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(stdhttp.MethodGet, "/version", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("allow-origin=%q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET,POST,PUT,DELETE,OPTIONS" {
+		t.Fatalf("allow-methods=%q", got)
+	}
+}
+
+func TestCORSAllowListEchoesMatchingOrigin(t *testing.T) {
+	t.Parallel()
+
+	handler := cors(CORSConfig{
+		AllowedOrigins: []string{"https://console.example.com/"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-API-Key"},
+	})(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, _ *stdhttp.Request) {
+		w.WriteHeader(stdhttp.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(stdhttp.MethodOptions, "/chats", nil)
+	req.Header.Set("Origin", "https://Console.example.com")
+	handler.ServeHTTP(w, req)
+	if w.Code != stdhttp.StatusNoContent {
+		t.Fatalf("preflight status=%d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://Console.example.com" {
+		t.Fatalf("allow-origin=%q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type,X-API-Key" {
+		t.Fatalf("allow-headers=%q", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Fatalf("vary=%q", got)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(stdhttp.MethodGet, "/chats", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	handler.ServeHTTP(w, req)
+	if w.Code != stdhttp.StatusOK {
+		t.Fatalf("status=%d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no allow-origin for unknown origin, got=%q", got)
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -17,6 +17,8 @@ import (
 
 	"github.com/gorilla/websocket"
 
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/repo"
 )
 
@@ -268,6 +270,7 @@ func (s *Server) loadQQInboundConfig() (qqInboundConfig, bool) {
 }
 
 func (s *Server) runQQInboundLoop(ctx context.Context, cfg qqInboundConfig) {
+	ctx = observability.WithLogFields(ctx, observability.LogKeyChannel, "qq")
 	backoff := qqInboundReconnectMinDelay
 	for {
 		if ctx.Err() != nil {
@@ -275,7 +278,7 @@ func (s *Server) runQQInboundLoop(ctx context.Context, cfg qqInboundConfig) {
 		}
 		err := s.runQQInboundSession(ctx, cfg)
 		if err != nil && ctx.Err() == nil {
-			log.Printf("qq inbound session ended: %v", err)
+			s.log().WarnContext(ctx, "qq inbound session ended", "err", err)
 			s.mutateQQInboundState(func(st *qqInboundRuntimeState) {
 				st.Connected = false
 				st.GatewayURL = ""
@@ -285,7 +288,7 @@ func (s *Server) runQQInboundLoop(ctx context.Context, cfg qqInboundConfig) {
 			if errors.Is(err, errQQInboundInvalidSession) && !cfg.IntentsSet && cfg.Intents != qqFallbackIntents {
 				cfg.Intents = qqFallbackIntents
 				backoff = qqInboundReconnectMinDelay
-				log.Printf("qq inbound fallback intents applied: %d", cfg.Intents)
+				s.log().InfoContext(ctx, "qq inbound fallback intents applied", "intents", cfg.Intents)
 				s.mutateQQInboundState(func(st *qqInboundRuntimeState) {
 					st.Intents = cfg.Intents
 					st.IntentsSource = "fallback"
@@ -333,7 +336,8 @@ func (s *Server) runQQInboundSession(ctx context.Context, cfg qqInboundConfig) e
 	}
 	defer conn.Close()
 
-	log.Printf("qq inbound connected: %s", gatewayURL)
+	s.log().InfoContext(ctx, "qq inbound connected", "gateway_url", gatewayURL)
+	observability.QQGatewayConnectsTotal.Inc()
 	s.mutateQQInboundState(func(st *qqInboundRuntimeState) {
 		st.Connected = true
 		st.GatewayURL = gatewayURL
@@ -417,7 +421,7 @@ func (s *Server) runQQInboundSession(ctx context.Context, cfg qqInboundConfig) e
 			stopHeartbeat()
 			heartbeatCtx, cancel := context.WithCancel(ctx)
 			heartbeatCancel = cancel
-			go runQQHeartbeatLoop(heartbeatCtx, interval, getSeq, writeJSON)
+			go runQQHeartbeatLoop(heartbeatCtx, s.log(), interval, getSeq, writeJSON)
 		case qqGatewayOpDispatch:
 			if !isQQInboundDispatchEvent(frame.T) {
 				continue
@@ -434,7 +438,7 @@ func (s *Server) runQQInboundSession(ctx context.Context, cfg qqInboundConfig) e
 			}
 			accepted, reason, err := s.dispatchQQInboundPayload(ctx, raw)
 			if err != nil {
-				log.Printf("qq inbound dispatch failed: event=%s err=%v", frame.T, err)
+				s.log().ErrorContext(ctx, "qq inbound dispatch failed", "event", frame.T, "err", err)
 				s.mutateQQInboundState(func(st *qqInboundRuntimeState) {
 					st.LastError = fmt.Sprintf("dispatch %s failed: %v", frame.T, err)
 					st.LastErrorAt = nowISO()
@@ -442,7 +446,7 @@ func (s *Server) runQQInboundSession(ctx context.Context, cfg qqInboundConfig) e
 				continue
 			}
 			if !accepted && reason != "" {
-				log.Printf("qq inbound ignored: event=%s reason=%s", frame.T, reason)
+				s.log().DebugContext(ctx, "qq inbound ignored", "event", frame.T, "reason", reason)
 				s.mutateQQInboundState(func(st *qqInboundRuntimeState) {
 					st.LastEventType = frame.T
 					st.LastEventAt = nowISO()
@@ -465,6 +469,7 @@ var errQQInboundInvalidSession = errors.New("qq gateway invalid session")
 
 func runQQHeartbeatLoop(
 	ctx context.Context,
+	logger *slog.Logger,
 	interval time.Duration,
 	getSeq func() interface{},
 	writeJSON func(interface{}) error,
@@ -484,7 +489,7 @@ func runQQHeartbeatLoop(
 				"op": qqGatewayOpHeartbeat,
 				"d":  getSeq(),
 			}); err != nil {
-				log.Printf("qq heartbeat failed: %v", err)
+				logger.WarnContext(ctx, "qq heartbeat failed", "err", err)
 				return
 			}
 		}
@@ -642,9 +647,20 @@ func qqInboundMap(raw interface{}) (map[string]interface{}, bool) {
 }
 
 func (s *Server) dispatchQQInboundPayload(ctx context.Context, payload []byte) (accepted bool, reason string, err error) {
+	ctx, span := observability.StartSpan(ctx, "qq.inbound.dispatch", observability.WithSpanKind(observability.SpanKindServer))
+	defer func() {
+		span.SetAttributes("qq.accepted", accepted)
+		span.SetError(err)
+		span.End()
+	}()
 	req := httptest.NewRequest(http.MethodPost, "/channels/qq/inbound", bytes.NewReader(payload)).WithContext(ctx)
 	rec := httptest.NewRecorder()
 	s.processQQInbound(rec, req)
+	if rec.Code == http.StatusTooManyRequests {
+		var body domain.APIErrorBody
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		return false, body.Error.Code, nil
+	}
 	if rec.Code < http.StatusOK || rec.Code >= http.StatusMultipleChoices {
 		return false, "", fmt.Errorf("qq inbound handler status=%d body=%s", rec.Code, strings.TrimSpace(rec.Body.String()))
 	}
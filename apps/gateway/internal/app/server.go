@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
 	"path"
 	"path/filepath"
@@ -22,19 +23,27 @@ import (
 	"nextai/apps/gateway/internal/channel"
 	"nextai/apps/gateway/internal/config"
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/plugin"
 	"nextai/apps/gateway/internal/repo"
 	"nextai/apps/gateway/internal/runner"
+	"nextai/apps/gateway/internal/search"
+	"nextai/apps/gateway/internal/secrets"
 	"nextai/apps/gateway/internal/service/adapters"
 	adminservice "nextai/apps/gateway/internal/service/admin"
 	agentservice "nextai/apps/gateway/internal/service/agent"
 	agentprotocolservice "nextai/apps/gateway/internal/service/agentprotocol"
+	apikeyservice "nextai/apps/gateway/internal/service/apikeys"
+	auditservice "nextai/apps/gateway/internal/service/audit"
 	codexpromptservice "nextai/apps/gateway/internal/service/codexprompt"
 	cronservice "nextai/apps/gateway/internal/service/cron"
+	healthservice "nextai/apps/gateway/internal/service/health"
 	modelservice "nextai/apps/gateway/internal/service/model"
 	"nextai/apps/gateway/internal/service/ports"
+	ratelimitservice "nextai/apps/gateway/internal/service/ratelimit"
 	selfopsservice "nextai/apps/gateway/internal/service/selfops"
 	systempromptservice "nextai/apps/gateway/internal/service/systemprompt"
+	turntraceservice "nextai/apps/gateway/internal/service/turntrace"
 	workspaceservice "nextai/apps/gateway/internal/service/workspace"
 )
 
@@ -62,6 +71,8 @@ const (
 
 	cronLeaseDirName = "cron-leases"
 
+	qqProactiveStateFileName = "qq-proactive.json"
+
 	aiToolsGuideRelativePath             = "prompts/AGENTS.md"
 	aiToolsGuideLegacyRelativePath       = "prompts/ai-tools.md"
 	aiToolsGuideLegacyV0RelativePath     = "docs/AI/AGENTS.md"
@@ -103,6 +114,7 @@ const (
 	collaborationModeExecuteName         = "Execute"
 	collaborationModePairProgrammingName = "PairProgramming"
 	chatMetaPromptModeKey                = "prompt_mode"
+	bizParamsActiveLLMKey                = "active_llm"
 	aiToolsGuidePathEnv                  = "NEXTAI_AI_TOOLS_GUIDE_PATH"
 	disabledToolsEnv                     = "NEXTAI_DISABLED_TOOLS"
 	enableBrowserToolEnv                 = "NEXTAI_ENABLE_BROWSER_TOOL"
@@ -128,6 +140,7 @@ const (
 var errCronJobNotFound = cronservice.ErrJobNotFound
 var errCronMaxConcurrencyReached = cronservice.ErrMaxConcurrencyReached
 var errCronDefaultProtected = cronservice.ErrDefaultProtected
+var errCronRunNotFound = cronservice.ErrRunNotFound
 
 var cronWorkflowIfConditionPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*(==|!=)\s*(?:"([^"]*)"|'([^']*)'|(\S+))\s*$`)
 
@@ -152,17 +165,27 @@ type Server struct {
 	cfg                 config.Config
 	store               *repo.Store
 	stateStore          ports.StateStore
+	secretCipher        *secrets.Cipher
 	runner              *runner.Runner
 	channels            map[string]plugin.ChannelPlugin
 	tools               map[string]plugin.ToolPlugin
 	toolCapabilities    map[string]toolCapabilitySet
 	adminService        *adminservice.Service
+	apiKeyService       *apikeyservice.Service
 	agentService        *agentservice.Service
 	cronService         *cronservice.Service
 	modelService        *modelservice.Service
 	selfOpsService      *selfopsservice.Service
 	systemPromptService *systempromptservice.Service
 	workspaceService    *workspaceservice.Service
+	turnTraceService    *turntraceservice.Service
+	auditService        *auditservice.Service
+	rateLimitService    *ratelimitservice.Service
+	healthService       *healthservice.Service
+	searchIndex         *search.Index
+	runtimeMetrics      *observability.Registry
+	logger              *slog.Logger
+	trustedProxies      []netip.Prefix
 	codexPromptResolver codexpromptservice.CodexInstructionResolver
 
 	disabledTools    map[string]struct{}
@@ -170,9 +193,11 @@ type Server struct {
 	memoryMu         sync.Mutex
 	userInputMu      sync.Mutex
 	subAgentMu       sync.Mutex
+	skillsMu         sync.RWMutex
 	qqInbound        qqInboundRuntimeState
 	pendingUserInput map[string]*pendingUserInputRequest
 	subAgents        map[string]*managedSubAgent
+	enabledSkills    []domain.SkillSpec
 
 	cronStop chan struct{}
 	cronDone chan struct{}
@@ -186,16 +211,40 @@ func codexPromptModeEnabled() bool {
 	return false
 }
 
-func NewServer(cfg config.Config) (*Server, error) {
-	store, err := repo.NewStore(cfg.DataDir)
+type ServerOption func(*Server)
+
+// WithLogger sets the structured logger used by the server and its services.
+func WithLogger(logger *slog.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+func (s *Server) log() *slog.Logger {
+	return observability.LoggerOrDefault(s.logger)
+}
+
+func NewServer(cfg config.Config, opts ...ServerOption) (*Server, error) {
+	cipher, err := secrets.LoadCipher(cfg.MasterKey, cfg.MasterKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load master key failed: %w", err)
+	}
+	trustedProxies, err := observability.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("load NEXTAI_TRUSTED_PROXIES failed: %w", err)
+	}
+	store, err := repo.NewStore(cfg.DataDir, repo.WithCipher(cipher))
 	if err != nil {
 		return nil, err
 	}
 	srv := &Server{
 		cfg:              cfg,
+		trustedProxies:   trustedProxies,
 		store:            store,
+		secretCipher:     cipher,
 		stateStore:       adapters.NewRepoStateStore(store),
 		runner:           runner.New(),
+		searchIndex:      search.NewIndex(),
 		channels:         map[string]plugin.ChannelPlugin{},
 		tools:            map[string]plugin.ToolPlugin{},
 		toolCapabilities: map[string]toolCapabilitySet{},
@@ -207,6 +256,18 @@ func NewServer(cfg config.Config) (*Server, error) {
 		cronStop:         make(chan struct{}),
 		cronDone:         make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	if cipher == nil {
+		srv.log().Warn("NEXTAI_MASTER_KEY is not set, provider keys and channel secrets are stored in plaintext")
+	}
+	srv.runtimeMetrics = srv.newRuntimeMetrics()
+	srv.healthService = srv.newHealthService()
+	store.Observe(func(state *repo.State) {
+		srv.searchIndex.Update(state.Chats, state.Histories, state.ChangedHistories())
+		srv.syncEnabledSkills(state.Skills)
+	})
 	srv.cfg.CodexPromptSource = normalizeCodexPromptSource(srv.cfg.CodexPromptSource)
 	if codexPromptModeEnabled() && (srv.cfg.CodexPromptSource == codexPromptSourceCatalog || srv.cfg.EnableCodexPromptShadowCompare) {
 		resolver, resolverErr := codexpromptservice.NewResolver(codexRuntimeCatalogRelativePath)
@@ -214,7 +275,7 @@ func NewServer(cfg config.Config) (*Server, error) {
 			if srv.cfg.CodexPromptSource == codexPromptSourceCatalog {
 				return nil, fmt.Errorf("init codex prompt resolver failed: %w", resolverErr)
 			}
-			log.Printf("warning: disable codex prompt shadow compare due to catalog load failure: %v", resolverErr)
+			srv.log().Warn("disable codex prompt shadow compare due to catalog load failure", "err", resolverErr)
 			srv.cfg.EnableCodexPromptShadowCompare = false
 		} else {
 			srv.codexPromptResolver = resolver
@@ -222,7 +283,11 @@ func NewServer(cfg config.Config) (*Server, error) {
 	}
 	srv.registerChannelPlugin(channel.NewConsoleChannel())
 	srv.registerChannelPlugin(channel.NewWebhookChannel())
-	srv.registerChannelPlugin(channel.NewQQChannel())
+	qqProactiveStateFile := ""
+	if srv.cfg.DataDir != "" {
+		qqProactiveStateFile = filepath.Join(srv.cfg.DataDir, qqProactiveStateFileName)
+	}
+	srv.registerChannelPlugin(channel.NewQQChannelWithStateFile(qqProactiveStateFile))
 	srv.registerToolPlugin(plugin.NewShellTool(), agentprotocolservice.ToolCapabilityExecute)
 	srv.registerToolPlugin(
 		plugin.NewViewFileLinesTool(""),
@@ -261,6 +326,10 @@ func NewServer(cfg config.Config) (*Server, error) {
 		)
 	}
 	srv.adminService = srv.newAdminService()
+	srv.apiKeyService = srv.newAPIKeyService()
+	srv.turnTraceService = srv.newTurnTraceService()
+	srv.auditService = srv.newAuditService()
+	srv.rateLimitService = srv.newRateLimitService()
 	srv.agentService = srv.newAgentService()
 	srv.cronService = srv.newCronService()
 	srv.modelService = srv.newModelService()
@@ -268,6 +337,7 @@ func NewServer(cfg config.Config) (*Server, error) {
 	srv.systemPromptService = srv.newSystemPromptService()
 	srv.workspaceService = srv.newWorkspaceService()
 	srv.startCronScheduler()
+	srv.startProviderProbes()
 	if !parseBool(os.Getenv(disableQQInboundSupervisorEnv)) {
 		srv.startQQInboundSupervisor()
 	}
@@ -279,6 +349,9 @@ func (s *Server) Close() {
 		close(s.cronStop)
 		<-s.cronDone
 		s.cronWG.Wait()
+		if s.rateLimitService != nil {
+			s.rateLimitService.Flush()
+		}
 	})
 }
 
@@ -290,7 +363,7 @@ func (s *Server) registerChannelPlugin(ch plugin.ChannelPlugin) {
 	if name == "" {
 		return
 	}
-	s.channels[name] = ch
+	s.channels[name] = meteredChannel{ChannelPlugin: ch, name: name, health: s.getHealthService()}
 }
 
 func (s *Server) registerToolPlugin(tp plugin.ToolPlugin, capabilities ...string) {
@@ -335,68 +408,107 @@ func (s *Server) Handler() http.Handler {
 			Public: apphttp.PublicHandlers{
 				Version:       s.handleVersion,
 				Healthz:       s.handleHealthz,
+				Readyz:        s.handleReadyz,
 				RuntimeConfig: s.handleRuntimeConfig,
 			},
 			Agent: apphttp.AgentHandlers{
 				ListChats:             s.listChats,
+				SearchChats:           s.searchChats,
 				CreateChat:            s.createChat,
 				BatchDeleteChats:      s.batchDeleteChats,
-				GetChat:               s.getChat,
-				UpdateChat:            s.updateChat,
-				DeleteChat:            s.deleteChat,
+				GetChat:               s.chatOwnerOnly(s.getChat),
+				UpdateChat:            s.chatOwnerOnly(s.updateChat),
+				DeleteChat:            s.chatOwnerOnly(s.deleteChat),
+				GetChatMessageTree:    s.chatOwnerOnly(s.getChatMessageTree),
+				EditChatMessage:       s.chatOwnerOnly(s.editChatMessage),
+				ActivateChatMessage:   s.chatOwnerOnly(s.activateChatMessage),
+				RegenerateChatMessage: s.chatOwnerOnly(s.regenerateChatMessage),
+				ForkChatMessage:       s.chatOwnerOnly(s.forkChatMessage),
 				ProcessAgent:          s.processAgent,
 				GetAgentSystemLayers:  s.getAgentSystemLayers,
-				BootstrapSession:      s.bootstrapSession,
-				SetSessionModel:       s.setSessionModel,
+				BootstrapSession:      s.audited("selfops-bootstrap-session", s.bootstrapSession),
+				SetSessionModel:       s.audited("selfops-set-session-model", s.setSessionModel),
 				PreviewMutation:       s.previewMutation,
-				ApplyMutation:         s.applyMutation,
+				ApplyMutation:         s.audited("selfops-apply-mutation", s.applyMutation),
 				SubmitToolInputAnswer: s.submitToolInputAnswer,
 				ProcessQQInbound:      s.processQQInbound,
 				GetQQInboundState:     s.getQQInboundState,
+				GetTurnTrace:          s.getTurnTrace,
+				ReplayTurn:            s.replayTurn,
 			},
 			Cron: apphttp.CronHandlers{
-				ListCronJobs:  s.listCronJobs,
-				CreateCronJob: s.createCronJob,
-				GetCronJob:    s.getCronJob,
-				UpdateCronJob: s.updateCronJob,
-				DeleteCronJob: s.deleteCronJob,
-				PauseCronJob:  s.pauseCronJob,
-				ResumeCronJob: s.resumeCronJob,
-				RunCronJob:    s.runCronJob,
-				GetCronState:  s.getCronJobState,
+				ListCronJobs:   s.listCronJobs,
+				CreateCronJob:  s.audited("create-cron-job", s.createCronJob),
+				GetCronJob:     s.getCronJob,
+				UpdateCronJob:  s.audited("update-cron-job", s.updateCronJob),
+				DeleteCronJob:  s.audited("delete-cron-job", s.deleteCronJob),
+				PauseCronJob:   s.audited("pause-cron-job", s.pauseCronJob),
+				ResumeCronJob:  s.audited("resume-cron-job", s.resumeCronJob),
+				RunCronJob:     s.audited("run-cron-job", s.runCronJob),
+				GetCronState:   s.getCronJobState,
+				ListCronRuns:   s.listCronRuns,
+				GetCronRun:     s.getCronRun,
+				PreviewCronJob: s.previewCronJob,
+				DryRunCronJob:  s.dryRunCronJob,
+
+				TriggerCronWebhook: s.audited("trigger-cron-webhook", s.triggerCronWebhook),
 			},
 			Admin: apphttp.AdminHandlers{
 				ListProviders:      s.listProviders,
 				GetModelCatalog:    s.getModelCatalog,
-				ConfigureProvider:  s.configureProvider,
-				DeleteProvider:     s.deleteProvider,
+				ConfigureProvider:  s.audited("configure-provider", s.configureProvider),
+				DeleteProvider:     s.audited("delete-provider", s.deleteProvider),
 				GetActiveModels:    s.getActiveModels,
-				SetActiveModels:    s.setActiveModels,
+				SetActiveModels:    s.audited("set-active-models", s.setActiveModels),
 				ListEnvs:           s.listEnvs,
-				PutEnvs:            s.putEnvs,
-				DeleteEnv:          s.deleteEnv,
+				PutEnvs:            s.audited("put-envs", s.putEnvs),
+				DeleteEnv:          s.audited("delete-env", s.deleteEnv),
 				ListSkills:         s.listSkills,
 				ListAvailableSkill: s.listAvailableSkills,
-				BatchDisableSkills: s.batchDisableSkills,
-				BatchEnableSkills:  s.batchEnableSkills,
-				CreateSkill:        s.createSkill,
-				DisableSkill:       s.disableSkill,
-				EnableSkill:        s.enableSkill,
-				DeleteSkill:        s.deleteSkill,
+				BatchDisableSkills: s.audited("batch-disable-skills", s.batchDisableSkills),
+				BatchEnableSkills:  s.audited("batch-enable-skills", s.batchEnableSkills),
+				CreateSkill:        s.audited("create-skill", s.createSkill),
+				DisableSkill:       s.audited("disable-skill", s.disableSkill),
+				EnableSkill:        s.audited("enable-skill", s.enableSkill),
+				DeleteSkill:        s.audited("delete-skill", s.deleteSkill),
 				LoadSkillFile:      s.loadSkillFile,
 				ListWorkspaceFiles: s.listWorkspaceFiles,
 				GetWorkspaceFile:   s.getWorkspaceFile,
-				PutWorkspaceFile:   s.putWorkspaceFile,
-				UploadWorkspace:    s.uploadWorkspaceFile,
-				DeleteWorkspace:    s.deleteWorkspaceFile,
+				PutWorkspaceFile:   s.audited("put-workspace-file", s.putWorkspaceFile),
+				UploadWorkspace:    s.audited("upload-workspace-file", s.uploadWorkspaceFile),
+				DeleteWorkspace:    s.audited("delete-workspace-file", s.deleteWorkspaceFile),
 				ExportWorkspace:    s.exportWorkspace,
-				ImportWorkspace:    s.importWorkspace,
+				ImportWorkspace:    s.audited("import-workspace", s.importWorkspace),
 				ListChannels:       s.listChannels,
 				ListChannelTypes:   s.listChannelTypes,
-				PutChannels:        s.putChannels,
+				PutChannels:        s.audited("put-channels", s.putChannels),
 				GetChannel:         s.getChannel,
-				PutChannel:         s.putChannel,
+				PutChannel:         s.audited("put-channel", s.putChannel),
+				GetMetrics:         s.getMetrics,
+				ListAudit:          s.listAudit,
+				ExportAudit:        s.exportAudit,
+				GetRateLimits:      s.getRateLimits,
+				GetStatus:          s.getStatus,
+			},
+			OpenAI: apphttp.OpenAIHandlers{
+				ChatCompletions: s.openAIChatCompletions,
+				ListModels:      s.openAIListModels,
+			},
+			Auth: apphttp.AuthHandlers{
+				ListAPIKeys:  s.listAPIKeys,
+				CreateAPIKey: s.audited("create-api-key", s.createAPIKey),
+				GetAPIKey:    s.getAPIKey,
+				UpdateAPIKey: s.audited("update-api-key", s.updateAPIKey),
+				DeleteAPIKey: s.audited("delete-api-key", s.deleteAPIKey),
 			},
+			KeyResolver: apiKeyResolver{service: s.getAPIKeyService(), logger: s.log()},
+			Logger:      s.log(),
+			CORS: apphttp.CORSConfig{
+				AllowedOrigins: s.cfg.CORSAllowedOrigins,
+				AllowedMethods: s.cfg.CORSAllowedMethods,
+				AllowedHeaders: s.cfg.CORSAllowedHeaders,
+			},
+			TrustedProxies: s.trustedProxies,
 		},
 		webStaticHandler(s.cfg.WebDir),
 	)
@@ -422,9 +534,11 @@ func (s *Server) startCronScheduler() {
 }
 
 func (s *Server) cronSchedulerTick() {
-	dueJobs, err := s.getCronService().SchedulerTick(time.Now().UTC())
+	started := time.Now()
+	dueJobs, err := s.getCronService().SchedulerTick(started.UTC())
+	s.getHealthService().Observe(healthservice.KindCron, cronSchedulerHealthName, time.Since(started), err)
 	if err != nil {
-		log.Printf("cron scheduler tick failed: %v", err)
+		s.log().Error("cron scheduler tick failed", "err", err)
 		return
 	}
 
@@ -432,10 +546,10 @@ func (s *Server) cronSchedulerTick() {
 		s.cronWG.Add(1)
 		go func(targetJobID string) {
 			defer s.cronWG.Done()
-			if err := s.executeCronJob(targetJobID); err != nil &&
+			if err := s.executeCronJob(targetJobID, cronservice.TriggerSchedule); err != nil &&
 				!errors.Is(err, errCronJobNotFound) &&
 				!errors.Is(err, errCronMaxConcurrencyReached) {
-				log.Printf("cron job %s execute failed: %v", targetJobID, err)
+				s.log().Error("cron job execute failed", observability.LogKeyJobID, targetJobID, "err", err)
 			}
 		}(jobID)
 	}
@@ -553,6 +667,13 @@ func (s *Server) createChat(w http.ResponseWriter, r *http.Request) {
 	if req.Name == "" {
 		req.Name = "New Chat"
 	}
+	if userID := boundUserID(r.Context()); userID != "" {
+		if req.UserID != "" && req.UserID != userID {
+			writeErr(w, http.StatusForbidden, "forbidden", "api key is bound to a different user_id", nil)
+			return
+		}
+		req.UserID = userID
+	}
 	if req.SessionID == "" || req.UserID == "" || req.Channel == "" {
 		writeErr(w, http.StatusBadRequest, "invalid_chat", "session_id, user_id, channel are required", nil)
 		return
@@ -588,10 +709,13 @@ func (s *Server) batchDeleteChats(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	userID := boundUserID(r.Context())
 	if err := s.store.Write(func(state *repo.State) error {
 		for _, id := range ids {
-			delete(state.Chats, id)
-			delete(state.Histories, id)
+			if chat, ok := state.Chats[id]; ok && userID != "" && chat.UserID != userID {
+				continue
+			}
+			deleteChatState(state, id)
 		}
 		return nil
 	}); err != nil {
@@ -606,7 +730,7 @@ func (s *Server) getChat(w http.ResponseWriter, r *http.Request) {
 	var history []domain.RuntimeMessage
 	found := false
 	s.store.Read(func(state *repo.State) {
-		if _, ok := state.Chats[id]; ok {
+		if chat, ok := state.Chats[id]; ok && chatVisible(r.Context(), chat) {
 			history = state.Histories[id]
 			found = true
 		}
@@ -631,9 +755,12 @@ func (s *Server) updateChat(w http.ResponseWriter, r *http.Request) {
 	}
 	if err := s.store.Write(func(state *repo.State) error {
 		old, ok := state.Chats[id]
-		if !ok {
+		if !ok || !chatVisible(r.Context(), old) {
 			return errors.New("not_found")
 		}
+		if req.UserID != old.UserID {
+			return errChatUserIDImmutable
+		}
 		req.CreatedAt = old.CreatedAt
 		req.UpdatedAt = nowISO()
 		state.Chats[id] = req
@@ -643,6 +770,10 @@ func (s *Server) updateChat(w http.ResponseWriter, r *http.Request) {
 			writeErr(w, http.StatusNotFound, "not_found", "chat not found", nil)
 			return
 		}
+		if errors.Is(err, errChatUserIDImmutable) {
+			writeErr(w, http.StatusBadRequest, "chat_user_id_immutable", "chat user_id cannot be changed", map[string]string{"chat_id": id})
+			return
+		}
 		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
 		return
 	}
@@ -657,10 +788,9 @@ func (s *Server) deleteChat(w http.ResponseWriter, r *http.Request) {
 	}
 	deleted := false
 	if err := s.store.Write(func(state *repo.State) error {
-		if _, ok := state.Chats[id]; ok {
+		if chat, ok := state.Chats[id]; ok && chatVisible(r.Context(), chat) {
 			deleted = true
-			delete(state.Chats, id)
-			delete(state.Histories, id)
+			deleteChatState(state, id)
 		}
 		return nil
 	}); err != nil {
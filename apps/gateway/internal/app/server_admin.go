@@ -6,7 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,6 +19,7 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/plugin"
 	"nextai/apps/gateway/internal/provider"
 	"nextai/apps/gateway/internal/repo"
@@ -50,31 +51,33 @@ func (s *Server) getModelCatalog(w http.ResponseWriter, _ *http.Request) {
 
 func (s *Server) configureProvider(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		APIKey          *string            `json:"api_key"`
-		BaseURL         *string            `json:"base_url"`
-		DisplayName     *string            `json:"display_name"`
-		ReasoningEffort *string            `json:"reasoning_effort"`
-		Enabled         *bool              `json:"enabled"`
-		Store           *bool              `json:"store"`
-		Headers         *map[string]string `json:"headers"`
-		TimeoutMS       *int               `json:"timeout_ms"`
-		ModelAliases    *map[string]string `json:"model_aliases"`
+		APIKey           *string                  `json:"api_key"`
+		BaseURL          *string                  `json:"base_url"`
+		DisplayName      *string                  `json:"display_name"`
+		ReasoningEffort  *string                  `json:"reasoning_effort"`
+		GenerationParams *domain.GenerationParams `json:"generation_params"`
+		Enabled          *bool                    `json:"enabled"`
+		Store            *bool                    `json:"store"`
+		Headers          *map[string]string       `json:"headers"`
+		TimeoutMS        *int                     `json:"timeout_ms"`
+		ModelAliases     *map[string]string       `json:"model_aliases"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeErr(w, http.StatusBadRequest, "invalid_json", "invalid request body", nil)
 		return
 	}
 	out, err := s.getModelService().ConfigureProvider(modelservice.ConfigureProviderInput{
-		ProviderID:      chi.URLParam(r, "provider_id"),
-		APIKey:          body.APIKey,
-		BaseURL:         body.BaseURL,
-		DisplayName:     body.DisplayName,
-		ReasoningEffort: body.ReasoningEffort,
-		Enabled:         body.Enabled,
-		Store:           body.Store,
-		Headers:         body.Headers,
-		TimeoutMS:       body.TimeoutMS,
-		ModelAliases:    body.ModelAliases,
+		ProviderID:       chi.URLParam(r, "provider_id"),
+		APIKey:           body.APIKey,
+		BaseURL:          body.BaseURL,
+		DisplayName:      body.DisplayName,
+		ReasoningEffort:  body.ReasoningEffort,
+		GenerationParams: body.GenerationParams,
+		Enabled:          body.Enabled,
+		Store:            body.Store,
+		Headers:          body.Headers,
+		TimeoutMS:        body.TimeoutMS,
+		ModelAliases:     body.ModelAliases,
 	})
 	if err != nil {
 		if validation := (*modelservice.ValidationError)(nil); errors.As(err, &validation) {
@@ -533,9 +536,14 @@ func (s *Server) deleteWorkspaceFile(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]bool{"deleted": deleted})
 }
 
-func (s *Server) exportWorkspace(w http.ResponseWriter, _ *http.Request) {
-	result, err := s.getWorkspaceService().Export()
+func (s *Server) exportWorkspace(w http.ResponseWriter, r *http.Request) {
+	result, err := s.getWorkspaceService().Export(r.URL.Query().Get("secrets"))
 	if err != nil {
+		validation := (*workspaceservice.ValidationError)(nil)
+		if errors.As(err, &validation) {
+			writeErr(w, http.StatusBadRequest, validation.Code, validation.Message, nil)
+			return
+		}
 		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
 		return
 	}
@@ -1044,7 +1052,7 @@ func mapToolError(err error) (status int, code string, message string) {
 	var te *toolError
 	if errors.As(err, &te) {
 		switch te.Code {
-		case "tool_disabled":
+		case "tool_disabled", "tool_scope_denied":
 			return http.StatusForbidden, te.Code, te.Message
 		case "tool_not_supported":
 			return http.StatusBadRequest, te.Code, te.Message
@@ -1265,6 +1273,7 @@ func buildProviderInfo(providerID string, setting repo.ProviderSetting) domain.P
 		APIKeyPrefix:       spec.APIKeyPrefix,
 		Models:             provider.ResolveModels(providerID, setting.ModelAliases),
 		ReasoningEffort:    setting.ReasoningEffort,
+		GenerationParams:   provider.NormalizeGenerationParams(setting.GenerationParams),
 		Headers:            sanitizeStringMap(setting.Headers),
 		TimeoutMS:          setting.TimeoutMS,
 		ModelAliases:       sanitizeStringMap(setting.ModelAliases),
@@ -1323,6 +1332,7 @@ func normalizeProviderSetting(setting *repo.ProviderSetting) {
 	setting.APIKey = strings.TrimSpace(setting.APIKey)
 	setting.BaseURL = strings.TrimSpace(setting.BaseURL)
 	setting.ReasoningEffort = strings.ToLower(strings.TrimSpace(setting.ReasoningEffort))
+	setting.GenerationParams = provider.NormalizeGenerationParams(setting.GenerationParams)
 	if setting.Enabled == nil {
 		enabled := true
 		setting.Enabled = &enabled
@@ -1652,7 +1662,7 @@ func (s *Server) appendCodexModelInstructionsLayer(
 	switch sourceMode {
 	case codexPromptSourceCatalog:
 		if catalogErr != nil {
-			log.Printf("warning: fallback codex model instructions to file due to catalog error: model_slug=%s err=%v", modelSlug, catalogErr)
+			slog.Warn("fallback codex model instructions to file due to catalog error", "model_slug", modelSlug, "err", catalogErr)
 		} else if strings.TrimSpace(catalogContent) != "" {
 			selectedSource = catalogSource
 			selectedContent = catalogContent
@@ -1693,10 +1703,7 @@ func resolveCodexModelInstructionsFromFile(personalityContent string, personalit
 		content = replaceTemplateVariable(content, "personality", personalityContent)
 	}
 	if unresolved := templatePlaceholderKeys(content); len(unresolved) > 0 {
-		log.Printf(
-			"warning: skip codex model instructions from file due to unresolved template vars: %s",
-			strings.Join(unresolved, ", "),
-		)
+		slog.Warn("skip codex model instructions from file due to unresolved template vars", "vars", strings.Join(unresolved, ", "))
 		return "", "", false, nil
 	}
 	content = strings.TrimSpace(content)
@@ -1770,7 +1777,7 @@ func (s *Server) resolveCodexPersonality(explicit string) string {
 	}
 	resolved, downgraded := codexpromptservice.NormalizePersonality(trimmed)
 	if downgraded {
-		log.Printf("warning: invalid codex personality %q, fallback to %s", trimmed, resolved)
+		slog.Warn("invalid codex personality, using fallback", "personality", trimmed, "fallback", resolved)
 	}
 	return resolved
 }
@@ -1818,13 +1825,12 @@ func logCodexPromptShadowDiff(
 	if strings.TrimSpace(modelSlug) == "" {
 		modelSlug = defaultCodexModelSlug
 	}
-	log.Printf(
-		"codex_prompt_shadow_diff session_id=%s model_slug=%s file_hash=%s catalog_hash=%s diff_reason=%s",
-		sessionID,
-		modelSlug,
-		fileHash,
-		catalogHash,
-		diffReason,
+	slog.Info("codex_prompt_shadow_diff",
+		observability.LogKeySessionID, sessionID,
+		"model_slug", modelSlug,
+		"file_hash", fileHash,
+		"catalog_hash", catalogHash,
+		"diff_reason", diffReason,
 	)
 }
 
@@ -11,9 +11,12 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/plugin"
+	"nextai/apps/gateway/internal/provider"
 	"nextai/apps/gateway/internal/repo"
 	"nextai/apps/gateway/internal/runner"
 	agentprotocolservice "nextai/apps/gateway/internal/service/agentprotocol"
@@ -38,11 +41,18 @@ type agentSystemLayerView struct {
 	EstimatedTokens int    `json:"estimated_tokens"`
 }
 
+type agentSystemSkillView struct {
+	Name            string `json:"name"`
+	Injection       string `json:"injection"`
+	EstimatedTokens int    `json:"estimated_tokens"`
+}
+
 type agentSystemLayersResponse struct {
 	Version              string                 `json:"version"`
 	ModeVariant          string                 `json:"mode_variant,omitempty"`
 	PromptHash           string                 `json:"prompt_hash,omitempty"`
 	Layers               []agentSystemLayerView `json:"layers"`
+	Skills               []agentSystemSkillView `json:"skills"`
 	EstimatedTokensTotal int                    `json:"estimated_tokens_total"`
 }
 
@@ -82,6 +92,7 @@ func (s *Server) getAgentSystemLayers(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	runtimeSnapshot = s.applySkillPlanToSnapshot(runtimeSnapshot, r.URL.Query().Get("query"))
 
 	compiled, err := s.compileSystemLayersForTurnRuntime(runtimeSnapshot)
 	if err != nil {
@@ -95,6 +106,7 @@ func (s *Server) getAgentSystemLayers(w http.ResponseWriter, r *http.Request) {
 		ModeVariant: s.resolvePromptModeVariant(promptMode),
 		PromptHash:  compiled.Hash,
 		Layers:      make([]agentSystemLayerView, 0, len(compiled.Layers)),
+		Skills:      buildAgentSystemSkillViews(runtimeSnapshot.skills),
 	}
 	for _, compiledLayer := range compiled.Layers {
 		layer := compiledLayer.Layer
@@ -227,6 +239,7 @@ func (s *Server) processAgentWithBody(w http.ResponseWriter, r *http.Request, bo
 
 	response, processErr := s.processAgentCore(r.Context(), req, rawRequest, streaming, emitEvent)
 	if processErr != nil {
+		setRetryAfterHeader(w, processErr)
 		streamFail(processErr.Status, processErr.Code, processErr.Message, processErr.Details)
 		return
 	}
@@ -326,8 +339,7 @@ func (s *Server) clearChatContext(sessionID, userID, channel string) error {
 			if spec.SessionID != sessionID || spec.UserID != userID || spec.Channel != channel {
 				continue
 			}
-			delete(state.Chats, chatID)
-			delete(state.Histories, chatID)
+			deleteChatState(state, chatID)
 		}
 		return nil
 	})
@@ -467,8 +479,18 @@ func parseChatActiveModelOverride(meta map[string]interface{}) (domain.ModelSlot
 	if len(meta) == 0 {
 		return domain.ModelSlotConfig{}, false
 	}
-	rawOverride, ok := meta[domain.ChatMetaActiveLLM]
-	if !ok || rawOverride == nil {
+	return parseActiveModelOverride(meta[domain.ChatMetaActiveLLM])
+}
+
+func parseRequestActiveModelOverride(bizParams map[string]interface{}) (domain.ModelSlotConfig, bool) {
+	if len(bizParams) == 0 {
+		return domain.ModelSlotConfig{}, false
+	}
+	return parseActiveModelOverride(bizParams[bizParamsActiveLLMKey])
+}
+
+func parseActiveModelOverride(rawOverride interface{}) (domain.ModelSlotConfig, bool) {
+	if rawOverride == nil {
 		return domain.ModelSlotConfig{}, false
 	}
 	switch value := rawOverride.(type) {
@@ -497,6 +519,49 @@ func parseChatActiveModelOverride(meta map[string]interface{}) (domain.ModelSlot
 	}
 }
 
+func parseGenerationParamsFromBizParams(bizParams map[string]interface{}) (*domain.GenerationParams, error) {
+	params, err := agentprotocolservice.ParseGenerationParamsFromBizParams(bizParams, domain.ChatMetaGeneration)
+	if err != nil {
+		return nil, err
+	}
+	return provider.NormalizeGenerationParams(params), nil
+}
+
+func parseChatGenerationParams(meta map[string]interface{}) *domain.GenerationParams {
+	if len(meta) == 0 {
+		return nil
+	}
+	params, err := agentprotocolservice.DecodeGenerationParams(meta[domain.ChatMetaGeneration])
+	if err != nil {
+		return nil
+	}
+	return provider.NormalizeGenerationParams(params)
+}
+
+// resolveTurnGenerationParams merges provider, chat and request params.
+// Provider params are defaults shared by all of the provider's models, so the
+// ones activeLLM cannot take are dropped; chat and request params are not.
+func resolveTurnGenerationParams(
+	activeLLM domain.ModelSlotConfig,
+	providerParams *domain.GenerationParams,
+	layers ...*domain.GenerationParams,
+) (domain.GenerationParams, error) {
+	adapterID := provider.ResolveAdapter(activeLLM.ProviderID)
+	var model *provider.ModelSpec
+	if spec, ok := provider.FindModel(activeLLM.ProviderID, activeLLM.Model); ok {
+		model = &spec
+	}
+	layers = append([]*domain.GenerationParams{provider.CompatibleGenerationParams(providerParams, adapterID, model)}, layers...)
+	params := provider.MergeGenerationParams(layers...)
+	if err := provider.ValidateGenerationParams(params, model); err != nil {
+		return domain.GenerationParams{}, err
+	}
+	if err := provider.ValidateAdapterGenerationParams(params, adapterID); err != nil {
+		return domain.GenerationParams{}, err
+	}
+	return params, nil
+}
+
 func normalizePromptMode(raw string) (string, bool) {
 	switch strings.ToLower(strings.TrimSpace(raw)) {
 	case promptModeDefault:
@@ -739,11 +804,12 @@ type completedModelRequestLayer struct {
 }
 
 type completedModelRequestPayload struct {
-	PromptMode   string                       `json:"prompt_mode,omitempty"`
-	ProviderID   string                       `json:"provider_id,omitempty"`
-	Model        string                       `json:"model,omitempty"`
-	SystemLayers []completedModelRequestLayer `json:"system_layers,omitempty"`
-	Input        []domain.AgentInputMessage   `json:"input"`
+	PromptMode       string                       `json:"prompt_mode,omitempty"`
+	ProviderID       string                       `json:"provider_id,omitempty"`
+	Model            string                       `json:"model,omitempty"`
+	GenerationParams *domain.GenerationParams     `json:"generation_params,omitempty"`
+	SystemLayers     []completedModelRequestLayer `json:"system_layers,omitempty"`
+	Input            []domain.AgentInputMessage   `json:"input"`
 }
 
 func buildCompletedModelRequestMeta(
@@ -761,6 +827,9 @@ func buildCompletedModelRequestMeta(
 		Model:      strings.TrimSpace(generateConfig.Model),
 		Input:      cloneAgentInputMessages(input),
 	}
+	if generation := provider.NormalizeGenerationParams(&generateConfig.Generation); generation != nil {
+		trace.GenerationParams = generation
+	}
 	if len(systemLayers) > 0 {
 		trace.SystemLayers = make([]completedModelRequestLayer, 0, len(systemLayers))
 		for _, layer := range systemLayers {
@@ -1109,6 +1178,30 @@ func (s *Server) executeToolCallForPromptModeWithContext(ctx context.Context, pr
 	if name == "" {
 		name = strings.ToLower(strings.TrimSpace(call.Name))
 	}
+	ctx, span := observability.StartSpan(ctx, "tool.call", observability.WithAttributes("tool.name", name))
+	defer span.End()
+	started := time.Now()
+	out, err := s.dispatchToolCall(ctx, name, call)
+	code := toolMetricCode(err)
+	observability.ToolInvocationsTotal.Inc(name, code)
+	observability.ToolInvocationDuration.ObserveDuration(started, name)
+	span.SetAttributes("tool.code", code)
+	span.SetError(err)
+	return out, err
+}
+
+func toolMetricCode(err error) string {
+	if err == nil {
+		return observability.MetricResultOK
+	}
+	var tErr *toolError
+	if errors.As(err, &tErr) && tErr.Code != "" {
+		return tErr.Code
+	}
+	return observability.MetricResultError
+}
+
+func (s *Server) dispatchToolCall(ctx context.Context, name string, call toolCall) (string, error) {
 	input := safeMap(call.Input)
 	if err := validateShellToolSandboxPermissions(ctx, name, input); err != nil {
 		return "", err
@@ -1121,6 +1214,13 @@ func (s *Server) executeToolCallForPromptModeWithContext(ctx context.Context, pr
 		}
 	}
 
+	if !toolsAllowed(ctx) {
+		return "", &toolError{
+			Code:    "tool_scope_denied",
+			Message: fmt.Sprintf("tool %q requires api key scope %q", name, domain.APIKeyScopeToolsExecute),
+		}
+	}
+
 	if runtimeSpec, ok := runtimeToolSpecFromContext(ctx, name); ok {
 		return s.executeRuntimeToolCall(ctx, runtimeSpec, input)
 	}
@@ -1147,7 +1247,13 @@ func (s *Server) executeToolCallForPromptModeWithContext(ctx context.Context, pr
 	case "click", "screenshot":
 		return s.executeApproxBrowserToolCall(name, input)
 	case "self_ops":
-		return s.executeSelfOpsToolCall(input)
+		return s.executeSelfOpsToolCall(ctx, input)
+	case loadSkillToolName:
+		return s.executeLoadSkillToolCall(input)
+	case scheduleReminderToolName:
+		return s.auditToolMutation(ctx, "schedule-reminder", "/cron/jobs", func() (string, error) {
+			return s.executeScheduleReminderToolCall(input)
+		})
 	default:
 		result, err := s.invokeRegisteredTool(name, input)
 		if err != nil {
@@ -1366,7 +1472,7 @@ func (s *Server) executeApproxBrowserToolCall(action string, input map[string]in
 	return renderToolResult(action, approx)
 }
 
-func (s *Server) executeSelfOpsToolCall(input map[string]interface{}) (string, error) {
+func (s *Server) executeSelfOpsToolCall(ctx context.Context, input map[string]interface{}) (string, error) {
 	payload := firstToolInputItem(input)
 	action := strings.ToLower(strings.TrimSpace(stringValue(payload["action"])))
 	if action == "" {
@@ -1470,11 +1576,13 @@ func (s *Server) executeSelfOpsToolCall(input map[string]interface{}) (string, e
 				Err:     err,
 			}
 		}
-		result, err := service.ApplyMutation(req)
-		if wrapped, wrapErrValue := wrapErr(err); wrapErrValue != nil {
-			return wrapped, wrapErrValue
-		}
-		return encodeResult(result)
+		return s.auditToolMutation(ctx, "selfops-apply-mutation", "/agent/self/config-mutations/"+req.MutationID, func() (string, error) {
+			result, err := service.ApplyMutation(req)
+			if wrapped, wrapErrValue := wrapErr(err); wrapErrValue != nil {
+				return wrapped, wrapErrValue
+			}
+			return encodeResult(result)
+		})
 	default:
 		return "", &toolError{
 			Code:    "tool_invoke_failed",
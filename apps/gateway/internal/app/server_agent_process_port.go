@@ -11,6 +11,7 @@ import (
 	"nextai/apps/gateway/internal/repo"
 	"nextai/apps/gateway/internal/runner"
 	agentservice "nextai/apps/gateway/internal/service/agent"
+	cronservice "nextai/apps/gateway/internal/service/cron"
 	"nextai/apps/gateway/internal/service/ports"
 )
 
@@ -26,7 +27,7 @@ func (s *Server) processAgentViaPort(
 		}
 	}
 	req.Channel = resolveProcessRequestChannel(nil, req.Channel)
-	return s.processAgentCore(ctx, req, nil, false, nil)
+	return s.processAgentCore(withInternalAgentTurn(ctx), req, nil, false, nil)
 }
 
 func (s *Server) processAgentCore(
@@ -36,6 +37,16 @@ func (s *Server) processAgentCore(
 	streaming bool,
 	emit func(domain.AgentEvent),
 ) (domain.AgentProcessResponse, *ports.AgentProcessError) {
+	if userID := boundUserID(ctx); userID != "" {
+		if req.UserID != "" && req.UserID != userID {
+			return domain.AgentProcessResponse{}, &ports.AgentProcessError{
+				Status:  http.StatusForbidden,
+				Code:    "forbidden",
+				Message: "api key is bound to a different user_id",
+			}
+		}
+		req.UserID = userID
+	}
 	if req.SessionID == "" || req.UserID == "" {
 		return domain.AgentProcessResponse{}, &ports.AgentProcessError{
 			Status:  http.StatusBadRequest,
@@ -54,6 +65,9 @@ func (s *Server) processAgentCore(
 		}
 	}
 	req.Channel = channelName
+	if isChatBranchTurn(ctx) {
+		channelPlugin = silentChannel{channelPlugin}
+	}
 
 	if isContextResetCommand(req.Input) {
 		if err := s.clearChatContext(req.SessionID, req.UserID, req.Channel); err != nil {
@@ -85,6 +99,11 @@ func (s *Server) processAgentCore(
 		return resp, nil
 	}
 
+	limitSubject := rateLimitSubject(ctx, req)
+	if decision := s.getRateLimitService().Allow(limitSubject); !decision.Allowed {
+		return domain.AgentProcessResponse{}, s.rejectRateLimited(ctx, req, channelPlugin, channelCfg, decision)
+	}
+
 	requestPromptMode, hasRequestPromptMode, err := parsePromptModeFromBizParams(req.BizParams)
 	if err != nil {
 		return domain.AgentProcessResponse{}, &ports.AgentProcessError{
@@ -93,6 +112,31 @@ func (s *Server) processAgentCore(
 			Message: err.Error(),
 		}
 	}
+	requestGeneration, err := parseGenerationParamsFromBizParams(req.BizParams)
+	if err != nil {
+		return domain.AgentProcessResponse{}, &ports.AgentProcessError{
+			Status:  http.StatusBadRequest,
+			Code:    "invalid_generation_params",
+			Message: err.Error(),
+		}
+	}
+	responseFormat, err := agentservice.NormalizeResponseFormat(req.ResponseFormat)
+	if err != nil {
+		return domain.AgentProcessResponse{}, &ports.AgentProcessError{
+			Status:  http.StatusBadRequest,
+			Code:    "invalid_response_format",
+			Message: err.Error(),
+		}
+	}
+	req.ResponseFormat = responseFormat
+	requestActiveLLM, hasRequestActiveLLM := parseRequestActiveModelOverride(req.BizParams)
+	if _, exists := req.BizParams[bizParamsActiveLLMKey]; exists && !hasRequestActiveLLM {
+		return domain.AgentProcessResponse{}, &ports.AgentProcessError{
+			Status:  http.StatusBadRequest,
+			Code:    "invalid_request",
+			Message: "biz_params.active_llm requires provider_id and model",
+		}
+	}
 	effectivePromptMode := requestPromptMode
 	sessionRuntimeToolSet := turnRuntimeToolSet{
 		MCPTools:     []turnRuntimeToolSpec{},
@@ -162,6 +206,7 @@ func (s *Server) processAgentCore(
 	chatID := ""
 	activeLLM := domain.ModelSlotConfig{}
 	providerSetting := repo.ProviderSetting{}
+	var chatGeneration *domain.GenerationParams
 	historyInput := []domain.AgentInputMessage{}
 	if err := s.store.Write(func(state *repo.State) error {
 		for id, c := range state.Chats {
@@ -211,15 +256,28 @@ func (s *Server) processAgentCore(
 		}
 		for _, input := range req.Input {
 			state.Histories[chatID] = append(state.Histories[chatID], domain.RuntimeMessage{
-				ID:      newID("msg"),
-				Role:    input.Role,
-				Type:    input.Type,
-				Content: toRuntimeContents(input.Content),
+				ID:        newID("msg"),
+				Role:      input.Role,
+				Type:      input.Type,
+				CreatedAt: nowISO(),
+				Content:   toRuntimeContents(input.Content),
 			})
 		}
+		state.MarkHistoryChanged(chatID)
 		historyInput = runtimeHistoryToAgentInputMessages(state.Histories[chatID])
 		chatSpec := state.Chats[chatID]
 		activeLLM = resolveChatActiveModelSlot(chatSpec.Meta, state)
+		if hasRequestActiveLLM {
+			activeLLM = requestActiveLLM
+		} else if failover, ok := s.failoverModelSlot(state, activeLLM); ok {
+			s.log().WarnContext(ctx, "provider failover",
+				"from_provider", activeLLM.ProviderID,
+				"to_provider", failover.ProviderID,
+				"model", failover.Model,
+			)
+			activeLLM = failover
+		}
+		chatGeneration = parseChatGenerationParams(chatSpec.Meta)
 		providerSetting = getProviderSettingByID(state, activeLLM.ProviderID)
 		return nil
 	}); err != nil {
@@ -248,6 +306,15 @@ func (s *Server) processAgentCore(
 		}
 	}
 
+	allowTools := toolsAllowed(ctx)
+	if hasToolCall && !allowTools {
+		return domain.AgentProcessResponse{}, &ports.AgentProcessError{
+			Status:  http.StatusForbidden,
+			Code:    "tool_scope_denied",
+			Message: "api key lacks scope \"tools:execute\"",
+		}
+	}
+
 	reply := ""
 	events := make([]domain.AgentEvent, 0, 12)
 	memoryRolloutContents := ""
@@ -265,35 +332,18 @@ func (s *Server) processAgentCore(
 				PreviousResponseID: latestProviderResponseIDFromInput(historyInput),
 			}
 		} else {
-			if !providerEnabled(providerSetting) {
-				return domain.AgentProcessResponse{}, &ports.AgentProcessError{
-					Status:  http.StatusBadRequest,
-					Code:    "provider_disabled",
-					Message: "active provider is disabled",
-				}
-			}
-			resolvedModel, ok := provider.ResolveModelID(activeLLM.ProviderID, activeLLM.Model, providerSetting.ModelAliases)
-			if !ok {
-				return domain.AgentProcessResponse{}, &ports.AgentProcessError{
-					Status:  http.StatusBadRequest,
-					Code:    "model_not_found",
-					Message: "active model is not available for provider",
-				}
-			}
-			activeLLM.Model = resolvedModel
-			generateConfig = runner.GenerateConfig{
-				ProviderID:         activeLLM.ProviderID,
-				Model:              activeLLM.Model,
-				APIKey:             resolveProviderAPIKey(activeLLM.ProviderID, providerSetting),
-				BaseURL:            resolveProviderBaseURL(activeLLM.ProviderID, providerSetting),
-				AdapterID:          provider.ResolveAdapter(activeLLM.ProviderID),
-				Headers:            sanitizeStringMap(providerSetting.Headers),
-				TimeoutMS:          providerSetting.TimeoutMS,
-				ReasoningEffort:    providerSetting.ReasoningEffort,
-				Store:              providerStoreEnabled(providerSetting),
-				PromptCacheKey:     req.SessionID,
-				PreviousResponseID: latestProviderResponseIDFromInput(historyInput),
+			resolvedConfig, configErr := buildProviderGenerateConfig(
+				activeLLM,
+				providerSetting,
+				chatGeneration,
+				requestGeneration,
+			)
+			if configErr != nil {
+				return domain.AgentProcessResponse{}, configErr
 			}
+			generateConfig = resolvedConfig
+			generateConfig.PromptCacheKey = req.SessionID
+			generateConfig.PreviousResponseID = latestProviderResponseIDFromInput(historyInput)
 		}
 		if len(historyInput) > 0 {
 			effectiveInput = prependSystemLayers(historyInput, systemLayers)
@@ -310,6 +360,7 @@ func (s *Server) processAgentCore(
 		}
 	}
 	toolDefinitions := s.listToolDefinitionsForTurnRuntime(runtimeSnapshot)
+	s.fireCronMessageTriggers(req)
 
 	processResult, processErr := s.getAgentService().Process(
 		withTurnRuntimeToolContext(ctx, runtimeSnapshot),
@@ -327,10 +378,14 @@ func (s *Server) processAgentCore(
 			PromptMode:        runtimeSnapshot.Mode.PromptMode,
 			CollaborationMode: runtimeSnapshot.Mode.CollaborationMode,
 			ToolDefinitions:   toolDefinitions,
+			DisableTools:      !allowTools,
 		},
 		emitEvent,
 	)
 	if processErr != nil {
+		if processErr.Code == runner.ErrorCodeProviderRequestFailed || processErr.Code == runner.ErrorCodeProviderInvalidReply {
+			s.fireCronSystemEvent(req, cronservice.EventProviderFailure, generateConfig.ProviderID, latestUserInputText(req.Input), processErr.Message)
+		}
 		return domain.AgentProcessResponse{}, &ports.AgentProcessError{
 			Status:  processErr.Status,
 			Code:    processErr.Code,
@@ -340,12 +395,26 @@ func (s *Server) processAgentCore(
 	}
 	reply = processResult.Reply
 	events = withCompletedEventMetaForEvents(processResult.Events, completedEventMeta)
+	usage := domain.AgentTokenUsage{
+		PromptTokens:     processResult.Usage.PromptTokens,
+		CompletionTokens: processResult.Usage.CompletionTokens,
+		TotalTokens:      processResult.Usage.TotalTokens,
+	}
+	if usage.TotalTokens == 0 {
+		estimateInput := effectiveInput
+		if len(estimateInput) == 0 {
+			estimateInput = req.Input
+		}
+		usage = turnTokenEstimate(estimateInput, reply)
+	}
+	s.getRateLimitService().RecordTokens(limitSubject, usage.TotalTokens)
 
 	assistant := domain.RuntimeMessage{
-		ID:      newID("msg"),
-		Role:    "assistant",
-		Type:    "message",
-		Content: []domain.RuntimeContent{{Type: "text", Text: reply}},
+		ID:        newID("msg"),
+		Role:      "assistant",
+		Type:      "message",
+		CreatedAt: nowISO(),
+		Content:   []domain.RuntimeContent{{Type: "text", Text: reply}},
 	}
 	metadata := buildAssistantMessageMetadata(events)
 	if responseID := strings.TrimSpace(processResult.ProviderResponseID); responseID != "" {
@@ -360,6 +429,7 @@ func (s *Server) processAgentCore(
 
 	_ = s.store.Write(func(state *repo.State) error {
 		state.Histories[chatID] = append(state.Histories[chatID], assistant)
+		state.MarkHistoryChanged(chatID)
 		if runtimeSnapshot.Mode.MemoryTask && !hasToolCall {
 			memoryRolloutContents = serializeCodexMemoryRollout(state.Histories[chatID])
 		}
@@ -386,6 +456,7 @@ func (s *Server) processAgentCore(
 			Message: fmt.Sprintf("failed to dispatch message to channel %q", channelName),
 			Err:     err,
 		})
+		s.fireCronSystemEvent(req, cronservice.EventDeliveryDeadLetter, channelName, reply, err.Error())
 		return domain.AgentProcessResponse{}, &ports.AgentProcessError{
 			Status:  status,
 			Code:    code,
@@ -399,7 +470,55 @@ func (s *Server) processAgentCore(
 
 	return domain.AgentProcessResponse{
 		Reply:  reply,
+		Parsed: processResult.Parsed,
 		Events: events,
+		Usage:  usage,
+	}, nil
+}
+
+// buildProviderGenerateConfig resolves model aliases and generation params for
+// activeLLM, layering generationLayers over the provider's own params, and
+// returns the provider config without per-session fields.
+func buildProviderGenerateConfig(
+	activeLLM domain.ModelSlotConfig,
+	providerSetting repo.ProviderSetting,
+	generationLayers ...*domain.GenerationParams,
+) (runner.GenerateConfig, *ports.AgentProcessError) {
+	if !providerEnabled(providerSetting) {
+		return runner.GenerateConfig{}, &ports.AgentProcessError{
+			Status:  http.StatusBadRequest,
+			Code:    "provider_disabled",
+			Message: "active provider is disabled",
+		}
+	}
+	resolvedModel, ok := provider.ResolveModelID(activeLLM.ProviderID, activeLLM.Model, providerSetting.ModelAliases)
+	if !ok {
+		return runner.GenerateConfig{}, &ports.AgentProcessError{
+			Status:  http.StatusBadRequest,
+			Code:    "model_not_found",
+			Message: "active model is not available for provider",
+		}
+	}
+	activeLLM.Model = resolvedModel
+	generation, err := resolveTurnGenerationParams(activeLLM, providerSetting.GenerationParams, generationLayers...)
+	if err != nil {
+		return runner.GenerateConfig{}, &ports.AgentProcessError{
+			Status:  http.StatusBadRequest,
+			Code:    "invalid_generation_params",
+			Message: err.Error(),
+		}
+	}
+	return runner.GenerateConfig{
+		ProviderID:      activeLLM.ProviderID,
+		Model:           activeLLM.Model,
+		APIKey:          resolveProviderAPIKey(activeLLM.ProviderID, providerSetting),
+		BaseURL:         resolveProviderBaseURL(activeLLM.ProviderID, providerSetting),
+		AdapterID:       provider.ResolveAdapter(activeLLM.ProviderID),
+		Headers:         sanitizeStringMap(providerSetting.Headers),
+		TimeoutMS:       providerSetting.TimeoutMS,
+		ReasoningEffort: providerSetting.ReasoningEffort,
+		Generation:      generation,
+		Store:           providerStoreEnabled(providerSetting),
 	}, nil
 }
 
@@ -51,5 +51,7 @@ func (s *Server) newAgentService() *agentservice.Service {
 				return mapRunnerError(err)
 			},
 		},
+		Logger:    s.log(),
+		TraceSink: s.turnTraceSink(),
 	})
 }
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+	"nextai/apps/gateway/internal/repo"
+	apikeyservice "nextai/apps/gateway/internal/service/apikeys"
+)
+
+func (s *Server) getAPIKeyService() *apikeyservice.Service {
+	if s.apiKeyService == nil {
+		s.apiKeyService = s.newAPIKeyService()
+	}
+	return s.apiKeyService
+}
+
+func (s *Server) newAPIKeyService() *apikeyservice.Service {
+	return apikeyservice.NewService(apikeyservice.Dependencies{
+		Store:   s.stateStore,
+		DataDir: s.cfg.DataDir,
+		Logger:  s.log(),
+	})
+}
+
+type apiKeyResolver struct {
+	service *apikeyservice.Service
+	logger  *slog.Logger
+}
+
+func (r apiKeyResolver) Enabled() bool {
+	return r.service.Enabled()
+}
+
+func (r apiKeyResolver) ResolveAPIKey(key string) (observability.Principal, error) {
+	spec, err := r.service.Authenticate(key, time.Now().UTC())
+	if err != nil {
+		switch {
+		case errors.Is(err, apikeyservice.ErrKeyExpired):
+			return observability.Principal{}, observability.ErrAPIKeyExpired
+		case errors.Is(err, apikeyservice.ErrQuotaExceeded):
+			return observability.Principal{}, observability.ErrAPIKeyQuotaExceeded
+		case !errors.Is(err, apikeyservice.ErrInvalidKey):
+			observability.LoggerOrDefault(r.logger).Error("api key resolve failed", "err", err)
+		}
+		return observability.Principal{}, observability.ErrAPIKeyInvalid
+	}
+	return observability.Principal{KeyID: spec.ID, UserID: spec.UserID, Scopes: spec.Scopes}, nil
+}
+
+// requestPrincipal returns the caller's principal; requests without one ran
+// with authentication disabled and get full scopes.
+func requestPrincipal(r *http.Request) observability.Principal {
+	principal, ok := observability.PrincipalFromContext(r.Context())
+	if !ok {
+		principal = observability.MasterPrincipal()
+	}
+	return principal
+}
+
+func apiKeyCaller(r *http.Request) apikeyservice.Caller {
+	principal := requestPrincipal(r)
+	return apikeyservice.Caller{Scopes: principal.Scopes, UserID: principal.UserID}
+}
+
+func boundUserID(ctx context.Context) string {
+	principal, _ := observability.PrincipalFromContext(ctx)
+	return principal.UserID
+}
+
+// chatVisible hides chats of other users from keys bound to a user_id.
+func chatVisible(ctx context.Context, chat domain.ChatSpec) bool {
+	userID := boundUserID(ctx)
+	return userID == "" || chat.UserID == userID
+}
+
+func toolsAllowed(ctx context.Context) bool {
+	principal, ok := observability.PrincipalFromContext(ctx)
+	return !ok || principal.HasScope(domain.APIKeyScopeToolsExecute)
+}
+
+func (s *Server) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.getAPIKeyService().List()
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
+		return
+	}
+	if userID := boundUserID(r.Context()); userID != "" {
+		visible := make([]domain.APIKeyView, 0, len(keys))
+		for _, key := range keys {
+			if key.UserID == userID {
+				visible = append(visible, key)
+			}
+		}
+		keys = visible
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (s *Server) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req domain.APIKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json", "invalid request body", nil)
+		return
+	}
+	created, err := s.getAPIKeyService().Create(req, apiKeyCaller(r), time.Now().UTC())
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *Server) getAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, err := s.getAPIKeyService().Get(chi.URLParam(r, "key_id"))
+	if err == nil {
+		if userID := boundUserID(r.Context()); userID != "" && key.UserID != userID {
+			err = apikeyservice.ErrKeyNotFound
+		}
+	}
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (s *Server) updateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req domain.APIKeyUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json", "invalid request body", nil)
+		return
+	}
+	updated, err := s.getAPIKeyService().Update(chi.URLParam(r, "key_id"), req, apiKeyCaller(r), time.Now().UTC())
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) deleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if err := s.getAPIKeyService().Delete(chi.URLParam(r, "key_id"), apiKeyCaller(r)); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"deleted": true})
+}
+
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	validation := (*apikeyservice.ValidationError)(nil)
+	switch {
+	case errors.As(err, &validation):
+		writeErr(w, http.StatusBadRequest, validation.Code, validation.Message, nil)
+	case errors.Is(err, apikeyservice.ErrKeyNotFound):
+		writeErr(w, http.StatusNotFound, "not_found", "api key not found", nil)
+	case errors.Is(err, apikeyservice.ErrScopeDenied):
+		writeErr(w, http.StatusForbidden, "forbidden", err.Error(), nil)
+	default:
+		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
+	}
+}
+
+func (s *Server) chatOwnerOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := boundUserID(r.Context())
+		if userID == "" {
+			next(w, r)
+			return
+		}
+		id := chi.URLParam(r, "chat_id")
+		owned := true
+		s.store.Read(func(state *repo.State) {
+			if chat, ok := state.Chats[id]; ok {
+				owned = chat.UserID == userID
+			}
+		})
+		if !owned {
+			writeErr(w, http.StatusNotFound, "not_found", "chat not found", map[string]string{"chat_id": id})
+			return
+		}
+		next(w, r)
+	}
+}
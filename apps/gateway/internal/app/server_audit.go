@@ -0,0 +1,218 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+	"nextai/apps/gateway/internal/repo"
+	"nextai/apps/gateway/internal/secrets"
+	auditservice "nextai/apps/gateway/internal/service/audit"
+)
+
+func (s *Server) getAuditService() *auditservice.Service {
+	if s.auditService == nil {
+		s.auditService = s.newAuditService()
+	}
+	return s.auditService
+}
+
+func (s *Server) newAuditService() *auditservice.Service {
+	return auditservice.NewService(auditservice.Dependencies{
+		DataDir: s.cfg.DataDir,
+		Logger:  s.log(),
+	})
+}
+
+// audited records an audit entry for a mutating route, diffing the config
+// snapshot taken before and after the handler runs. Concurrent writers can
+// leak into the diff; the entry is a record of what changed, not a lock.
+func (s *Server) audited(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		before := s.auditSnapshot()
+		rec := &auditStatusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		entry := buildAuditEntry(r.Context(), auditActorFromRequest(r), action, auditTarget(r), before, s.auditSnapshot())
+		entry.Method = r.Method
+		entry.Status = rec.status
+		s.getAuditService().Record(entry)
+	}
+}
+
+// auditToolMutation records config changes made by an agent through a tool call.
+func (s *Server) auditToolMutation(ctx context.Context, action, target string, run func() (string, error)) (string, error) {
+	before := s.auditSnapshot()
+	out, err := run()
+	actor := auditActorFromContext(ctx)
+	actor.Type = domain.AuditActorAgent
+	actor.SessionID = observability.LogFieldFromContext(ctx, observability.LogKeySessionID)
+	if userID := observability.LogFieldFromContext(ctx, observability.LogKeyUserID); userID != "" {
+		actor.UserID = userID
+	}
+	entry := buildAuditEntry(ctx, actor, action, target, before, s.auditSnapshot())
+	if err != nil {
+		entry.Error = observability.RedactSecrets(err.Error())
+	}
+	s.getAuditService().Record(entry)
+	return out, err
+}
+
+type auditStatusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *auditStatusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *auditStatusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func buildAuditEntry(ctx context.Context, actor domain.AuditActor, action, target string, before, after map[string]interface{}) domain.AuditEntry {
+	entry := domain.AuditEntry{
+		RequestID:  observability.RequestIDFromContext(ctx),
+		Actor:      actor,
+		Action:     action,
+		Target:     target,
+		BeforeHash: auditservice.Hash(before),
+		AfterHash:  auditservice.Hash(after),
+		Changes:    auditservice.Diff(before, after),
+	}
+	if sc := observability.SpanContextFromContext(ctx); sc.IsValid() {
+		entry.TraceID = sc.TraceID.String()
+	}
+	return entry
+}
+
+func auditActorFromContext(ctx context.Context) domain.AuditActor {
+	principal, ok := observability.PrincipalFromContext(ctx)
+	if !ok {
+		return domain.AuditActor{Type: domain.AuditActorAnonymous}
+	}
+	actor := domain.AuditActor{Type: domain.AuditActorMaster, KeyID: principal.KeyID, UserID: principal.UserID}
+	if principal.KeyID != "" {
+		actor.Type = domain.AuditActorAPIKey
+	}
+	return actor
+}
+
+func auditActorFromRequest(r *http.Request) domain.AuditActor {
+	actor := auditActorFromContext(r.Context())
+	if chi.URLParam(r, "secret") != "" {
+		actor.Type = domain.AuditActorWebhook
+	}
+	actor.SessionID = strings.TrimSpace(r.Header.Get(openAICompatSessionHeader))
+	return actor
+}
+
+// auditTarget is the request path with webhook secrets masked.
+func auditTarget(r *http.Request) string {
+	target := r.URL.Path
+	if secret := chi.URLParam(r, "secret"); secret != "" {
+		target = strings.Replace(target, secret, secrets.Redacted, 1)
+	}
+	return target
+}
+
+// auditSnapshot captures the configuration covered by the audit log as plain
+// JSON values: state sections plus a content hash per workspace text file.
+func (s *Server) auditSnapshot() map[string]interface{} {
+	var body []byte
+	s.store.Read(func(state *repo.State) {
+		body, _ = json.Marshal(map[string]interface{}{
+			"providers":  state.Providers,
+			"active_llm": state.ActiveLLM,
+			"envs":       state.Envs,
+			"skills":     state.Skills,
+			"channels":   state.Channels,
+			"cron_jobs":  state.CronJobs,
+			"api_keys":   state.APIKeys,
+		})
+	})
+	snapshot := map[string]interface{}{}
+	_ = json.Unmarshal(body, &snapshot)
+
+	files := map[string]interface{}{}
+	for _, entry := range collectWorkspaceTextFileEntries() {
+		_, content, err := readWorkspaceTextFileRawForPath(entry.Path)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256([]byte(content))
+		files[entry.Path] = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	snapshot["workspace_files"] = files
+	return snapshot
+}
+
+func (s *Server) listAudit(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+	items, err := s.getAuditService().Query(filter)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "audit_error", err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (s *Server) exportAudit(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseAuditFilter(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	if err := s.getAuditService().Export(w, filter); err != nil {
+		s.log().ErrorContext(r.Context(), "audit export failed", "err", err)
+	}
+}
+
+func parseAuditFilter(w http.ResponseWriter, r *http.Request) (auditservice.Filter, bool) {
+	values := r.URL.Query()
+	filter := auditservice.Filter{
+		ActorType: strings.TrimSpace(values.Get("actor_type")),
+		KeyID:     strings.TrimSpace(values.Get("key_id")),
+		UserID:    strings.TrimSpace(values.Get("user_id")),
+		SessionID: strings.TrimSpace(values.Get("session_id")),
+		Action:    strings.TrimSpace(values.Get("action")),
+		Target:    strings.TrimSpace(values.Get("target")),
+	}
+	for name, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		raw := strings.TrimSpace(values.Get(name))
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeErr(w, http.StatusBadRequest, "invalid_audit_query", name+" must be an RFC3339 timestamp", nil)
+			return auditservice.Filter{}, false
+		}
+		*dst = parsed
+	}
+	if raw := strings.TrimSpace(values.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > auditservice.MaxQueryLimit {
+			writeErr(w, http.StatusBadRequest, "invalid_audit_query", "limit must be between 1 and 1000", nil)
+			return auditservice.Filter{}, false
+		}
+		filter.Limit = limit
+	}
+	return filter, true
+}
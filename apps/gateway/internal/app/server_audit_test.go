@@ -0,0 +1,82 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/secrets"
+)
+
+func TestAuditRecordsAdminMutationsWithRedactedDiff(t *testing.T) {
+	srv := newTestServer(t)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-NextAI-Session-Id", "s-audit")
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve(http.MethodPut, "/models/openai/config", `{"api_key":"sk-audit-secret-123456","base_url":"https://api.example.com/v1"}`); w.Code != http.StatusOK {
+		t.Fatalf("configure provider status=%d body=%s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, "/models", ""); w.Code != http.StatusOK {
+		t.Fatalf("list providers status=%d", w.Code)
+	}
+	if w := serve(http.MethodPut, "/envs", `{"REGION":"eu"}`); w.Code != http.StatusOK {
+		t.Fatalf("put envs status=%d body=%s", w.Code, w.Body.String())
+	}
+
+	w := serve(http.MethodGet, "/audit", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("audit status=%d body=%s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "sk-audit-secret-123456") {
+		t.Fatalf("audit log leaked provider key: %s", w.Body.String())
+	}
+	var listed struct {
+		Items []domain.AuditEntry `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decode audit failed: %v", err)
+	}
+	if len(listed.Items) != 2 || listed.Items[0].Action != "put-envs" || listed.Items[1].Action != "configure-provider" {
+		t.Fatalf("expected two mutations newest first, got=%+v", listed.Items)
+	}
+	provider := listed.Items[1]
+	if provider.Actor.Type != domain.AuditActorAnonymous || provider.Actor.SessionID != "s-audit" {
+		t.Fatalf("unexpected actor: %+v", provider.Actor)
+	}
+	if provider.Target != "/models/openai/config" || provider.Status != http.StatusOK || provider.BeforeHash == provider.AfterHash {
+		t.Fatalf("unexpected entry: %+v", provider)
+	}
+	redacted := false
+	for _, change := range provider.Changes {
+		if change.Path == "providers.openai.api_key" {
+			redacted = change.After == secrets.Redacted
+		}
+	}
+	if !redacted {
+		t.Fatalf("expected redacted api_key change, got=%+v", provider.Changes)
+	}
+	if listed.Items[0].BeforeHash != provider.AfterHash {
+		t.Fatalf("expected hashes to chain, before=%s after=%s", listed.Items[0].BeforeHash, provider.AfterHash)
+	}
+
+	w = serve(http.MethodGet, "/audit/export?action=put-envs", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("export status=%d content-type=%s", w.Code, w.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"envs.REGION"`) {
+		t.Fatalf("unexpected export: %s", w.Body.String())
+	}
+
+	if w := serve(http.MethodGet, "/audit?since=yesterday", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid since status=%d", w.Code)
+	}
+}
@@ -0,0 +1,457 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/plugin"
+	"nextai/apps/gateway/internal/repo"
+)
+
+var (
+	errChatNotFound        = errors.New("chat_not_found")
+	errChatMessageNotFound = errors.New("message_not_found")
+	errChatMessageRole     = errors.New("message_role_invalid")
+	errChatUserIDImmutable = errors.New("chat_user_id_immutable")
+)
+
+type chatBranchTurnContextKey struct{}
+
+// withChatBranchTurn marks turns replayed from the console's branch editor;
+// their replies must not be pushed to the chat's original channel.
+func withChatBranchTurn(ctx context.Context) context.Context {
+	return context.WithValue(ctx, chatBranchTurnContextKey{}, true)
+}
+
+func isChatBranchTurn(ctx context.Context) bool {
+	branch, _ := ctx.Value(chatBranchTurnContextKey{}).(bool)
+	return branch
+}
+
+// silentChannel keeps the channel identity of a chat but drops outbound text.
+type silentChannel struct {
+	plugin.ChannelPlugin
+}
+
+func (silentChannel) SendText(context.Context, string, string, string, map[string]interface{}) error {
+	return nil
+}
+
+// chatBranchSnapshot is the active history and archived branches of a chat
+// before a branch turn truncated it.
+type chatBranchSnapshot struct {
+	history  []domain.RuntimeMessage
+	branches []domain.RuntimeMessage
+}
+
+func (s *Server) getChatMessageTree(w http.ResponseWriter, r *http.Request) {
+	chatID := chi.URLParam(r, "chat_id")
+	var tree domain.ChatMessageTree
+	found := false
+	s.store.Read(func(state *repo.State) {
+		if chat, ok := state.Chats[chatID]; !ok || !chatVisible(r.Context(), chat) {
+			return
+		}
+		found = true
+		tree = buildChatMessageTree(chatID, state.Histories[chatID], state.Branches[chatID])
+	})
+	if !found {
+		writeErr(w, http.StatusNotFound, "not_found", "chat not found", map[string]string{"chat_id": chatID})
+		return
+	}
+	writeJSON(w, http.StatusOK, tree)
+}
+
+func (s *Server) activateChatMessage(w http.ResponseWriter, r *http.Request) {
+	chatID := chi.URLParam(r, "chat_id")
+	messageID := chi.URLParam(r, "message_id")
+	var tree domain.ChatMessageTree
+	if err := s.store.Write(func(state *repo.State) error {
+		if chat, ok := state.Chats[chatID]; !ok || !chatVisible(r.Context(), chat) {
+			return errChatNotFound
+		}
+		if err := activateChatBranch(state, chatID, messageID); err != nil {
+			return err
+		}
+		tree = buildChatMessageTree(chatID, state.Histories[chatID], state.Branches[chatID])
+		return nil
+	}); err != nil {
+		writeChatBranchErr(w, chatID, messageID, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tree)
+}
+
+func (s *Server) editChatMessage(w http.ResponseWriter, r *http.Request) {
+	chatID := chi.URLParam(r, "chat_id")
+	messageID := chi.URLParam(r, "message_id")
+	var body domain.ChatMessageEditRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json", "invalid request body", nil)
+		return
+	}
+	if !runtimeContentHasText(body.Content) {
+		writeErr(w, http.StatusBadRequest, "invalid_request", "content must include non-empty text", nil)
+		return
+	}
+
+	var chat domain.ChatSpec
+	var snapshot chatBranchSnapshot
+	if err := s.store.Write(func(state *repo.State) error {
+		spec, ok := state.Chats[chatID]
+		if !ok || !chatVisible(r.Context(), spec) {
+			return errChatNotFound
+		}
+		chat = spec
+		if err := activateChatBranch(state, chatID, messageID); err != nil {
+			return err
+		}
+		idx := indexOfRuntimeMessage(state.Histories[chatID], messageID)
+		if !strings.EqualFold(state.Histories[chatID][idx].Role, "user") {
+			return errChatMessageRole
+		}
+		snapshot = archiveChatHistorySuffix(state, chatID, idx)
+		return nil
+	}); err != nil {
+		writeChatBranchErr(w, chatID, messageID, err)
+		return
+	}
+
+	s.runChatBranchTurn(w, r, chat, snapshot, []domain.AgentInputMessage{{
+		Role:    "user",
+		Type:    "message",
+		Content: body.Content,
+	}})
+}
+
+func (s *Server) regenerateChatMessage(w http.ResponseWriter, r *http.Request) {
+	chatID := chi.URLParam(r, "chat_id")
+	messageID := chi.URLParam(r, "message_id")
+	var chat domain.ChatSpec
+	var snapshot chatBranchSnapshot
+	if err := s.store.Write(func(state *repo.State) error {
+		spec, ok := state.Chats[chatID]
+		if !ok || !chatVisible(r.Context(), spec) {
+			return errChatNotFound
+		}
+		chat = spec
+		if err := activateChatBranch(state, chatID, messageID); err != nil {
+			return err
+		}
+		history := state.Histories[chatID]
+		cut := -1
+		for i := indexOfRuntimeMessage(history, messageID); i >= 0; i-- {
+			if strings.EqualFold(history[i].Role, "user") {
+				cut = i + 1
+				break
+			}
+		}
+		if cut < 0 {
+			return errChatMessageRole
+		}
+		snapshot = archiveChatHistorySuffix(state, chatID, cut)
+		return nil
+	}); err != nil {
+		writeChatBranchErr(w, chatID, messageID, err)
+		return
+	}
+
+	s.runChatBranchTurn(w, r, chat, snapshot, []domain.AgentInputMessage{})
+}
+
+func (s *Server) forkChatMessage(w http.ResponseWriter, r *http.Request) {
+	chatID := chi.URLParam(r, "chat_id")
+	messageID := chi.URLParam(r, "message_id")
+	var out domain.ChatForkResponse
+	if err := s.store.Write(func(state *repo.State) error {
+		source, ok := state.Chats[chatID]
+		if !ok || !chatVisible(r.Context(), source) {
+			return errChatNotFound
+		}
+		nodes := chatMessageNodes(state.Histories[chatID], state.Branches[chatID])
+		path, ok := chatMessagePath(nodes, messageID)
+		if !ok {
+			return errChatMessageNotFound
+		}
+
+		messages := make([]domain.RuntimeMessage, 0, len(path))
+		for _, item := range path {
+			copied := cloneRuntimeMessage(item)
+			copied.ID = newID("msg")
+			messages = append(messages, copied)
+		}
+		messages = linkChatHistory(messages)
+
+		meta := map[string]interface{}{}
+		for key, value := range source.Meta {
+			if key == domain.ChatMetaSystemDefault {
+				continue
+			}
+			meta[key] = value
+		}
+		meta[domain.ChatMetaForkedFrom] = map[string]interface{}{
+			"chat_id":    chatID,
+			"message_id": messageID,
+		}
+		now := nowISO()
+		fork := domain.ChatSpec{
+			ID:        newID("chat"),
+			Name:      source.Name,
+			SessionID: newID("session"),
+			UserID:    source.UserID,
+			Channel:   source.Channel,
+			Meta:      meta,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		state.Chats[fork.ID] = fork
+		state.Histories[fork.ID] = messages
+		state.MarkHistoryChanged(fork.ID)
+		out = domain.ChatForkResponse{Chat: fork, Messages: messages}
+		return nil
+	}); err != nil {
+		writeChatBranchErr(w, chatID, messageID, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// runChatBranchTurn runs the turn against the truncated history. When it
+// fails, the history is put back so the archived suffix is not lost.
+func (s *Server) runChatBranchTurn(w http.ResponseWriter, r *http.Request, chat domain.ChatSpec, snapshot chatBranchSnapshot, input []domain.AgentInputMessage) {
+	response, processErr := s.processAgentCore(withChatBranchTurn(r.Context()), domain.AgentProcessRequest{
+		Input:     input,
+		SessionID: chat.SessionID,
+		UserID:    chat.UserID,
+		Channel:   chat.Channel,
+	}, nil, false, nil)
+	if processErr != nil {
+		if err := s.store.Write(func(state *repo.State) error {
+			if _, ok := state.Chats[chat.ID]; !ok {
+				return nil
+			}
+			state.Histories[chat.ID] = snapshot.history
+			state.MarkHistoryChanged(chat.ID)
+			setChatBranches(state, chat.ID, snapshot.branches)
+			return nil
+		}); err != nil {
+			s.log().ErrorContext(r.Context(), "restore chat history after failed branch turn", "chat_id", chat.ID, "err", err)
+		}
+		writeErr(w, processErr.Status, processErr.Code, processErr.Message, processErr.Details)
+		return
+	}
+	var messages []domain.RuntimeMessage
+	s.store.Read(func(state *repo.State) {
+		messages = state.Histories[chat.ID]
+	})
+	writeJSON(w, http.StatusOK, domain.ChatBranchTurnResponse{
+		Reply:    response.Reply,
+		Parsed:   response.Parsed,
+		Events:   response.Events,
+		Messages: messages,
+	})
+}
+
+func writeChatBranchErr(w http.ResponseWriter, chatID, messageID string, err error) {
+	details := map[string]string{"chat_id": chatID, "message_id": messageID}
+	switch {
+	case errors.Is(err, errChatNotFound):
+		writeErr(w, http.StatusNotFound, "not_found", "chat not found", details)
+	case errors.Is(err, errChatMessageNotFound):
+		writeErr(w, http.StatusNotFound, "message_not_found", "message not found in chat", details)
+	case errors.Is(err, errChatMessageRole):
+		writeErr(w, http.StatusBadRequest, "message_role_invalid", "operation is not supported for this message", details)
+	default:
+		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
+	}
+}
+
+func deleteChatState(state *repo.State, chatID string) {
+	delete(state.Chats, chatID)
+	delete(state.Histories, chatID)
+	state.MarkHistoryChanged(chatID)
+	delete(state.Branches, chatID)
+}
+
+func linkChatHistory(history []domain.RuntimeMessage) []domain.RuntimeMessage {
+	parentID := ""
+	for i := range history {
+		if strings.TrimSpace(history[i].ID) == "" {
+			history[i].ID = newID("msg")
+		}
+		history[i].ParentID = parentID
+		parentID = history[i].ID
+	}
+	return history
+}
+
+func chatMessageNodes(active []domain.RuntimeMessage, inactive []domain.RuntimeMessage) []domain.RuntimeMessage {
+	nodes := make([]domain.RuntimeMessage, 0, len(active)+len(inactive))
+	nodes = append(nodes, inactive...)
+	nodes = append(nodes, linkChatHistory(active)...)
+	return nodes
+}
+
+func chatMessagePath(nodes []domain.RuntimeMessage, messageID string) ([]domain.RuntimeMessage, bool) {
+	byID := make(map[string]domain.RuntimeMessage, len(nodes))
+	for _, node := range nodes {
+		byID[node.ID] = node
+	}
+	current, ok := byID[messageID]
+	if !ok {
+		return nil, false
+	}
+	reversed := []domain.RuntimeMessage{current}
+	seen := map[string]bool{current.ID: true}
+	for current.ParentID != "" {
+		parent, ok := byID[current.ParentID]
+		if !ok || seen[parent.ID] {
+			break
+		}
+		seen[parent.ID] = true
+		reversed = append(reversed, parent)
+		current = parent
+	}
+	path := make([]domain.RuntimeMessage, 0, len(reversed))
+	for i := len(reversed) - 1; i >= 0; i-- {
+		path = append(path, reversed[i])
+	}
+	return path, true
+}
+
+func activateChatBranch(state *repo.State, chatID, messageID string) error {
+	active := linkChatHistory(state.Histories[chatID])
+	nodes := chatMessageNodes(active, state.Branches[chatID])
+	path, ok := chatMessagePath(nodes, messageID)
+	if !ok {
+		return errChatMessageNotFound
+	}
+
+	latestChild := map[string]domain.RuntimeMessage{}
+	for _, node := range nodes {
+		latestChild[node.ParentID] = node
+	}
+	onPath := make(map[string]bool, len(nodes))
+	for _, node := range path {
+		onPath[node.ID] = true
+	}
+	for {
+		child, ok := latestChild[path[len(path)-1].ID]
+		if !ok || onPath[child.ID] {
+			break
+		}
+		onPath[child.ID] = true
+		path = append(path, child)
+	}
+
+	inactive := make([]domain.RuntimeMessage, 0, len(nodes))
+	for _, node := range state.Branches[chatID] {
+		if !onPath[node.ID] {
+			inactive = append(inactive, node)
+		}
+	}
+	for _, node := range active {
+		if !onPath[node.ID] {
+			inactive = append(inactive, node)
+		}
+	}
+	state.Histories[chatID] = path
+	state.MarkHistoryChanged(chatID)
+	setChatBranches(state, chatID, inactive)
+	return nil
+}
+
+func archiveChatHistorySuffix(state *repo.State, chatID string, from int) chatBranchSnapshot {
+	history := linkChatHistory(state.Histories[chatID])
+	snapshot := chatBranchSnapshot{
+		history:  append([]domain.RuntimeMessage{}, history...),
+		branches: append([]domain.RuntimeMessage{}, state.Branches[chatID]...),
+	}
+	if from < 0 || from >= len(history) {
+		return snapshot
+	}
+	archived := append(append([]domain.RuntimeMessage{}, state.Branches[chatID]...), history[from:]...)
+	state.Histories[chatID] = append([]domain.RuntimeMessage{}, history[:from]...)
+	state.MarkHistoryChanged(chatID)
+	setChatBranches(state, chatID, archived)
+	return snapshot
+}
+
+func setChatBranches(state *repo.State, chatID string, inactive []domain.RuntimeMessage) {
+	if state.Branches == nil {
+		state.Branches = map[string][]domain.RuntimeMessage{}
+	}
+	if len(inactive) == 0 {
+		delete(state.Branches, chatID)
+		return
+	}
+	state.Branches[chatID] = inactive
+}
+
+func buildChatMessageTree(chatID string, active []domain.RuntimeMessage, inactive []domain.RuntimeMessage) domain.ChatMessageTree {
+	activeCopy := make([]domain.RuntimeMessage, len(active))
+	copy(activeCopy, active)
+	nodes := chatMessageNodes(activeCopy, inactive)
+
+	activeIDs := make(map[string]bool, len(activeCopy))
+	for _, node := range activeCopy {
+		activeIDs[node.ID] = true
+	}
+	children := map[string][]string{}
+	for _, node := range nodes {
+		if node.ParentID != "" {
+			children[node.ParentID] = append(children[node.ParentID], node.ID)
+		}
+	}
+	tree := domain.ChatMessageTree{
+		ChatID:   chatID,
+		Messages: make([]domain.ChatMessageNode, 0, len(nodes)),
+	}
+	if len(activeCopy) > 0 {
+		tree.ActiveLeafID = activeCopy[len(activeCopy)-1].ID
+	}
+	for _, node := range nodes {
+		tree.Messages = append(tree.Messages, domain.ChatMessageNode{
+			RuntimeMessage: node,
+			Children:       children[node.ID],
+			Active:         activeIDs[node.ID],
+		})
+	}
+	return tree
+}
+
+func indexOfRuntimeMessage(history []domain.RuntimeMessage, messageID string) int {
+	for i, item := range history {
+		if item.ID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+func cloneRuntimeMessage(in domain.RuntimeMessage) domain.RuntimeMessage {
+	out := in
+	out.Content = append([]domain.RuntimeContent{}, in.Content...)
+	if in.Metadata != nil {
+		out.Metadata = make(map[string]interface{}, len(in.Metadata))
+		for key, value := range in.Metadata {
+			out.Metadata[key] = value
+		}
+	}
+	return out
+}
+
+func runtimeContentHasText(content []domain.RuntimeContent) bool {
+	for _, part := range content {
+		if strings.TrimSpace(part.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
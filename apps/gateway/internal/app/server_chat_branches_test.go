@@ -0,0 +1,204 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/repo"
+)
+
+func seedBranchTestChat(t *testing.T, srv *Server) {
+	t.Helper()
+	text := func(value string) []domain.RuntimeContent {
+		return []domain.RuntimeContent{{Type: "text", Text: value}}
+	}
+	if err := srv.store.Write(func(state *repo.State) error {
+		state.Chats["chat-branch"] = domain.ChatSpec{
+			ID: "chat-branch", Name: "branch", SessionID: "s-branch", UserID: "u-branch", Channel: "console",
+			Meta: map[string]interface{}{},
+		}
+		state.Histories["chat-branch"] = []domain.RuntimeMessage{
+			{ID: "m1", Role: "user", Type: "message", Content: text("hi")},
+			{ID: "m2", Role: "assistant", Type: "message", Content: text("hello")},
+			{ID: "m3", Role: "user", Type: "message", Content: text("question")},
+			{ID: "m4", Role: "assistant", Type: "message", Content: text("answer")},
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveAndActivateChatBranchKeepsTree(t *testing.T) {
+	srv := newTestServer(t)
+	seedBranchTestChat(t, srv)
+
+	if err := srv.store.Write(func(state *repo.State) error {
+		archiveChatHistorySuffix(state, "chat-branch", 2)
+		state.Histories["chat-branch"] = append(state.Histories["chat-branch"],
+			domain.RuntimeMessage{ID: "m3b", Role: "user", Type: "message"},
+			domain.RuntimeMessage{ID: "m4b", Role: "assistant", Type: "message"},
+		)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/chats/chat-branch/messages", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var tree domain.ChatMessageTree
+	if err := json.Unmarshal(w.Body.Bytes(), &tree); err != nil {
+		t.Fatal(err)
+	}
+	if tree.ActiveLeafID != "m4b" || len(tree.Messages) != 6 {
+		t.Fatalf("unexpected tree: %s", w.Body.String())
+	}
+	for _, node := range tree.Messages {
+		if node.ID == "m2" && len(node.Children) != 2 {
+			t.Fatalf("expected m2 to have two branches, got=%v", node.Children)
+		}
+		if node.ID == "m3" && node.Active {
+			t.Fatalf("archived message should be inactive: %#v", node)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chats/chat-branch/messages/m3/activate", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &tree); err != nil {
+		t.Fatal(err)
+	}
+	if tree.ActiveLeafID != "m4" {
+		t.Fatalf("expected activation to extend to leaf m4, got=%s", tree.ActiveLeafID)
+	}
+	srv.store.Read(func(state *repo.State) {
+		history := state.Histories["chat-branch"]
+		if len(history) != 4 || history[2].ID != "m3" || history[3].ParentID != "m3" {
+			t.Fatalf("unexpected active history: %#v", history)
+		}
+		if len(state.Branches["chat-branch"]) != 2 {
+			t.Fatalf("expected previous branch to be archived: %#v", state.Branches["chat-branch"])
+		}
+	})
+}
+
+func TestForkChatMessageCreatesNewChat(t *testing.T) {
+	srv := newTestServer(t)
+	seedBranchTestChat(t, srv)
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chats/chat-branch/messages/m2/fork", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var out domain.ChatForkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Chat.ID == "chat-branch" || out.Chat.SessionID == "s-branch" || out.Chat.UserID != "u-branch" {
+		t.Fatalf("unexpected fork chat: %#v", out.Chat)
+	}
+	if len(out.Messages) != 2 || out.Messages[1].ParentID != out.Messages[0].ID || out.Messages[1].Content[0].Text != "hello" {
+		t.Fatalf("unexpected fork history: %#v", out.Messages)
+	}
+	forkedFrom, _ := out.Chat.Meta[domain.ChatMetaForkedFrom].(map[string]interface{})
+	if forkedFrom["chat_id"] != "chat-branch" || forkedFrom["message_id"] != "m2" {
+		t.Fatalf("unexpected forked_from meta: %#v", out.Chat.Meta)
+	}
+	srv.store.Read(func(state *repo.State) {
+		if len(state.Histories["chat-branch"]) != 4 {
+			t.Fatalf("fork should not change source history")
+		}
+	})
+}
+
+func TestChatMessageBranchErrors(t *testing.T) {
+	srv := newTestServer(t)
+	seedBranchTestChat(t, srv)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{http.MethodPost, "/chats/chat-branch/messages/missing/activate", "", http.StatusNotFound, "message_not_found"},
+		{http.MethodPut, "/chats/chat-branch/messages/m2", `{"content":[{"type":"text","text":"edit"}]}`, http.StatusBadRequest, "message_role_invalid"},
+		{http.MethodPut, "/chats/chat-branch/messages/m1", `{"content":[]}`, http.StatusBadRequest, "invalid_request"},
+		{http.MethodPost, "/chats/missing/messages/m1/regenerate", "", http.StatusNotFound, "not_found"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if w.Code != tc.status {
+			t.Fatalf("%s %s status=%d body=%s", tc.method, tc.path, w.Code, w.Body.String())
+		}
+		var body domain.APIErrorBody
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error.Code != tc.code {
+			t.Fatalf("%s %s unexpected error body: %s", tc.method, tc.path, w.Body.String())
+		}
+	}
+}
+
+func TestRegenerateChatMessageSkipsChannelDispatchAndRestoresHistoryOnFailure(t *testing.T) {
+	srv := newTestServer(t)
+	seedBranchTestChat(t, srv)
+	probe := &contractRegressionProbeChannel{name: "qq"}
+	srv.channels["qq"] = probe
+	if err := srv.store.Write(func(state *repo.State) error {
+		state.Channels["qq"] = map[string]interface{}{"enabled": true}
+		chat := state.Chats["chat-branch"]
+		chat.Channel = "qq"
+		state.Chats["chat-branch"] = chat
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chats/chat-branch/messages/m4/regenerate", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if probe.callCount != 0 {
+		t.Fatalf("branch turn must not dispatch to the chat channel, got %d sends", probe.callCount)
+	}
+
+	disabled := false
+	var before []domain.RuntimeMessage
+	if err := srv.store.Write(func(state *repo.State) error {
+		state.Providers["openai"] = repo.ProviderSetting{Enabled: &disabled}
+		state.ActiveLLM = domain.ModelSlotConfig{ProviderID: "openai", Model: "gpt-4o-mini"}
+		before = append([]domain.RuntimeMessage{}, state.Histories["chat-branch"]...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/chats/chat-branch/messages/m3",
+		strings.NewReader(`{"content":[{"type":"text","text":"edited"}]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected failed turn, status=%d body=%s", w.Code, w.Body.String())
+	}
+	srv.store.Read(func(state *repo.State) {
+		history := state.Histories["chat-branch"]
+		if len(history) != len(before) || history[len(history)-1].ID != before[len(before)-1].ID {
+			t.Fatalf("history not restored after failed branch turn: %#v", history)
+		}
+		for _, msg := range history {
+			if runtimeContentHasText(msg.Content) && msg.Content[0].Text == "edited" {
+				t.Fatalf("failed edit must not stay in history: %#v", history)
+			}
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nextai/apps/gateway/internal/search"
+)
+
+func (s *Server) searchChats(w http.ResponseWriter, r *http.Request) {
+	query, err := parseChatSearchQuery(r)
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_search_query", err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.searchIndex.Search(query))
+}
+
+func parseChatSearchQuery(r *http.Request) (search.Query, error) {
+	values := r.URL.Query()
+	query := search.Query{
+		Text:    strings.TrimSpace(values.Get("q")),
+		ChatID:  strings.TrimSpace(values.Get("chat_id")),
+		Channel: strings.TrimSpace(values.Get("channel")),
+		UserID:  strings.TrimSpace(values.Get("user_id")),
+		Role:    strings.TrimSpace(values.Get("role")),
+	}
+	if query.Text == "" {
+		return search.Query{}, errors.New("q is required")
+	}
+	var err error
+	if query.From, err = parseChatSearchTime(values.Get("from"), false); err != nil {
+		return search.Query{}, errors.New("from must be RFC3339 or YYYY-MM-DD")
+	}
+	if query.To, err = parseChatSearchTime(values.Get("to"), true); err != nil {
+		return search.Query{}, errors.New("to must be RFC3339 or YYYY-MM-DD")
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return search.Query{}, errors.New("from must be before to")
+	}
+	if query.Limit, err = parseChatSearchInt(values.Get("limit"), search.DefaultLimit); err != nil || query.Limit <= 0 || query.Limit > search.MaxLimit {
+		return search.Query{}, errors.New("limit must be between 1 and " + strconv.Itoa(search.MaxLimit))
+	}
+	if query.Offset, err = parseChatSearchInt(values.Get("offset"), 0); err != nil || query.Offset < 0 {
+		return search.Query{}, errors.New("offset must be >= 0")
+	}
+	return query, nil
+}
+
+func parseChatSearchTime(raw string, endOfDay bool) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if at, err := time.Parse(time.RFC3339, raw); err == nil {
+		return at, nil
+	}
+	day, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+func parseChatSearchInt(raw string, fallback int) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(raw)
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nextai/apps/gateway/internal/repo"
+	"nextai/apps/gateway/internal/search"
+)
+
+func TestSearchChatsTracksHistoryWrites(t *testing.T) {
+	srv := newTestServer(t)
+	seedBranchTestChat(t, srv)
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/chats/search?q=question&channel=console", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var result search.Result
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 1 || result.Hits[0].ChatID != "chat-branch" || result.Hits[0].MessageID != "m3" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if err := srv.store.Write(func(state *repo.State) error {
+		deleteChatState(state, "chat-branch")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/chats/search?q=question", nil))
+	result = search.Result{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 0 {
+		t.Fatalf("expected deleted chat to drop from index, got %+v", result)
+	}
+}
+
+func TestSearchChatsValidatesQuery(t *testing.T) {
+	srv := newTestServer(t)
+	for _, target := range []string{
+		"/chats/search",
+		"/chats/search?q=x&limit=0",
+		"/chats/search?q=x&offset=-1",
+		"/chats/search?q=x&from=yesterday",
+		"/chats/search?q=x&from=2026-02-01&to=2026-01-01",
+	} {
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status=%d body=%s", target, w.Code, w.Body.String())
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/repo"
 	"nextai/apps/gateway/internal/service/ports"
 )
@@ -643,7 +644,7 @@ func (s *Server) executeSpawnAgentToolCall(ctx context.Context, input map[string
 			Err:     err,
 		}
 	}
-	if err := s.startSubAgentTurn(agent.AgentID, task); err != nil {
+	if err := s.startSubAgentTurn(ctx, agent.AgentID, task); err != nil {
 		s.removeSubAgent(agent.AgentID)
 		return "", &toolError{
 			Code:    "tool_invoke_failed",
@@ -756,7 +757,7 @@ func (s *Server) executeResumeAgentToolCall(ctx context.Context, input map[strin
 			return "", err
 		}
 	}
-	if err := s.resumeSubAgent(agentID); err != nil {
+	if err := s.resumeSubAgent(ctx, agentID); err != nil {
 		return "", &toolError{
 			Code:    "tool_invoke_failed",
 			Message: `tool "resume_agent" invocation failed`,
@@ -971,7 +972,7 @@ func (s *Server) closeSubAgent(agentID string) (managedSubAgentSnapshot, error)
 	return snapshot, nil
 }
 
-func (s *Server) startSubAgentTurn(agentID string, rawInput string) error {
+func (s *Server) startSubAgentTurn(parentCtx context.Context, agentID string, rawInput string) error {
 	agentID = strings.TrimSpace(agentID)
 	if agentID == "" {
 		return errMultiAgentIDRequired
@@ -1007,11 +1008,11 @@ func (s *Server) startSubAgentTurn(agentID string, rawInput string) error {
 
 	s.notifySubAgentUpdate(agent)
 
-	go s.runSubAgentTurn(runCtx, snapshot.AgentID, inputText)
+	go s.runSubAgentTurn(runCtx, observability.SpanContextFromContext(parentCtx), snapshot.AgentID, inputText)
 	return nil
 }
 
-func (s *Server) resumeSubAgent(agentID string) error {
+func (s *Server) resumeSubAgent(parentCtx context.Context, agentID string) error {
 	agentID = strings.TrimSpace(agentID)
 	if agentID == "" {
 		return errMultiAgentIDRequired
@@ -1047,10 +1048,10 @@ func (s *Server) resumeSubAgent(agentID string) error {
 	if nextInput == "" {
 		return nil
 	}
-	return s.startSubAgentTurn(agentID, nextInput)
+	return s.startSubAgentTurn(parentCtx, agentID, nextInput)
 }
 
-func (s *Server) runSubAgentTurn(ctx context.Context, agentID string, inputText string) {
+func (s *Server) runSubAgentTurn(ctx context.Context, parent observability.SpanContext, agentID string, inputText string) {
 	s.subAgentMu.Lock()
 	agent, exists := s.subAgents[agentID]
 	if !exists {
@@ -1088,7 +1089,20 @@ func (s *Server) runSubAgentTurn(ctx context.Context, agentID string, inputText
 	}
 	request.BizParams[requestUserInputMetaAgentDepthKey] = depth
 
+	// Sub-agent turns outlive the spawning turn, so they start their own trace
+	// and link back to the parent span instead of nesting under it.
+	ctx, span := observability.StartSpan(ctx, "subagent.turn", observability.WithLinks(parent), observability.WithAttributes(
+		"subagent.id", agentID,
+		"subagent.depth", depth,
+	))
+	if parent.IsValid() {
+		span.SetAttributes("subagent.parent_trace_id", parent.TraceID.String())
+	}
 	response, processErr := s.processAgentViaPort(ctx, request)
+	if processErr != nil {
+		span.SetStatus(observability.SpanStatusError, processErr.Message)
+	}
+	span.End()
 
 	s.subAgentMu.Lock()
 	current, exists := s.subAgents[agentID]
@@ -3,7 +3,10 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +31,9 @@ func (s *Server) createCronJob(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, http.StatusBadRequest, "invalid_json", "invalid request body", nil)
 		return
 	}
+	if !s.authorizeCronJobSave(w, r, &req) {
+		return
+	}
 	job, err := s.getCronService().CreateJob(req)
 	if err != nil {
 		if validation := (*cronservice.ValidationError)(nil); errors.As(err, &validation) {
@@ -65,6 +71,9 @@ func (s *Server) updateCronJob(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, http.StatusBadRequest, "job_id_mismatch", "job_id mismatch", nil)
 		return
 	}
+	if !s.authorizeCronJobSave(w, r, &req) {
+		return
+	}
 	job, err := s.getCronService().UpdateJob(id, req)
 	if err != nil {
 		if validation := (*cronservice.ValidationError)(nil); errors.As(err, &validation) {
@@ -105,7 +114,10 @@ func (s *Server) resumeCronJob(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) runCronJob(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "job_id")
-	if err := s.executeCronJob(id); err != nil {
+	if view, err := s.getCronService().GetJob(id); err == nil && !cronToolScopeAllowed(w, r, view.Spec) {
+		return
+	}
+	if err := s.executeCronJob(id, cronservice.TriggerManual); err != nil {
 		if errors.Is(err, errCronJobNotFound) {
 			writeErr(w, http.StatusNotFound, "not_found", "cron job not found", nil)
 			return
@@ -150,8 +162,125 @@ func (s *Server) updateCronStatus(w http.ResponseWriter, id, status string) {
 	writeJSON(w, http.StatusOK, map[string]bool{key: true})
 }
 
-func (s *Server) executeCronJob(id string) error {
-	return s.getCronService().ExecuteJob(id)
+func (s *Server) listCronRuns(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "job_id")
+	values := r.URL.Query()
+	limit, limitErr := parseCronRunQueryInt(values.Get("limit"))
+	offset, offsetErr := parseCronRunQueryInt(values.Get("offset"))
+	if limitErr != nil || offsetErr != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_cron_run_query", "limit and offset must be non-negative integers", nil)
+		return
+	}
+	query := cronservice.RunQuery{Status: values.Get("status"), Limit: limit, Offset: offset}
+	out, err := s.getCronService().ListRuns(id, query)
+	if err != nil {
+		if errors.Is(err, errCronJobNotFound) {
+			writeErr(w, http.StatusNotFound, "not_found", "cron job not found", nil)
+			return
+		}
+		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func parseCronRunQueryInt(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, errors.New("invalid integer")
+	}
+	return value, nil
+}
+
+func (s *Server) getCronRun(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "job_id")
+	run, err := s.getCronService().GetRun(id, chi.URLParam(r, "run_id"))
+	if err != nil {
+		if errors.Is(err, errCronJobNotFound) {
+			writeErr(w, http.StatusNotFound, "not_found", "cron job not found", nil)
+			return
+		}
+		if errors.Is(err, errCronRunNotFound) {
+			writeErr(w, http.StatusNotFound, "not_found", "cron run not found", nil)
+			return
+		}
+		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+func (s *Server) previewCronJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "job_id")
+	count, err := parseCronRunQueryInt(r.URL.Query().Get("count"))
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_cron_preview_query", "count must be a non-negative integer", nil)
+		return
+	}
+	preview, err := s.getCronService().PreviewSchedule(id, count, time.Now().UTC())
+	if err != nil {
+		if validation := (*cronservice.ValidationError)(nil); errors.As(err, &validation) {
+			writeErr(w, http.StatusBadRequest, validation.Code, validation.Message, nil)
+			return
+		}
+		if errors.Is(err, errCronJobNotFound) {
+			writeErr(w, http.StatusNotFound, "not_found", "cron job not found", nil)
+			return
+		}
+		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, preview)
+}
+
+func (s *Server) dryRunCronJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "job_id")
+	var req domain.CronDryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeErr(w, http.StatusBadRequest, "invalid_json", "invalid request body", nil)
+		return
+	}
+	execution, err := s.getCronService().DryRunJob(id, req)
+	if err != nil {
+		if validation := (*cronservice.ValidationError)(nil); errors.As(err, &validation) {
+			writeErr(w, http.StatusBadRequest, validation.Code, validation.Message, nil)
+			return
+		}
+		if errors.Is(err, errCronJobNotFound) {
+			writeErr(w, http.StatusNotFound, "not_found", "cron job not found", nil)
+			return
+		}
+		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, execution)
+}
+
+// authorizeCronJobSave rejects jobs that would run tools the caller may not
+// run, and records the caller's scopes for later scheduled runs.
+func (s *Server) authorizeCronJobSave(w http.ResponseWriter, r *http.Request, job *domain.CronJobSpec) bool {
+	if !cronToolScopeAllowed(w, r, *job) {
+		return false
+	}
+	job.CreatorScopes = append([]string(nil), requestPrincipal(r).Scopes...)
+	return true
+}
+
+func cronToolScopeAllowed(w http.ResponseWriter, r *http.Request, job domain.CronJobSpec) bool {
+	if !cronservice.RequiresToolScope(job) || requestPrincipal(r).HasScope(domain.APIKeyScopeToolsExecute) {
+		return true
+	}
+	writeErr(w, http.StatusForbidden, "forbidden",
+		fmt.Sprintf("cron jobs with tool_call or agent_prompt nodes require scope %q", domain.APIKeyScopeToolsExecute), nil)
+	return false
+}
+
+func (s *Server) executeCronJob(id, trigger string) error {
+	return s.getCronService().ExecuteJobWithTrigger(id, trigger)
 }
 
 func resolveCronNextRunAt(job domain.CronJobSpec, current *string, now time.Time) (time.Time, *time.Time, error) {
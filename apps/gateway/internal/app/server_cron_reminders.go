@@ -0,0 +1,75 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cronservice "nextai/apps/gateway/internal/service/cron"
+)
+
+const scheduleReminderToolName = "schedule_reminder"
+
+func (s *Server) executeScheduleReminderToolCall(input map[string]interface{}) (string, error) {
+	now := time.Now().UTC()
+	runAt, err := parseScheduleReminderTime(input, now)
+	if err != nil {
+		return "", scheduleReminderToolError(err)
+	}
+	job, err := s.getCronService().CreateReminder(cronservice.ReminderRequest{
+		Text:      stringValue(input["text"]),
+		RunAt:     runAt,
+		Timezone:  stringValue(input["timezone"]),
+		Channel:   stringValue(input[requestUserInputMetaChannelKey]),
+		UserID:    stringValue(input[requestUserInputMetaUserIDKey]),
+		SessionID: stringValue(input[requestUserInputMetaSessionIDKey]),
+	}, now)
+	if err != nil {
+		return "", scheduleReminderToolError(err)
+	}
+	return renderToolResult(scheduleReminderToolName, map[string]interface{}{
+		"ok":       true,
+		"job_id":   job.ID,
+		"run_at":   job.Schedule.RunAt,
+		"channel":  job.Dispatch.Channel,
+		"message":  job.Text,
+		"in":       runAt.Sub(now).Round(time.Second).String(),
+		"timezone": job.Schedule.Timezone,
+	})
+}
+
+func parseScheduleReminderTime(input map[string]interface{}, now time.Time) (time.Time, error) {
+	rawRunAt := strings.TrimSpace(stringValue(input["run_at"]))
+	rawDelay := strings.TrimSpace(stringValue(input["delay"]))
+	switch {
+	case rawRunAt != "" && rawDelay != "":
+		return time.Time{}, errors.New("set either run_at or delay, not both")
+	case rawRunAt != "":
+		runAt, err := time.Parse(time.RFC3339, rawRunAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("run_at %q must be RFC3339 with a UTC offset", rawRunAt)
+		}
+		return runAt, nil
+	case rawDelay != "":
+		delay, err := time.ParseDuration(rawDelay)
+		if err != nil || delay <= 0 {
+			return time.Time{}, fmt.Errorf("delay %q must be a positive duration such as 45m or 2h30m", rawDelay)
+		}
+		return now.Add(delay), nil
+	default:
+		return time.Time{}, errors.New("run_at or delay is required")
+	}
+}
+
+func scheduleReminderToolError(err error) error {
+	message := fmt.Sprintf("tool %q invocation failed", scheduleReminderToolName)
+	if errors.Is(err, cronservice.ErrReminderQuotaExceeded) {
+		message = fmt.Sprintf("tool %q invocation failed: reminder quota exceeded", scheduleReminderToolName)
+	}
+	return &toolError{
+		Code:    "tool_invoke_failed",
+		Message: message,
+		Err:     err,
+	}
+}
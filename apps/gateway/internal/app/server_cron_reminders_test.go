@@ -0,0 +1,50 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/repo"
+)
+
+func TestScheduleReminderToolCreatesOnceJobForCurrentChat(t *testing.T) {
+	srv := newTestServer(t)
+
+	out, err := srv.executeToolCallForPromptMode(promptModeDefault, toolCall{Name: scheduleReminderToolName, Input: map[string]interface{}{
+		"text":                           "drink water",
+		"delay":                          "30m",
+		requestUserInputMetaChannelKey:   "console",
+		requestUserInputMetaUserIDKey:    "u-1",
+		requestUserInputMetaSessionIDKey: "s-1",
+	}})
+	if err != nil || !strings.Contains(out, `"job_id":"reminder-`) {
+		t.Fatalf("unexpected tool result out=%q err=%v", out, err)
+	}
+
+	var reminders []domain.CronJobSpec
+	srv.store.Read(func(state *repo.State) {
+		for _, job := range state.CronJobs {
+			if strings.HasPrefix(job.ID, "reminder-") {
+				reminders = append(reminders, job)
+			}
+		}
+	})
+	if len(reminders) != 1 {
+		t.Fatalf("expected one reminder job, got=%+v", reminders)
+	}
+	job := reminders[0]
+	if job.Schedule.Type != "once" || job.Dispatch.Target.UserID != "u-1" || job.Dispatch.Target.SessionID != "s-1" || job.Text != "Reminder: drink water" {
+		t.Fatalf("unexpected reminder job: %+v", job)
+	}
+
+	_, err = srv.executeToolCallForPromptMode(promptModeDefault, toolCall{Name: scheduleReminderToolName, Input: map[string]interface{}{
+		"text":                        "no time",
+		requestUserInputMetaUserIDKey: "u-1",
+	}})
+	var toolErr *toolError
+	if !errors.As(err, &toolErr) || toolErr.Err == nil || !strings.Contains(toolErr.Err.Error(), "run_at or delay") {
+		t.Fatalf("expected missing time error, got=%v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"nextai/apps/gateway/internal/domain"
 	"nextai/apps/gateway/internal/service/adapters"
@@ -12,6 +13,8 @@ import (
 	"nextai/apps/gateway/internal/service/ports"
 )
 
+const cronWorkflowDefaultUserID = "cron"
+
 func (s *Server) getCronService() *cronservice.Service {
 	if s.cronService == nil {
 		s.cronService = s.newCronService()
@@ -24,16 +27,26 @@ func (s *Server) newCronService() *cronservice.Service {
 		ProcessFunc: s.processAgentViaPort,
 	}
 	return cronservice.NewService(cronservice.Dependencies{
-		Store:   s.stateStore,
-		DataDir: s.cfg.DataDir,
+		Store:              s.stateStore,
+		DataDir:            s.cfg.DataDir,
+		RunHistoryLimit:    s.cfg.CronRunHistoryLimit,
+		RunHistoryMaxAge:   time.Duration(s.cfg.CronRunHistoryMaxAgeDays) * 24 * time.Hour,
+		ReminderMaxPerUser: s.cfg.CronReminderMaxPerUser,
+		EmitEvent:          s.fireCronTriggers,
+		Logger:             s.log(),
+		Stop:               s.cronStop,
 		ChannelResolver: adapters.ChannelResolver{
 			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
 				return s.resolveChannel(name)
 			},
 		},
-		ExecuteConsoleAgentTask: func(ctx context.Context, job domain.CronJobSpec, text string) error {
-			return s.executeCronConsoleAgentTask(ctx, agentProcessor, job, text)
+		ExecuteAgentTask: func(ctx context.Context, job domain.CronJobSpec, channel string, text string) (string, error) {
+			return s.executeCronAgentTask(ctx, agentProcessor, job, channel, text)
+		},
+		ExecuteAgentPrompt: func(ctx context.Context, job domain.CronJobSpec, prompt cronservice.AgentPrompt) (string, error) {
+			return s.executeCronAgentPrompt(ctx, agentProcessor, job, prompt)
 		},
+		ExecuteToolCall: s.executeCronToolCall,
 		ExecuteTask: func(ctx context.Context, job domain.CronJobSpec) (bool, error) {
 			if s.cronTaskExecutor == nil {
 				return false, nil
@@ -43,21 +56,22 @@ func (s *Server) newCronService() *cronservice.Service {
 	})
 }
 
-func (s *Server) executeCronConsoleAgentTask(
+func (s *Server) executeCronAgentTask(
 	ctx context.Context,
 	agentProcessor ports.AgentProcessor,
 	job domain.CronJobSpec,
+	channel string,
 	text string,
-) error {
+) (string, error) {
 	sessionID := strings.TrimSpace(job.Dispatch.Target.SessionID)
 	userID := strings.TrimSpace(job.Dispatch.Target.UserID)
 	if sessionID == "" || userID == "" {
-		return errors.New("cron dispatch target requires non-empty session_id and user_id")
+		return "", errors.New("cron dispatch target requires non-empty session_id and user_id")
 	}
 
 	text = strings.TrimSpace(text)
 	if text == "" {
-		return nil
+		return "", nil
 	}
 
 	agentReq := domain.AgentProcessRequest{
@@ -72,22 +86,123 @@ func (s *Server) executeCronConsoleAgentTask(
 		},
 		SessionID: sessionID,
 		UserID:    userID,
-		Channel:   "console",
+		Channel:   channel,
 		Stream:    false,
-		BizParams: cronservice.BuildBizParams(job),
+		BizParams: buildCronAgentBizParams(job, channel),
 	}
 
 	if agentProcessor == nil {
-		return errors.New("cron console agent processor is unavailable")
+		return "", errors.New("cron agent processor is unavailable")
 	}
-	if _, processErr := agentProcessor.Process(ctx, agentReq); processErr != nil {
-		return fmt.Errorf(
-			"cron console agent execution failed: status=%d code=%s message=%s",
+	resp, processErr := agentProcessor.Process(ctx, agentReq)
+	if processErr != nil {
+		return "", fmt.Errorf(
+			"cron %s agent execution failed: status=%d code=%s message=%s",
+			channel,
 			processErr.Status,
 			strings.TrimSpace(processErr.Code),
 			strings.TrimSpace(processErr.Message),
 		)
 	}
+	return resp.Reply, nil
+}
+
+func buildCronAgentBizParams(job domain.CronJobSpec, channel string) map[string]interface{} {
+	bizParams := cronservice.BuildBizParams(job)
+	if channel != "qq" {
+		return bizParams
+	}
+	if bizParams == nil {
+		bizParams = map[string]interface{}{}
+	}
+	target := map[string]interface{}{}
+	if targetType := strings.TrimSpace(job.Dispatch.Target.TargetType); targetType != "" {
+		target["target_type"] = targetType
+	}
+	if targetID := strings.TrimSpace(job.Dispatch.Target.TargetID); targetID != "" {
+		target["target_id"] = targetID
+	}
+	if len(target) > 0 {
+		bizParams["channel"] = target
+	}
+	return bizParams
+}
 
-	return nil
+func (s *Server) executeCronAgentPrompt(
+	ctx context.Context,
+	agentProcessor ports.AgentProcessor,
+	job domain.CronJobSpec,
+	prompt cronservice.AgentPrompt,
+) (string, error) {
+	if agentProcessor == nil {
+		return "", errors.New("cron agent processor is unavailable")
+	}
+	userID := strings.TrimSpace(job.Dispatch.Target.UserID)
+	if userID == "" {
+		userID = cronWorkflowDefaultUserID
+	}
+	bizParams := cronservice.BuildBizParams(job)
+	if bizParams == nil {
+		bizParams = map[string]interface{}{}
+	}
+	if mode := strings.TrimSpace(prompt.PromptMode); mode != "" {
+		bizParams[chatMetaPromptModeKey] = mode
+	}
+	if prompt.ProviderID != "" && prompt.Model != "" {
+		bizParams[bizParamsActiveLLMKey] = map[string]interface{}{
+			"provider_id": prompt.ProviderID,
+			"model":       prompt.Model,
+		}
+	}
+
+	resp, processErr := agentProcessor.Process(ctx, domain.AgentProcessRequest{
+		Input: []domain.AgentInputMessage{
+			{
+				Role: "user",
+				Type: "message",
+				Content: []domain.RuntimeContent{
+					{Type: "text", Text: prompt.Prompt},
+				},
+			},
+		},
+		SessionID: cronAgentPromptSessionID(job.ID, prompt),
+		UserID:    userID,
+		Channel:   defaultProcessChannel,
+		Stream:    false,
+		BizParams: bizParams,
+	})
+	if processErr != nil {
+		return "", fmt.Errorf(
+			"cron agent_prompt %s failed: status=%d code=%s message=%s",
+			prompt.NodeID,
+			processErr.Status,
+			strings.TrimSpace(processErr.Code),
+			strings.TrimSpace(processErr.Message),
+		)
+	}
+	return resp.Reply, nil
+}
+
+// cronAgentPromptSessionID scopes the node's chat to one run so later runs
+// start from a clean context instead of replaying every earlier reply.
+func cronAgentPromptSessionID(jobID string, prompt cronservice.AgentPrompt) string {
+	jobID = strings.TrimSpace(jobID)
+	if prompt.RunID == "" {
+		return fmt.Sprintf("cron:%s:%s", jobID, prompt.NodeID)
+	}
+	return fmt.Sprintf("cron:%s:%s:%s", jobID, prompt.RunID, prompt.NodeID)
+}
+
+func (s *Server) executeCronToolCall(ctx context.Context, name string, input map[string]interface{}) (string, error) {
+	if !toolsAllowed(ctx) {
+		return "", fmt.Errorf("cron tool_call %q requires the job creator to hold scope %q", name, domain.APIKeyScopeToolsExecute)
+	}
+	if err := validateShellToolSandboxPermissions(ctx, name, input); err != nil {
+		return "", err
+	}
+	result, err := s.invokeRegisteredTool(name, input)
+	if err != nil {
+		return "", err
+	}
+	return renderToolResult(name, result)
 }
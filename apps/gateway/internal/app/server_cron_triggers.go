@@ -0,0 +1,112 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+	cronservice "nextai/apps/gateway/internal/service/cron"
+)
+
+const (
+	cronWebhookMaxBodyBytes    = 1 << 20
+	cronWebhookSecretHeader    = "X-NextAI-Webhook-Secret"
+	cronWebhookSignatureHeader = "X-NextAI-Webhook-Signature"
+)
+
+func (s *Server) triggerCronWebhook(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "job_id")
+	body, err := io.ReadAll(io.LimitReader(r.Body, cronWebhookMaxBodyBytes))
+	if err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_request", "failed to read request body", nil)
+		return
+	}
+	// The credential travels in a header so it never reaches request logs or
+	// span attributes, which record the path.
+	var event cronservice.TriggerEvent
+	if signature := r.Header.Get(cronWebhookSignatureHeader); signature != "" {
+		event, err = s.getCronService().ResolveSignedWebhookTrigger(id, signature, body)
+	} else {
+		event, err = s.getCronService().ResolveWebhookTrigger(id, r.Header.Get(cronWebhookSecretHeader), body)
+	}
+	if err != nil {
+		if errors.Is(err, cronservice.ErrTriggerNotFound) {
+			writeErr(w, http.StatusNotFound, "not_found", "cron webhook not found", nil)
+			return
+		}
+		if errors.Is(err, cronservice.ErrTriggerInactive) {
+			writeErr(w, http.StatusConflict, "cron_job_inactive", "cron job is disabled or paused", nil)
+			return
+		}
+		writeErr(w, http.StatusInternalServerError, "store_error", err.Error(), nil)
+		return
+	}
+	s.startTriggeredCronJob(cronservice.TriggeredJob{JobID: id, Event: event})
+	writeJSON(w, http.StatusAccepted, map[string]bool{"accepted": true})
+}
+
+func (s *Server) fireCronTriggers(event cronservice.TriggerEvent) {
+	matched, err := s.getCronService().MatchTriggers(event)
+	if err != nil {
+		s.log().Error("cron trigger match failed", "trigger_type", event.Type, "event", event.Event, "err", err)
+		return
+	}
+	for _, triggered := range matched {
+		s.startTriggeredCronJob(triggered)
+	}
+}
+
+func (s *Server) startTriggeredCronJob(triggered cronservice.TriggeredJob) {
+	s.cronWG.Add(1)
+	go func() {
+		defer s.cronWG.Done()
+		if err := s.getCronService().ExecuteJobWithEvent(triggered.JobID, triggered.Event); err != nil &&
+			!errors.Is(err, errCronJobNotFound) &&
+			!errors.Is(err, errCronMaxConcurrencyReached) {
+			s.log().Error("cron job execute failed", observability.LogKeyJobID, triggered.JobID, "trigger_type", triggered.Event.Type, "err", err)
+		}
+	}()
+}
+
+func (s *Server) fireCronMessageTriggers(req domain.AgentProcessRequest) {
+	if isCronOriginatedRequest(req) {
+		return
+	}
+	text := latestUserInputText(req.Input)
+	if text == "" {
+		return
+	}
+	s.fireCronTriggers(cronservice.TriggerEvent{
+		Type:      cronservice.TriggerMessage,
+		Source:    req.Channel,
+		Channel:   req.Channel,
+		UserID:    req.UserID,
+		SessionID: req.SessionID,
+		Text:      text,
+	})
+}
+
+func (s *Server) fireCronSystemEvent(req domain.AgentProcessRequest, name, source, text, message string) {
+	if isCronOriginatedRequest(req) {
+		return
+	}
+	s.fireCronTriggers(cronservice.TriggerEvent{
+		Type:      cronservice.TriggerSystemEvent,
+		Event:     name,
+		Source:    source,
+		Channel:   req.Channel,
+		UserID:    req.UserID,
+		SessionID: req.SessionID,
+		Text:      text,
+		Error:     message,
+	})
+}
+
+func isCronOriginatedRequest(req domain.AgentProcessRequest) bool {
+	_, ok := req.BizParams["cron"]
+	return ok
+}
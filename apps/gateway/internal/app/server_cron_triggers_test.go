@@ -0,0 +1,85 @@
+package app
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"nextai/apps/gateway/internal/config"
+	cronservice "nextai/apps/gateway/internal/service/cron"
+)
+
+type lockedLogBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedLogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedLogBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCronWebhookAuthenticatesByHeaderAndKeepsSecretOutOfLogs(t *testing.T) {
+	t.Setenv("NEXTAI_DISABLE_QQ_INBOUND_SUPERVISOR", "true")
+	logs := &lockedLogBuffer{}
+	srv, err := NewServer(
+		config.Config{Host: "127.0.0.1", Port: "0", DataDir: t.TempDir()},
+		WithLogger(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	const secret = "hook-secret-0123456789"
+	job := `{"id":"job-hook","name":"job-hook","enabled":true,"schedule":{"type":"manual"},"task_type":"text","text":"deployed",` +
+		`"dispatch":{"channel":"console","mode":"text","target":{"user_id":"u1","session_id":"s1"}},` +
+		`"triggers":[{"type":"webhook","secret":"` + secret + `"}]}`
+	createW := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(createW, httptest.NewRequest(http.MethodPost, "/cron/jobs", strings.NewReader(job)))
+	if createW.Code != http.StatusOK {
+		t.Fatalf("create job status=%d body=%s", createW.Code, createW.Body.String())
+	}
+
+	trigger := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/cron/job-hook", strings.NewReader(`{"ref":"main"}`))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+	signature := "sha256=" + hex.EncodeToString(cronservice.WebhookSignature(secret, []byte(`{"ref":"main"}`)))
+	for _, tc := range []struct {
+		header, value string
+		want          int
+	}{
+		{"", "", http.StatusNotFound},
+		{cronWebhookSecretHeader, "wrong-secret-0123456789", http.StatusNotFound},
+		{cronWebhookSignatureHeader, "sha256=" + hex.EncodeToString([]byte("forged")), http.StatusNotFound},
+		{cronWebhookSecretHeader, secret, http.StatusAccepted},
+		{cronWebhookSignatureHeader, signature, http.StatusAccepted},
+	} {
+		if got := trigger(tc.header, tc.value); got != tc.want {
+			t.Fatalf("%s=%q: status=%d want=%d", tc.header, tc.value, got, tc.want)
+		}
+	}
+
+	srv.Close()
+	if strings.Contains(logs.String(), secret) {
+		t.Fatalf("webhook secret leaked into logs:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,240 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/provider"
+	"nextai/apps/gateway/internal/repo"
+	healthservice "nextai/apps/gateway/internal/service/health"
+)
+
+const (
+	cronSchedulerHealthName       = "scheduler"
+	cronLivenessTimeout           = 30 * time.Second
+	defaultProviderProbeInterval  = 60 * time.Second
+	providerProbeStartDelay       = 5 * time.Second
+	providerFailoverDetailPrefix  = "failover to "
+	providerActiveHealthDetail    = "active"
+	qqInboundDisconnectedDetail   = "inbound gateway disconnected"
+	cronSchedulerStalledErrorText = "no successful scheduler tick within liveness window"
+)
+
+func (s *Server) getHealthService() *healthservice.Service {
+	if s.healthService == nil {
+		s.healthService = s.newHealthService()
+	}
+	return s.healthService
+}
+
+func (s *Server) newHealthService() *healthservice.Service {
+	return healthservice.NewService(healthservice.Dependencies{
+		HTTPClient: &http.Client{},
+		Logger:     s.log(),
+	})
+}
+
+func (s *Server) providerProbeInterval() time.Duration {
+	if s.cfg.ProviderProbeIntervalSeconds > 0 {
+		return time.Duration(s.cfg.ProviderProbeIntervalSeconds) * time.Second
+	}
+	return defaultProviderProbeInterval
+}
+
+// providerProbeTargets lists enabled providers that can be reached: both an
+// API key and a base URL are required, anything else would only probe config.
+func (s *Server) providerProbeTargets() []healthservice.ProviderTarget {
+	targets := []healthservice.ProviderTarget{}
+	s.store.Read(func(st *repo.State) {
+		for rawID, setting := range st.Providers {
+			providerID := normalizeProviderID(rawID)
+			if providerID == "" || !providerEnabled(setting) {
+				continue
+			}
+			apiKey := resolveProviderAPIKey(providerID, setting)
+			baseURL := resolveProviderBaseURL(providerID, setting)
+			if apiKey == "" || baseURL == "" {
+				continue
+			}
+			targets = append(targets, healthservice.ProviderTarget{
+				ID:      providerID,
+				BaseURL: baseURL,
+				APIKey:  apiKey,
+				Headers: sanitizeStringMap(setting.Headers),
+				Timeout: time.Duration(setting.TimeoutMS) * time.Millisecond,
+			})
+		}
+	})
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ID < targets[j].ID })
+	return targets
+}
+
+func (s *Server) startProviderProbes() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cronWG.Add(1)
+	go func() {
+		defer s.cronWG.Done()
+		defer cancel()
+		go func() {
+			<-s.cronStop
+			cancel()
+		}()
+		// The first probe waits briefly so startup, and short-lived test
+		// servers, never block on or call out to provider endpoints.
+		timer := time.NewTimer(min(providerProbeStartDelay, s.providerProbeInterval()))
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				s.getHealthService().ProbeProviders(ctx, s.providerProbeTargets())
+				timer.Reset(s.providerProbeInterval())
+			case <-s.cronStop:
+				return
+			}
+		}
+	}()
+}
+
+// failoverModelSlot returns the first configured failover slot when the
+// active provider failed its latest probe. Entries are "provider[/model]";
+// without a model the provider's default model is used.
+func (s *Server) failoverModelSlot(st *repo.State, active domain.ModelSlotConfig) (domain.ModelSlotConfig, bool) {
+	if len(s.cfg.ProviderFailover) == 0 || active.ProviderID == "" {
+		return domain.ModelSlotConfig{}, false
+	}
+	health := s.getHealthService()
+	if health.ProviderHealthy(active.ProviderID) {
+		return domain.ModelSlotConfig{}, false
+	}
+	for _, entry := range s.cfg.ProviderFailover {
+		providerID, model, _ := strings.Cut(strings.TrimSpace(entry), "/")
+		providerID = normalizeProviderID(providerID)
+		model = strings.TrimSpace(model)
+		if providerID == "" || providerID == active.ProviderID {
+			continue
+		}
+		setting, ok := findProviderSettingByID(st, providerID)
+		if !ok || !providerEnabled(setting) || resolveProviderAPIKey(providerID, setting) == "" {
+			continue
+		}
+		if !health.ProviderHealthy(providerID) {
+			continue
+		}
+		if model == "" {
+			model = provider.DefaultModelID(providerID)
+		}
+		if model == "" {
+			continue
+		}
+		return domain.ModelSlotConfig{ProviderID: providerID, Model: model}, true
+	}
+	return domain.ModelSlotConfig{}, false
+}
+
+func (s *Server) buildHealthStatus() domain.HealthStatus {
+	health := s.getHealthService()
+	checks := []domain.HealthCheck{}
+
+	_ = health.ProbeStore(s.cfg.DataDir)
+	storeCheck := health.Check(healthservice.KindStore, "state")
+	storeCheck.Critical = true
+	checks = append(checks, storeCheck)
+
+	cronCheck := health.Check(healthservice.KindCron, cronSchedulerHealthName)
+	cronCheck.Critical = true
+	if rec, ok := health.Get(healthservice.KindCron, cronSchedulerHealthName); ok && time.Since(rec.LastSuccess) > cronLivenessTimeout {
+		cronCheck.Status = domain.HealthStatusDown
+		if cronCheck.LastError == "" {
+			cronCheck.LastError = cronSchedulerStalledErrorText
+		}
+	}
+	checks = append(checks, cronCheck)
+
+	checks = append(checks, s.providerHealthChecks()...)
+	checks = append(checks, s.channelHealthChecks()...)
+	return healthservice.Summarize(checks, time.Now())
+}
+
+// providerHealthChecks marks the active provider critical unless a healthy
+// failover target would take over its turns.
+func (s *Server) providerHealthChecks() []domain.HealthCheck {
+	health := s.getHealthService()
+	active := domain.ModelSlotConfig{}
+	failover, hasFailover := domain.ModelSlotConfig{}, false
+	s.store.Read(func(st *repo.State) {
+		active = st.ActiveLLM
+		failover, hasFailover = s.failoverModelSlot(st, active)
+	})
+
+	checks := []domain.HealthCheck{}
+	for _, target := range s.providerProbeTargets() {
+		check := health.Check(healthservice.KindProvider, target.ID)
+		if target.ID == active.ProviderID {
+			check.Detail = providerActiveHealthDetail
+			check.Critical = !hasFailover
+			if hasFailover {
+				check.Detail = providerFailoverDetailPrefix + failover.ProviderID
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func (s *Server) channelHealthChecks() []domain.HealthCheck {
+	health := s.getHealthService()
+	enabled := []string{}
+	s.store.Read(func(st *repo.State) {
+		for name := range s.channels {
+			if channelEnabled(name, cloneChannelConfig(st.Channels[name])) {
+				enabled = append(enabled, name)
+			}
+		}
+	})
+	sort.Strings(enabled)
+
+	_, qqInbound := s.loadQQInboundConfig()
+	checks := []domain.HealthCheck{}
+	for _, name := range enabled {
+		check := health.Check(healthservice.KindChannel, name)
+		if name == "qq" && qqInbound {
+			runtime := s.snapshotQQInboundState()
+			check.LastSuccessAt = runtime.LastConnectedAt
+			check.LastFailureAt = runtime.LastErrorAt
+			check.LastError = runtime.LastError
+			check.Status = domain.HealthStatusOK
+			if !runtime.Running || !runtime.Connected {
+				check.Status = domain.HealthStatusDown
+				check.Detail = qqInboundDisconnectedDetail
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// handleReadyz is public, so it only exposes check names and statuses.
+func (s *Server) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	status := s.buildHealthStatus()
+	for i := range status.Checks {
+		status.Checks[i] = domain.HealthCheck{
+			Kind:     status.Checks[i].Kind,
+			Name:     status.Checks[i].Name,
+			Status:   status.Checks[i].Status,
+			Critical: status.Checks[i].Critical,
+		}
+	}
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.buildHealthStatus())
+}
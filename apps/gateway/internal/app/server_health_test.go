@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nextai/apps/gateway/internal/config"
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/repo"
+	healthservice "nextai/apps/gateway/internal/service/health"
+)
+
+func newProbeTestProvider(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			t.Errorf("unexpected probe path: %s", r.URL.Path)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getHealthStatusForTest(t *testing.T, srv *Server, path string, wantCode int) domain.HealthStatus {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code != wantCode {
+		t.Fatalf("%s status=%d body=%s", path, w.Code, w.Body.String())
+	}
+	var status domain.HealthStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return status
+}
+
+func findHealthCheck(status domain.HealthStatus, kind, name string) (domain.HealthCheck, bool) {
+	for _, check := range status.Checks {
+		if check.Kind == kind && check.Name == name {
+			return check, true
+		}
+	}
+	return domain.HealthCheck{}, false
+}
+
+func TestReadyzFailsWhenActiveProviderProbeFails(t *testing.T) {
+	srv := newTestServer(t)
+	status := getHealthStatusForTest(t, srv, "/readyz", http.StatusOK)
+	if !status.Ready {
+		t.Fatalf("expected ready without providers: %+v", status)
+	}
+	if check, ok := findHealthCheck(status, healthservice.KindStore, "state"); !ok || check.Status != domain.HealthStatusOK || !check.Critical {
+		t.Fatalf("unexpected store check: %+v", status.Checks)
+	}
+
+	configureOpenAIProviderForTest(t, srv, newProbeTestProvider(t, http.StatusUnauthorized).URL)
+	srv.getHealthService().ProbeProviders(context.Background(), srv.providerProbeTargets())
+
+	status = getHealthStatusForTest(t, srv, "/readyz", http.StatusServiceUnavailable)
+	check, ok := findHealthCheck(status, healthservice.KindProvider, "openai")
+	if status.Ready || status.Status != domain.HealthStatusDown || !ok || check.Status != domain.HealthStatusDown || !check.Critical {
+		t.Fatalf("unexpected readiness: %+v", status)
+	}
+	if check.LastError != "" || check.LastFailureAt != "" {
+		t.Fatalf("readyz must not expose probe details: %+v", check)
+	}
+
+	status = getHealthStatusForTest(t, srv, "/status", http.StatusOK)
+	check, _ = findHealthCheck(status, healthservice.KindProvider, "openai")
+	if check.LastFailureAt == "" || !strings.Contains(check.LastError, "401") || check.Detail != providerActiveHealthDetail {
+		t.Fatalf("unexpected status check: %+v", check)
+	}
+}
+
+func TestProviderFailoverSkipsUnhealthyActiveProvider(t *testing.T) {
+	srv := newRateLimitedTestServer(t, config.Config{ProviderFailover: []string{"openai", "backup/backup-model"}})
+	configureOpenAIProviderForTest(t, srv, newProbeTestProvider(t, http.StatusUnauthorized).URL)
+	backupConfig := `{"api_key":"sk-backup","base_url":"` + newProbeTestProvider(t, http.StatusOK).URL + `"}`
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/models/backup/config", strings.NewReader(backupConfig)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("config backup status=%d body=%s", w.Code, w.Body.String())
+	}
+
+	srv.getHealthService().ProbeProviders(context.Background(), srv.providerProbeTargets())
+	var failover domain.ModelSlotConfig
+	var ok bool
+	srv.store.Read(func(st *repo.State) {
+		failover, ok = srv.failoverModelSlot(st, st.ActiveLLM)
+	})
+	if !ok || failover.ProviderID != "backup" || failover.Model != "backup-model" {
+		t.Fatalf("unexpected failover: ok=%v slot=%+v", ok, failover)
+	}
+
+	status := getHealthStatusForTest(t, srv, "/readyz", http.StatusOK)
+	if !status.Ready || status.Status != domain.HealthStatusDegraded {
+		t.Fatalf("failover should keep the gateway ready but degraded: %+v", status)
+	}
+	status = getHealthStatusForTest(t, srv, "/status", http.StatusOK)
+	if check, _ := findHealthCheck(status, healthservice.KindProvider, "openai"); check.Critical || check.Detail != "failover to backup" {
+		t.Fatalf("unexpected active provider check: %+v", check)
+	}
+}
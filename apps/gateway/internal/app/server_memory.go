@@ -6,13 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/runner"
 	systempromptservice "nextai/apps/gateway/internal/service/systemprompt"
 )
@@ -213,7 +213,7 @@ func (s *Server) startCodexMemoryPipeline(
 		s.memoryMu.Lock()
 		defer s.memoryMu.Unlock()
 		if err := s.runCodexMemoryPipeline(context.Background(), sessionID, generateConfig, rolloutContents); err != nil {
-			log.Printf("warning: codex memory pipeline failed for session %q: %v", sessionID, err)
+			s.log().Warn("codex memory pipeline failed", observability.LogKeySessionID, sessionID, "err", err)
 		}
 	}()
 }
@@ -277,7 +277,7 @@ func (s *Server) runCodexMemoryPipeline(
 	); err != nil {
 		return err
 	}
-	log.Printf("info: codex memory pipeline completed for session %q", sessionID)
+	s.log().Info("codex memory pipeline completed", observability.LogKeySessionID, sessionID)
 	return nil
 }
 
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"nextai/apps/gateway/internal/observability"
+	"nextai/apps/gateway/internal/plugin"
+	healthservice "nextai/apps/gateway/internal/service/health"
+	"nextai/apps/gateway/internal/service/ports"
+)
+
+func (s *Server) newRuntimeMetrics() *observability.Registry {
+	registry := observability.NewRegistry()
+	registry.NewGaugeFunc("nextai_subagents_active", "Sub-agents that have not been closed.", func() float64 {
+		return float64(s.countSubAgents(func(status string) bool { return status != managedSubAgentStatusClosed }))
+	})
+	registry.NewGaugeFunc("nextai_subagents_running", "Sub-agents currently running a turn.", func() float64 {
+		return float64(s.countSubAgents(func(status string) bool { return status == managedSubAgentStatusRunning }))
+	})
+	registry.NewGaugeFunc("nextai_user_input_waiters_pending", "request_user_input calls waiting for an answer.", func() float64 {
+		s.userInputMu.Lock()
+		defer s.userInputMu.Unlock()
+		return float64(len(s.pendingUserInput))
+	})
+	registry.NewGaugeFunc("nextai_qq_gateway_running", "Whether the QQ inbound supervisor is running.", func() float64 {
+		return observability.BoolGauge(s.snapshotQQInboundState().Running)
+	})
+	registry.NewGaugeFunc("nextai_qq_gateway_connected", "Whether the QQ gateway websocket is connected.", func() float64 {
+		return observability.BoolGauge(s.snapshotQQInboundState().Connected)
+	})
+	return registry
+}
+
+func (s *Server) countSubAgents(match func(status string) bool) int {
+	s.subAgentMu.Lock()
+	defer s.subAgentMu.Unlock()
+	count := 0
+	for _, agent := range s.subAgents {
+		if match(agent.Status) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Server) getMetrics(w http.ResponseWriter, r *http.Request) {
+	observability.MetricsHandler(observability.DefaultRegistry, s.runtimeMetrics)(w, r)
+}
+
+// meteredChannel records send metrics and spans for every registered channel plugin.
+type meteredChannel struct {
+	plugin.ChannelPlugin
+	name   string
+	health *healthservice.Service
+}
+
+func (c meteredChannel) SendText(ctx context.Context, userID, sessionID, text string, cfg map[string]interface{}) error {
+	ctx, span := observability.StartSpan(ctx, "channel.send", observability.WithSpanKind(observability.SpanKindClient),
+		observability.WithAttributes("channel.name", c.name, "channel.session_id", sessionID))
+	defer span.End()
+	started := time.Now()
+	err := c.ChannelPlugin.SendText(ctx, userID, sessionID, text, cfg)
+	result := observability.MetricResultOK
+	if err != nil {
+		result = observability.MetricResultError
+	}
+	observability.ChannelSendsTotal.Inc(c.name, result)
+	observability.ChannelSendDuration.ObserveDuration(started, c.name)
+	if c.health != nil {
+		c.health.Observe(healthservice.KindChannel, c.name, time.Since(started), err)
+	}
+	span.SetError(err)
+	return err
+}
+
+func (c meteredChannel) SupportsProactive() bool {
+	proactive, ok := c.ChannelPlugin.(ports.ProactiveChannel)
+	return ok && proactive.SupportsProactive()
+}
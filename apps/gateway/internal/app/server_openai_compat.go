@@ -0,0 +1,477 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/repo"
+)
+
+const (
+	openAICompatAutoModel     = "nextai"
+	openAICompatOwner         = "nextai"
+	openAICompatSessionHeader = "X-NextAI-Session-Id"
+	openAICompatDefaultUserID = "openai-client"
+)
+
+type openAICompatChatRequest struct {
+	Model               string                 `json:"model"`
+	Messages            []openAICompatMessage  `json:"messages"`
+	Stream              bool                   `json:"stream"`
+	Temperature         *float64               `json:"temperature,omitempty"`
+	TopP                *float64               `json:"top_p,omitempty"`
+	MaxTokens           *int                   `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int                   `json:"max_completion_tokens,omitempty"`
+	Stop                json.RawMessage        `json:"stop,omitempty"`
+	Seed                *int64                 `json:"seed,omitempty"`
+	N                   *int                   `json:"n,omitempty"`
+	ResponseFormat      *domain.ResponseFormat `json:"response_format,omitempty"`
+	User                string                 `json:"user,omitempty"`
+}
+
+type openAICompatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+	Name    string          `json:"name,omitempty"`
+}
+
+type openAICompatContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type openAICompatCompletion struct {
+	ID      string                  `json:"id"`
+	Object  string                  `json:"object"`
+	Created int64                   `json:"created"`
+	Model   string                  `json:"model"`
+	Choices []openAICompatChoice    `json:"choices"`
+	Usage   *domain.AgentTokenUsage `json:"usage,omitempty"`
+	Parsed  interface{}             `json:"parsed,omitempty"`
+}
+
+type openAICompatChoice struct {
+	Index        int                `json:"index"`
+	Message      *openAICompatReply `json:"message,omitempty"`
+	Delta        *openAICompatReply `json:"delta,omitempty"`
+	FinishReason *string            `json:"finish_reason"`
+}
+
+type openAICompatReply struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type openAICompatModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type openAICompatModelList struct {
+	Object string              `json:"object"`
+	Data   []openAICompatModel `json:"data"`
+}
+
+type openAICompatErrorBody struct {
+	Error openAICompatError `json:"error"`
+}
+
+type openAICompatError struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Code    string  `json:"code"`
+	Param   *string `json:"param"`
+}
+
+type openAICompatRequestError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *openAICompatRequestError) Error() string {
+	return e.Message
+}
+
+func (s *Server) openAIListModels(w http.ResponseWriter, _ *http.Request) {
+	providers, _, _ := s.collectProviderCatalog()
+	out := openAICompatModelList{
+		Object: "list",
+		Data: []openAICompatModel{{
+			ID:      openAICompatAutoModel,
+			Object:  "model",
+			OwnedBy: openAICompatOwner,
+		}},
+	}
+	for _, item := range providers {
+		if !item.Enabled {
+			continue
+		}
+		for _, model := range item.Models {
+			out.Data = append(out.Data, openAICompatModel{
+				ID:      item.ID + "/" + model.ID,
+				Object:  "model",
+				OwnedBy: item.ID,
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) openAIChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var body openAICompatChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeOpenAICompatErr(w, http.StatusBadRequest, "invalid_json", "invalid request body")
+		return
+	}
+	req, stateful, err := s.buildOpenAICompatProcessRequest(r.Context(), body, strings.TrimSpace(r.Header.Get(openAICompatSessionHeader)))
+	if err != nil {
+		var reqErr *openAICompatRequestError
+		if errors.As(err, &reqErr) {
+			writeOpenAICompatErr(w, reqErr.Status, reqErr.Code, reqErr.Message)
+			return
+		}
+		writeOpenAICompatErr(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if !stateful {
+		defer func() {
+			_ = s.clearChatContext(req.SessionID, req.UserID, req.Channel)
+		}()
+	}
+
+	completionID := newID("chatcmpl")
+	created := time.Now().Unix()
+	model := strings.TrimSpace(body.Model)
+	if model == "" {
+		model = openAICompatAutoModel
+	}
+
+	if !body.Stream {
+		response, processErr := s.processAgentCore(r.Context(), req, nil, false, nil)
+		if processErr != nil {
+			setRetryAfterHeader(w, processErr)
+			writeOpenAICompatErr(w, processErr.Status, processErr.Code, processErr.Message)
+			return
+		}
+		finish := "stop"
+		writeJSON(w, http.StatusOK, openAICompatCompletion{
+			ID:      completionID,
+			Object:  "chat.completion",
+			Created: created,
+			Model:   model,
+			Choices: []openAICompatChoice{{
+				Index:        0,
+				Message:      &openAICompatReply{Role: "assistant", Content: response.Reply},
+				FinishReason: &finish,
+			}},
+			Usage:  &response.Usage,
+			Parsed: response.Parsed,
+		})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAICompatErr(w, http.StatusInternalServerError, "stream_not_supported", "streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	streamStarted := false
+	writeChunk := func(delta openAICompatReply, finishReason *string) {
+		payload, _ := json.Marshal(openAICompatCompletion{
+			ID:      completionID,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []openAICompatChoice{{Index: 0, Delta: &delta, FinishReason: finishReason}},
+		})
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", payload)
+		flusher.Flush()
+	}
+	startStream := func() {
+		if streamStarted {
+			return
+		}
+		streamStarted = true
+		writeChunk(openAICompatReply{Role: "assistant"}, nil)
+	}
+	emitEvent := func(evt domain.AgentEvent) {
+		if evt.Type != "assistant_delta" || evt.Delta == "" {
+			return
+		}
+		startStream()
+		writeChunk(openAICompatReply{Content: evt.Delta}, nil)
+	}
+
+	response, processErr := s.processAgentCore(r.Context(), req, nil, true, emitEvent)
+	if processErr != nil {
+		if !streamStarted {
+			setRetryAfterHeader(w, processErr)
+			writeOpenAICompatErr(w, processErr.Status, processErr.Code, processErr.Message)
+			return
+		}
+		payload, _ := json.Marshal(openAICompatErrorBody{Error: newOpenAICompatError(processErr.Status, processErr.Code, processErr.Message)})
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", payload)
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		flusher.Flush()
+		return
+	}
+	if !streamStarted {
+		startStream()
+		if response.Reply != "" {
+			writeChunk(openAICompatReply{Content: response.Reply}, nil)
+		}
+	}
+	finish := "stop"
+	writeChunk(openAICompatReply{}, &finish)
+	_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+func (s *Server) buildOpenAICompatProcessRequest(ctx context.Context, body openAICompatChatRequest, sessionID string) (domain.AgentProcessRequest, bool, error) {
+	if body.N != nil && *body.N != 1 {
+		return domain.AgentProcessRequest{}, false, errors.New("only n=1 is supported")
+	}
+	stateful := sessionID != ""
+	if !stateful {
+		sessionID = newID("openai")
+	}
+	input, err := openAICompatInputMessages(body.Messages, stateful)
+	if err != nil {
+		return domain.AgentProcessRequest{}, false, err
+	}
+	generation, err := openAICompatGenerationParams(body)
+	if err != nil {
+		return domain.AgentProcessRequest{}, false, err
+	}
+	override, hasOverride, err := s.resolveOpenAICompatModel(body.Model)
+	if err != nil {
+		return domain.AgentProcessRequest{}, false, err
+	}
+
+	bizParams := map[string]interface{}{}
+	if generation != nil {
+		bizParams[domain.ChatMetaGeneration] = generation
+	}
+	if hasOverride {
+		bizParams[bizParamsActiveLLMKey] = map[string]interface{}{
+			"provider_id": override.ProviderID,
+			"model":       override.Model,
+		}
+	}
+	// A key bound to a user always acts as that user; OpenAI SDKs usually
+	// leave user out, so it only has to match when it is sent.
+	userID := boundUserID(ctx)
+	if requested := strings.TrimSpace(body.User); userID != "" && requested != "" && requested != userID {
+		return domain.AgentProcessRequest{}, false, &openAICompatRequestError{
+			Status:  http.StatusForbidden,
+			Code:    "forbidden",
+			Message: "api key is bound to a different user",
+		}
+	}
+	if userID == "" {
+		userID = strings.TrimSpace(body.User)
+	}
+	if userID == "" {
+		userID = openAICompatDefaultUserID
+	}
+	if stateful {
+		input = s.dropStoredSystemMessages(sessionID, userID, input)
+	}
+	return domain.AgentProcessRequest{
+		Input:          input,
+		SessionID:      sessionID,
+		UserID:         userID,
+		Channel:        defaultProcessChannel,
+		Stream:         body.Stream,
+		ResponseFormat: body.ResponseFormat,
+		BizParams:      bizParams,
+	}, stateful, nil
+}
+
+func (s *Server) resolveOpenAICompatModel(model string) (domain.ModelSlotConfig, bool, error) {
+	requested := strings.TrimSpace(model)
+	if requested == "" || requested == openAICompatAutoModel {
+		return domain.ModelSlotConfig{}, false, nil
+	}
+	providerID := ""
+	modelID := requested
+	if idx := strings.Index(requested, "/"); idx > 0 {
+		providerID = normalizeProviderID(requested[:idx])
+		modelID = strings.TrimSpace(requested[idx+1:])
+	}
+	providers, _, _ := s.collectProviderCatalog()
+	for _, item := range providers {
+		if !item.Enabled || (providerID != "" && item.ID != providerID) {
+			continue
+		}
+		for _, candidate := range item.Models {
+			if candidate.ID == modelID {
+				return domain.ModelSlotConfig{ProviderID: item.ID, Model: candidate.ID}, true, nil
+			}
+		}
+	}
+	return domain.ModelSlotConfig{}, false, &openAICompatRequestError{
+		Status:  http.StatusNotFound,
+		Code:    "model_not_found",
+		Message: fmt.Sprintf("model %q does not exist", requested),
+	}
+}
+
+func openAICompatInputMessages(messages []openAICompatMessage, stateful bool) ([]domain.AgentInputMessage, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("messages is required")
+	}
+	out := make([]domain.AgentInputMessage, 0, len(messages))
+	for idx, msg := range messages {
+		role := strings.ToLower(strings.TrimSpace(msg.Role))
+		switch role {
+		case "developer":
+			role = "system"
+		case "system", "user", "assistant":
+		default:
+			return nil, fmt.Errorf("messages[%d].role %q is not supported", idx, msg.Role)
+		}
+		text, err := openAICompatMessageText(msg.Content)
+		if err != nil {
+			return nil, fmt.Errorf("messages[%d].content %v", idx, err)
+		}
+		if role == "assistant" && stateful {
+			// The session history already holds the earlier turns; only the
+			// client's system and developer messages carry forward.
+			kept := out[:0]
+			for _, prev := range out {
+				if prev.Role == "system" {
+					kept = append(kept, prev)
+				}
+			}
+			out = kept
+			continue
+		}
+		out = append(out, domain.AgentInputMessage{
+			Role:    role,
+			Type:    "message",
+			Content: []domain.RuntimeContent{{Type: "text", Text: text}},
+		})
+	}
+	hasUser := false
+	for _, msg := range out {
+		if msg.Role == "user" {
+			hasUser = true
+			break
+		}
+	}
+	if !hasUser {
+		return nil, errors.New("messages must include a user message")
+	}
+	return out, nil
+}
+
+// dropStoredSystemMessages removes system messages the session history
+// already holds, so clients that resend their system prompt every turn do not
+// grow the history with copies of it.
+func (s *Server) dropStoredSystemMessages(sessionID, userID string, input []domain.AgentInputMessage) []domain.AgentInputMessage {
+	stored := map[string]bool{}
+	s.store.Read(func(state *repo.State) {
+		for chatID, chat := range state.Chats {
+			if chat.SessionID != sessionID || chat.UserID != userID || chat.Channel != defaultProcessChannel {
+				continue
+			}
+			for _, msg := range state.Histories[chatID] {
+				if msg.Role == "system" {
+					stored[flattenRuntimeContentsText(msg.Content)] = true
+				}
+			}
+		}
+	})
+	if len(stored) == 0 {
+		return input
+	}
+	out := make([]domain.AgentInputMessage, 0, len(input))
+	for _, msg := range input {
+		if msg.Role == "system" && stored[flattenRuntimeContentsText(msg.Content)] {
+			continue
+		}
+		out = append(out, msg)
+	}
+	return out
+}
+
+func openAICompatMessageText(raw json.RawMessage) (string, error) {
+	trimmed := strings.TrimSpace(string(raw))
+	if trimmed == "" || trimmed == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []openAICompatContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", errors.New("must be a string or an array of content parts")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch strings.TrimSpace(part.Type) {
+		case "text", "input_text":
+			texts = append(texts, part.Text)
+		default:
+			return "", fmt.Errorf("part type %q is not supported", part.Type)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+func openAICompatGenerationParams(body openAICompatChatRequest) (*domain.GenerationParams, error) {
+	params := domain.GenerationParams{
+		Temperature: body.Temperature,
+		TopP:        body.TopP,
+		MaxTokens:   body.MaxTokens,
+		Seed:        body.Seed,
+	}
+	if body.MaxCompletionTokens != nil {
+		params.MaxTokens = body.MaxCompletionTokens
+	}
+	if trimmed := strings.TrimSpace(string(body.Stop)); trimmed != "" && trimmed != "null" {
+		var single string
+		if err := json.Unmarshal(body.Stop, &single); err == nil {
+			params.Stop = []string{single}
+		} else if err := json.Unmarshal(body.Stop, &params.Stop); err != nil {
+			return nil, errors.New("stop must be a string or an array of strings")
+		}
+	}
+	if params.Temperature == nil && params.TopP == nil && params.MaxTokens == nil && len(params.Stop) == 0 && params.Seed == nil {
+		return nil, nil
+	}
+	return &params, nil
+}
+
+func newOpenAICompatError(status int, code, message string) openAICompatError {
+	errType := "invalid_request_error"
+	switch {
+	case status == http.StatusUnauthorized:
+		errType = "authentication_error"
+	case status == http.StatusTooManyRequests:
+		errType = "rate_limit_error"
+	case status >= http.StatusInternalServerError:
+		errType = "api_error"
+	}
+	return openAICompatError{Message: message, Type: errType, Code: code}
+}
+
+func writeOpenAICompatErr(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, openAICompatErrorBody{Error: newOpenAICompatError(status, code, message)})
+}
@@ -0,0 +1,278 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nextai/apps/gateway/internal/config"
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/repo"
+)
+
+func TestOpenAICompatListModelsIncludesCatalog(t *testing.T) {
+	srv := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var out openAICompatModelList
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode models: %v", err)
+	}
+	ids := map[string]string{}
+	for _, item := range out.Data {
+		ids[item.ID] = item.OwnedBy
+	}
+	if out.Object != "list" || ids["nextai"] != "nextai" || ids["openai/gpt-4o-mini"] != "openai" {
+		t.Fatalf("unexpected model list: %s", w.Body.String())
+	}
+}
+
+func TestOpenAICompatChatCompletionsRejectsUnknownModel(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := `{"model":"openai/not-a-model","messages":[{"role":"user","content":"hi"}]}`
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var out openAICompatErrorBody
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if out.Error.Code != "model_not_found" || out.Error.Type != "invalid_request_error" {
+		t.Fatalf("unexpected error body: %s", w.Body.String())
+	}
+}
+
+func TestOpenAICompatBuildProcessRequestMapsMessagesAndParams(t *testing.T) {
+	srv := newTestServer(t)
+
+	var body openAICompatChatRequest
+	raw := `{
+		"model":"gpt-4o-mini",
+		"messages":[
+			{"role":"developer","content":"be brief"},
+			{"role":"user","content":"first"},
+			{"role":"assistant","content":"answer"},
+			{"role":"user","content":[{"type":"text","text":"second"},{"type":"text","text":"part"}]}
+		],
+		"max_completion_tokens":128,
+		"stop":"END",
+		"user":"u-1"
+	}`
+	if err := json.Unmarshal([]byte(raw), &body); err != nil {
+		t.Fatalf("decode request: %v", err)
+	}
+
+	req, stateful, err := srv.buildOpenAICompatProcessRequest(context.Background(), body, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stateful || !strings.HasPrefix(req.SessionID, "openai-") || req.UserID != "u-1" || req.Channel != defaultProcessChannel {
+		t.Fatalf("unexpected request identity: %#v", req)
+	}
+	if len(req.Input) != 4 || req.Input[0].Role != "system" || req.Input[3].Content[0].Text != "second\npart" {
+		t.Fatalf("unexpected stateless input: %#v", req.Input)
+	}
+	override, ok := parseRequestActiveModelOverride(req.BizParams)
+	if !ok || override.ProviderID != "openai" || override.Model != "gpt-4o-mini" {
+		t.Fatalf("unexpected model override: %#v", req.BizParams)
+	}
+	generation, err := parseGenerationParamsFromBizParams(req.BizParams)
+	if err != nil || generation == nil || *generation.MaxTokens != 128 || len(generation.Stop) != 1 || generation.Stop[0] != "END" {
+		t.Fatalf("unexpected generation params: %#v err=%v", generation, err)
+	}
+
+	req, stateful, err = srv.buildOpenAICompatProcessRequest(context.Background(), body, "editor-session")
+	if err != nil {
+		t.Fatalf("unexpected stateful error: %v", err)
+	}
+	if !stateful || req.SessionID != "editor-session" || len(req.Input) != 2 ||
+		req.Input[0].Role != "system" || req.Input[0].Content[0].Text != "be brief" || req.Input[1].Role != "user" {
+		t.Fatalf("expected system and trailing user messages in stateful mode: %#v", req)
+	}
+
+	if err := srv.store.Write(func(state *repo.State) error {
+		state.Chats["chat-editor"] = domain.ChatSpec{ID: "chat-editor", SessionID: "editor-session", UserID: "u-1", Channel: defaultProcessChannel}
+		state.Histories["chat-editor"] = []domain.RuntimeMessage{
+			{ID: "msg-1", Role: "system", Type: "message", Content: []domain.RuntimeContent{{Type: "text", Text: "be brief"}}},
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("seed chat: %v", err)
+	}
+	req, _, err = srv.buildOpenAICompatProcessRequest(context.Background(), body, "editor-session")
+	if err != nil {
+		t.Fatalf("unexpected stateful error: %v", err)
+	}
+	if len(req.Input) != 1 || req.Input[0].Role != "user" {
+		t.Fatalf("expected stored system message to be skipped: %#v", req.Input)
+	}
+}
+
+func newOpenAICompatProviderMock(t *testing.T) *httptest.Server {
+	t.Helper()
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode provider request: %v", err)
+		}
+		if stream, _ := req["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hello \"}}]}\n\n"))
+			_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"there\"}}]}\n\n"))
+			_, _ = w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":11,\"completion_tokens\":3,\"total_tokens\":14}}\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]interface{}{"content": "hello there"}}},
+			"usage":   map[string]interface{}{"prompt_tokens": 11, "completion_tokens": 3, "total_tokens": 14},
+		})
+	}))
+	t.Cleanup(mock.Close)
+	return mock
+}
+
+func TestOpenAICompatChatCompletionsReturnsCompletion(t *testing.T) {
+	srv := newTestServer(t)
+	configureOpenAIProviderForTest(t, srv, newOpenAICompatProviderMock(t).URL)
+
+	body := `{"model":"openai/gpt-4o-mini","messages":[{"role":"user","content":"hi"}]}`
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var out openAICompatCompletion
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode completion: %v", err)
+	}
+	if out.Object != "chat.completion" || !strings.HasPrefix(out.ID, "chatcmpl") || out.Model != "openai/gpt-4o-mini" || len(out.Choices) != 1 {
+		t.Fatalf("unexpected completion: %s", w.Body.String())
+	}
+	choice := out.Choices[0]
+	if choice.Message == nil || choice.Message.Role != "assistant" || choice.Message.Content != "hello there" ||
+		choice.FinishReason == nil || *choice.FinishReason != "stop" {
+		t.Fatalf("unexpected choice: %s", w.Body.String())
+	}
+	if out.Usage == nil || out.Usage.PromptTokens != 11 || out.Usage.CompletionTokens != 3 || out.Usage.TotalTokens != 14 {
+		t.Fatalf("unexpected usage: %s", w.Body.String())
+	}
+}
+
+func TestOpenAICompatChatCompletionsStreamsChunks(t *testing.T) {
+	srv := newTestServer(t)
+	configureOpenAIProviderForTest(t, srv, newOpenAICompatProviderMock(t).URL)
+
+	body := `{"model":"openai/gpt-4o-mini","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("status=%d content-type=%q body=%s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	var frames []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			frames = append(frames, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if len(frames) < 3 || frames[len(frames)-1] != "[DONE]" {
+		t.Fatalf("expected chunks closed by [DONE], got=%q", frames)
+	}
+	var chunks []openAICompatCompletion
+	for _, frame := range frames[:len(frames)-1] {
+		var chunk openAICompatCompletion
+		if err := json.Unmarshal([]byte(frame), &chunk); err != nil {
+			t.Fatalf("decode chunk %q: %v", frame, err)
+		}
+		if chunk.Object != "chat.completion.chunk" || len(chunk.Choices) != 1 || chunk.Choices[0].Delta == nil {
+			t.Fatalf("unexpected chunk: %s", frame)
+		}
+		chunks = append(chunks, chunk)
+	}
+	first := chunks[0].Choices[0]
+	if first.Delta.Role != "assistant" || first.Delta.Content != "" || first.FinishReason != nil {
+		t.Fatalf("expected role-only first delta, got=%s", frames[0])
+	}
+	content := ""
+	for _, chunk := range chunks[1 : len(chunks)-1] {
+		if chunk.Choices[0].Delta.Role != "" || chunk.Choices[0].FinishReason != nil {
+			t.Fatalf("unexpected content chunk: %+v", chunk.Choices[0])
+		}
+		content += chunk.Choices[0].Delta.Content
+	}
+	if content != "hello there" {
+		t.Fatalf("unexpected streamed content: %q", content)
+	}
+	last := chunks[len(chunks)-1].Choices[0]
+	if last.Delta.Content != "" || last.FinishReason == nil || *last.FinishReason != "stop" {
+		t.Fatalf("expected closing stop chunk, got=%s", frames[len(frames)-2])
+	}
+}
+
+func TestOpenAICompatChatCompletionsUsesBoundKeyUser(t *testing.T) {
+	t.Setenv("NEXTAI_DISABLE_QQ_INBOUND_SUPERVISOR", "true")
+	srv, err := NewServer(config.Config{Host: "127.0.0.1", Port: "0", DataDir: t.TempDir(), APIKey: "secret-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+	mock := newOpenAICompatProviderMock(t)
+	if w := serve(http.MethodPut, "/models/openai/config", "secret-token", `{"api_key":"sk-test","base_url":"`+mock.URL+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("config provider status=%d body=%s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodPut, "/models/active", "secret-token", `{"provider_id":"openai","model":"gpt-4o-mini"}`); w.Code != http.StatusOK {
+		t.Fatalf("set active status=%d body=%s", w.Code, w.Body.String())
+	}
+	keyW := serve(http.MethodPost, "/auth/keys", "secret-token", `{"label":"alice","scopes":["chat"],"user_id":"alice"}`)
+	if keyW.Code != http.StatusCreated {
+		t.Fatalf("create key status=%d body=%s", keyW.Code, keyW.Body.String())
+	}
+	var alice domain.APIKeyCreateResponse
+	if err := json.Unmarshal(keyW.Body.Bytes(), &alice); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("X-API-Key", alice.Key)
+	req.Header.Set(openAICompatSessionHeader, "alice-editor")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected bound key without user to succeed, status=%d body=%s", w.Code, w.Body.String())
+	}
+	owner := ""
+	srv.store.Read(func(state *repo.State) {
+		for _, chat := range state.Chats {
+			if chat.SessionID == "alice-editor" {
+				owner = chat.UserID
+			}
+		}
+	})
+	if owner != "alice" {
+		t.Fatalf("expected session owned by bound user, got=%q", owner)
+	}
+
+	w = serve(http.MethodPost, "/v1/chat/completions", alice.Key, `{"user":"bob","messages":[{"role":"user","content":"hi"}]}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected mismatched user to be rejected, status=%d body=%s", w.Code, w.Body.String())
+	}
+}
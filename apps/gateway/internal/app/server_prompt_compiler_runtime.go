@@ -129,6 +129,7 @@ func (s *Server) compileSystemLayersForTurnRuntime(runtime TurnRuntimeSnapshot)
 	if err != nil {
 		return compiledSystemPromptResult{}, err
 	}
+	layers = appendSkillPromptLayers(layers, normalizedRuntime.skills)
 	layers = appendTurnRuntimeToolAvailabilityLayerIfNeeded(layers, normalizedRuntime)
 
 	compiledLayers := make([]compiledSystemPromptLayer, 0, len(layers))
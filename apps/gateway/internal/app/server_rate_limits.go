@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+	"nextai/apps/gateway/internal/plugin"
+	"nextai/apps/gateway/internal/service/ports"
+	ratelimitservice "nextai/apps/gateway/internal/service/ratelimit"
+)
+
+const (
+	rateLimitedReply        = "消息有点太频繁啦，请 %d 秒后再试。"
+	tokenBudgetDailyReply   = "今天的对话额度已经用完了，明天再来吧。"
+	tokenBudgetMonthlyReply = "本月的对话额度已经用完了，下个月再来吧。"
+
+	rateLimitPersistInterval = 2 * time.Second
+)
+
+type internalAgentTurnContextKey struct{}
+
+// withInternalAgentTurn marks turns started by the gateway itself (cron,
+// selfops, sub-agents) so they skip the per-request buckets.
+func withInternalAgentTurn(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalAgentTurnContextKey{}, true)
+}
+
+func isInternalAgentTurn(ctx context.Context) bool {
+	internal, _ := ctx.Value(internalAgentTurnContextKey{}).(bool)
+	return internal
+}
+
+func (s *Server) getRateLimitService() *ratelimitservice.Service {
+	if s.rateLimitService == nil {
+		s.rateLimitService = s.newRateLimitService()
+	}
+	return s.rateLimitService
+}
+
+func (s *Server) newRateLimitService() *ratelimitservice.Service {
+	return ratelimitservice.NewService(ratelimitservice.Dependencies{
+		DataDir: s.cfg.DataDir,
+		Config: domain.RateLimitConfig{
+			User:       domain.RateLimitRule{PerMinute: s.cfg.RateLimitUserPerMinute, Burst: s.cfg.RateLimitUserBurst},
+			Channel:    domain.RateLimitRule{PerMinute: s.cfg.RateLimitChannelPerMinute, Burst: s.cfg.RateLimitChannelBurst},
+			APIKey:     domain.RateLimitRule{PerMinute: s.cfg.RateLimitAPIKeyPerMinute, Burst: s.cfg.RateLimitAPIKeyBurst},
+			UserTokens: domain.TokenBudgetRule{Daily: s.cfg.TokenBudgetUserDaily, Monthly: s.cfg.TokenBudgetUserMonthly},
+			ChatTokens: domain.TokenBudgetRule{Daily: s.cfg.TokenBudgetChatDaily, Monthly: s.cfg.TokenBudgetChatMonthly},
+		},
+		Logger:          s.log(),
+		PersistInterval: rateLimitPersistInterval,
+	})
+}
+
+func rateLimitSubject(ctx context.Context, req domain.AgentProcessRequest) ratelimitservice.Subject {
+	principal, _ := observability.PrincipalFromContext(ctx)
+	return ratelimitservice.Subject{
+		UserID:            req.UserID,
+		Channel:           req.Channel,
+		APIKeyID:          principal.KeyID,
+		ChatKey:           rateLimitChatKey(req),
+		SkipRequestLimits: isInternalAgentTurn(ctx),
+	}
+}
+
+// rateLimitChatKey groups a chat by its channel target when one is known, so
+// every member of a QQ group shares the group's budget.
+func rateLimitChatKey(req domain.AgentProcessRequest) string {
+	if channelParams, ok := req.BizParams["channel"].(map[string]interface{}); ok {
+		targetType := strings.TrimSpace(qqString(channelParams["target_type"]))
+		targetID := strings.TrimSpace(qqString(channelParams["target_id"]))
+		if targetType != "" && targetID != "" {
+			return req.Channel + ":" + targetType + ":" + targetID
+		}
+	}
+	return req.Channel + ":" + req.SessionID
+}
+
+func rateLimitReply(decision ratelimitservice.Decision) string {
+	if decision.Reason == ratelimitservice.ReasonTokenBudget {
+		if decision.Period == ratelimitservice.PeriodMonth {
+			return tokenBudgetMonthlyReply
+		}
+		return tokenBudgetDailyReply
+	}
+	return fmt.Sprintf(rateLimitedReply, int(decision.RetryAfter.Seconds()))
+}
+
+// rejectRateLimited tells the user through their channel why the turn was
+// dropped and returns the matching 429 for HTTP callers.
+func (s *Server) rejectRateLimited(
+	ctx context.Context,
+	req domain.AgentProcessRequest,
+	channelPlugin plugin.ChannelPlugin,
+	channelCfg map[string]interface{},
+	decision ratelimitservice.Decision,
+) *ports.AgentProcessError {
+	reply := rateLimitReply(decision)
+	s.log().InfoContext(ctx, "agent turn rate limited",
+		"reason", decision.Reason,
+		"scope", decision.Scope,
+		"key", decision.Key,
+		"retry_after_seconds", int(decision.RetryAfter.Seconds()),
+	)
+	if decision.Notify {
+		dispatchCfg := mergeChannelDispatchConfig(req.Channel, channelCfg, req.BizParams)
+		if err := channelPlugin.SendText(ctx, req.UserID, req.SessionID, reply, dispatchCfg); err != nil {
+			s.log().WarnContext(ctx, "rate limit notice dispatch failed", "channel", req.Channel, "err", err)
+		}
+	}
+	details := map[string]interface{}{
+		"scope":               decision.Scope,
+		"key":                 decision.Key,
+		"retry_after_seconds": int(decision.RetryAfter.Seconds()),
+	}
+	if decision.Period != "" {
+		details["period"] = decision.Period
+	}
+	return &ports.AgentProcessError{
+		Status:  http.StatusTooManyRequests,
+		Code:    decision.Reason,
+		Message: reply,
+		Details: details,
+	}
+}
+
+func setRetryAfterHeader(w http.ResponseWriter, processErr *ports.AgentProcessError) {
+	if processErr == nil || processErr.Status != http.StatusTooManyRequests {
+		return
+	}
+	details, _ := processErr.Details.(map[string]interface{})
+	if seconds, ok := details["retry_after_seconds"].(int); ok && seconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+}
+
+func turnTokenEstimate(input []domain.AgentInputMessage, reply string) domain.AgentTokenUsage {
+	var builder strings.Builder
+	for _, msg := range input {
+		for _, part := range msg.Content {
+			builder.WriteString(part.Text)
+			builder.WriteByte('\n')
+		}
+	}
+	prompt := estimatePromptTokenCount(builder.String())
+	completion := estimatePromptTokenCount(reply)
+	return domain.AgentTokenUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+func (s *Server) getRateLimits(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.getRateLimitService().Status())
+}
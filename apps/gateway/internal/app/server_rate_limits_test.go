@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"nextai/apps/gateway/internal/config"
+	"nextai/apps/gateway/internal/domain"
+	ratelimitservice "nextai/apps/gateway/internal/service/ratelimit"
+)
+
+func newRateLimitedTestServer(t *testing.T, cfg config.Config) *Server {
+	t.Helper()
+	t.Setenv("NEXTAI_DISABLE_QQ_INBOUND_SUPERVISOR", "true")
+	cfg.Host = "127.0.0.1"
+	cfg.Port = "0"
+	cfg.DataDir = t.TempDir()
+	srv, err := NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func TestAgentProcessRateLimitReturns429AndShowsCounters(t *testing.T) {
+	srv := newRateLimitedTestServer(t, config.Config{RateLimitUserPerMinute: 1})
+	body := `{"input":[{"role":"user","type":"message","content":[{"type":"text","text":"hi"}]}],"session_id":"s1","user_id":"u-limit","channel":"console","stream":false}`
+
+	first := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(first, httptest.NewRequest(http.MethodPost, "/agent/process", strings.NewReader(body)))
+	if first.Code == http.StatusTooManyRequests {
+		t.Fatalf("first request should pass the limiter, body=%s", first.Body.String())
+	}
+
+	second := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(second, httptest.NewRequest(http.MethodPost, "/agent/process", strings.NewReader(body)))
+	if second.Code != http.StatusTooManyRequests {
+		t.Fatalf("status=%d body=%s", second.Code, second.Body.String())
+	}
+	if got := second.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("retry-after=%q", got)
+	}
+	var errBody domain.APIErrorBody
+	if err := json.Unmarshal(second.Body.Bytes(), &errBody); err != nil {
+		t.Fatalf("decode error body: %v", err)
+	}
+	if errBody.Error.Code != ratelimitservice.ReasonRateLimited || !strings.Contains(errBody.Error.Message, "60 秒") {
+		t.Fatalf("unexpected error: %+v", errBody.Error)
+	}
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rate-limits", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("rate limits status=%d body=%s", w.Code, w.Body.String())
+	}
+	var status domain.RateLimitStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.Config.User.PerMinute != 1 || status.Config.User.Burst != 1 {
+		t.Fatalf("unexpected config: %+v", status.Config)
+	}
+	if len(status.Buckets) != 1 || status.Buckets[0].Key != "u-limit" || status.Buckets[0].Tokens >= 1 {
+		t.Fatalf("unexpected buckets: %+v", status.Buckets)
+	}
+}
+
+func TestQQGroupTokenBudgetRepliesOnceAndIgnoresEvent(t *testing.T) {
+	var groupCalls atomic.Int32
+	var lastMessage atomic.Value
+	qqAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"qq-token","expires_in":7200}`))
+		case "/v2/groups/group-busy/messages":
+			groupCalls.Add(1)
+			var payload map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			lastMessage.Store(qqString(payload["content"]))
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected qq path: %s", r.URL.Path)
+		}
+	}))
+	defer qqAPI.Close()
+
+	srv := newRateLimitedTestServer(t, config.Config{TokenBudgetChatDaily: 100})
+	channelConfig := `{"enabled":true,"app_id":"app-1","client_secret":"secret-1","token_url":"` + qqAPI.URL + `/token","api_base":"` + qqAPI.URL + `","target_type":"c2c"}`
+	configW := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(configW, httptest.NewRequest(http.MethodPut, "/config/channels/qq", strings.NewReader(channelConfig)))
+	if configW.Code != http.StatusOK {
+		t.Fatalf("set qq channel config status=%d body=%s", configW.Code, configW.Body.String())
+	}
+	srv.getRateLimitService().RecordTokens(ratelimitservice.Subject{ChatKey: "qq:group:group-busy"}, 150)
+
+	for i, member := range []string{"member-a", "member-b"} {
+		payload := `{"t":"GROUP_AT_MESSAGE_CREATE","d":{"id":"m-` + member + `","content":"hello","group_openid":"group-busy","author":{"member_openid":"` + member + `"}}}`
+		accepted, reason, err := srv.dispatchQQInboundPayload(context.Background(), []byte(payload))
+		if err != nil || accepted || reason != ratelimitservice.ReasonTokenBudget {
+			t.Fatalf("dispatch %d: accepted=%v reason=%q err=%v", i, accepted, reason, err)
+		}
+	}
+	if got := groupCalls.Load(); got != 1 {
+		t.Fatalf("expected a single budget notice, got=%d", got)
+	}
+	if got, _ := lastMessage.Load().(string); got != tokenBudgetDailyReply {
+		t.Fatalf("unexpected notice: %q", got)
+	}
+}
+
+func TestDirectToolCallChargesEstimatedTokens(t *testing.T) {
+	srv := newRateLimitedTestServer(t, config.Config{TokenBudgetUserDaily: 100000})
+	body := `{"input":[{"role":"user","type":"message","content":[{"type":"text","text":"/shell printf hello"}]}],` +
+		`"session_id":"s-tool","user_id":"u-tool","channel":"console","stream":false,` +
+		`"biz_params":{"tool":{"name":"shell","items":[{"command":"printf hello"}]}}}`
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/agent/process", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	for _, budget := range srv.getRateLimitService().Status().Budgets {
+		if budget.Key == "u-tool" && budget.DayTokens > 0 {
+			return
+		}
+	}
+	t.Fatalf("expected tool turn to charge the user budget, got=%+v", srv.getRateLimitService().Status().Budgets)
+}
@@ -0,0 +1,295 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/search"
+	adminservice "nextai/apps/gateway/internal/service/admin"
+	systempromptservice "nextai/apps/gateway/internal/service/systemprompt"
+)
+
+const (
+	loadSkillToolName          = "load_skill"
+	skillInlineTokenBudget     = 2000
+	skillManifestSummaryRunes  = 160
+	skillPromptLayerName       = "skill_system"
+	skillManifestLayerName     = "skill_manifest_system"
+	skillManifestLayerSource   = "runtime://skills"
+	skillPromptLayerSourceBase = "skill://"
+	skillInjectionInline       = "inline"
+	skillInjectionManifest     = "manifest"
+)
+
+type skillPromptPlan struct {
+	Inline   []domain.SkillSpec
+	Manifest []domain.SkillSpec
+}
+
+func (s *Server) syncEnabledSkills(skills map[string]domain.SkillSpec) {
+	enabled := make([]domain.SkillSpec, 0, len(skills))
+	for name, spec := range skills {
+		if !spec.Enabled || strings.TrimSpace(spec.Content) == "" {
+			continue
+		}
+		if strings.TrimSpace(spec.Name) == "" {
+			spec.Name = name
+		}
+		enabled = append(enabled, spec)
+	}
+	sort.Slice(enabled, func(i, j int) bool { return enabled[i].Name < enabled[j].Name })
+	s.skillsMu.Lock()
+	s.enabledSkills = enabled
+	s.skillsMu.Unlock()
+}
+
+func (s *Server) listEnabledSkills() []domain.SkillSpec {
+	s.skillsMu.RLock()
+	defer s.skillsMu.RUnlock()
+	return append([]domain.SkillSpec{}, s.enabledSkills...)
+}
+
+func (s *Server) findEnabledSkill(name string) (domain.SkillSpec, bool) {
+	target := strings.TrimSpace(name)
+	for _, skill := range s.listEnabledSkills() {
+		if strings.EqualFold(skill.Name, target) {
+			return skill, true
+		}
+	}
+	return domain.SkillSpec{}, false
+}
+
+func (s *Server) applySkillPlanToSnapshot(snapshot TurnRuntimeSnapshot, query string) TurnRuntimeSnapshot {
+	snapshot.skills = planSkillPrompt(s.listEnabledSkills(), query, skillInlineTokenBudget)
+	if snapshot.skills.needsLoadTool() && !s.toolDisabled(loadSkillToolName) {
+		snapshot.AvailableTools = normalizeTurnRuntimeToolNames(append(snapshot.AvailableTools, loadSkillToolName))
+	}
+	return snapshot
+}
+
+func (plan skillPromptPlan) needsLoadTool() bool {
+	if len(plan.Manifest) > 0 {
+		return true
+	}
+	for _, skill := range plan.Inline {
+		if len(listSkillFiles(skill)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func latestUserInputText(input []domain.AgentInputMessage) string {
+	for i := len(input) - 1; i >= 0; i-- {
+		if !strings.EqualFold(strings.TrimSpace(input[i].Role), "user") {
+			continue
+		}
+		parts := make([]string, 0, len(input[i].Content))
+		for _, part := range input[i].Content {
+			if text := strings.TrimSpace(part.Text); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, "\n")
+		}
+	}
+	return ""
+}
+
+func planSkillPrompt(skills []domain.SkillSpec, query string, budget int) skillPromptPlan {
+	if len(skills) == 0 {
+		return skillPromptPlan{}
+	}
+	total := 0
+	for _, skill := range skills {
+		total += estimatePromptTokenCount(renderSkillPromptContent(skill))
+	}
+	if total <= budget {
+		return skillPromptPlan{Inline: append([]domain.SkillSpec{}, skills...)}
+	}
+
+	type rankedSkill struct {
+		skill  domain.SkillSpec
+		score  int
+		tokens int
+	}
+	queryTerms := search.QueryTerms(query)
+	ranked := make([]rankedSkill, 0, len(skills))
+	for _, skill := range skills {
+		ranked = append(ranked, rankedSkill{
+			skill:  skill,
+			score:  skillRelevanceScore(skill, queryTerms),
+			tokens: estimatePromptTokenCount(renderSkillPromptContent(skill)),
+		})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	plan := skillPromptPlan{}
+	remaining := budget
+	for _, item := range ranked {
+		if item.score > 0 && item.tokens <= remaining {
+			plan.Inline = append(plan.Inline, item.skill)
+			remaining -= item.tokens
+			continue
+		}
+		plan.Manifest = append(plan.Manifest, item.skill)
+	}
+	sort.Slice(plan.Manifest, func(i, j int) bool { return plan.Manifest[i].Name < plan.Manifest[j].Name })
+	return plan
+}
+
+func skillRelevanceScore(skill domain.SkillSpec, queryTerms []string) int {
+	if len(queryTerms) == 0 {
+		return 0
+	}
+	nameTerms := search.Terms(skill.Name)
+	contentTerms := search.Terms(skill.Content)
+	score := 0
+	for _, term := range queryTerms {
+		if _, ok := nameTerms[term]; ok {
+			score += 3
+		}
+		if _, ok := contentTerms[term]; ok {
+			score++
+		}
+	}
+	return score
+}
+
+func appendSkillPromptLayers(layers []systemPromptLayer, plan skillPromptPlan) []systemPromptLayer {
+	for _, skill := range plan.Inline {
+		source := skillPromptLayerSourceBase + skill.Name
+		layers = systempromptservice.AppendLayerIfPresent(layers, systemPromptLayer{
+			Name:    skillPromptLayerName,
+			Role:    "system",
+			Source:  source,
+			Content: systempromptservice.FormatLayerSourceContent(source, renderSkillPromptContent(skill)),
+		})
+	}
+	if len(plan.Manifest) == 0 {
+		return layers
+	}
+	lines := []string{
+		"Additional skills are available but not loaded into this prompt.",
+		fmt.Sprintf("Call the %s tool with {\"name\": \"<skill>\"} to read a skill before relying on it; pass \"path\" (for example \"references/<file>\") to read one of its files.", loadSkillToolName),
+		"",
+	}
+	for _, skill := range plan.Manifest {
+		lines = append(lines, skillManifestEntry(skill))
+	}
+	content := strings.Join(lines, "\n")
+	return append(layers, systemPromptLayer{
+		Name:    skillManifestLayerName,
+		Role:    "system",
+		Source:  skillManifestLayerSource,
+		Content: systempromptservice.FormatLayerSourceContent(skillManifestLayerSource, content),
+	})
+}
+
+func buildAgentSystemSkillViews(plan skillPromptPlan) []agentSystemSkillView {
+	out := make([]agentSystemSkillView, 0, len(plan.Inline)+len(plan.Manifest))
+	for _, skill := range plan.Inline {
+		out = append(out, agentSystemSkillView{
+			Name:            skill.Name,
+			Injection:       skillInjectionInline,
+			EstimatedTokens: estimatePromptTokenCount(renderSkillPromptContent(skill)),
+		})
+	}
+	for _, skill := range plan.Manifest {
+		out = append(out, agentSystemSkillView{
+			Name:            skill.Name,
+			Injection:       skillInjectionManifest,
+			EstimatedTokens: estimatePromptTokenCount(skillManifestEntry(skill)),
+		})
+	}
+	return out
+}
+
+func renderSkillPromptContent(skill domain.SkillSpec) string {
+	content := strings.TrimSpace(skill.Content)
+	files := listSkillFiles(skill)
+	if len(files) == 0 {
+		return content
+	}
+	return content + fmt.Sprintf("\n\nSkill files (read with %s): %s", loadSkillToolName, strings.Join(files, ", "))
+}
+
+func skillManifestEntry(skill domain.SkillSpec) string {
+	return fmt.Sprintf("- %s: %s", skill.Name, summarizeSkill(skill))
+}
+
+func summarizeSkill(skill domain.SkillSpec) string {
+	for _, line := range strings.Split(skill.Content, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if line == "" || line == "---" {
+			continue
+		}
+		return summarizeLayerPreview(line, skillManifestSummaryRunes)
+	}
+	return "(no description)"
+}
+
+func listSkillFiles(skill domain.SkillSpec) []string {
+	out := []string{}
+	var walk func(prefix string, node map[string]interface{})
+	walk = func(prefix string, node map[string]interface{}) {
+		for key, value := range node {
+			path := prefix + "/" + key
+			switch item := value.(type) {
+			case string:
+				out = append(out, path)
+			case map[string]interface{}:
+				walk(path, item)
+			}
+		}
+	}
+	walk("references", skill.References)
+	walk("scripts", skill.Scripts)
+	sort.Strings(out)
+	return out
+}
+
+func (s *Server) executeLoadSkillToolCall(input map[string]interface{}) (string, error) {
+	name := strings.TrimSpace(stringValue(input["name"]))
+	if name == "" {
+		return "", &toolError{
+			Code:    "tool_invoke_failed",
+			Message: fmt.Sprintf("tool %q invocation failed", loadSkillToolName),
+			Err:     errors.New("name is required"),
+		}
+	}
+	skill, ok := s.findEnabledSkill(name)
+	if !ok {
+		return "", &toolError{
+			Code:    "tool_invoke_failed",
+			Message: fmt.Sprintf("tool %q invocation failed", loadSkillToolName),
+			Err:     fmt.Errorf("skill %q is not enabled", name),
+		}
+	}
+	if path := strings.TrimSpace(stringValue(input["path"])); path != "" {
+		content, found := adminservice.ReadSkillVirtualFile(skill, path)
+		if !found {
+			return "", &toolError{
+				Code:    "tool_invoke_failed",
+				Message: fmt.Sprintf("tool %q invocation failed", loadSkillToolName),
+				Err:     fmt.Errorf("skill %q has no file %q", skill.Name, path),
+			}
+		}
+		return renderToolResult(loadSkillToolName, map[string]interface{}{
+			"ok":   true,
+			"name": skill.Name,
+			"path": path,
+			"text": content,
+		})
+	}
+	return renderToolResult(loadSkillToolName, map[string]interface{}{
+		"ok":    true,
+		"name":  skill.Name,
+		"files": listSkillFiles(skill),
+		"text":  renderSkillPromptContent(skill),
+	})
+}
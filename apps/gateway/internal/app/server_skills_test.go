@@ -0,0 +1,89 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/repo"
+)
+
+func TestPlanSkillPromptInlinesSmallSkillSets(t *testing.T) {
+	skills := []domain.SkillSpec{
+		{Name: "alpha", Content: "# Alpha\nUse alpha for greetings.", Enabled: true},
+		{Name: "beta", Content: "# Beta\nUse beta for farewells.", Enabled: true},
+	}
+	plan := planSkillPrompt(skills, "", skillInlineTokenBudget)
+	if len(plan.Inline) != 2 || len(plan.Manifest) != 0 {
+		t.Fatalf("expected all skills inline, got %+v", plan)
+	}
+	layers := appendSkillPromptLayers(nil, plan)
+	if len(layers) != 2 || layers[0].Source != "skill://alpha" || !strings.Contains(layers[0].Content, "greetings") {
+		t.Fatalf("unexpected layers: %+v", layers)
+	}
+}
+
+func TestPlanSkillPromptPrefersRelevantSkillsAndListsTheRest(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum dolor ", 40)
+	skills := []domain.SkillSpec{
+		{Name: "deploy", Content: "# Deploy gateway\nRoll out the gateway to staging. " + filler, Enabled: true},
+		{Name: "report", Content: "# Weekly report\nSummarize 周报 metrics. " + filler, Enabled: true},
+		{Name: "translate", Content: "# Translate\nTranslate documents. " + filler, Enabled: true},
+	}
+	plan := planSkillPrompt(skills, "please deploy the gateway", 400)
+	if len(plan.Inline) != 1 || plan.Inline[0].Name != "deploy" {
+		t.Fatalf("expected deploy inline, got %+v", plan.Inline)
+	}
+	if len(plan.Manifest) != 2 || plan.Manifest[0].Name != "report" || plan.Manifest[1].Name != "translate" {
+		t.Fatalf("unexpected manifest: %+v", plan.Manifest)
+	}
+	if !plan.needsLoadTool() {
+		t.Fatal("expected load_skill tool for manifest skills")
+	}
+
+	plan = planSkillPrompt(skills, "写周报", 400)
+	if len(plan.Inline) != 1 || plan.Inline[0].Name != "report" {
+		t.Fatalf("expected cjk query to select report, got %+v", plan.Inline)
+	}
+
+	layers := appendSkillPromptLayers(nil, plan)
+	manifest := layers[len(layers)-1]
+	if manifest.Name != skillManifestLayerName || !strings.Contains(manifest.Content, "- deploy: Deploy gateway") {
+		t.Fatalf("unexpected manifest layer: %+v", manifest)
+	}
+}
+
+func TestLoadSkillToolReadsEnabledSkills(t *testing.T) {
+	srv := newTestServer(t)
+	if err := srv.store.Write(func(state *repo.State) error {
+		state.Skills["deploy"] = domain.SkillSpec{
+			Name:       "deploy",
+			Content:    "Deploy steps",
+			References: map[string]interface{}{"checklist.md": "- build\n- ship"},
+			Enabled:    true,
+		}
+		state.Skills["hidden"] = domain.SkillSpec{Name: "hidden", Content: "secret", Enabled: false}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := srv.buildTurnRuntimeSnapshotForInput(promptModeDefault, []domain.AgentInputMessage{
+		{Role: "user", Type: "message", Content: []domain.RuntimeContent{{Type: "text", Text: "deploy"}}},
+	}, "s1", "", "")
+	if len(snapshot.skills.Inline) != 1 || !runtimeHasAvailableTool(snapshot, loadSkillToolName) {
+		t.Fatalf("unexpected snapshot skills=%+v tools=%v", snapshot.skills, snapshot.AvailableTools)
+	}
+
+	out, err := srv.executeToolCallForPromptMode(promptModeDefault, toolCall{Name: loadSkillToolName, Input: map[string]interface{}{"name": "deploy"}})
+	if err != nil || !strings.Contains(out, "Deploy steps") || !strings.Contains(out, "references/checklist.md") {
+		t.Fatalf("unexpected skill load out=%q err=%v", out, err)
+	}
+	out, err = srv.executeToolCallForPromptMode(promptModeDefault, toolCall{Name: loadSkillToolName, Input: map[string]interface{}{"name": "deploy", "path": "references/checklist.md"}})
+	if err != nil || out != "- build\n- ship" {
+		t.Fatalf("unexpected file load out=%q err=%v", out, err)
+	}
+	if _, err := srv.executeToolCallForPromptMode(promptModeDefault, toolCall{Name: loadSkillToolName, Input: map[string]interface{}{"name": "hidden"}}); err == nil {
+		t.Fatal("expected disabled skill to be rejected")
+	}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -16,7 +17,9 @@ import (
 
 	"nextai/apps/gateway/internal/config"
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/plugin"
+	"nextai/apps/gateway/internal/repo"
 )
 
 func newTestServer(t *testing.T) *Server {
@@ -516,6 +519,236 @@ func TestAPIKeyAuthMiddleware(t *testing.T) {
 	}
 }
 
+func TestScopedAPIKeysEnforceRouteGroupsAndUserBinding(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("NEXTAI_DISABLE_QQ_INBOUND_SUPERVISOR", "true")
+	srv, err := NewServer(config.Config{Host: "127.0.0.1", Port: "0", DataDir: dir, APIKey: "secret-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	createW := serve(http.MethodPost, "/auth/keys", "secret-token", `{"label":"alice","scopes":["chat"],"user_id":"alice"}`)
+	if createW.Code != http.StatusCreated {
+		t.Fatalf("create key status=%d body=%s", createW.Code, createW.Body.String())
+	}
+	var created domain.APIKeyCreateResponse
+	if err := json.Unmarshal(createW.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chat := range []string{
+		`{"id":"chat-alice","session_id":"s1","user_id":"alice","channel":"console"}`,
+		`{"id":"chat-bob","session_id":"s2","user_id":"bob","channel":"console"}`,
+	} {
+		if w := serve(http.MethodPost, "/chats", "secret-token", chat); w.Code != http.StatusOK {
+			t.Fatalf("create chat status=%d body=%s", w.Code, w.Body.String())
+		}
+	}
+
+	if w := serve(http.MethodGet, "/models", created.Key, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected admin route to require admin scope, got=%d body=%s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, "/cron/jobs", created.Key, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected cron route to require cron scope, got=%d body=%s", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodPost, "/auth/keys", created.Key, `{"label":"x","scopes":["chat"]}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected key management to require admin:write, got=%d body=%s", w.Code, w.Body.String())
+	}
+
+	listW := serve(http.MethodGet, "/chats?user_id=bob", created.Key, "")
+	if listW.Code != http.StatusOK {
+		t.Fatalf("list chats status=%d body=%s", listW.Code, listW.Body.String())
+	}
+	var chats []domain.ChatSpec
+	if err := json.Unmarshal(listW.Body.Bytes(), &chats); err != nil {
+		t.Fatal(err)
+	}
+	if len(chats) != 1 || chats[0].ID != "chat-alice" {
+		t.Fatalf("expected only alice chats, got=%+v", chats)
+	}
+	if w := serve(http.MethodGet, "/chats/chat-bob", created.Key, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected other user's chat to be hidden, got=%d body=%s", w.Code, w.Body.String())
+	}
+	processBody := `{"input":[{"role":"user","type":"message","content":[{"type":"text","text":"hi"}]}],"session_id":"s2","user_id":"bob","channel":"console","stream":false}`
+	if w := serve(http.MethodPost, "/agent/process", created.Key, processBody); w.Code != http.StatusForbidden {
+		t.Fatalf("expected user binding mismatch to be rejected, got=%d body=%s", w.Code, w.Body.String())
+	}
+	toolCtx := observability.WithPrincipal(context.Background(), observability.Principal{Scopes: created.Scopes})
+	_, err = srv.executeToolCallForPromptModeWithContext(toolCtx, promptModeDefault, toolCall{Name: "shell", Input: map[string]interface{}{"command": "pwd"}})
+	if status, code, _ := mapToolError(err); status != http.StatusForbidden || code != "tool_scope_denied" {
+		t.Fatalf("expected tool call without tools:execute to be rejected, got status=%d code=%s err=%v", status, code, err)
+	}
+}
+
+func TestUserBoundKeyCannotAccessOtherUsersChatByID(t *testing.T) {
+	t.Setenv("NEXTAI_DISABLE_QQ_INBOUND_SUPERVISOR", "true")
+	srv, err := NewServer(config.Config{Host: "127.0.0.1", Port: "0", DataDir: t.TempDir(), APIKey: "secret-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+	createChat := func(key, userID string) domain.ChatSpec {
+		t.Helper()
+		w := serve(http.MethodPost, "/chats", key, `{"name":"c","session_id":"s-`+userID+`","user_id":"`+userID+`","channel":"console"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("create chat status=%d body=%s", w.Code, w.Body.String())
+		}
+		var chat domain.ChatSpec
+		if err := json.Unmarshal(w.Body.Bytes(), &chat); err != nil {
+			t.Fatal(err)
+		}
+		return chat
+	}
+
+	keyW := serve(http.MethodPost, "/auth/keys", "secret-token", `{"label":"alice","scopes":["chat"],"user_id":"alice"}`)
+	if keyW.Code != http.StatusCreated {
+		t.Fatalf("create key status=%d body=%s", keyW.Code, keyW.Body.String())
+	}
+	var alice domain.APIKeyCreateResponse
+	if err := json.Unmarshal(keyW.Body.Bytes(), &alice); err != nil {
+		t.Fatal(err)
+	}
+	bobChat := createChat("secret-token", "bob")
+	aliceChat := createChat(alice.Key, "alice")
+
+	bobPath := "/chats/" + bobChat.ID
+	updated, _ := json.Marshal(domain.ChatSpec{ID: bobChat.ID, Name: "mine", SessionID: bobChat.SessionID, UserID: "bob", Channel: "console"})
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodGet, bobPath, ""},
+		{http.MethodPut, bobPath, string(updated)},
+		{http.MethodDelete, bobPath, ""},
+		{http.MethodGet, bobPath + "/messages", ""},
+		{http.MethodPut, bobPath + "/messages/msg-x", `{"content":[{"type":"text","text":"hi"}]}`},
+		{http.MethodPost, bobPath + "/messages/msg-x/activate", ""},
+		{http.MethodPost, bobPath + "/messages/msg-x/regenerate", ""},
+		{http.MethodPost, bobPath + "/messages/msg-x/fork", ""},
+	} {
+		w := serve(tc.method, tc.path, alice.Key, tc.body)
+		var errBody domain.APIErrorBody
+		_ = json.Unmarshal(w.Body.Bytes(), &errBody)
+		if w.Code != http.StatusNotFound || errBody.Error.Code != "not_found" {
+			t.Fatalf("%s %s: expected not_found, got=%d body=%s", tc.method, tc.path, w.Code, w.Body.String())
+		}
+	}
+	if w := serve(http.MethodGet, bobPath, "secret-token", ""); w.Code != http.StatusOK {
+		t.Fatalf("bob chat should be untouched, got=%d body=%s", w.Code, w.Body.String())
+	}
+
+	reassigned, _ := json.Marshal(domain.ChatSpec{ID: aliceChat.ID, Name: "c", SessionID: aliceChat.SessionID, UserID: "bob", Channel: "console"})
+	if w := serve(http.MethodPut, "/chats/"+aliceChat.ID, alice.Key, string(reassigned)); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "chat_user_id_immutable") {
+		t.Fatalf("expected user_id change to be rejected, got=%d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestCronToolWorkflowRequiresToolsExecuteScope(t *testing.T) {
+	t.Setenv("NEXTAI_DISABLE_QQ_INBOUND_SUPERVISOR", "true")
+	srv, err := NewServer(config.Config{Host: "127.0.0.1", Port: "0", DataDir: t.TempDir(), APIKey: "secret-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+	createW := serve(http.MethodPost, "/auth/keys", "secret-token", `{"label":"scheduler","scopes":["cron"]}`)
+	if createW.Code != http.StatusCreated {
+		t.Fatalf("create key status=%d body=%s", createW.Code, createW.Body.String())
+	}
+	var cronKey domain.APIKeyCreateResponse
+	if err := json.Unmarshal(createW.Body.Bytes(), &cronKey); err != nil {
+		t.Fatal(err)
+	}
+
+	job := `{"id":"job-shell","name":"job-shell","enabled":false,"schedule":{"type":"interval","cron":"60s"},"task_type":"workflow",` +
+		`"workflow":{"version":"v1","nodes":[{"id":"start","type":"start","x":0,"y":0},{"id":"run","type":"tool_call","x":0,"y":100,"tool":"shell","tool_input":{"items":[{"command":"id"}]}}],` +
+		`"edges":[{"id":"e1","source":"start","target":"run"}]},"creator_scopes":["tools:execute"]}`
+	if w := serve(http.MethodPost, "/cron/jobs", cronKey.Key, job); w.Code != http.StatusForbidden {
+		t.Fatalf("expected tool workflow to require tools:execute, got=%d body=%s", w.Code, w.Body.String())
+	}
+	masterW := serve(http.MethodPost, "/cron/jobs", "secret-token", job)
+	if masterW.Code != http.StatusOK {
+		t.Fatalf("create job status=%d body=%s", masterW.Code, masterW.Body.String())
+	}
+	var saved domain.CronJobSpec
+	if err := json.Unmarshal(masterW.Body.Bytes(), &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.CreatorScopes) != len(domain.APIKeyScopes) {
+		t.Fatalf("expected creator scopes from the caller, got=%v", saved.CreatorScopes)
+	}
+	if w := serve(http.MethodPost, "/cron/jobs/job-shell/run", cronKey.Key, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected manual run to require tools:execute, got=%d body=%s", w.Code, w.Body.String())
+	}
+
+	ctx := observability.WithPrincipal(context.Background(), observability.Principal{Scopes: cronKey.Scopes})
+	if _, err := srv.executeCronToolCall(ctx, "shell", map[string]interface{}{"command": "id"}); err == nil || !strings.Contains(err.Error(), "tools:execute") {
+		t.Fatalf("expected cron tool call without tools:execute to fail, got=%v", err)
+	}
+}
+
+func TestCronJobWithoutCreatorScopesKeepsToolsEnabled(t *testing.T) {
+	srv := newTestServer(t)
+
+	seed := func(id string, scopes []string) {
+		t.Helper()
+		if err := srv.store.Write(func(st *repo.State) error {
+			st.CronJobs[id] = domain.CronJobSpec{
+				ID:       id,
+				Name:     id,
+				TaskType: "workflow",
+				Schedule: domain.CronScheduleSpec{Type: "interval", Cron: "60s"},
+				Workflow: &domain.CronWorkflowSpec{
+					Version: "v1",
+					Nodes: []domain.CronWorkflowNode{
+						{ID: "start", Type: "start"},
+						{ID: "run", Type: "tool_call", Tool: "shell", ToolInput: map[string]interface{}{
+							"items": []interface{}{map[string]interface{}{"command": "printf legacy"}},
+						}},
+					},
+					Edges: []domain.CronWorkflowEdge{{ID: "e1", Source: "start", Target: "run"}},
+				},
+				Runtime:       domain.CronRuntimeSpec{MaxConcurrency: 1, TimeoutSeconds: 5},
+				CreatorScopes: scopes,
+			}
+			st.CronStates[id] = domain.CronJobState{}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seed("job-legacy", nil)
+	seed("job-scoped", []string{domain.APIKeyScopeCron})
+
+	if err := srv.getCronService().ExecuteJob("job-legacy"); err != nil {
+		t.Fatalf("expected job without creator scopes to run its tool, got=%v", err)
+	}
+	if err := srv.getCronService().ExecuteJob("job-scoped"); err == nil || !strings.Contains(err.Error(), "tools:execute") {
+		t.Fatalf("expected job saved without tools:execute to be rejected, got=%v", err)
+	}
+}
+
 func TestChatCreateAndGetHistory(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -1569,3 +1802,42 @@ func TestBuildSystemLayersForLegacyOptionsCodexModeFallsBackToDefaultLayers(t *t
 		t.Fatalf("expected fallback default layer order, got=%#v", layers)
 	}
 }
+
+func TestMetricsEndpointExposesPrometheusText(t *testing.T) {
+	srv := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("version status=%d", w.Code)
+	}
+	if _, err := srv.executeToolCall(toolCall{Name: "metrics_probe_tool", Input: map[string]interface{}{}}); err == nil {
+		t.Fatal("expected unknown tool to fail")
+	}
+	if err := srv.channels["console"].SendText(context.Background(), "u-metrics", "s-metrics", "hello", nil); err != nil {
+		t.Fatalf("console send failed: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics status=%d body=%s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != observability.MetricsContentType {
+		t.Fatalf("content-type=%q", got)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE nextai_http_request_duration_seconds histogram",
+		`nextai_http_requests_total{method="GET",route="/version",status="200"}`,
+		`nextai_tool_invocation_duration_seconds_count{tool="metrics_probe_tool"} `,
+		`nextai_channel_sends_total{channel="console",result="ok"}`,
+		"nextai_subagents_active 0",
+		"nextai_user_input_waiters_pending 0",
+		"nextai_qq_gateway_connected 0",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+}
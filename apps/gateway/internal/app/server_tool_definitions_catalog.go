@@ -448,6 +448,51 @@ func buildToolDefinition(name string) runner.ToolDefinition {
 				"additionalProperties": true,
 			},
 		}
+	case "load_skill":
+		return runner.ToolDefinition{
+			Name:        "load_skill",
+			Description: "Load an enabled skill's instructions, or one of its reference/script files when path is set.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional file path such as references/<file> or scripts/<file>.",
+					},
+				},
+				"required":             []string{"name"},
+				"additionalProperties": false,
+			},
+		}
+	case "schedule_reminder":
+		return runner.ToolDefinition{
+			Name:        "schedule_reminder",
+			Description: "Schedule a one-time reminder that is delivered to the current chat. Set run_at (RFC3339 with offset) or delay (for example 45m or 24h).",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"text": map[string]interface{}{
+						"type":        "string",
+						"description": "Reminder message sent to the user.",
+					},
+					"run_at": map[string]interface{}{
+						"type":        "string",
+						"description": "Absolute time such as 2026-03-01T09:00:00+08:00.",
+					},
+					"delay": map[string]interface{}{
+						"type":        "string",
+						"description": "Relative delay from now such as 45m or 2h30m.",
+					},
+					"timezone": map[string]interface{}{
+						"type":        "string",
+						"description": "Optional IANA timezone of the user, for example Asia/Shanghai.",
+					},
+				},
+				"required":             []string{"text"},
+				"additionalProperties": false,
+			},
+		}
 	case "spawn_agent":
 		return runner.ToolDefinition{
 			Name:        "spawn_agent",
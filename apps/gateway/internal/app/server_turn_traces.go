@@ -0,0 +1,127 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/provider"
+	"nextai/apps/gateway/internal/repo"
+	"nextai/apps/gateway/internal/runner"
+	"nextai/apps/gateway/internal/service/adapters"
+	turntraceservice "nextai/apps/gateway/internal/service/turntrace"
+)
+
+func (s *Server) getTurnTraceService() *turntraceservice.Service {
+	if s.turnTraceService == nil {
+		s.turnTraceService = s.newTurnTraceService()
+	}
+	return s.turnTraceService
+}
+
+func (s *Server) newTurnTraceService() *turntraceservice.Service {
+	return turntraceservice.NewService(turntraceservice.Dependencies{
+		DataDir:               s.cfg.DataDir,
+		Limit:                 s.cfg.TurnTraceLimit,
+		Runner:                adapters.AgentRunner{Runner: s.runner},
+		ResolveGenerateConfig: s.resolveReplayGenerateConfig,
+		Logger:                s.log(),
+	})
+}
+
+// turnTraceSink is handed to the agent service; it is nil unless the trace
+// store is enabled so untraced turns skip recording entirely.
+func (s *Server) turnTraceSink() func(domain.TurnTrace) {
+	if !s.cfg.TurnTraceEnabled {
+		return nil
+	}
+	return s.getTurnTraceService().Record
+}
+
+func (s *Server) resolveReplayGenerateConfig(providerID, model string) (runner.GenerateConfig, error) {
+	providerID = normalizeProviderID(providerID)
+	if providerID == runner.ProviderDemo {
+		return runner.GenerateConfig{ProviderID: runner.ProviderDemo, Model: model, AdapterID: provider.AdapterDemo}, nil
+	}
+	setting := repo.ProviderSetting{}
+	found := false
+	s.store.Read(func(state *repo.State) {
+		setting, found = findProviderSettingByID(state, providerID)
+	})
+	if !found {
+		return runner.GenerateConfig{}, &turntraceservice.ValidationError{Code: "provider_not_found", Message: "provider is not configured"}
+	}
+	cfg, processErr := buildProviderGenerateConfig(domain.ModelSlotConfig{ProviderID: providerID, Model: model}, setting)
+	if processErr != nil {
+		return runner.GenerateConfig{}, &turntraceservice.ValidationError{Code: processErr.Code, Message: processErr.Message}
+	}
+	return cfg, nil
+}
+
+func (s *Server) getTurnTrace(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.TurnTraceEnabled {
+		writeTurnTraceDisabled(w)
+		return
+	}
+	trace, err := s.getTurnTraceService().Get(chi.URLParam(r, "turn_id"))
+	if err == nil {
+		if userID := boundUserID(r.Context()); userID != "" && trace.UserID != userID {
+			err = turntraceservice.ErrTraceNotFound
+		}
+	}
+	if err != nil {
+		writeTurnTraceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, trace)
+}
+
+func (s *Server) replayTurn(w http.ResponseWriter, r *http.Request) {
+	if !s.cfg.TurnTraceEnabled {
+		writeTurnTraceDisabled(w)
+		return
+	}
+	var req domain.TurnReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeErr(w, http.StatusBadRequest, "invalid_json", "invalid request body", nil)
+		return
+	}
+	turnID := chi.URLParam(r, "turn_id")
+	if userID := boundUserID(r.Context()); userID != "" {
+		trace, err := s.getTurnTraceService().Get(turnID)
+		if err == nil && trace.UserID != userID {
+			err = turntraceservice.ErrTraceNotFound
+		}
+		if err != nil {
+			writeTurnTraceError(w, err)
+			return
+		}
+	}
+	result, err := s.getTurnTraceService().Replay(r.Context(), turnID, req)
+	if err != nil {
+		writeTurnTraceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeTurnTraceDisabled(w http.ResponseWriter) {
+	writeErr(w, http.StatusNotFound, "turn_trace_disabled", "turn trace store is disabled (set NEXTAI_TURN_TRACE_ENABLED=true)", nil)
+}
+
+func writeTurnTraceError(w http.ResponseWriter, err error) {
+	validation := (*turntraceservice.ValidationError)(nil)
+	switch {
+	case errors.As(err, &validation):
+		writeErr(w, http.StatusBadRequest, validation.Code, validation.Message, nil)
+	case errors.Is(err, turntraceservice.ErrTraceNotFound):
+		writeErr(w, http.StatusNotFound, "not_found", "turn trace not found", nil)
+	default:
+		writeErr(w, http.StatusInternalServerError, "turn_trace_error", strings.TrimSpace(err.Error()), nil)
+	}
+}
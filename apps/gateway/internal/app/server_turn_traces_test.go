@@ -0,0 +1,107 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nextai/apps/gateway/internal/config"
+	"nextai/apps/gateway/internal/domain"
+)
+
+func TestTurnTraceRecordsProviderExchangeAndReplays(t *testing.T) {
+	calls := 0
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		reply := "first answer"
+		if calls > 1 {
+			reply = "second answer"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]interface{}{"content": reply}}},
+		})
+	}))
+	defer mock.Close()
+
+	t.Setenv("NEXTAI_DISABLE_QQ_INBOUND_SUPERVISOR", "true")
+	srv, err := NewServer(config.Config{Host: "127.0.0.1", Port: "0", DataDir: t.TempDir(), TurnTraceEnabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	configureOpenAIProviderForTest(t, srv, mock.URL)
+
+	procReq := `{"input":[{"role":"user","type":"message","content":[{"type":"text","text":"hello"}]}],"session_id":"s-trace","user_id":"u-trace","channel":"console","stream":false}`
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/agent/process", strings.NewReader(procReq)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("process status=%d body=%s", w.Code, w.Body.String())
+	}
+	var processed domain.AgentProcessResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &processed); err != nil {
+		t.Fatalf("decode process response failed: %v", err)
+	}
+	turnID := ""
+	for _, event := range processed.Events {
+		if id, ok := event.Meta["turn_id"].(string); ok && id != "" {
+			turnID = id
+			break
+		}
+	}
+	if turnID == "" {
+		t.Fatalf("expected turn_id in event meta, events=%+v", processed.Events)
+	}
+
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/agent/turns/"+turnID+"/trace", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("trace status=%d body=%s", w.Code, w.Body.String())
+	}
+	var trace domain.TurnTrace
+	if err := json.Unmarshal(w.Body.Bytes(), &trace); err != nil {
+		t.Fatalf("decode trace failed: %v", err)
+	}
+	if trace.ProviderID != "openai" || trace.Status != "succeeded" || len(trace.Steps) != 1 {
+		t.Fatalf("unexpected trace: %+v", trace)
+	}
+	exchanges := trace.Steps[0].Exchanges
+	if len(exchanges) != 1 || exchanges[0].StatusCode != http.StatusOK {
+		t.Fatalf("expected one recorded exchange, got=%+v", exchanges)
+	}
+	if !strings.Contains(exchanges[0].RequestBody, "hello") || !strings.Contains(exchanges[0].ResponseBody, "first answer") {
+		t.Fatalf("expected raw request and response bodies, got=%+v", exchanges[0])
+	}
+	if strings.Contains(w.Body.String(), "sk-test") {
+		t.Fatalf("expected provider key to be redacted, body=%s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/agent/turns/"+turnID+"/replay", strings.NewReader(`{}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("replay status=%d body=%s", w.Code, w.Body.String())
+	}
+	var replay domain.TurnReplayResult
+	if err := json.Unmarshal(w.Body.Bytes(), &replay); err != nil {
+		t.Fatalf("decode replay failed: %v", err)
+	}
+	if replay.Replay.Text != "second answer" || !replay.Diff.TextChanged || len(replay.Exchanges) != 1 {
+		t.Fatalf("unexpected replay result: %+v", replay)
+	}
+
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/agent/turns/0123456789abcdef/trace", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing trace status=%d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestTurnTraceEndpointsDisabledByDefault(t *testing.T) {
+	srv := newTestServer(t)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/agent/turns/0123456789abcdef/trace", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "turn_trace_disabled") {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+}
@@ -20,6 +20,7 @@ func (s *Server) newWorkspaceService() *workspaceservice.Service {
 	return workspaceservice.NewService(workspaceservice.Dependencies{
 		Store:             s.stateStore,
 		DataDir:           s.cfg.DataDir,
+		Cipher:            s.secretCipher,
 		SupportedChannels: supportedChannels,
 		IsTextFilePath: func(path string) bool {
 			return isWorkspaceTextFilePath(path)
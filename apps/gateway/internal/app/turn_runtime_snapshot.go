@@ -52,6 +52,7 @@ type TurnRuntimeSnapshot struct {
 	Personality string `json:"personality,omitempty"`
 
 	runtimeToolSpecs map[string]turnRuntimeToolSpec
+	skills           skillPromptPlan
 }
 
 type TurnRuntimeModeSnapshot struct {
@@ -149,6 +150,7 @@ func (s *Server) buildTurnRuntimeSnapshotForInput(
 		}
 	}
 	snapshot.AvailableTools = s.resolveAvailableToolDefinitionNames(snapshot.Mode.PromptMode)
+	snapshot = s.applySkillPlanToSnapshot(snapshot, latestUserInputText(input))
 	return applyCollaborationModeToolConstraints(snapshot)
 }
 
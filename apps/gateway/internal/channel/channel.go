@@ -2,7 +2,7 @@ package channel
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"unicode/utf8"
 )
 
@@ -16,7 +16,11 @@ func (c *ConsoleChannel) Name() string {
 	return "console"
 }
 
-func (c *ConsoleChannel) SendText(_ context.Context, _ string, _ string, text string, _ map[string]interface{}) error {
-	log.Printf("[console] outbound message delivered chars=%d", utf8.RuneCountInString(text))
+func (c *ConsoleChannel) SupportsProactive() bool {
+	return true
+}
+
+func (c *ConsoleChannel) SendText(ctx context.Context, _ string, _ string, text string, _ map[string]interface{}) error {
+	slog.InfoContext(ctx, "console outbound message delivered", "channel", "console", "chars", utf8.RuneCountInString(text))
 	return nil
 }
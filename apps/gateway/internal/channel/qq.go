@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,22 +24,48 @@ const (
 	qqTokenRefreshAhead = 5 * time.Minute
 	qqMessageSeqLimit   = 1000
 	qqMessageSeqTrimTo  = 500
+
+	defaultQQProactiveMonthlyLimit = 4
 )
 
+var ErrQQProactiveQuotaExceeded = errors.New("qq_proactive_quota_exceeded")
+
+type qqProactiveUsage struct {
+	Period string `json:"period"`
+	Count  int    `json:"count"`
+}
+
 type QQChannel struct {
-	mu           sync.Mutex
-	token        string
-	tokenExpire  time.Time
-	tokenCacheID string
-	messageSeq   map[string]int
+	mu              sync.Mutex
+	token           string
+	tokenExpire     time.Time
+	tokenCacheID    string
+	messageSeq      map[string]int
+	proactive       map[string]qqProactiveUsage
+	proactiveFile   string
+	proactiveLoaded bool
+	now             func() time.Time
 }
 
 func NewQQChannel() *QQChannel {
+	return NewQQChannelWithStateFile("")
+}
+
+// NewQQChannelWithStateFile keeps proactive quota usage in stateFile so the
+// monthly limit survives restarts. An empty path keeps it in memory only.
+func NewQQChannelWithStateFile(stateFile string) *QQChannel {
 	return &QQChannel{
-		messageSeq: map[string]int{},
+		messageSeq:    map[string]int{},
+		proactive:     map[string]qqProactiveUsage{},
+		proactiveFile: stateFile,
+		now:           time.Now,
 	}
 }
 
+func (c *QQChannel) SupportsProactive() bool {
+	return true
+}
+
 func (c *QQChannel) Name() string {
 	return "qq"
 }
@@ -68,6 +97,15 @@ func (c *QQChannel) SendText(ctx context.Context, userID, _ string, text string,
 		return fmt.Errorf("channel qq requires config.target_id for target_type %q", targetType)
 	}
 
+	msgID := strings.TrimSpace(toString(cfg["msg_id"]))
+	proactiveKey := ""
+	if msgID == "" && targetType != "guild" {
+		proactiveKey = targetType + ":" + targetID
+		if err := c.checkProactiveQuota(proactiveKey, cfg["proactive_monthly_limit"]); err != nil {
+			return err
+		}
+	}
+
 	timeout := toDurationSeconds(cfg["timeout_seconds"], defaultQQTimeout)
 	requestCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
@@ -81,7 +119,6 @@ func (c *QQChannel) SendText(ctx context.Context, userID, _ string, text string,
 		return err
 	}
 
-	msgID := strings.TrimSpace(toString(cfg["msg_id"]))
 	body := map[string]interface{}{
 		"content": content,
 	}
@@ -111,9 +148,119 @@ func (c *QQChannel) SendText(ctx context.Context, userID, _ string, text string,
 	if err := sendQQAPIRequest(requestCtx, token, baseURL+path, body); err != nil {
 		return err
 	}
+	if proactiveKey != "" {
+		c.recordProactiveSend(proactiveKey)
+	}
+	return nil
+}
+
+func (c *QQChannel) checkProactiveQuota(key string, rawLimit interface{}) error {
+	limit := qqProactiveMonthlyLimit(rawLimit)
+	if limit < 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadProactiveLocked()
+	used := 0
+	if usage, ok := c.proactive[key]; ok && usage.Period == c.proactivePeriod() {
+		used = usage.Count
+	}
+	if used >= limit {
+		return fmt.Errorf("%w: target %s already received %d proactive messages this month", ErrQQProactiveQuotaExceeded, key, used)
+	}
 	return nil
 }
 
+func qqProactiveMonthlyLimit(raw interface{}) int {
+	switch value := raw.(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case string:
+		if parsed, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return parsed
+		}
+	}
+	return defaultQQProactiveMonthlyLimit
+}
+
+func (c *QQChannel) recordProactiveSend(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadProactiveLocked()
+	period := c.proactivePeriod()
+	usage := c.proactive[key]
+	if usage.Period != period {
+		usage = qqProactiveUsage{Period: period}
+	}
+	usage.Count++
+	c.proactive[key] = usage
+	c.saveProactiveLocked(period)
+}
+
+func (c *QQChannel) loadProactiveLocked() {
+	if c.proactive == nil {
+		c.proactive = map[string]qqProactiveUsage{}
+	}
+	if c.proactiveLoaded || c.proactiveFile == "" {
+		return
+	}
+	c.proactiveLoaded = true
+	body, err := os.ReadFile(c.proactiveFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Error("qq proactive quota load failed", "channel", "qq", "err", err)
+		}
+		return
+	}
+	loaded := map[string]qqProactiveUsage{}
+	if err := json.Unmarshal(body, &loaded); err != nil {
+		slog.Error("qq proactive quota load failed", "channel", "qq", "err", err)
+		return
+	}
+	for key, usage := range loaded {
+		if _, ok := c.proactive[key]; !ok {
+			c.proactive[key] = usage
+		}
+	}
+}
+
+// saveProactiveLocked drops targets from earlier months, which no longer
+// count against the quota, and writes the rest atomically.
+func (c *QQChannel) saveProactiveLocked(period string) {
+	for key, usage := range c.proactive {
+		if usage.Period != period {
+			delete(c.proactive, key)
+		}
+	}
+	if c.proactiveFile == "" {
+		return
+	}
+	body, err := json.MarshalIndent(c.proactive, "", "  ")
+	if err == nil {
+		tmp := c.proactiveFile + ".tmp"
+		if err = os.WriteFile(tmp, body, 0o600); err == nil {
+			err = os.Rename(tmp, c.proactiveFile)
+		}
+	}
+	if err != nil {
+		slog.Error("qq proactive quota persist failed", "channel", "qq", "err", err)
+	}
+}
+
+func (c *QQChannel) proactivePeriod() string {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	return now().UTC().Format("2006-01")
+}
+
 func normalizeQQTargetType(raw interface{}) string {
 	switch strings.ToLower(strings.TrimSpace(toString(raw))) {
 	case "group":
@@ -3,10 +3,13 @@ package channel
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestQQChannelSendTextC2C(t *testing.T) {
@@ -110,3 +113,102 @@ func TestQQChannelCachesTokenAcrossCalls(t *testing.T) {
 		t.Fatalf("expected two message calls, got=%d", got)
 	}
 }
+
+func TestQQChannelEnforcesProactiveQuota(t *testing.T) {
+	var messageCalls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"quota-token","expires_in":7200}`))
+		case "/v2/users/u-1/messages":
+			messageCalls.Add(1)
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	channel := NewQQChannel()
+	channel.now = func() time.Time { return now }
+	cfg := map[string]interface{}{
+		"app_id":                  "app-1",
+		"client_secret":           "secret-1",
+		"token_url":               server.URL + "/token",
+		"api_base":                server.URL,
+		"target_type":             "c2c",
+		"proactive_monthly_limit": float64(2),
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := channel.SendText(context.Background(), "u-1", "s-1", "ping", cfg); err != nil {
+			t.Fatalf("send %d failed: %v", i, err)
+		}
+	}
+	if err := channel.SendText(context.Background(), "u-1", "s-1", "ping", cfg); !errors.Is(err, ErrQQProactiveQuotaExceeded) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+
+	passive := map[string]interface{}{}
+	for key, value := range cfg {
+		passive[key] = value
+	}
+	passive["msg_id"] = "m-1"
+	if err := channel.SendText(context.Background(), "u-1", "s-1", "reply", passive); err != nil {
+		t.Fatalf("passive reply should bypass quota: %v", err)
+	}
+
+	now = now.Add(24 * time.Hour)
+	if err := channel.SendText(context.Background(), "u-1", "s-1", "ping", cfg); err != nil {
+		t.Fatalf("quota should reset next month: %v", err)
+	}
+	if got := messageCalls.Load(); got != 4 {
+		t.Fatalf("expected four message calls, got=%d", got)
+	}
+}
+
+func TestQQChannelPersistsProactiveQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"quota-token","expires_in":7200}`))
+		case "/v2/groups/g-1/messages":
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	stateFile := filepath.Join(t.TempDir(), "qq-proactive.json")
+	cfg := map[string]interface{}{
+		"app_id":                  "app-1",
+		"client_secret":           "secret-1",
+		"token_url":               server.URL + "/token",
+		"api_base":                server.URL,
+		"target_type":             "group",
+		"target_id":               "g-1",
+		"proactive_monthly_limit": float64(1),
+	}
+
+	first := NewQQChannelWithStateFile(stateFile)
+	first.now = func() time.Time { return now }
+	if err := first.SendText(context.Background(), "", "s-1", "ping", cfg); err != nil {
+		t.Fatalf("send failed: %v", err)
+	}
+
+	restarted := NewQQChannelWithStateFile(stateFile)
+	restarted.now = func() time.Time { return now }
+	if err := restarted.SendText(context.Background(), "", "s-1", "ping", cfg); !errors.Is(err, ErrQQProactiveQuotaExceeded) {
+		t.Fatalf("expected quota to survive restart, got %v", err)
+	}
+	now = now.AddDate(0, 1, 0)
+	if err := restarted.SendText(context.Background(), "", "s-1", "ping", cfg); err != nil {
+		t.Fatalf("quota should reset next month: %v", err)
+	}
+}
@@ -26,6 +26,10 @@ func (c *WebhookChannel) Name() string {
 	return "webhook"
 }
 
+func (c *WebhookChannel) SupportsProactive() bool {
+	return true
+}
+
 func (c *WebhookChannel) SendText(ctx context.Context, userID, sessionID, text string, cfg map[string]interface{}) error {
 	url := strings.TrimSpace(toString(cfg["url"]))
 	if url == "" {
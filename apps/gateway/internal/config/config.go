@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -10,12 +12,43 @@ type Config struct {
 	Port                           string
 	DataDir                        string
 	APIKey                         string
+	MasterKey                      string
+	MasterKeyFile                  string
 	WebDir                         string
 	EnablePromptTemplates          bool
 	EnablePromptContextIntrospect  bool
 	EnableCodexModeV2              bool
 	CodexPromptSource              string
 	EnableCodexPromptShadowCompare bool
+	CronRunHistoryLimit            int
+	CronRunHistoryMaxAgeDays       int
+	CronReminderMaxPerUser         int
+	TraceExporter                  string
+	TraceOTLPEndpoint              string
+	TraceOTLPHeaders               string
+	TraceFile                      string
+	LogLevel                       string
+	LogFormat                      string
+	TurnTraceEnabled               bool
+	TurnTraceLimit                 int
+	CORSAllowedOrigins             []string
+	CORSAllowedMethods             []string
+	CORSAllowedHeaders             []string
+	TLSCertFile                    string
+	TLSKeyFile                     string
+	TrustedProxies                 []string
+	RateLimitUserPerMinute         int
+	RateLimitUserBurst             int
+	RateLimitChannelPerMinute      int
+	RateLimitChannelBurst          int
+	RateLimitAPIKeyPerMinute       int
+	RateLimitAPIKeyBurst           int
+	TokenBudgetUserDaily           int
+	TokenBudgetUserMonthly         int
+	TokenBudgetChatDaily           int
+	TokenBudgetChatMonthly         int
+	ProviderProbeIntervalSeconds   int
+	ProviderFailover               []string
 }
 
 func Load() Config {
@@ -32,23 +65,64 @@ func Load() Config {
 		dataDir = ".data"
 	}
 	apiKey := os.Getenv("NEXTAI_API_KEY")
+	masterKey := os.Getenv("NEXTAI_MASTER_KEY")
+	masterKeyFile := os.Getenv("NEXTAI_MASTER_KEY_FILE")
 	webDir := os.Getenv("NEXTAI_WEB_DIR")
 	enablePromptTemplates := parseEnvBool("NEXTAI_ENABLE_PROMPT_TEMPLATES")
 	enablePromptContextIntrospect := parseEnvBool("NEXTAI_ENABLE_PROMPT_CONTEXT_INTROSPECT")
 	enableCodexModeV2 := parseEnvBool("NEXTAI_ENABLE_CODEX_MODE_V2")
 	codexPromptSource := parseCodexPromptSource("NEXTAI_CODEX_PROMPT_SOURCE")
 	enableCodexPromptShadowCompare := parseEnvBool("NEXTAI_CODEX_PROMPT_SHADOW_COMPARE")
+	cronRunHistoryLimit := parseEnvPositiveInt("NEXTAI_CRON_RUN_HISTORY_LIMIT")
+	cronRunHistoryMaxAgeDays := parseEnvPositiveInt("NEXTAI_CRON_RUN_HISTORY_MAX_AGE_DAYS")
+	cronReminderMaxPerUser := parseEnvPositiveInt("NEXTAI_CRON_REMINDER_MAX_PER_USER")
+	traceExporter := strings.ToLower(strings.TrimSpace(os.Getenv("NEXTAI_TRACE_EXPORTER")))
+	traceFile := os.Getenv("NEXTAI_TRACE_FILE")
+	if traceFile == "" {
+		traceFile = filepath.Join(dataDir, "traces.jsonl")
+	}
 	return Config{
 		Host:                           host,
 		Port:                           port,
 		DataDir:                        dataDir,
 		APIKey:                         apiKey,
+		MasterKey:                      masterKey,
+		MasterKeyFile:                  masterKeyFile,
 		WebDir:                         webDir,
 		EnablePromptTemplates:          enablePromptTemplates,
 		EnablePromptContextIntrospect:  enablePromptContextIntrospect,
 		EnableCodexModeV2:              enableCodexModeV2,
 		CodexPromptSource:              codexPromptSource,
 		EnableCodexPromptShadowCompare: enableCodexPromptShadowCompare,
+		CronRunHistoryLimit:            cronRunHistoryLimit,
+		CronRunHistoryMaxAgeDays:       cronRunHistoryMaxAgeDays,
+		CronReminderMaxPerUser:         cronReminderMaxPerUser,
+		TraceExporter:                  traceExporter,
+		TraceOTLPEndpoint:              os.Getenv("NEXTAI_OTLP_ENDPOINT"),
+		TraceOTLPHeaders:               os.Getenv("NEXTAI_OTLP_HEADERS"),
+		TraceFile:                      traceFile,
+		LogLevel:                       strings.ToLower(strings.TrimSpace(os.Getenv("NEXTAI_LOG_LEVEL"))),
+		LogFormat:                      strings.ToLower(strings.TrimSpace(os.Getenv("NEXTAI_LOG_FORMAT"))),
+		TurnTraceEnabled:               parseEnvBool("NEXTAI_TURN_TRACE_ENABLED"),
+		TurnTraceLimit:                 parseEnvPositiveInt("NEXTAI_TURN_TRACE_LIMIT"),
+		CORSAllowedOrigins:             parseEnvList("NEXTAI_CORS_ALLOWED_ORIGINS"),
+		CORSAllowedMethods:             parseEnvList("NEXTAI_CORS_ALLOWED_METHODS"),
+		CORSAllowedHeaders:             parseEnvList("NEXTAI_CORS_ALLOWED_HEADERS"),
+		TLSCertFile:                    strings.TrimSpace(os.Getenv("NEXTAI_TLS_CERT_FILE")),
+		TLSKeyFile:                     strings.TrimSpace(os.Getenv("NEXTAI_TLS_KEY_FILE")),
+		TrustedProxies:                 parseEnvList("NEXTAI_TRUSTED_PROXIES"),
+		RateLimitUserPerMinute:         parseEnvPositiveInt("NEXTAI_RATE_LIMIT_USER_PER_MINUTE"),
+		RateLimitUserBurst:             parseEnvPositiveInt("NEXTAI_RATE_LIMIT_USER_BURST"),
+		RateLimitChannelPerMinute:      parseEnvPositiveInt("NEXTAI_RATE_LIMIT_CHANNEL_PER_MINUTE"),
+		RateLimitChannelBurst:          parseEnvPositiveInt("NEXTAI_RATE_LIMIT_CHANNEL_BURST"),
+		RateLimitAPIKeyPerMinute:       parseEnvPositiveInt("NEXTAI_RATE_LIMIT_API_KEY_PER_MINUTE"),
+		RateLimitAPIKeyBurst:           parseEnvPositiveInt("NEXTAI_RATE_LIMIT_API_KEY_BURST"),
+		TokenBudgetUserDaily:           parseEnvPositiveInt("NEXTAI_TOKEN_BUDGET_USER_DAILY"),
+		TokenBudgetUserMonthly:         parseEnvPositiveInt("NEXTAI_TOKEN_BUDGET_USER_MONTHLY"),
+		TokenBudgetChatDaily:           parseEnvPositiveInt("NEXTAI_TOKEN_BUDGET_CHAT_DAILY"),
+		TokenBudgetChatMonthly:         parseEnvPositiveInt("NEXTAI_TOKEN_BUDGET_CHAT_MONTHLY"),
+		ProviderProbeIntervalSeconds:   parseEnvPositiveInt("NEXTAI_PROVIDER_PROBE_INTERVAL_SECONDS"),
+		ProviderFailover:               parseEnvList("NEXTAI_PROVIDER_FAILOVER"),
 	}
 }
 
@@ -56,6 +130,25 @@ func parseEnvBool(key string) bool {
 	return strings.EqualFold(strings.TrimSpace(os.Getenv(key)), "true")
 }
 
+func parseEnvPositiveInt(key string) int {
+	value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil || value <= 0 {
+		return 0
+	}
+	return value
+}
+
+// parseEnvList splits a comma-separated value, dropping empty items.
+func parseEnvList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 func parseCodexPromptSource(key string) string {
 	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
 	case "catalog":
@@ -36,3 +36,34 @@ func TestLoadCodexPromptSourceInvalidFallsBackToFile(t *testing.T) {
 		t.Fatalf("expected invalid source to fallback file, got=%q", cfg.CodexPromptSource)
 	}
 }
+
+func TestLoadCronRunHistoryRetention(t *testing.T) {
+	t.Setenv("NEXTAI_CRON_RUN_HISTORY_LIMIT", "50")
+	t.Setenv("NEXTAI_CRON_RUN_HISTORY_MAX_AGE_DAYS", "-3")
+
+	cfg := Load()
+	if cfg.CronRunHistoryLimit != 50 {
+		t.Fatalf("expected cron run history limit 50, got=%d", cfg.CronRunHistoryLimit)
+	}
+	if cfg.CronRunHistoryMaxAgeDays != 0 {
+		t.Fatalf("expected invalid max age to fall back to 0, got=%d", cfg.CronRunHistoryMaxAgeDays)
+	}
+}
+
+func TestLoadHTTPEdgeSettings(t *testing.T) {
+	t.Setenv("NEXTAI_CORS_ALLOWED_ORIGINS", " https://a.example, ,https://b.example ")
+	t.Setenv("NEXTAI_CORS_ALLOWED_METHODS", "")
+	t.Setenv("NEXTAI_TRUSTED_PROXIES", "10.0.0.0/8,127.0.0.1")
+	t.Setenv("NEXTAI_TLS_CERT_FILE", " /etc/nextai/tls.crt ")
+
+	cfg := Load()
+	if len(cfg.CORSAllowedOrigins) != 2 || cfg.CORSAllowedOrigins[1] != "https://b.example" {
+		t.Fatalf("unexpected cors origins: %#v", cfg.CORSAllowedOrigins)
+	}
+	if cfg.CORSAllowedMethods != nil {
+		t.Fatalf("expected unset methods to stay nil, got=%#v", cfg.CORSAllowedMethods)
+	}
+	if len(cfg.TrustedProxies) != 2 || cfg.TLSCertFile != "/etc/nextai/tls.crt" {
+		t.Fatalf("unexpected proxies=%#v cert=%q", cfg.TrustedProxies, cfg.TLSCertFile)
+	}
+}
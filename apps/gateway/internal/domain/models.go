@@ -8,14 +8,36 @@ const (
 	DefaultChatChannel    = "console"
 	ChatMetaSystemDefault = "system_default"
 	ChatMetaActiveLLM     = "active_llm_override"
+	ChatMetaGeneration    = "generation_params"
+	ChatMetaForkedFrom    = "forked_from"
+
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
 
 	DefaultCronJobID       = "cron-default"
 	DefaultCronJobName     = "\u4f60\u597d\u6587\u672c\u4efb\u52a1"
 	DefaultCronJobText     = "\u4f60\u597d"
 	DefaultCronJobInterval = "60s"
 	CronMetaSystemDefault  = "system_default"
+
+	APIKeyScopeChat         = "chat"
+	APIKeyScopeAdminRead    = "admin:read"
+	APIKeyScopeAdminWrite   = "admin:write"
+	APIKeyScopeCron         = "cron"
+	APIKeyScopeSelfOps      = "selfops"
+	APIKeyScopeToolsExecute = "tools:execute"
 )
 
+var APIKeyScopes = []string{
+	APIKeyScopeChat,
+	APIKeyScopeAdminRead,
+	APIKeyScopeAdminWrite,
+	APIKeyScopeCron,
+	APIKeyScopeSelfOps,
+	APIKeyScopeToolsExecute,
+}
+
 type APIErrorBody struct {
 	Error APIError `json:"error"`
 }
@@ -43,23 +65,61 @@ type ChatActiveLLMOverride struct {
 	UpdatedAt  string `json:"updated_at"`
 }
 
+type GenerationParams struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int64   `json:"seed,omitempty"`
+}
+
 type RuntimeContent struct {
 	Type string `json:"type"`
 	Text string `json:"text,omitempty"`
 }
 
 type RuntimeMessage struct {
-	ID       string                 `json:"id,omitempty"`
-	Role     string                 `json:"role,omitempty"`
-	Type     string                 `json:"type,omitempty"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
-	Content  []RuntimeContent       `json:"content,omitempty"`
+	ID        string                 `json:"id,omitempty"`
+	ParentID  string                 `json:"parent_id,omitempty"`
+	Role      string                 `json:"role,omitempty"`
+	Type      string                 `json:"type,omitempty"`
+	CreatedAt string                 `json:"created_at,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Content   []RuntimeContent       `json:"content,omitempty"`
 }
 
 type ChatHistory struct {
 	Messages []RuntimeMessage `json:"messages"`
 }
 
+type ChatMessageNode struct {
+	RuntimeMessage
+	Children []string `json:"children,omitempty"`
+	Active   bool     `json:"active"`
+}
+
+type ChatMessageTree struct {
+	ChatID       string            `json:"chat_id"`
+	ActiveLeafID string            `json:"active_leaf_id,omitempty"`
+	Messages     []ChatMessageNode `json:"messages"`
+}
+
+type ChatMessageEditRequest struct {
+	Content []RuntimeContent `json:"content"`
+}
+
+type ChatBranchTurnResponse struct {
+	Reply    string           `json:"reply"`
+	Parsed   interface{}      `json:"parsed,omitempty"`
+	Events   []AgentEvent     `json:"events,omitempty"`
+	Messages []RuntimeMessage `json:"messages"`
+}
+
+type ChatForkResponse struct {
+	Chat     ChatSpec         `json:"chat"`
+	Messages []RuntimeMessage `json:"messages"`
+}
+
 type AgentInputMessage struct {
 	Role     string                 `json:"role"`
 	Type     string                 `json:"type"`
@@ -67,13 +127,26 @@ type AgentInputMessage struct {
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
+type ResponseFormat struct {
+	Type       string              `json:"type"`
+	JSONSchema *ResponseJSONSchema `json:"json_schema,omitempty"`
+}
+
+type ResponseJSONSchema struct {
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
 type AgentProcessRequest struct {
-	Input     []AgentInputMessage    `json:"input"`
-	SessionID string                 `json:"session_id"`
-	UserID    string                 `json:"user_id"`
-	Channel   string                 `json:"channel"`
-	Stream    bool                   `json:"stream"`
-	BizParams map[string]interface{} `json:"biz_params,omitempty"`
+	Input          []AgentInputMessage    `json:"input"`
+	SessionID      string                 `json:"session_id"`
+	UserID         string                 `json:"user_id"`
+	Channel        string                 `json:"channel"`
+	Stream         bool                   `json:"stream"`
+	ResponseFormat *ResponseFormat        `json:"response_format,omitempty"`
+	BizParams      map[string]interface{} `json:"biz_params,omitempty"`
 }
 
 type AgentToolCallPayload struct {
@@ -99,18 +172,40 @@ type AgentEvent struct {
 
 type AgentProcessResponse struct {
 	Reply  string       `json:"reply"`
+	Parsed interface{}  `json:"parsed,omitempty"`
 	Events []AgentEvent `json:"events,omitempty"`
+	// Usage is reported by the OpenAI-compatible endpoint only.
+	Usage AgentTokenUsage `json:"-"`
+}
+
+// AgentTokenUsage is the provider-reported usage of a turn, or an estimate
+// when the provider reports none.
+type AgentTokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type CronScheduleSpec struct {
-	Type     string `json:"type"`
-	Cron     string `json:"cron"`
-	Timezone string `json:"timezone"`
+	Type      string               `json:"type"`
+	Cron      string               `json:"cron"`
+	Timezone  string               `json:"timezone"`
+	RunAt     string               `json:"run_at,omitempty"`
+	Blackouts []CronBlackoutWindow `json:"blackouts,omitempty"`
+	Holidays  []string             `json:"holidays,omitempty"`
+}
+
+type CronBlackoutWindow struct {
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Weekdays []string `json:"weekdays,omitempty"`
 }
 
 type CronDispatchTarget struct {
-	UserID    string `json:"user_id"`
-	SessionID string `json:"session_id"`
+	UserID     string `json:"user_id"`
+	SessionID  string `json:"session_id"`
+	TargetType string `json:"target_type,omitempty"`
+	TargetID   string `json:"target_id,omitempty"`
 }
 
 type CronDispatchSpec struct {
@@ -122,9 +217,27 @@ type CronDispatchSpec struct {
 }
 
 type CronRuntimeSpec struct {
-	MaxConcurrency      int `json:"max_concurrency"`
-	TimeoutSeconds      int `json:"timeout_seconds"`
-	MisfireGraceSeconds int `json:"misfire_grace_seconds"`
+	MaxConcurrency      int                    `json:"max_concurrency"`
+	TimeoutSeconds      int                    `json:"timeout_seconds"`
+	MisfireGraceSeconds int                    `json:"misfire_grace_seconds"`
+	Retry               *CronRetryPolicy       `json:"retry,omitempty"`
+	OnFailure           *CronFailureNotifySpec `json:"on_failure,omitempty"`
+}
+
+type CronRetryPolicy struct {
+	MaxAttempts       int      `json:"max_attempts"`
+	BackoffSeconds    int      `json:"backoff_seconds,omitempty"`
+	BackoffMultiplier float64  `json:"backoff_multiplier,omitempty"`
+	MaxBackoffSeconds int      `json:"max_backoff_seconds,omitempty"`
+	RetryOn           []string `json:"retry_on,omitempty"`
+}
+
+type CronFailureNotifySpec struct {
+	Channel                  string             `json:"channel,omitempty"`
+	Target                   CronDispatchTarget `json:"target"`
+	WebhookURL               string             `json:"webhook_url,omitempty"`
+	WebhookHeaders           map[string]string  `json:"webhook_headers,omitempty"`
+	AfterConsecutiveFailures int                `json:"after_consecutive_failures,omitempty"`
 }
 
 type CronWorkflowSpec struct {
@@ -135,21 +248,34 @@ type CronWorkflowSpec struct {
 }
 
 type CronWorkflowNode struct {
-	ID              string  `json:"id"`
-	Type            string  `json:"type"`
-	Title           string  `json:"title,omitempty"`
-	X               float64 `json:"x"`
-	Y               float64 `json:"y"`
-	Text            string  `json:"text,omitempty"`
-	DelaySeconds    int     `json:"delay_seconds,omitempty"`
-	IfCondition     string  `json:"if_condition,omitempty"`
-	ContinueOnError bool    `json:"continue_on_error,omitempty"`
+	ID              string                 `json:"id"`
+	Type            string                 `json:"type"`
+	Title           string                 `json:"title,omitempty"`
+	X               float64                `json:"x"`
+	Y               float64                `json:"y"`
+	Text            string                 `json:"text,omitempty"`
+	DelaySeconds    int                    `json:"delay_seconds,omitempty"`
+	IfCondition     string                 `json:"if_condition,omitempty"`
+	Prompt          string                 `json:"prompt,omitempty"`
+	ProviderID      string                 `json:"provider_id,omitempty"`
+	Model           string                 `json:"model,omitempty"`
+	PromptMode      string                 `json:"prompt_mode,omitempty"`
+	Method          string                 `json:"method,omitempty"`
+	URL             string                 `json:"url,omitempty"`
+	Headers         map[string]string      `json:"headers,omitempty"`
+	Body            string                 `json:"body,omitempty"`
+	TimeoutSeconds  int                    `json:"timeout_seconds,omitempty"`
+	Tool            string                 `json:"tool,omitempty"`
+	ToolInput       map[string]interface{} `json:"tool_input,omitempty"`
+	OutputVar       string                 `json:"output_var,omitempty"`
+	ContinueOnError bool                   `json:"continue_on_error,omitempty"`
 }
 
 type CronWorkflowEdge struct {
-	ID     string `json:"id"`
-	Source string `json:"source"`
-	Target string `json:"target"`
+	ID        string `json:"id"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Condition string `json:"condition,omitempty"`
 }
 
 type CronWorkflowViewport struct {
@@ -163,17 +289,23 @@ type CronWorkflowExecution struct {
 	StartedAt   string                      `json:"started_at"`
 	FinishedAt  *string                     `json:"finished_at,omitempty"`
 	HadFailures bool                        `json:"had_failures"`
+	DryRun      bool                        `json:"dry_run,omitempty"`
 	Nodes       []CronWorkflowNodeExecution `json:"nodes"`
 }
 
 type CronWorkflowNodeExecution struct {
-	NodeID          string  `json:"node_id"`
-	NodeType        string  `json:"node_type"`
-	Status          string  `json:"status"`
-	ContinueOnError bool    `json:"continue_on_error"`
-	StartedAt       string  `json:"started_at"`
-	FinishedAt      *string `json:"finished_at,omitempty"`
-	Error           *string `json:"error,omitempty"`
+	NodeID          string            `json:"node_id"`
+	NodeType        string            `json:"node_type"`
+	Status          string            `json:"status"`
+	ContinueOnError bool              `json:"continue_on_error"`
+	StartedAt       string            `json:"started_at"`
+	FinishedAt      *string           `json:"finished_at,omitempty"`
+	Error           *string           `json:"error,omitempty"`
+	Branch          string            `json:"branch,omitempty"`
+	Outputs         map[string]string `json:"outputs,omitempty"`
+	Stubbed         bool              `json:"stubbed,omitempty"`
+	Preview         string            `json:"preview,omitempty"`
+	Resumed         bool              `json:"resumed,omitempty"`
 }
 
 type CronJobSpec struct {
@@ -187,7 +319,20 @@ type CronJobSpec struct {
 	Request  map[string]interface{} `json:"request,omitempty"`
 	Dispatch CronDispatchSpec       `json:"dispatch"`
 	Runtime  CronRuntimeSpec        `json:"runtime"`
+	Triggers []CronTriggerSpec      `json:"triggers,omitempty"`
 	Meta     map[string]interface{} `json:"meta"`
+	// CreatorScopes are the API key scopes of whoever last saved the job;
+	// scheduled runs execute with these scopes.
+	CreatorScopes []string `json:"creator_scopes,omitempty"`
+}
+
+type CronTriggerSpec struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+	Keyword string `json:"keyword,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Event   string `json:"event,omitempty"`
+	Secret  string `json:"secret,omitempty"`
 }
 
 type CronJobState struct {
@@ -197,6 +342,59 @@ type CronJobState struct {
 	LastError     *string                `json:"last_error,omitempty"`
 	Paused        bool                   `json:"paused,omitempty"`
 	LastExecution *CronWorkflowExecution `json:"last_execution,omitempty"`
+
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+}
+
+type CronRunLogEntry struct {
+	At      string `json:"at"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+type CronRunRecord struct {
+	RunID      string                      `json:"run_id"`
+	JobID      string                      `json:"job_id"`
+	Trigger    string                      `json:"trigger"`
+	Status     string                      `json:"status"`
+	StartedAt  string                      `json:"started_at"`
+	FinishedAt *string                     `json:"finished_at,omitempty"`
+	DurationMS int64                       `json:"duration_ms"`
+	Attempts   int                         `json:"attempts,omitempty"`
+	Error      *string                     `json:"error,omitempty"`
+	Reply      string                      `json:"reply,omitempty"`
+	Nodes      []CronWorkflowNodeExecution `json:"nodes,omitempty"`
+	Logs       []CronRunLogEntry           `json:"logs,omitempty"`
+}
+
+type CronRunList struct {
+	Runs   []CronRunRecord `json:"runs"`
+	Total  int             `json:"total"`
+	Offset int             `json:"offset"`
+	Limit  int             `json:"limit"`
+}
+
+type CronSchedulePreview struct {
+	JobID    string   `json:"job_id"`
+	Timezone string   `json:"timezone"`
+	NextRuns []string `json:"next_runs"`
+}
+
+type CronDryRunRequest struct {
+	Outputs map[string]string  `json:"outputs,omitempty"`
+	Trigger *CronDryRunTrigger `json:"trigger,omitempty"`
+}
+
+type CronDryRunTrigger struct {
+	Type      string `json:"type"`
+	Event     string `json:"event,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Channel   string `json:"channel,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Text      string `json:"text,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Body      string `json:"body,omitempty"`
 }
 
 type CronJobView struct {
@@ -244,6 +442,7 @@ type ProviderInfo struct {
 	APIKeyPrefix       string            `json:"api_key_prefix"`
 	Models             []ModelInfo       `json:"models"`
 	ReasoningEffort    string            `json:"reasoning_effort,omitempty"`
+	GenerationParams   *GenerationParams `json:"generation_params,omitempty"`
 	Store              bool              `json:"store"`
 	Headers            map[string]string `json:"headers,omitempty"`
 	TimeoutMS          int               `json:"timeout_ms,omitempty"`
@@ -292,3 +491,259 @@ type SkillSpec struct {
 }
 
 type ChannelConfigMap map[string]map[string]interface{}
+
+type APIKeyQuota struct {
+	DailyRequests   int `json:"daily_requests,omitempty"`
+	MonthlyRequests int `json:"monthly_requests,omitempty"`
+}
+
+type APIKeySpec struct {
+	ID        string       `json:"id"`
+	Label     string       `json:"label"`
+	Prefix    string       `json:"prefix"`
+	Scopes    []string     `json:"scopes"`
+	UserID    string       `json:"user_id,omitempty"`
+	ExpiresAt *string      `json:"expires_at,omitempty"`
+	Quota     *APIKeyQuota `json:"quota,omitempty"`
+	Disabled  bool         `json:"disabled,omitempty"`
+	CreatedAt string       `json:"created_at"`
+	UpdatedAt string       `json:"updated_at"`
+}
+
+type APIKeyUsage struct {
+	Day           string  `json:"day,omitempty"`
+	DayRequests   int     `json:"day_requests"`
+	Month         string  `json:"month,omitempty"`
+	MonthRequests int     `json:"month_requests"`
+	TotalRequests int     `json:"total_requests"`
+	LastUsedAt    *string `json:"last_used_at,omitempty"`
+}
+
+type APIKeyView struct {
+	APIKeySpec
+	Usage APIKeyUsage `json:"usage"`
+}
+
+type APIKeyCreateRequest struct {
+	Label     string       `json:"label"`
+	Scopes    []string     `json:"scopes"`
+	UserID    string       `json:"user_id,omitempty"`
+	ExpiresAt *string      `json:"expires_at,omitempty"`
+	Quota     *APIKeyQuota `json:"quota,omitempty"`
+}
+
+type APIKeyUpdateRequest struct {
+	Label     *string      `json:"label,omitempty"`
+	Scopes    []string     `json:"scopes,omitempty"`
+	UserID    *string      `json:"user_id,omitempty"`
+	ExpiresAt *string      `json:"expires_at,omitempty"`
+	Quota     *APIKeyQuota `json:"quota,omitempty"`
+	Disabled  *bool        `json:"disabled,omitempty"`
+}
+
+type APIKeyCreateResponse struct {
+	APIKeyView
+	Key string `json:"key"`
+}
+
+type ProviderExchange struct {
+	Method            string            `json:"method"`
+	URL               string            `json:"url"`
+	RequestHeaders    map[string]string `json:"request_headers,omitempty"`
+	RequestBody       string            `json:"request_body,omitempty"`
+	StatusCode        int               `json:"status_code,omitempty"`
+	ResponseBody      string            `json:"response_body,omitempty"`
+	ResponseTruncated bool              `json:"response_truncated,omitempty"`
+	Error             string            `json:"error,omitempty"`
+	StartedAt         string            `json:"started_at"`
+	DurationMS        int64             `json:"duration_ms"`
+}
+
+type TurnTraceToolCall struct {
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+type TurnTraceTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+type TurnTraceStep struct {
+	Step      int                 `json:"step"`
+	Input     []AgentInputMessage `json:"input"`
+	Exchanges []ProviderExchange  `json:"exchanges,omitempty"`
+	Text      string              `json:"text,omitempty"`
+	ToolCalls []TurnTraceToolCall `json:"tool_calls,omitempty"`
+	Error     string              `json:"error,omitempty"`
+}
+
+type TurnTrace struct {
+	ID             string          `json:"id"`
+	TraceID        string          `json:"trace_id,omitempty"`
+	SessionID      string          `json:"session_id"`
+	UserID         string          `json:"user_id"`
+	Channel        string          `json:"channel"`
+	PromptMode     string          `json:"prompt_mode,omitempty"`
+	ProviderID     string          `json:"provider_id"`
+	Model          string          `json:"model"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	Status         string          `json:"status"`
+	ErrorCode      string          `json:"error_code,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Reply          string          `json:"reply,omitempty"`
+	StartedAt      string          `json:"started_at"`
+	FinishedAt     string          `json:"finished_at"`
+	Tools          []TurnTraceTool `json:"tools,omitempty"`
+	Steps          []TurnTraceStep `json:"steps"`
+}
+
+type TurnReplayRequest struct {
+	ProviderID string `json:"provider_id,omitempty"`
+	Model      string `json:"model,omitempty"`
+	Step       int    `json:"step,omitempty"`
+}
+
+type TurnReplayOutcome struct {
+	ProviderID string              `json:"provider_id"`
+	Model      string              `json:"model"`
+	Text       string              `json:"text,omitempty"`
+	ToolCalls  []TurnTraceToolCall `json:"tool_calls,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+type TurnReplayDiff struct {
+	Changed          bool     `json:"changed"`
+	TextChanged      bool     `json:"text_changed"`
+	ToolCallsChanged bool     `json:"tool_calls_changed"`
+	TextDiff         []string `json:"text_diff,omitempty"`
+	AddedToolCalls   []string `json:"added_tool_calls,omitempty"`
+	RemovedToolCalls []string `json:"removed_tool_calls,omitempty"`
+}
+
+type TurnReplayResult struct {
+	TurnID    string             `json:"turn_id"`
+	Step      int                `json:"step"`
+	Original  TurnReplayOutcome  `json:"original"`
+	Replay    TurnReplayOutcome  `json:"replay"`
+	Diff      TurnReplayDiff     `json:"diff"`
+	Exchanges []ProviderExchange `json:"exchanges,omitempty"`
+}
+
+const (
+	AuditActorMaster    = "master"
+	AuditActorAPIKey    = "api_key"
+	AuditActorAgent     = "agent"
+	AuditActorWebhook   = "webhook"
+	AuditActorAnonymous = "anonymous"
+)
+
+type AuditActor struct {
+	Type      string `json:"type"`
+	KeyID     string `json:"key_id,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+type AuditChange struct {
+	Path   string      `json:"path"`
+	Op     string      `json:"op"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+type AuditEntry struct {
+	ID         string        `json:"id"`
+	Time       string        `json:"time"`
+	RequestID  string        `json:"request_id,omitempty"`
+	TraceID    string        `json:"trace_id,omitempty"`
+	Actor      AuditActor    `json:"actor"`
+	Action     string        `json:"action"`
+	Method     string        `json:"method,omitempty"`
+	Target     string        `json:"target"`
+	Status     int           `json:"status,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	BeforeHash string        `json:"before_hash"`
+	AfterHash  string        `json:"after_hash"`
+	Changes    []AuditChange `json:"changes,omitempty"`
+}
+
+const (
+	RateLimitScopeUser    = "user"
+	RateLimitScopeChannel = "channel"
+	RateLimitScopeAPIKey  = "api_key"
+	RateLimitScopeChat    = "chat"
+)
+
+type RateLimitRule struct {
+	PerMinute int `json:"per_minute"`
+	Burst     int `json:"burst"`
+}
+
+type TokenBudgetRule struct {
+	Daily   int `json:"daily"`
+	Monthly int `json:"monthly"`
+}
+
+type RateLimitConfig struct {
+	User       RateLimitRule   `json:"user"`
+	Channel    RateLimitRule   `json:"channel"`
+	APIKey     RateLimitRule   `json:"api_key"`
+	UserTokens TokenBudgetRule `json:"user_tokens"`
+	ChatTokens TokenBudgetRule `json:"chat_tokens"`
+}
+
+type RateLimitBucket struct {
+	Scope     string  `json:"scope"`
+	Key       string  `json:"key"`
+	Tokens    float64 `json:"tokens"`
+	Capacity  int     `json:"capacity"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type TokenBudgetUsage struct {
+	Scope        string  `json:"scope"`
+	Key          string  `json:"key"`
+	Day          string  `json:"day"`
+	DayTokens    int     `json:"day_tokens"`
+	DailyLimit   int     `json:"daily_limit"`
+	Month        string  `json:"month"`
+	MonthTokens  int     `json:"month_tokens"`
+	MonthlyLimit int     `json:"monthly_limit"`
+	TotalTokens  int     `json:"total_tokens"`
+	LastUsedAt   *string `json:"last_used_at,omitempty"`
+}
+
+type RateLimitStatus struct {
+	Config  RateLimitConfig    `json:"config"`
+	Buckets []RateLimitBucket  `json:"buckets"`
+	Budgets []TokenBudgetUsage `json:"budgets"`
+}
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+	HealthStatusDown     = "down"
+	HealthStatusUnknown  = "unknown"
+)
+
+type HealthCheck struct {
+	Kind          string `json:"kind"`
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	Critical      bool   `json:"critical"`
+	Detail        string `json:"detail,omitempty"`
+	LastSuccessAt string `json:"last_success_at,omitempty"`
+	LastFailureAt string `json:"last_failure_at,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	LatencyMS     int64  `json:"latency_ms,omitempty"`
+}
+
+type HealthStatus struct {
+	Status    string        `json:"status"`
+	Ready     bool          `json:"ready"`
+	CheckedAt string        `json:"checked_at"`
+	Checks    []HealthCheck `json:"checks"`
+}
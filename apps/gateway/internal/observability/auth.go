@@ -1,48 +1,154 @@
 package observability
 
 import (
+	"context"
 	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+
+	"nextai/apps/gateway/internal/domain"
 )
 
 var publicAuthBypass = map[string]bool{
 	"/healthz": true,
+	"/readyz":  true,
 	"/version": true,
 }
 
-func APIKey(requiredKey string) func(http.Handler) http.Handler {
-	required := strings.TrimSpace(requiredKey)
-	if required == "" {
-		return func(next http.Handler) http.Handler { return next }
+var (
+	ErrAPIKeyInvalid       = errors.New("api_key_invalid")
+	ErrAPIKeyExpired       = errors.New("api_key_expired")
+	ErrAPIKeyQuotaExceeded = errors.New("api_key_quota_exceeded")
+)
+
+type Principal struct {
+	KeyID  string
+	UserID string
+	Scopes []string
+}
+
+func (p Principal) HasScope(scope string) bool {
+	for _, item := range p.Scopes {
+		if item == scope {
+			return true
+		}
 	}
+	return false
+}
+
+type KeyResolver interface {
+	Enabled() bool
+	ResolveAPIKey(key string) (Principal, error)
+}
+
+type principalContextKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
+
+func MasterPrincipal() Principal {
+	return Principal{Scopes: append([]string(nil), domain.APIKeyScopes...)}
+}
+
+func Authenticate(masterKey string, resolver KeyResolver) func(http.Handler) http.Handler {
+	master := strings.TrimSpace(masterKey)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if publicAuthBypass[r.URL.Path] {
+			registry := resolver != nil && resolver.Enabled()
+			if (master == "" && !registry) || publicAuthBypass[r.URL.Path] {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			candidate := strings.TrimSpace(r.Header.Get("X-API-Key"))
-			if candidate == "" {
-				authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
-				if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
-					candidate = strings.TrimSpace(authHeader[7:])
+			candidate := requestAPIKey(r)
+			if master != "" && subtle.ConstantTimeCompare([]byte(candidate), []byte(master)) == 1 {
+				next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), MasterPrincipal())))
+				return
+			}
+			if candidate == "" || !registry {
+				writeAuthError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid api key")
+				return
+			}
+			principal, err := resolver.ResolveAPIKey(candidate)
+			if err != nil {
+				switch {
+				case errors.Is(err, ErrAPIKeyExpired):
+					writeAuthError(w, http.StatusUnauthorized, "api_key_expired", "api key has expired")
+				case errors.Is(err, ErrAPIKeyQuotaExceeded):
+					writeAuthError(w, http.StatusTooManyRequests, "api_key_quota_exceeded", "api key request quota exceeded")
+				default:
+					writeAuthError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid api key")
 				}
+				return
+			}
+			bound := r.Clone(WithPrincipal(r.Context(), principal))
+			if principal.UserID != "" {
+				query := bound.URL.Query()
+				query.Set("user_id", principal.UserID)
+				bound.URL.RawQuery = query.Encode()
+			}
+			next.ServeHTTP(w, bound)
+		})
+	}
+}
+
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return requireScope(func(*http.Request) string { return scope })
+}
+
+func RequireMethodScope(readScope, writeScope string) func(http.Handler) http.Handler {
+	return requireScope(func(r *http.Request) string {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return readScope
+		}
+		return writeScope
+	})
+}
+
+func requireScope(resolve func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
 			}
-			if subtle.ConstantTimeCompare([]byte(candidate), []byte(required)) != 1 {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnauthorized)
-				_ = json.NewEncoder(w).Encode(map[string]any{
-					"error": map[string]any{
-						"code":    "unauthorized",
-						"message": "missing or invalid api key",
-					},
-				})
+			if scope := resolve(r); !principal.HasScope(scope) {
+				writeAuthError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("api key lacks scope %q", scope))
 				return
 			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+func requestAPIKey(r *http.Request) string {
+	candidate := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if candidate == "" {
+		authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+		if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+			candidate = strings.TrimSpace(authHeader[7:])
+		}
+	}
+	return candidate
+}
+
+func writeAuthError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
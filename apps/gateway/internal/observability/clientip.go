@@ -0,0 +1,115 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPContextKey struct{}
+
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPContextKey{}).(string)
+	return ip
+}
+
+// ParseTrustedProxies accepts IP addresses and CIDR ranges.
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(values))
+	for _, raw := range values {
+		value := strings.TrimSpace(raw)
+		if value == "" {
+			continue
+		}
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			out = append(out, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		addr = addr.Unmap()
+		out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return out, nil
+}
+
+// ClientIP resolves the client address and stores it in the request context
+// and RemoteAddr. X-Forwarded-For and X-Real-IP are only honoured when the
+// direct peer is a trusted proxy; the forwarded chain is walked from the right,
+// skipping trusted hops, so clients cannot spoof the address by prepending entries.
+func ClientIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			if ip != "" {
+				r.RemoteAddr = ip
+				r = r.WithContext(context.WithValue(r.Context(), clientIPContextKey{}, ip))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) string {
+	peer, ok := parseIP(r.RemoteAddr)
+	if !ok {
+		return ""
+	}
+	if !isTrustedProxy(peer, trusted) {
+		return peer.String()
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseIP(hops[i])
+		if !ok {
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop, trusted) {
+			return hop.String()
+		}
+	}
+	if len(hops) == 0 {
+		if realIP, ok := parseIP(r.Header.Get("X-Real-IP")); ok {
+			return realIP.String()
+		}
+	}
+	return client.String()
+}
+
+func parseIP(raw string) (netip.Addr, bool) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return netip.Addr{}, false
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(value, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
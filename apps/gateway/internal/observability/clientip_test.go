@@ -0,0 +1,54 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPHonoursForwardedHeadersOnlyFromTrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.7"})
+	if err != nil {
+		t.Fatalf("parse trusted proxies failed: %v", err)
+	}
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "untrusted peer ignores header", remoteAddr: "203.0.113.5:4000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.5"},
+		{name: "trusted peer uses forwarded client", remoteAddr: "10.1.2.3:4000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "spoofed left entries are skipped", remoteAddr: "10.1.2.3:4000", forwarded: []string{"1.1.1.1, 198.51.100.1, 192.0.2.7"}, want: "198.51.100.1"},
+		{name: "multiple header lines", remoteAddr: "192.0.2.7:80", forwarded: []string{"198.51.100.9", "10.0.0.1"}, want: "198.51.100.9"},
+		{name: "all hops trusted", remoteAddr: "10.1.2.3:4000", forwarded: []string{"10.9.9.9"}, want: "10.9.9.9"},
+		{name: "real ip fallback", remoteAddr: "10.1.2.3:4000", realIP: "198.51.100.4", want: "198.51.100.4"},
+		{name: "ipv6 peer", remoteAddr: "[2001:db8::1]:443", want: "2001:db8::1"},
+	}
+	for _, tc := range cases {
+		var gotContext, gotRemote string
+		handler := ClientIP(trusted)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			gotContext = ClientIPFromContext(r.Context())
+			gotRemote = r.RemoteAddr
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		for _, value := range tc.forwarded {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		if tc.realIP != "" {
+			req.Header.Set("X-Real-IP", tc.realIP)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if gotContext != tc.want || gotRemote != tc.want {
+			t.Fatalf("%s: context=%q remote=%q want=%q", tc.name, gotContext, gotRemote, tc.want)
+		}
+	}
+
+	if _, err := ParseTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Fatal("expected invalid trusted proxy error")
+	}
+}
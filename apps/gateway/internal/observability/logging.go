@@ -0,0 +1,328 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"nextai/apps/gateway/internal/secrets"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+
+	LogKeyRequestID = "request_id"
+	LogKeyClientIP  = "client_ip"
+	LogKeyTraceID   = "trace_id"
+	LogKeySpanID    = "span_id"
+	LogKeySessionID = "session_id"
+	LogKeyUserID    = "user_id"
+	LogKeyChannel   = "channel"
+	LogKeyTurnID    = "turn_id"
+	LogKeyStep      = "step"
+	LogKeyTool      = "tool"
+	LogKeyJobID     = "job_id"
+	LogKeyRunID     = "run_id"
+)
+
+var ErrLogConfigInvalid = errors.New("invalid log config")
+
+type LogConfig struct {
+	Level  string
+	Format string
+	Output io.Writer
+	// Secrets are literal values (API keys, master keys) masked wherever they
+	// appear in a message or attribute.
+	Secrets []string
+}
+
+// NewLogger builds the gateway logger. Every record is enriched with the request,
+// trace and turn fields carried by its context, and secrets are redacted before
+// the record reaches the output handler.
+func NewLogger(cfg LogConfig) (*slog.Logger, error) {
+	level, err := ParseLogLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var inner slog.Handler
+	switch strings.ToLower(strings.TrimSpace(cfg.Format)) {
+	case "", LogFormatText:
+		inner = slog.NewTextHandler(out, opts)
+	case LogFormatJSON:
+		inner = slog.NewJSONHandler(out, opts)
+	default:
+		return nil, fmt.Errorf("%w: unknown log format %q (use text or json)", ErrLogConfigInvalid, cfg.Format)
+	}
+	return slog.New(&contextHandler{inner: inner, redactor: newRedactor(cfg.Secrets)}), nil
+}
+
+func ParseLogLevel(raw string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("%w: unknown log level %q (use debug, info, warn or error)", ErrLogConfigInvalid, raw)
+	}
+}
+
+// SetDefaultLogger installs logger as the slog default and routes the standard
+// log package through it, so remaining log.Printf calls are structured and redacted.
+func SetDefaultLogger(logger *slog.Logger) {
+	if logger == nil {
+		return
+	}
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+}
+
+// LoggerOrDefault returns logger, or the slog default when it is nil.
+func LoggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+type logFieldsContextKey struct{}
+
+// WithLogFields attaches key/value pairs to ctx; every record logged with that
+// context carries them. Later values for the same key replace earlier ones.
+func WithLogFields(ctx context.Context, kv ...interface{}) context.Context {
+	if len(kv) == 0 {
+		return ctx
+	}
+	current := LogFieldsFromContext(ctx)
+	fields := make([]slog.Attr, 0, len(current)+len(kv)/2)
+	added := argsToAttrs(kv)
+	for _, attr := range current {
+		if !hasAttrKey(added, attr.Key) {
+			fields = append(fields, attr)
+		}
+	}
+	fields = append(fields, added...)
+	return context.WithValue(ctx, logFieldsContextKey{}, fields)
+}
+
+func LogFieldsFromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(logFieldsContextKey{}).([]slog.Attr)
+	return fields
+}
+
+// LogFieldFromContext returns the string value of a field added with WithLogFields.
+func LogFieldFromContext(ctx context.Context, key string) string {
+	for _, attr := range LogFieldsFromContext(ctx) {
+		if attr.Key == key {
+			return attr.Value.String()
+		}
+	}
+	return ""
+}
+
+func argsToAttrs(kv []interface{}) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok || key == "" {
+			continue
+		}
+		if text, isString := kv[i+1].(string); isString && strings.TrimSpace(text) == "" {
+			continue
+		}
+		attrs = append(attrs, slog.Any(key, kv[i+1]))
+	}
+	return attrs
+}
+
+func hasAttrKey(attrs []slog.Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+type contextHandler struct {
+	inner    slog.Handler
+	redactor *redactor
+	grouped  bool
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	out := slog.NewRecord(record.Time, record.Level, h.redactor.redactString(record.Message), record.PC)
+	own := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		own = append(own, h.redactor.redactAttr(attr))
+		return true
+	})
+	if ctx != nil && !h.grouped {
+		for _, attr := range contextAttrs(ctx) {
+			if !hasAttrKey(own, attr.Key) {
+				out.AddAttrs(h.redactor.redactAttr(attr))
+			}
+		}
+	}
+	out.AddAttrs(own...)
+	return h.inner.Handle(ctx, out)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redacted = append(redacted, h.redactor.redactAttr(attr))
+	}
+	return &contextHandler{inner: h.inner.WithAttrs(redacted), redactor: h.redactor, grouped: h.grouped}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{inner: h.inner.WithGroup(name), redactor: h.redactor, grouped: true}
+}
+
+func contextAttrs(ctx context.Context) []slog.Attr {
+	attrs := make([]slog.Attr, 0, 4)
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		attrs = append(attrs, slog.String(LogKeyRequestID, requestID))
+	}
+	if clientIP := ClientIPFromContext(ctx); clientIP != "" {
+		attrs = append(attrs, slog.String(LogKeyClientIP, clientIP))
+	}
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, slog.String(LogKeyTraceID, sc.TraceID.String()))
+		if sc.SpanID.IsValid() {
+			attrs = append(attrs, slog.String(LogKeySpanID, sc.SpanID.String()))
+		}
+	}
+	return append(attrs, LogFieldsFromContext(ctx)...)
+}
+
+const redactedLogValue = "[REDACTED]"
+
+type secretPattern struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+var secretValuePatterns = []secretPattern{
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{8,}`), "${1}" + redactedLogValue},
+	{regexp.MustCompile(`(?i)((?:api[_-]?key|access[_-]?token|refresh[_-]?token|token|secret|password|passwd|client[_-]?secret|authorization)["']?\s*[:=]\s*["']?)[^\s"',&;}]+`), "${1}" + redactedLogValue},
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{12,}`), redactedLogValue},
+	{regexp.MustCompile(`enc:v1:[A-Za-z0-9_:+/=-]+`), redactedLogValue},
+}
+
+type redactor struct {
+	literals []string
+}
+
+func newRedactor(values []string) *redactor {
+	r := &redactor{}
+	for _, value := range values {
+		if value = strings.TrimSpace(value); len(value) >= 6 {
+			r.literals = append(r.literals, value)
+		}
+	}
+	return r
+}
+
+// RedactSecrets masks credentials that look like bearer tokens, key=value secrets
+// or provider API keys inside free-form text.
+func RedactSecrets(text string) string {
+	return (&redactor{}).redactString(text)
+}
+
+func (r *redactor) redactString(text string) string {
+	if text == "" {
+		return text
+	}
+	for _, literal := range r.literals {
+		text = strings.ReplaceAll(text, literal, redactedLogValue)
+	}
+	for _, pattern := range secretValuePatterns {
+		text = pattern.re.ReplaceAllString(text, pattern.replacement)
+	}
+	return text
+}
+
+func (r *redactor) redactAttr(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if isSensitiveLogKey(attr.Key) && attr.Value.Kind() != slog.KindGroup {
+		if attr.Value.Kind() == slog.KindString && attr.Value.String() == "" {
+			return attr
+		}
+		return slog.String(attr.Key, redactedLogValue)
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, r.redactString(attr.Value.String()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]slog.Attr, 0, len(group))
+		for _, item := range group {
+			redacted = append(redacted, r.redactAttr(item))
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch value := attr.Value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, r.redactString(value.Error()))
+		case fmt.Stringer:
+			return slog.String(attr.Key, r.redactString(value.String()))
+		}
+	}
+	return attr
+}
+
+func isSensitiveLogKey(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(key)) {
+	case "password", "passwd", "token", "secret", "client_secret", "apikey":
+		return true
+	}
+	return secrets.IsSensitiveHeaderName(key)
+}
+
+// Logging writes one record per HTTP request with its route and outcome.
+func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			level := slog.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			LoggerOrDefault(logger).LogAttrs(r.Context(), level, "http request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.status),
+				slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decode log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggerAddsContextFieldsAndRedactsSecrets(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := NewLogger(LogConfig{Level: "debug", Format: LogFormatJSON, Output: &buf, Secrets: []string{"master-secret-value"}})
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	ctx := context.WithValue(context.Background(), requestIDContextKey{}, "req-1")
+	ctx = WithLogFields(ctx, LogKeySessionID, "s1", LogKeyUserID, "u1", LogKeyChannel, "console", LogKeyStep, 1)
+	ctx = WithLogFields(ctx, LogKeyStep, 2, LogKeyTool, "shell")
+
+	logger.InfoContext(ctx, "calling provider with Authorization: Bearer abcdefghijklmnop",
+		"api_key", "sk-live-1234567890abcdef",
+		"url", "https://example.com/hook?token=abc123&x=1",
+		"err", errors.New("upstream rejected key sk-abcdefghijklmnopqrst"),
+		"note", "uses master-secret-value",
+	)
+	records := decodeLogLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("expected one record, got=%d", len(records))
+	}
+	record := records[0]
+	for key, want := range map[string]interface{}{
+		"request_id": "req-1",
+		"session_id": "s1",
+		"user_id":    "u1",
+		"channel":    "console",
+		"step":       float64(2),
+		"tool":       "shell",
+		"api_key":    redactedLogValue,
+		"url":        "https://example.com/hook?token=" + redactedLogValue + "&x=1",
+		"err":        "upstream rejected key " + redactedLogValue,
+		"note":       "uses " + redactedLogValue,
+	} {
+		if record[key] != want {
+			t.Fatalf("expected %s=%v, got=%v (record=%v)", key, want, record[key], record)
+		}
+	}
+	if msg, _ := record["msg"].(string); strings.Contains(msg, "abcdefghijklmnop") {
+		t.Fatalf("expected bearer token redacted from message, got=%q", msg)
+	}
+}
+
+func TestLoggerLevelsAndFormats(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := NewLogger(LogConfig{Level: "warn", Format: "text", Output: &buf})
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "password", "hunter2")
+	out := buf.String()
+	if strings.Contains(out, "hidden") || !strings.Contains(out, "msg=shown") || strings.Contains(out, "hunter2") {
+		t.Fatalf("unexpected text output: %q", out)
+	}
+
+	if _, err := NewLogger(LogConfig{Level: "verbose"}); !errors.Is(err, ErrLogConfigInvalid) {
+		t.Fatalf("expected invalid level error, got=%v", err)
+	}
+	if _, err := NewLogger(LogConfig{Format: "xml"}); !errors.Is(err, ErrLogConfigInvalid) {
+		t.Fatalf("expected invalid format error, got=%v", err)
+	}
+}
+
+func TestLoggingMiddlewareIncludesRequestAndTraceIDs(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger, err := NewLogger(LogConfig{Format: LogFormatJSON, Output: &buf})
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	handler := RequestID(Tracing(Logging(logger)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))))
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Request-Id", "0123456789abcdef0123456789abcdef")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := decodeLogLines(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("expected one record, got=%d", len(records))
+	}
+	record := records[0]
+	if record["request_id"] != "0123456789abcdef0123456789abcdef" || record["trace_id"] != "0123456789abcdef0123456789abcdef" {
+		t.Fatalf("expected request and trace ids, got=%v", record)
+	}
+	if record["status"] != float64(http.StatusTeapot) || record["path"] != "/healthz" {
+		t.Fatalf("unexpected request fields: %v", record)
+	}
+}
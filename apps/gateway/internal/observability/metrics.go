@@ -0,0 +1,384 @@
+package observability
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	MetricResultOK    = "ok"
+	MetricResultError = "error"
+
+	unmatchedRoute = "unmatched"
+)
+
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// DefaultRegistry holds process-wide counters and histograms. Per-server gauges
+// live in their own Registry and are written after it by MetricsHandler.
+var DefaultRegistry = NewRegistry()
+
+var (
+	HTTPRequestsTotal = DefaultRegistry.NewCounterVec(
+		"nextai_http_requests_total", "HTTP requests by method, route pattern and status.",
+		"method", "route", "status",
+	)
+	HTTPRequestDuration = DefaultRegistry.NewHistogramVec(
+		"nextai_http_request_duration_seconds", "HTTP request latency by method and route pattern.",
+		DefaultLatencyBuckets, "method", "route",
+	)
+	ProviderTurnsTotal = DefaultRegistry.NewCounterVec(
+		"nextai_provider_turns_total", "Model turns by provider, model and result code.",
+		"provider", "model", "code",
+	)
+	ProviderTurnDuration = DefaultRegistry.NewHistogramVec(
+		"nextai_provider_turn_duration_seconds", "Model turn latency by provider and model.",
+		DefaultLatencyBuckets, "provider", "model",
+	)
+	ToolInvocationsTotal = DefaultRegistry.NewCounterVec(
+		"nextai_tool_invocations_total", "Tool invocations by tool name and result code.",
+		"tool", "code",
+	)
+	ToolInvocationDuration = DefaultRegistry.NewHistogramVec(
+		"nextai_tool_invocation_duration_seconds", "Tool invocation latency by tool name.",
+		DefaultLatencyBuckets, "tool",
+	)
+	CronRunsTotal = DefaultRegistry.NewCounterVec(
+		"nextai_cron_runs_total", "Cron run outcomes by job and status.",
+		"job_id", "status",
+	)
+	CronRunDuration = DefaultRegistry.NewHistogramVec(
+		"nextai_cron_run_duration_seconds", "Cron run latency by job.",
+		DefaultLatencyBuckets, "job_id",
+	)
+	ChannelSendsTotal = DefaultRegistry.NewCounterVec(
+		"nextai_channel_sends_total", "Outbound channel sends by channel and result.",
+		"channel", "result",
+	)
+	ChannelSendDuration = DefaultRegistry.NewHistogramVec(
+		"nextai_channel_send_duration_seconds", "Outbound channel send latency by channel.",
+		DefaultLatencyBuckets, "channel",
+	)
+	QQGatewayConnectsTotal = DefaultRegistry.NewCounterVec(
+		"nextai_qq_gateway_connects_total", "Successful QQ gateway websocket connections, including reconnects.",
+	)
+)
+
+type collector interface {
+	metricName() string
+	writeTo(w io.Writer)
+}
+
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []collector
+	names      map[string]struct{}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]struct{}{}}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.names[c.metricName()]; exists {
+		panic(fmt.Sprintf("observability: metric %s registered twice", c.metricName()))
+	}
+	r.names[c.metricName()] = struct{}{}
+	r.collectors = append(r.collectors, c)
+}
+
+func (r *Registry) Write(w io.Writer) {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.RUnlock()
+	for _, c := range collectors {
+		c.writeTo(w)
+	}
+}
+
+func MetricsHandler(registries ...*Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		var buf bytes.Buffer
+		for _, registry := range registries {
+			registry.Write(&buf)
+		}
+		w.Header().Set("Content-Type", MetricsContentType)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: map[string]*counterValue{}}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if c == nil || delta < 0 {
+		return
+	}
+	key, values := c.labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.values[key]
+	if !ok {
+		entry = &counterValue{labels: values}
+		c.values[key] = entry
+	}
+	entry.value += delta
+}
+
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	if c == nil {
+		return 0
+	}
+	key, _ := c.labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.values[key]; ok {
+		return entry.value
+	}
+	return 0
+}
+
+func (c *CounterVec) labelKey(labelValues []string) (string, []string) {
+	return labelKey(c.name, c.labels, labelValues)
+}
+
+func (c *CounterVec) metricName() string {
+	return c.name
+}
+
+func (c *CounterVec) writeTo(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		entry := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, entry.labels, "", ""), formatFloat(entry.value))
+	}
+}
+
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: sorted, values: map[string]*histogramValue{}}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	if h == nil || math.IsNaN(value) {
+		return
+	}
+	key, values := labelKey(h.name, h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.values[key]
+	if !ok {
+		entry = &histogramValue{labels: values, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = entry
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			entry.counts[i]++
+		}
+	}
+	entry.count++
+	entry.sum += value
+}
+
+func (h *HistogramVec) ObserveDuration(started time.Time, labelValues ...string) {
+	h.Observe(time.Since(started).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	if h == nil {
+		return 0
+	}
+	key, _ := labelKey(h.name, h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if entry, ok := h.values[key]; ok {
+		return entry.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) metricName() string {
+	return h.name
+}
+
+func (h *HistogramVec) writeTo(w io.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		entry := h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, entry.labels, "le", formatFloat(bound)), entry.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, entry.labels, "le", "+Inf"), entry.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, entry.labels, "", ""), formatFloat(entry.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, entry.labels, "", ""), entry.count)
+	}
+}
+
+type GaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, fn: fn}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) metricName() string {
+	return g.name
+}
+
+func (g *GaugeFunc) writeTo(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// HTTPMetrics records request counts and latency labelled by the chi route
+// pattern, so path parameters such as chat ids do not explode cardinality.
+func HTTPMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+		HTTPRequestsTotal.Inc(r.Method, route, strconv.Itoa(rec.status))
+		HTTPRequestDuration.ObserveDuration(start, r.Method, route)
+	})
+}
+
+func BoolGauge(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func labelKey(name string, labels, labelValues []string) (string, []string) {
+	if len(labelValues) != len(labels) {
+		panic(fmt.Sprintf("observability: metric %s expects %d labels, got %d", name, len(labels), len(labelValues)))
+	}
+	values := append([]string(nil), labelValues...)
+	return strings.Join(values, "\xff"), values
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	parts := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		parts = append(parts, name+`="`+escapeLabelValue(values[i])+`"`)
+	}
+	if extraName != "" {
+		parts = append(parts, extraName+`="`+escapeLabelValue(extraValue)+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,477 @@
+package observability
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	TraceParentHeader = "traceparent"
+	TraceIDHeader     = "X-Trace-Id"
+
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+
+	SpanStatusUnset = 0
+	SpanStatusOK    = 1
+	SpanStatusError = 2
+)
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Remote  bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// TraceParent renders the W3C traceparent header value; spans are always sampled.
+func (sc SpanContext) TraceParent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-01"
+}
+
+type SpanData struct {
+	Name       string                 `json:"name"`
+	Kind       int                    `json:"kind"`
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_span_id,omitempty"`
+	StartTime  time.Time              `json:"start_time"`
+	EndTime    time.Time              `json:"end_time"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Links      []SpanLink             `json:"links,omitempty"`
+	StatusCode int                    `json:"status_code,omitempty"`
+	StatusMsg  string                 `json:"status_message,omitempty"`
+}
+
+type SpanLink struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+}
+
+type Span struct {
+	mu       sync.Mutex
+	sc       SpanContext
+	parentID SpanID
+	name     string
+	kind     int
+	start    time.Time
+	attrs    map[string]interface{}
+	links    []SpanContext
+	status   int
+	message  string
+	ended    bool
+}
+
+type SpanOption func(*Span)
+
+func WithSpanKind(kind int) SpanOption {
+	return func(s *Span) { s.kind = kind }
+}
+
+func WithAttributes(kv ...interface{}) SpanOption {
+	return func(s *Span) { s.setAttributes(kv) }
+}
+
+// WithLinks links the new span to related spans without making them its parent,
+// e.g. a background sub-agent turn pointing back at the turn that spawned it.
+func WithLinks(links ...SpanContext) SpanOption {
+	return func(s *Span) {
+		for _, link := range links {
+			if link.IsValid() {
+				s.links = append(s.links, link)
+			}
+		}
+	}
+}
+
+type spanContextKey struct{}
+
+type remoteSpanContextKey struct{}
+
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.sc
+	}
+	if ctx == nil {
+		return SpanContext{}
+	}
+	sc, _ := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc
+}
+
+func TraceIDFromContext(ctx context.Context) string {
+	sc := SpanContextFromContext(ctx)
+	if !sc.TraceID.IsValid() {
+		return ""
+	}
+	return sc.TraceID.String()
+}
+
+// StartSpan starts a child of the span (or remote span context) carried by ctx,
+// or a new trace when ctx carries neither. Callers must End the returned span.
+func StartSpan(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := SpanContextFromContext(ctx)
+	span := &Span{name: name, kind: SpanKindInternal, start: time.Now()}
+	if parent.TraceID.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.parentID = parent.SpanID
+	} else {
+		span.sc.TraceID = newTraceID()
+	}
+	span.sc.SpanID = newSpanID()
+	for _, opt := range opts {
+		opt(span)
+	}
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.sc.TraceID.String()
+}
+
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *Span) SetAttributes(kv ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setAttributes(kv)
+}
+
+func (s *Span) setAttributes(kv []interface{}) {
+	if s.attrs == nil {
+		s.attrs = map[string]interface{}{}
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok || key == "" {
+			continue
+		}
+		s.attrs[key] = kv[i+1]
+	}
+}
+
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetStatus(SpanStatusError, err.Error())
+}
+
+func (s *Span) SetStatus(code int, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+	s.message = message
+}
+
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Name:       s.name,
+		Kind:       s.kind,
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		StartTime:  s.start,
+		EndTime:    time.Now(),
+		Attributes: s.attrs,
+		StatusCode: s.status,
+		StatusMsg:  s.message,
+	}
+	if s.parentID.IsValid() {
+		data.ParentID = s.parentID.String()
+	}
+	for _, link := range s.links {
+		data.Links = append(data.Links, SpanLink{TraceID: link.TraceID.String(), SpanID: link.SpanID.String()})
+	}
+	s.mu.Unlock()
+
+	if tracer := currentTracer(); tracer != nil {
+		tracer.enqueue(data)
+	}
+}
+
+// ParseTraceParent parses a W3C traceparent header value.
+func ParseTraceParent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Remote = true
+	return sc, true
+}
+
+// TraceIDFromRequestID maps an X-Request-Id onto a trace id: 32-hex ids are used
+// verbatim so generated request ids and trace ids match, anything else is hashed.
+func TraceIDFromRequestID(requestID string) TraceID {
+	var id TraceID
+	requestID = strings.TrimSpace(requestID)
+	if requestID == "" {
+		return id
+	}
+	if len(requestID) == 32 {
+		if _, err := hex.Decode(id[:], []byte(strings.ToLower(requestID))); err == nil && id.IsValid() {
+			return id
+		}
+	}
+	sum := sha256.Sum256([]byte(requestID))
+	copy(id[:], sum[:16])
+	return id
+}
+
+// Tracing starts the server span for each request. Trace context comes from an
+// incoming traceparent header, falling back to the request id.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		requestID := RequestIDFromContext(ctx)
+		if remote, ok := ParseTraceParent(r.Header.Get(TraceParentHeader)); ok {
+			ctx = ContextWithRemoteSpanContext(ctx, remote)
+		} else if traceID := TraceIDFromRequestID(requestID); traceID.IsValid() {
+			ctx = ContextWithRemoteSpanContext(ctx, SpanContext{TraceID: traceID})
+		}
+		ctx, span := StartSpan(ctx, "HTTP "+r.Method, WithSpanKind(SpanKindServer), WithAttributes(
+			"http.method", r.Method,
+			"http.target", r.URL.Path,
+			"http.request_id", requestID,
+		))
+		defer span.End()
+		w.Header().Set(TraceIDHeader, span.TraceID())
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(ctx); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttributes("http.route", pattern)
+			}
+		}
+		span.SetAttributes("http.status_code", rec.status)
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(SpanStatusError, http.StatusText(rec.status))
+		}
+	})
+}
+
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+type Tracer struct {
+	exporter SpanExporter
+	queue    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	dropped  atomic.Int64
+}
+
+const (
+	tracerQueueSize     = 2048
+	tracerBatchSize     = 256
+	tracerFlushInterval = 2 * time.Second
+	tracerExportTimeout = 10 * time.Second
+)
+
+var activeTracer atomic.Pointer[Tracer]
+
+func currentTracer() *Tracer {
+	return activeTracer.Load()
+}
+
+// StartTracer installs exporter as the process-wide span sink. The returned
+// function flushes pending spans and shuts the exporter down.
+func StartTracer(exporter SpanExporter) func(context.Context) error {
+	if exporter == nil {
+		return func(context.Context) error { return nil }
+	}
+	tracer := &Tracer{
+		exporter: exporter,
+		queue:    make(chan SpanData, tracerQueueSize),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go tracer.loop()
+	activeTracer.Store(tracer)
+	return func(ctx context.Context) error {
+		activeTracer.CompareAndSwap(tracer, nil)
+		close(tracer.done)
+		select {
+		case <-tracer.stopped:
+		case <-ctx.Done():
+		}
+		return exporter.Shutdown(ctx)
+	}
+}
+
+// ForceFlush exports queued spans synchronously; used by tests and shutdown.
+func ForceFlush() {
+	tracer := currentTracer()
+	if tracer == nil {
+		return
+	}
+	ack := make(chan struct{})
+	select {
+	case tracer.flush <- ack:
+		<-ack
+	case <-tracer.done:
+	}
+}
+
+func (t *Tracer) enqueue(span SpanData) {
+	select {
+	case t.queue <- span:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *Tracer) loop() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(tracerFlushInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, tracerBatchSize)
+	export := func() {
+		for {
+			select {
+			case span := <-t.queue:
+				batch = append(batch, span)
+				if len(batch) < tracerBatchSize {
+					continue
+				}
+			default:
+			}
+			if len(batch) == 0 {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), tracerExportTimeout)
+			if err := t.exporter.ExportSpans(ctx, batch); err != nil {
+				logTraceExportError(err, len(batch))
+			}
+			cancel()
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) >= tracerBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flush:
+			export()
+			close(ack)
+		case <-t.done:
+			export()
+			return
+		}
+	}
+}
+
+var traceExportErrorOnce sync.Once
+
+func logTraceExportError(err error, count int) {
+	traceExportErrorOnce.Do(func() {
+		slog.Warn("trace export failed, further export errors are suppressed", "spans", count, "err", err)
+	})
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	_, _ = rand.Read(id[:])
+	return id
+}
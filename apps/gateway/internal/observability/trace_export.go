@@ -0,0 +1,223 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TraceExporterNone   = "none"
+	TraceExporterOTLP   = "otlp"
+	TraceExporterFile   = "file"
+	TraceExporterStdout = "stdout"
+
+	DefaultOTLPEndpoint = "http://127.0.0.1:4318"
+	traceServiceName    = "nextai-gateway"
+	traceScopeName      = "nextai/apps/gateway"
+)
+
+var ErrTraceExporterUnknown = errors.New("unknown trace exporter")
+
+type TraceExporterConfig struct {
+	Exporter     string
+	OTLPEndpoint string
+	OTLPHeaders  string
+	File         string
+}
+
+// NewTraceExporter returns nil for the "none" exporter, in which case spans
+// still carry ids (for AgentEvent.Meta and logs) but are not exported.
+func NewTraceExporter(cfg TraceExporterConfig) (SpanExporter, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Exporter)) {
+	case "", TraceExporterNone:
+		return nil, nil
+	case TraceExporterOTLP:
+		endpoint := strings.TrimRight(strings.TrimSpace(cfg.OTLPEndpoint), "/")
+		if endpoint == "" {
+			endpoint = DefaultOTLPEndpoint
+		}
+		if !strings.HasSuffix(endpoint, "/v1/traces") {
+			endpoint += "/v1/traces"
+		}
+		return &OTLPHTTPExporter{
+			Endpoint: endpoint,
+			Headers:  parseOTLPHeaders(cfg.OTLPHeaders),
+			Client:   &http.Client{},
+		}, nil
+	case TraceExporterFile:
+		path := strings.TrimSpace(cfg.File)
+		if path == "" {
+			return nil, errors.New("trace file exporter requires a file path")
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return &WriterExporter{w: file, closer: file}, nil
+	case TraceExporterStdout:
+		return &WriterExporter{w: os.Stdout}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q (use none, otlp, file or stdout)", ErrTraceExporterUnknown, cfg.Exporter)
+	}
+}
+
+// WriterExporter writes one JSON span per line, for local debugging.
+type WriterExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+func (e *WriterExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, span := range spans {
+		if err := enc.Encode(span); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *WriterExporter) Shutdown(context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// OTLPHTTPExporter posts spans to an OTLP/HTTP collector using the JSON encoding.
+type OTLPHTTPExporter struct {
+	Endpoint string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+func (e *OTLPHTTPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(otlpTracesRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("otlp collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *OTLPHTTPExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func otlpTracesRequest(spans []SpanData) map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(spans))
+	for _, span := range spans {
+		item := map[string]interface{}{
+			"traceId":           span.TraceID,
+			"spanId":            span.SpanID,
+			"name":              span.Name,
+			"kind":              span.Kind,
+			"startTimeUnixNano": strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.Attributes),
+			"status":            map[string]interface{}{"code": span.StatusCode, "message": span.StatusMsg},
+		}
+		if span.ParentID != "" {
+			item["parentSpanId"] = span.ParentID
+		}
+		if len(span.Links) > 0 {
+			links := make([]map[string]interface{}, 0, len(span.Links))
+			for _, link := range span.Links {
+				links = append(links, map[string]interface{}{"traceId": link.TraceID, "spanId": link.SpanID})
+			}
+			item["links"] = links
+		}
+		out = append(out, item)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": traceServiceName}),
+			},
+			"scopeSpans": []map[string]interface{}{{
+				"scope": map[string]interface{}{"name": traceScopeName},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, map[string]interface{}{"key": key, "value": otlpAnyValue(attrs[key])})
+	}
+	return out
+}
+
+func otlpAnyValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+}
+
+func parseOTLPHeaders(raw string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		out[key] = strings.TrimSpace(value)
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	t.Parallel()
+
+	sc, ok := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || !sc.Remote {
+		t.Fatalf("expected valid remote span context, got=%+v ok=%v", sc, ok)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected ids: %+v", sc)
+	}
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceParent(invalid); ok {
+			t.Fatalf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestTraceIDFromRequestID(t *testing.T) {
+	t.Parallel()
+
+	if got := TraceIDFromRequestID("4BF92F3577B34DA6A3CE929D0E0E4736").String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected hex request id to be used verbatim, got=%s", got)
+	}
+	first := TraceIDFromRequestID("client-req-1")
+	if !first.IsValid() || first != TraceIDFromRequestID("client-req-1") {
+		t.Fatalf("expected stable hashed trace id, got=%s", first)
+	}
+	if TraceIDFromRequestID("  ").IsValid() {
+		t.Fatal("expected empty request id to yield no trace id")
+	}
+}
+
+func TestStartSpanInheritsParent(t *testing.T) {
+	t.Parallel()
+
+	remote := SpanContext{TraceID: TraceIDFromRequestID("req-parent")}
+	ctx, root := StartSpan(ContextWithRemoteSpanContext(context.Background(), remote), "root")
+	_, child := StartSpan(ctx, "child")
+	if root.SpanContext().TraceID != remote.TraceID || child.SpanContext().TraceID != remote.TraceID {
+		t.Fatalf("expected spans to join remote trace %s", remote.TraceID)
+	}
+	if child.SpanContext().SpanID == root.SpanContext().SpanID {
+		t.Fatal("expected child to get its own span id")
+	}
+	if TraceIDFromContext(ctx) != remote.TraceID.String() {
+		t.Fatalf("unexpected trace id in context: %s", TraceIDFromContext(ctx))
+	}
+}
+
+func TestTracingMiddlewareExportsServerSpan(t *testing.T) {
+	var buf bytes.Buffer
+	stop := StartTracer(NewWriterExporter(&buf))
+	defer func() { _ = stop(context.Background()) }()
+
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(Tracing)
+	r.Get("/chats/{chat_id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := StartSpan(r.Context(), "handler")
+		span.End()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/chats/c1", nil)
+	req.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(TraceIDHeader); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected traceparent trace id in response header, got=%q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/chats/c2", nil)
+	req.Header.Set("X-Request-Id", "0123456789abcdef0123456789abcdef")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(TraceIDHeader); got != "0123456789abcdef0123456789abcdef" {
+		t.Fatalf("expected request id to become trace id, got=%q", got)
+	}
+
+	ForceFlush()
+	spans := map[string]SpanData{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var span SpanData
+		if err := json.Unmarshal([]byte(line), &span); err != nil {
+			t.Fatalf("decode span line %q: %v", line, err)
+		}
+		spans[span.TraceID+"/"+span.Name] = span
+	}
+	server, ok := spans["4bf92f3577b34da6a3ce929d0e0e4736/GET /chats/{chat_id}"]
+	if !ok || server.ParentID != "00f067aa0ba902b7" || server.Kind != SpanKindServer {
+		t.Fatalf("expected server span parented to traceparent, got=%+v spans=%v", server, spans)
+	}
+	handler, ok := spans["4bf92f3577b34da6a3ce929d0e0e4736/handler"]
+	if !ok || handler.ParentID != server.SpanID {
+		t.Fatalf("expected handler span to be child of server span, got=%+v", handler)
+	}
+	if _, ok := spans["0123456789abcdef0123456789abcdef/GET /chats/{chat_id}"]; !ok {
+		t.Fatalf("expected server span for request id trace, spans=%v", spans)
+	}
+}
+
+func TestOTLPHTTPExporterPostsResourceSpans(t *testing.T) {
+	t.Parallel()
+
+	var body map[string]interface{}
+	var auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		auth = r.Header.Get("Authorization")
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	exporter, err := NewTraceExporter(TraceExporterConfig{
+		Exporter:     TraceExporterOTLP,
+		OTLPEndpoint: collector.URL,
+		OTLPHeaders:  "Authorization=Bearer abc",
+	})
+	if err != nil {
+		t.Fatalf("new exporter: %v", err)
+	}
+	span := SpanData{Name: "agent.turn", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Attributes: map[string]interface{}{"step": 1}}
+	if err := exporter.ExportSpans(context.Background(), []SpanData{span}); err != nil {
+		t.Fatalf("export spans: %v", err)
+	}
+	if auth != "Bearer abc" {
+		t.Fatalf("expected configured header, got=%q", auth)
+	}
+	raw, _ := json.Marshal(body)
+	for _, want := range []string{`"resourceSpans"`, `"nextai-gateway"`, `"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`, `"name":"agent.turn"`} {
+		if !strings.Contains(string(raw), want) {
+			t.Fatalf("expected %s in OTLP body, got=%s", want, raw)
+		}
+	}
+}
+
+func TestNewTraceExporterRejectsUnknownName(t *testing.T) {
+	t.Parallel()
+
+	if exporter, err := NewTraceExporter(TraceExporterConfig{}); exporter != nil || err != nil {
+		t.Fatalf("expected no exporter by default, got=%v err=%v", exporter, err)
+	}
+	if _, err := NewTraceExporter(TraceExporterConfig{Exporter: "jaeger"}); err == nil || !strings.Contains(err.Error(), "unknown trace exporter") {
+		t.Fatalf("expected unknown exporter error, got=%v", err)
+	}
+}
@@ -1,6 +1,10 @@
 package provider
 
-import "testing"
+import (
+	"testing"
+
+	"nextai/apps/gateway/internal/domain"
+)
 
 func TestResolveModelsIncludesCustomAliasTargetsForCustomProvider(t *testing.T) {
 	models := ResolveModels("custom-openai", map[string]string{
@@ -65,3 +69,91 @@ func TestResolveAdapterUsesCodexForCodexCompatibleProviderIDs(t *testing.T) {
 		t.Fatalf("expected openai-compatible adapter for custom-openai, got=%q", got)
 	}
 }
+
+func TestValidateGenerationParamsChecksModelCapabilities(t *testing.T) {
+	temperature := 0.7
+	maxTokens := 20000
+	model, ok := FindModel("openai", "gpt-4o-mini")
+	if !ok {
+		t.Fatalf("expected builtin model gpt-4o-mini")
+	}
+
+	if err := ValidateGenerationParams(domain.GenerationParams{Temperature: &temperature}, &model); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ValidateGenerationParams(domain.GenerationParams{MaxTokens: &maxTokens}, &model); err == nil {
+		t.Fatalf("expected max_tokens above output limit to fail")
+	}
+
+	model.Capabilities.Temperature = false
+	if err := ValidateGenerationParams(domain.GenerationParams{Temperature: &temperature}, &model); err == nil {
+		t.Fatalf("expected temperature to fail for model without temperature capability")
+	}
+
+	tooHot := 2.5
+	if err := ValidateGenerationParams(domain.GenerationParams{Temperature: &tooHot}, nil); err == nil {
+		t.Fatalf("expected out-of-range temperature to fail")
+	}
+}
+
+func TestCompatibleGenerationParamsDropsUnsupportedParams(t *testing.T) {
+	temperature := 0.7
+	maxTokens := 20000
+	seed := int64(7)
+	model, ok := FindModel("openai", "gpt-4o-mini")
+	if !ok {
+		t.Fatalf("expected builtin model gpt-4o-mini")
+	}
+	model.Capabilities.Temperature = false
+	params := &domain.GenerationParams{Temperature: &temperature, MaxTokens: &maxTokens, Stop: []string{"END"}, Seed: &seed}
+
+	got := CompatibleGenerationParams(params, AdapterCodexCompatible, &model)
+	if got.Temperature != nil || got.Stop != nil || got.Seed != nil {
+		t.Fatalf("expected unsupported params to be dropped, got=%+v", got)
+	}
+	if got.MaxTokens == nil || *got.MaxTokens != model.Limit.Output {
+		t.Fatalf("expected max_tokens capped at %d, got=%+v", model.Limit.Output, got.MaxTokens)
+	}
+	if err := ValidateGenerationParams(*got, &model); err != nil {
+		t.Fatalf("compatible params should validate: %v", err)
+	}
+	if *params.Temperature != temperature || len(params.Stop) != 1 {
+		t.Fatalf("input params should not be modified: %+v", params)
+	}
+}
+
+func TestValidateAdapterGenerationParamsRejectsCodexStopAndSeed(t *testing.T) {
+	seed := int64(7)
+	if err := ValidateAdapterGenerationParams(domain.GenerationParams{Stop: []string{"END"}}, AdapterCodexCompatible); err == nil {
+		t.Fatalf("expected stop to fail for codex adapter")
+	}
+	if err := ValidateAdapterGenerationParams(domain.GenerationParams{Seed: &seed}, AdapterCodexCompatible); err == nil {
+		t.Fatalf("expected seed to fail for codex adapter")
+	}
+	if err := ValidateAdapterGenerationParams(domain.GenerationParams{Stop: []string{"END"}, Seed: &seed}, AdapterOpenAICompatible); err != nil {
+		t.Fatalf("unexpected error for openai adapter: %v", err)
+	}
+}
+
+func TestMergeGenerationParamsLaterLayersOverride(t *testing.T) {
+	low := 0.1
+	high := 0.9
+	seed := int64(7)
+	merged := MergeGenerationParams(
+		&domain.GenerationParams{Temperature: &low, Seed: &seed, Stop: []string{"a"}},
+		nil,
+		&domain.GenerationParams{Temperature: &high},
+	)
+	if merged.Temperature == nil || *merged.Temperature != high {
+		t.Fatalf("expected temperature override, got=%v", merged.Temperature)
+	}
+	if merged.Seed == nil || *merged.Seed != seed {
+		t.Fatalf("expected inherited seed, got=%v", merged.Seed)
+	}
+	if len(merged.Stop) != 1 || merged.Stop[0] != "a" {
+		t.Fatalf("expected inherited stop, got=%v", merged.Stop)
+	}
+	if NormalizeGenerationParams(&domain.GenerationParams{Stop: []string{""}}) != nil {
+		t.Fatalf("expected empty params to normalize to nil")
+	}
+}
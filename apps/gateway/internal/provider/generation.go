@@ -0,0 +1,156 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"nextai/apps/gateway/internal/domain"
+)
+
+const (
+	maxGenerationStopSequences = 4
+	maxGenerationTemperature   = 2.0
+)
+
+func FindModel(providerID, modelID string) (ModelSpec, bool) {
+	id := strings.TrimSpace(modelID)
+	if id == "" {
+		return ModelSpec{}, false
+	}
+	spec := ResolveProvider(providerID)
+	for _, model := range spec.Models {
+		if model.ID == id {
+			return model, true
+		}
+	}
+	return ModelSpec{}, false
+}
+
+func NormalizeGenerationParams(in *domain.GenerationParams) *domain.GenerationParams {
+	if in == nil {
+		return nil
+	}
+	out := cloneGenerationParams(*in)
+	stop := make([]string, 0, len(out.Stop))
+	for _, item := range out.Stop {
+		if item == "" {
+			continue
+		}
+		stop = append(stop, item)
+	}
+	out.Stop = nil
+	if len(stop) > 0 {
+		out.Stop = stop
+	}
+	if generationParamsEmpty(out) {
+		return nil
+	}
+	return &out
+}
+
+func MergeGenerationParams(layers ...*domain.GenerationParams) domain.GenerationParams {
+	out := domain.GenerationParams{}
+	for _, layer := range layers {
+		if layer == nil {
+			continue
+		}
+		if layer.Temperature != nil {
+			value := *layer.Temperature
+			out.Temperature = &value
+		}
+		if layer.TopP != nil {
+			value := *layer.TopP
+			out.TopP = &value
+		}
+		if layer.MaxTokens != nil {
+			value := *layer.MaxTokens
+			out.MaxTokens = &value
+		}
+		if len(layer.Stop) > 0 {
+			out.Stop = append([]string{}, layer.Stop...)
+		}
+		if layer.Seed != nil {
+			value := *layer.Seed
+			out.Seed = &value
+		}
+	}
+	return out
+}
+
+func ValidateGenerationParams(params domain.GenerationParams, model *ModelSpec) error {
+	if params.Temperature != nil && (*params.Temperature < 0 || *params.Temperature > maxGenerationTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %g", maxGenerationTemperature)
+	}
+	if params.TopP != nil && (*params.TopP <= 0 || *params.TopP > 1) {
+		return errors.New("top_p must be > 0 and <= 1")
+	}
+	if params.MaxTokens != nil && *params.MaxTokens <= 0 {
+		return errors.New("max_tokens must be > 0")
+	}
+	if len(params.Stop) > maxGenerationStopSequences {
+		return fmt.Errorf("stop accepts at most %d sequences", maxGenerationStopSequences)
+	}
+	for _, item := range params.Stop {
+		if item == "" {
+			return errors.New("stop sequences cannot be empty")
+		}
+	}
+	if model == nil {
+		return nil
+	}
+	if !model.Capabilities.Temperature && (params.Temperature != nil || params.TopP != nil) {
+		return fmt.Errorf("model %q does not support temperature or top_p", model.ID)
+	}
+	if params.MaxTokens != nil && model.Limit.Output > 0 && *params.MaxTokens > model.Limit.Output {
+		return fmt.Errorf("max_tokens exceeds model %q output limit %d", model.ID, model.Limit.Output)
+	}
+	return nil
+}
+
+// ValidateAdapterGenerationParams rejects params the adapter's API has no
+// field for, instead of letting them drop silently.
+func ValidateAdapterGenerationParams(params domain.GenerationParams, adapterID string) error {
+	if adapterID != AdapterCodexCompatible {
+		return nil
+	}
+	if len(params.Stop) > 0 {
+		return fmt.Errorf("stop is not supported by adapter %q", adapterID)
+	}
+	if params.Seed != nil {
+		return fmt.Errorf("seed is not supported by adapter %q", adapterID)
+	}
+	return nil
+}
+
+// CompatibleGenerationParams drops the params that model, or the adapter
+// serving it, cannot take, and caps max_tokens at the model's output limit.
+func CompatibleGenerationParams(params *domain.GenerationParams, adapterID string, model *ModelSpec) *domain.GenerationParams {
+	if params == nil {
+		return nil
+	}
+	out := cloneGenerationParams(*params)
+	if adapterID == AdapterCodexCompatible {
+		out.Stop = nil
+		out.Seed = nil
+	}
+	if model != nil {
+		if !model.Capabilities.Temperature {
+			out.Temperature = nil
+			out.TopP = nil
+		}
+		if out.MaxTokens != nil && model.Limit.Output > 0 && *out.MaxTokens > model.Limit.Output {
+			limit := model.Limit.Output
+			out.MaxTokens = &limit
+		}
+	}
+	return &out
+}
+
+func cloneGenerationParams(in domain.GenerationParams) domain.GenerationParams {
+	return MergeGenerationParams(&in)
+}
+
+func generationParamsEmpty(in domain.GenerationParams) bool {
+	return in.Temperature == nil && in.TopP == nil && in.MaxTokens == nil && len(in.Stop) == 0 && in.Seed == nil
+}
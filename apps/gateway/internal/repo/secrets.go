@@ -0,0 +1,172 @@
+package repo
+
+import (
+	"fmt"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/secrets"
+)
+
+type StoreOption func(*Store)
+
+func WithCipher(cipher *secrets.Cipher) StoreOption {
+	return func(s *Store) {
+		s.cipher = cipher
+	}
+}
+
+type SecretFunc func(path, value string) (string, error)
+
+func WalkSecrets(
+	envs map[string]string,
+	channels domain.ChannelConfigMap,
+	providers map[string]ProviderSetting,
+	allEnvs bool,
+	fn SecretFunc,
+) error {
+	for key, value := range envs {
+		if !allEnvs && !secrets.IsSensitiveFieldName(key) {
+			continue
+		}
+		next, err := fn("envs."+key, value)
+		if err != nil {
+			return err
+		}
+		envs[key] = next
+	}
+	for name, cfg := range channels {
+		if err := walkChannelSecrets("channels."+name, cfg, fn); err != nil {
+			return err
+		}
+	}
+	for id, setting := range providers {
+		next, err := fn("providers."+id+".api_key", setting.APIKey)
+		if err != nil {
+			return err
+		}
+		setting.APIKey = next
+		for header, value := range setting.Headers {
+			if !secrets.IsSensitiveHeaderName(header) {
+				continue
+			}
+			next, err := fn("providers."+id+".headers."+header, value)
+			if err != nil {
+				return err
+			}
+			setting.Headers[header] = next
+		}
+		providers[id] = setting
+	}
+	return nil
+}
+
+func walkChannelSecrets(path string, cfg map[string]interface{}, fn SecretFunc) error {
+	for key, raw := range cfg {
+		switch value := raw.(type) {
+		case string:
+			if !secrets.IsSensitiveFieldName(key) {
+				continue
+			}
+			next, err := fn(path+"."+key, value)
+			if err != nil {
+				return err
+			}
+			cfg[key] = next
+		case map[string]interface{}:
+			if err := walkChannelSecrets(path+"."+key, value, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func CloneSecretFields(
+	envs map[string]string,
+	channels domain.ChannelConfigMap,
+	providers map[string]ProviderSetting,
+) (map[string]string, domain.ChannelConfigMap, map[string]ProviderSetting) {
+	outEnvs := make(map[string]string, len(envs))
+	for key, value := range envs {
+		outEnvs[key] = value
+	}
+	outChannels := make(domain.ChannelConfigMap, len(channels))
+	for name, cfg := range channels {
+		outChannels[name] = cloneSecretMap(cfg)
+	}
+	outProviders := make(map[string]ProviderSetting, len(providers))
+	for id, setting := range providers {
+		if setting.Headers != nil {
+			headers := make(map[string]string, len(setting.Headers))
+			for key, value := range setting.Headers {
+				headers[key] = value
+			}
+			setting.Headers = headers
+		}
+		outProviders[id] = setting
+	}
+	return outEnvs, outChannels, outProviders
+}
+
+func cloneSecretMap(in map[string]interface{}) map[string]interface{} {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(in))
+	for key, raw := range in {
+		if nested, ok := raw.(map[string]interface{}); ok {
+			out[key] = cloneSecretMap(nested)
+			continue
+		}
+		out[key] = raw
+	}
+	return out
+}
+
+func sealState(state State, cipher *secrets.Cipher) (State, error) {
+	if cipher == nil {
+		return state, nil
+	}
+	state.Envs, state.Channels, state.Providers = CloneSecretFields(state.Envs, state.Channels, state.Providers)
+	err := WalkSecrets(state.Envs, state.Channels, state.Providers, true, func(path, value string) (string, error) {
+		if value == "" || secrets.IsSealed(value) {
+			return value, nil
+		}
+		sealed, err := cipher.Seal(value)
+		if err != nil {
+			return "", fmt.Errorf("seal %s: %w", path, err)
+		}
+		return sealed, nil
+	})
+	return state, err
+}
+
+func openState(state *State, cipher *secrets.Cipher) (bool, error) {
+	plaintext := false
+	err := WalkSecrets(state.Envs, state.Channels, state.Providers, true, func(path, value string) (string, error) {
+		if !secrets.IsSealed(value) {
+			if value != "" {
+				plaintext = true
+			}
+			return value, nil
+		}
+		opened, err := cipher.Open(value)
+		if err != nil {
+			return "", fmt.Errorf("open %s: %w", path, err)
+		}
+		return opened, nil
+	})
+	return plaintext, err
+}
+
+func (s *Store) RotateCipher(next *secrets.Cipher) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.cipher
+	s.cipher = next
+	if err := s.saveLocked(); err != nil {
+		s.cipher = previous
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nextai/apps/gateway/internal/secrets"
+)
+
+func TestStoreEncryptsSecretsAtRestAndRotates(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	raw := `{
+  "schema_version": 1,
+  "envs": {"OPENAI_API_KEY": "sk-env", "MODE": "dev"},
+  "channels": {"qq": {"app_id": "1001", "client_secret": "qq-secret"}},
+  "providers": {"openai": {"api_key": "sk-provider", "headers": {"Authorization": "Bearer hdr"}}}
+}`
+	if err := os.WriteFile(statePath, []byte(raw), 0o644); err != nil {
+		t.Fatalf("write state failed: %v", err)
+	}
+
+	first := testCipher(t, "11")
+	store, err := NewStore(dir, WithCipher(first))
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	assertNoPlaintextSecrets(t, statePath)
+	store.Read(func(state *State) {
+		if state.Providers["openai"].APIKey != "sk-provider" || state.Channels["qq"]["client_secret"] != "qq-secret" {
+			t.Fatalf("expected decrypted secrets in memory, got=%+v %+v", state.Providers["openai"], state.Channels["qq"])
+		}
+	})
+
+	if _, err := NewStore(dir); err == nil {
+		t.Fatal("expected load without master key to fail")
+	}
+
+	second := testCipher(t, "22")
+	if err := store.RotateCipher(second); err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	assertNoPlaintextSecrets(t, statePath)
+	if _, err := NewStore(dir, WithCipher(first)); err == nil {
+		t.Fatal("expected load with the previous key to fail")
+	}
+	reloaded, err := NewStore(dir, WithCipher(second))
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	reloaded.Read(func(state *State) {
+		if state.Envs["OPENAI_API_KEY"] != "sk-env" || state.Envs["MODE"] != "dev" {
+			t.Fatalf("unexpected envs: %+v", state.Envs)
+		}
+		if state.Providers["openai"].Headers["Authorization"] != "Bearer hdr" {
+			t.Fatalf("unexpected headers: %+v", state.Providers["openai"].Headers)
+		}
+	})
+}
+
+func testCipher(t *testing.T, fill string) *secrets.Cipher {
+	t.Helper()
+	c, err := secrets.LoadCipher(strings.Repeat(fill, 32), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func assertNoPlaintextSecrets(t *testing.T, statePath string) {
+	t.Helper()
+	body, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state failed: %v", err)
+	}
+	for _, plain := range []string{"sk-env", "sk-provider", "qq-secret", "Bearer hdr", `"dev"`} {
+		if strings.Contains(string(body), plain) {
+			t.Fatalf("state.json contains plaintext %q", plain)
+		}
+	}
+	if !strings.Contains(string(body), secrets.SealedPrefix) {
+		t.Fatal("expected sealed values in state.json")
+	}
+}
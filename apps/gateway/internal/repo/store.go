@@ -11,18 +11,26 @@ import (
 	"time"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/provider"
+	"nextai/apps/gateway/internal/secrets"
 )
 
 type ProviderSetting struct {
-	APIKey          string            `json:"api_key"`
-	BaseURL         string            `json:"base_url"`
-	DisplayName     string            `json:"display_name,omitempty"`
-	ReasoningEffort string            `json:"reasoning_effort,omitempty"`
-	Enabled         *bool             `json:"enabled,omitempty"`
-	Store           *bool             `json:"store,omitempty"`
-	Headers         map[string]string `json:"headers,omitempty"`
-	TimeoutMS       int               `json:"timeout_ms,omitempty"`
-	ModelAliases    map[string]string `json:"model_aliases,omitempty"`
+	APIKey           string                   `json:"api_key"`
+	BaseURL          string                   `json:"base_url"`
+	DisplayName      string                   `json:"display_name,omitempty"`
+	ReasoningEffort  string                   `json:"reasoning_effort,omitempty"`
+	GenerationParams *domain.GenerationParams `json:"generation_params,omitempty"`
+	Enabled          *bool                    `json:"enabled,omitempty"`
+	Store            *bool                    `json:"store,omitempty"`
+	Headers          map[string]string        `json:"headers,omitempty"`
+	TimeoutMS        int                      `json:"timeout_ms,omitempty"`
+	ModelAliases     map[string]string        `json:"model_aliases,omitempty"`
+}
+
+type APIKeyRecord struct {
+	domain.APIKeySpec
+	KeyHash string `json:"key_hash"`
 }
 
 const currentStateSchemaVersion = 1
@@ -31,6 +39,7 @@ type State struct {
 	SchemaVersion int                                `json:"schema_version"`
 	Chats         map[string]domain.ChatSpec         `json:"chats"`
 	Histories     map[string][]domain.RuntimeMessage `json:"histories"`
+	Branches      map[string][]domain.RuntimeMessage `json:"history_branches,omitempty"`
 	CronJobs      map[string]domain.CronJobSpec      `json:"cron_jobs"`
 	CronStates    map[string]domain.CronJobState     `json:"cron_states"`
 	Providers     map[string]ProviderSetting         `json:"providers"`
@@ -38,15 +47,43 @@ type State struct {
 	Envs          map[string]string                  `json:"envs"`
 	Skills        map[string]domain.SkillSpec        `json:"skills"`
 	Channels      domain.ChannelConfigMap            `json:"channels"`
+	APIKeys       map[string]APIKeyRecord            `json:"api_keys,omitempty"`
+
+	// changedHistories collects chats whose history was rewritten during the
+	// current Write; observers read it instead of diffing every history.
+	changedHistories map[string]struct{}
+}
+
+// MarkHistoryChanged records that the histories of chatIDs were appended to,
+// truncated, replaced or deleted in the current Write.
+func (s *State) MarkHistoryChanged(chatIDs ...string) {
+	if s.changedHistories == nil {
+		s.changedHistories = map[string]struct{}{}
+	}
+	for _, chatID := range chatIDs {
+		s.changedHistories[chatID] = struct{}{}
+	}
+}
+
+// ChangedHistories lists the chats marked by MarkHistoryChanged since the
+// last Write completed.
+func (s *State) ChangedHistories() []string {
+	out := make([]string, 0, len(s.changedHistories))
+	for chatID := range s.changedHistories {
+		out = append(out, chatID)
+	}
+	return out
 }
 
 type Store struct {
 	mu        sync.RWMutex
 	state     State
 	stateFile string
+	observers []func(state *State)
+	cipher    *secrets.Cipher
 }
 
-func NewStore(dataDir string) (*Store, error) {
+func NewStore(dataDir string, opts ...StoreOption) (*Store, error) {
 	if err := os.MkdirAll(dataDir, 0o755); err != nil {
 		return nil, err
 	}
@@ -54,6 +91,9 @@ func NewStore(dataDir string) (*Store, error) {
 		stateFile: filepath.Join(dataDir, "state.json"),
 		state:     defaultState(dataDir),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
 	if err := s.load(); err != nil {
 		return nil, err
 	}
@@ -65,6 +105,7 @@ func defaultState(dataDir string) State {
 		SchemaVersion: currentStateSchemaVersion,
 		Chats:         map[string]domain.ChatSpec{},
 		Histories:     map[string][]domain.RuntimeMessage{},
+		Branches:      map[string][]domain.RuntimeMessage{},
 		CronJobs:      map[string]domain.CronJobSpec{},
 		CronStates:    map[string]domain.CronJobState{},
 		Providers: map[string]ProviderSetting{
@@ -73,6 +114,7 @@ func defaultState(dataDir string) State {
 		ActiveLLM: domain.ModelSlotConfig{},
 		Envs:      map[string]string{},
 		Skills:    map[string]domain.SkillSpec{},
+		APIKeys:   map[string]APIKeyRecord{},
 		Channels: domain.ChannelConfigMap{
 			"console": {
 				"enabled":    true,
@@ -119,6 +161,13 @@ func (s *Store) load() error {
 	if err != nil {
 		return err
 	}
+	plaintextSecrets, err := openState(&state, s.cipher)
+	if err != nil {
+		return err
+	}
+	if plaintextSecrets && s.cipher != nil {
+		migrated = true
+	}
 	normalizeState(&state)
 	s.state = state
 	if migrated {
@@ -186,6 +235,9 @@ func normalizeState(state *State) {
 	if state.Histories == nil {
 		state.Histories = map[string][]domain.RuntimeMessage{}
 	}
+	if state.Branches == nil {
+		state.Branches = map[string][]domain.RuntimeMessage{}
+	}
 	if state.CronJobs == nil {
 		state.CronJobs = map[string]domain.CronJobSpec{}
 	}
@@ -229,6 +281,9 @@ func normalizeState(state *State) {
 	if state.Skills == nil {
 		state.Skills = map[string]domain.SkillSpec{}
 	}
+	if state.APIKeys == nil {
+		state.APIKeys = map[string]APIKeyRecord{}
+	}
 	if state.Channels == nil {
 		state.Channels = domain.ChannelConfigMap{}
 	}
@@ -274,7 +329,11 @@ func (s *Store) saveLocked() error {
 	s.state.SchemaVersion = currentStateSchemaVersion
 	ensureDefaultChat(&s.state)
 	ensureDefaultCronJob(&s.state)
-	b, err := json.MarshalIndent(s.state, "", "  ")
+	sealed, err := sealState(s.state, s.cipher)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(sealed, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -454,10 +513,27 @@ func (s *Store) Read(fn func(state *State)) {
 func (s *Store) Write(fn func(state *State) error) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer func() { s.state.changedHistories = nil }()
 	if err := fn(&s.state); err != nil {
 		return err
 	}
-	return s.saveLocked()
+	if err := s.saveLocked(); err != nil {
+		return err
+	}
+	for _, observe := range s.observers {
+		observe(&s.state)
+	}
+	return nil
+}
+
+func (s *Store) Observe(fn func(state *State)) {
+	if fn == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, fn)
+	fn(&s.state)
 }
 
 func defaultProviderSetting() ProviderSetting {
@@ -481,6 +557,7 @@ func normalizeProviderSetting(setting *ProviderSetting) {
 	setting.APIKey = strings.TrimSpace(setting.APIKey)
 	setting.BaseURL = strings.TrimSpace(setting.BaseURL)
 	setting.ReasoningEffort = strings.ToLower(strings.TrimSpace(setting.ReasoningEffort))
+	setting.GenerationParams = provider.NormalizeGenerationParams(setting.GenerationParams)
 	if setting.Enabled == nil {
 		enabled := true
 		setting.Enabled = &enabled
@@ -509,6 +586,9 @@ func mergeProviderSetting(dst *ProviderSetting, src ProviderSetting) {
 	if src.ReasoningEffort != "" {
 		dst.ReasoningEffort = src.ReasoningEffort
 	}
+	if src.GenerationParams != nil {
+		dst.GenerationParams = provider.NormalizeGenerationParams(src.GenerationParams)
+	}
 	if src.Enabled != nil {
 		enabled := *src.Enabled
 		dst.Enabled = &enabled
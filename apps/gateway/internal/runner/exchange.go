@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+	"nextai/apps/gateway/internal/secrets"
+)
+
+const maxRecordedResponseBytes = 1 << 20
+
+type exchangeRecorderContextKey struct{}
+
+// WithExchangeRecorder makes provider calls issued with ctx report their raw
+// HTTP request and response to record. Credentials are redacted before record
+// sees them; the response is reported once its body is closed.
+func WithExchangeRecorder(ctx context.Context, record func(domain.ProviderExchange)) context.Context {
+	if record == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, exchangeRecorderContextKey{}, record)
+}
+
+func exchangeRecorderFromContext(ctx context.Context) func(domain.ProviderExchange) {
+	record, _ := ctx.Value(exchangeRecorderContextKey{}).(func(domain.ProviderExchange))
+	return record
+}
+
+func (r *Runner) doProviderRequest(httpReq *http.Request, body []byte) (*http.Response, error) {
+	record := exchangeRecorderFromContext(httpReq.Context())
+	if record == nil {
+		return r.httpClient.Do(httpReq)
+	}
+	started := time.Now()
+	exchange := domain.ProviderExchange{
+		Method:         httpReq.Method,
+		URL:            observability.RedactSecrets(httpReq.URL.String()),
+		RequestHeaders: redactExchangeHeaders(httpReq.Header),
+		RequestBody:    observability.RedactSecrets(string(body)),
+		StartedAt:      started.UTC().Format(time.RFC3339Nano),
+	}
+	resp, err := r.httpClient.Do(httpReq)
+	if err != nil {
+		exchange.Error = observability.RedactSecrets(err.Error())
+		exchange.DurationMS = time.Since(started).Milliseconds()
+		record(exchange)
+		return nil, err
+	}
+	exchange.StatusCode = resp.StatusCode
+	resp.Body = &recordingBody{ReadCloser: resp.Body, exchange: exchange, started: started, record: record}
+	return resp, nil
+}
+
+func redactExchangeHeaders(header http.Header) map[string]string {
+	out := make(map[string]string, len(header))
+	for key, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		if secrets.IsSensitiveHeaderName(key) {
+			out[key] = secrets.Redacted
+			continue
+		}
+		out[key] = observability.RedactSecrets(values[0])
+	}
+	return out
+}
+
+type recordingBody struct {
+	io.ReadCloser
+	buf       bytes.Buffer
+	truncated bool
+	exchange  domain.ProviderExchange
+	started   time.Time
+	record    func(domain.ProviderExchange)
+	once      sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if room := maxRecordedResponseBytes - b.buf.Len(); room > 0 {
+			if n > room {
+				b.truncated = true
+				b.buf.Write(p[:room])
+			} else {
+				b.buf.Write(p[:n])
+			}
+		} else {
+			b.truncated = true
+		}
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.exchange.ResponseBody = observability.RedactSecrets(b.buf.String())
+		b.exchange.ResponseTruncated = b.truncated
+		b.exchange.DurationMS = time.Since(b.started).Milliseconds()
+		b.record(b.exchange)
+	})
+	return err
+}
@@ -16,6 +16,7 @@ import (
 	"unicode"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/provider"
 )
 
@@ -24,7 +25,8 @@ const (
 	ProviderOpenAI = "openai"
 	ProviderCodex  = "codex-compatible"
 
-	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIBaseURL      = "https://api.openai.com/v1"
+	defaultResponseSchemaName = "response"
 
 	ErrorCodeProviderNotConfigured = "provider_not_configured"
 	ErrorCodeProviderNotSupported  = "provider_not_supported"
@@ -102,6 +104,7 @@ type GenerateConfig struct {
 	Headers            map[string]string
 	TimeoutMS          int
 	ReasoningEffort    string
+	Generation         domain.GenerationParams
 	Store              bool
 	PromptCacheKey     string
 	PreviousResponseID string
@@ -123,13 +126,22 @@ type TurnResult struct {
 	Text       string
 	ToolCalls  []ToolCall
 	ResponseID string
+	// Usage is reported by the provider; zero when the provider omits it.
+	Usage TokenUsage
+}
+
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
 }
 
 type ProviderCapabilities struct {
-	Stream      bool
-	ToolCall    bool
-	Attachments bool
-	Reasoning   bool
+	Stream           bool
+	ToolCall         bool
+	Attachments      bool
+	Reasoning        bool
+	StructuredOutput bool
 }
 
 type ProviderAdapter interface {
@@ -181,6 +193,14 @@ func (r *Runner) registerAdapter(adapter ProviderAdapter) {
 }
 
 func (r *Runner) GenerateTurn(ctx context.Context, req domain.AgentProcessRequest, cfg GenerateConfig, tools []ToolDefinition) (TurnResult, error) {
+	ctx, span := startTurnSpan(ctx, cfg, false)
+	started := time.Now()
+	turn, err := r.generateTurn(ctx, req, cfg, tools)
+	observeTurn(span, cfg, started, err)
+	return turn, err
+}
+
+func (r *Runner) generateTurn(ctx context.Context, req domain.AgentProcessRequest, cfg GenerateConfig, tools []ToolDefinition) (TurnResult, error) {
 	providerID := strings.ToLower(strings.TrimSpace(cfg.ProviderID))
 	if providerID == "" {
 		providerID = ProviderDemo
@@ -237,6 +257,20 @@ func (r *Runner) GenerateTurnStream(
 	cfg GenerateConfig,
 	tools []ToolDefinition,
 	onDelta func(string),
+) (TurnResult, error) {
+	ctx, span := startTurnSpan(ctx, cfg, true)
+	started := time.Now()
+	turn, err := r.generateTurnStream(ctx, req, cfg, tools, onDelta)
+	observeTurn(span, cfg, started, err)
+	return turn, err
+}
+
+func (r *Runner) generateTurnStream(
+	ctx context.Context,
+	req domain.AgentProcessRequest,
+	cfg GenerateConfig,
+	tools []ToolDefinition,
+	onDelta func(string),
 ) (TurnResult, error) {
 	providerID := strings.ToLower(strings.TrimSpace(cfg.ProviderID))
 	if providerID == "" {
@@ -292,6 +326,46 @@ func (r *Runner) GenerateTurnStream(
 	return turn, nil
 }
 
+func metricProviderID(cfg GenerateConfig) string {
+	providerID := strings.ToLower(strings.TrimSpace(cfg.ProviderID))
+	if providerID == "" {
+		providerID = ProviderDemo
+	}
+	return providerID
+}
+
+func startTurnSpan(ctx context.Context, cfg GenerateConfig, stream bool) (context.Context, *observability.Span) {
+	return observability.StartSpan(ctx, "provider.generate_turn",
+		observability.WithSpanKind(observability.SpanKindClient),
+		observability.WithAttributes(
+			"provider.id", metricProviderID(cfg),
+			"provider.model", strings.TrimSpace(cfg.Model),
+			"provider.adapter", strings.TrimSpace(cfg.AdapterID),
+			"provider.stream", stream,
+		),
+	)
+}
+
+func observeTurn(span *observability.Span, cfg GenerateConfig, started time.Time, err error) {
+	providerID := metricProviderID(cfg)
+	model := strings.TrimSpace(cfg.Model)
+	code := observability.MetricResultOK
+	if err != nil {
+		code = observability.MetricResultError
+		var runnerErr *RunnerError
+		if errors.As(err, &runnerErr) && runnerErr.Code != "" {
+			code = runnerErr.Code
+		} else if errors.Is(err, context.Canceled) {
+			code = "canceled"
+		}
+	}
+	observability.ProviderTurnsTotal.Inc(providerID, model, code)
+	observability.ProviderTurnDuration.ObserveDuration(started, providerID, model)
+	span.SetAttributes("provider.code", code)
+	span.SetError(err)
+	span.End()
+}
+
 func (r *Runner) capabilitiesForAdapter(adapterID string) ProviderCapabilities {
 	if r == nil || len(r.adapterCapabilities) == 0 {
 		return ProviderCapabilities{}
@@ -325,7 +399,12 @@ func prepareTurnInputsByCapabilities(
 		preparedTools = nil
 	}
 
-	return req, preparedCfg, preparedTools, nil
+	preparedReq := req
+	if !capabilities.StructuredOutput {
+		preparedReq.ResponseFormat = nil
+	}
+
+	return preparedReq, preparedCfg, preparedTools, nil
 }
 
 func requestContainsAttachment(req domain.AgentProcessRequest) bool {
@@ -349,10 +428,11 @@ func (a *demoAdapter) ID() string {
 
 func (a *demoAdapter) Capabilities() ProviderCapabilities {
 	return ProviderCapabilities{
-		Stream:      false,
-		ToolCall:    false,
-		Attachments: false,
-		Reasoning:   false,
+		Stream:           false,
+		ToolCall:         false,
+		Attachments:      false,
+		Reasoning:        false,
+		StructuredOutput: false,
 	}
 }
 
@@ -368,10 +448,11 @@ func (a *openAICompatibleAdapter) ID() string {
 
 func (a *openAICompatibleAdapter) Capabilities() ProviderCapabilities {
 	return ProviderCapabilities{
-		Stream:      true,
-		ToolCall:    true,
-		Attachments: false,
-		Reasoning:   true,
+		Stream:           true,
+		ToolCall:         true,
+		Attachments:      false,
+		Reasoning:        true,
+		StructuredOutput: true,
 	}
 }
 
@@ -398,10 +479,11 @@ func (a *codexCompatibleAdapter) ID() string {
 
 func (a *codexCompatibleAdapter) Capabilities() ProviderCapabilities {
 	return ProviderCapabilities{
-		Stream:      true,
-		ToolCall:    true,
-		Attachments: false,
-		Reasoning:   true,
+		Stream:           true,
+		ToolCall:         true,
+		Attachments:      false,
+		Reasoning:        true,
+		StructuredOutput: true,
 	}
 }
 
@@ -479,6 +561,111 @@ func applyReasoningEffort(payload *openAIChatRequest, cfg GenerateConfig) {
 	payload.ReasoningEffort = normalizeReasoningEffort(cfg.ReasoningEffort)
 }
 
+func applyGenerationParams(payload *openAIChatRequest, cfg GenerateConfig) {
+	if payload == nil {
+		return
+	}
+	params := cfg.Generation
+	payload.Temperature = params.Temperature
+	payload.TopP = params.TopP
+	payload.MaxTokens = params.MaxTokens
+	if len(params.Stop) > 0 {
+		payload.Stop = append([]string{}, params.Stop...)
+	}
+	payload.Seed = params.Seed
+}
+
+func applyCodexGenerationParams(payload *codexResponsesRequest, cfg GenerateConfig) error {
+	if payload == nil {
+		return nil
+	}
+	params := cfg.Generation
+	if err := provider.ValidateAdapterGenerationParams(params, provider.AdapterCodexCompatible); err != nil {
+		return &RunnerError{Code: ErrorCodeProviderNotSupported, Message: err.Error()}
+	}
+	payload.Temperature = params.Temperature
+	payload.TopP = params.TopP
+	payload.MaxOutputTokens = params.MaxTokens
+	return nil
+}
+
+func applyResponseFormat(payload *openAIChatRequest, req domain.AgentProcessRequest) {
+	if payload == nil {
+		return
+	}
+	payload.ResponseFormat = toOpenAIResponseFormat(req.ResponseFormat)
+}
+
+func applyCodexResponseFormat(payload *codexResponsesRequest, req domain.AgentProcessRequest) {
+	if payload == nil {
+		return
+	}
+	format := toCodexTextFormat(req.ResponseFormat)
+	if format == nil {
+		payload.Text = nil
+		return
+	}
+	payload.Text = &codexTextConfig{Format: format}
+}
+
+func toOpenAIResponseFormat(format *domain.ResponseFormat) *openAIResponseFormat {
+	if format == nil {
+		return nil
+	}
+	switch strings.TrimSpace(format.Type) {
+	case domain.ResponseFormatJSONObject:
+		return &openAIResponseFormat{Type: domain.ResponseFormatJSONObject}
+	case domain.ResponseFormatJSONSchema:
+		if format.JSONSchema == nil {
+			return nil
+		}
+		return &openAIResponseFormat{
+			Type: domain.ResponseFormatJSONSchema,
+			JSONSchema: &openAIResponseJSONSchema{
+				Name:        responseSchemaName(format.JSONSchema),
+				Description: strings.TrimSpace(format.JSONSchema.Description),
+				Schema:      format.JSONSchema.Schema,
+				Strict:      format.JSONSchema.Strict,
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+func toCodexTextFormat(format *domain.ResponseFormat) *codexTextFormat {
+	if format == nil {
+		return nil
+	}
+	switch strings.TrimSpace(format.Type) {
+	case domain.ResponseFormatJSONObject:
+		return &codexTextFormat{Type: domain.ResponseFormatJSONObject}
+	case domain.ResponseFormatJSONSchema:
+		if format.JSONSchema == nil {
+			return nil
+		}
+		return &codexTextFormat{
+			Type:        domain.ResponseFormatJSONSchema,
+			Name:        responseSchemaName(format.JSONSchema),
+			Description: strings.TrimSpace(format.JSONSchema.Description),
+			Schema:      format.JSONSchema.Schema,
+			Strict:      format.JSONSchema.Strict,
+		}
+	default:
+		return nil
+	}
+}
+
+func responseSchemaName(schema *domain.ResponseJSONSchema) string {
+	if schema == nil {
+		return defaultResponseSchemaName
+	}
+	if name := strings.TrimSpace(schema.Name); name != "" {
+		return name
+	}
+	return defaultResponseSchemaName
+}
+
 func (r *Runner) generateOpenAICompatibleTurn(ctx context.Context, req domain.AgentProcessRequest, cfg GenerateConfig, tools []ToolDefinition) (TurnResult, error) {
 	apiKey := strings.TrimSpace(cfg.APIKey)
 	if apiKey == "" {
@@ -496,6 +683,8 @@ func (r *Runner) generateOpenAICompatibleTurn(ctx context.Context, req domain.Ag
 		Tools:    toOpenAITools(tools),
 	}
 	applyReasoningEffort(&payload, cfg)
+	applyGenerationParams(&payload, cfg)
+	applyResponseFormat(&payload, req)
 	applyOpenAICompatibleCacheConfig(&payload, cfg)
 	if len(payload.Messages) == 0 {
 		return TurnResult{Text: generateDemoReply(req)}, nil
@@ -536,7 +725,7 @@ func (r *Runner) generateOpenAICompatibleTurn(ctx context.Context, req domain.Ag
 		httpReq.Header.Set(k, v)
 	}
 
-	resp, err := r.httpClient.Do(httpReq)
+	resp, err := r.doProviderRequest(httpReq, body)
 	if err != nil {
 		return TurnResult{}, &RunnerError{
 			Code:    ErrorCodeProviderRequestFailed,
@@ -598,6 +787,7 @@ func (r *Runner) generateOpenAICompatibleTurn(ctx context.Context, req domain.Ag
 		Text:       text,
 		ToolCalls:  toolCalls,
 		ResponseID: strings.TrimSpace(completion.ID),
+		Usage:      completion.Usage.tokenUsage(),
 	}, nil
 }
 
@@ -623,8 +813,13 @@ func (r *Runner) generateOpenAICompatibleTurnStream(
 		Messages: toOpenAIMessages(req.Input),
 		Tools:    toOpenAITools(tools),
 		Stream:   true,
+		StreamOptions: &openAIStreamOptions{
+			IncludeUsage: true,
+		},
 	}
 	applyReasoningEffort(&payload, cfg)
+	applyGenerationParams(&payload, cfg)
+	applyResponseFormat(&payload, req)
 	applyOpenAICompatibleCacheConfig(&payload, cfg)
 	if len(payload.Messages) == 0 {
 		return TurnResult{Text: generateDemoReply(req)}, nil
@@ -666,7 +861,7 @@ func (r *Runner) generateOpenAICompatibleTurnStream(
 		httpReq.Header.Set(k, v)
 	}
 
-	resp, err := r.httpClient.Do(httpReq)
+	resp, err := r.doProviderRequest(httpReq, body)
 	if err != nil {
 		return TurnResult{}, &RunnerError{
 			Code:    ErrorCodeProviderRequestFailed,
@@ -687,6 +882,7 @@ func (r *Runner) generateOpenAICompatibleTurnStream(
 	var replyBuilder strings.Builder
 	toolCalls := map[int]*openAIToolCall{}
 	responseID := ""
+	var usage TokenUsage
 	processData := func(data string) error {
 		if isSSEControlToken(data) {
 			return nil
@@ -698,6 +894,9 @@ func (r *Runner) generateOpenAICompatibleTurnStream(
 		if id := strings.TrimSpace(chunk.ID); id != "" {
 			responseID = id
 		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.tokenUsage()
+		}
 		if len(chunk.Choices) == 0 {
 			return nil
 		}
@@ -775,6 +974,7 @@ func (r *Runner) generateOpenAICompatibleTurnStream(
 		Text:       reply,
 		ToolCalls:  parsedToolCalls,
 		ResponseID: responseID,
+		Usage:      usage,
 	}, nil
 }
 
@@ -819,6 +1019,10 @@ func (r *Runner) generateCodexCompatibleTurnStream(
 	if effort := normalizeReasoningEffort(cfg.ReasoningEffort); effort != "" {
 		payload.Reasoning = &codexReasoningConfig{Effort: effort}
 	}
+	if err := applyCodexGenerationParams(&payload, cfg); err != nil {
+		return TurnResult{}, err
+	}
+	applyCodexResponseFormat(&payload, req)
 
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -856,7 +1060,7 @@ func (r *Runner) generateCodexCompatibleTurnStream(
 		httpReq.Header.Set(k, v)
 	}
 
-	resp, err := r.httpClient.Do(httpReq)
+	resp, err := r.doProviderRequest(httpReq, body)
 	if err != nil {
 		return TurnResult{}, &RunnerError{
 			Code:    ErrorCodeProviderRequestFailed,
@@ -879,6 +1083,7 @@ func (r *Runner) generateCodexCompatibleTurnStream(
 	sawDelta := false
 	rawToolCalls := make([]codexResponseFunctionCall, 0, 1)
 	responseID := ""
+	var usage TokenUsage
 
 	processData := func(data string) error {
 		if isSSEControlToken(data) {
@@ -895,6 +1100,9 @@ func (r *Runner) generateCodexCompatibleTurnStream(
 				if id := strings.TrimSpace(event.Response.ID); id != "" {
 					responseID = id
 				}
+				if event.Response.Usage != nil {
+					usage = event.Response.Usage.tokenUsage()
+				}
 			}
 		case "response.output_text.delta":
 			delta := event.Delta
@@ -964,7 +1172,7 @@ func (r *Runner) generateCodexCompatibleTurnStream(
 		}
 	}
 
-	return TurnResult{Text: reply, ToolCalls: toolCalls, ResponseID: responseID}, nil
+	return TurnResult{Text: reply, ToolCalls: toolCalls, ResponseID: responseID, Usage: usage}, nil
 }
 
 func toCodexResponsesInput(input []domain.AgentInputMessage) (string, []codexResponsesInputItem) {
@@ -1086,6 +1294,10 @@ type codexResponsesRequest struct {
 	Input              []codexResponsesInputItem `json:"input"`
 	Tools              []codexToolDefinition     `json:"tools,omitempty"`
 	Reasoning          *codexReasoningConfig     `json:"reasoning,omitempty"`
+	Temperature        *float64                  `json:"temperature,omitempty"`
+	TopP               *float64                  `json:"top_p,omitempty"`
+	MaxOutputTokens    *int                      `json:"max_output_tokens,omitempty"`
+	Text               *codexTextConfig          `json:"text,omitempty"`
 	ToolChoice         string                    `json:"tool_choice,omitempty"`
 	ParallelToolCalls  bool                      `json:"parallel_tool_calls"`
 	Store              bool                      `json:"store"`
@@ -1093,6 +1305,18 @@ type codexResponsesRequest struct {
 	PromptCacheKey     string                    `json:"prompt_cache_key,omitempty"`
 }
 
+type codexTextConfig struct {
+	Format *codexTextFormat `json:"format,omitempty"`
+}
+
+type codexTextFormat struct {
+	Type        string                 `json:"type"`
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty"`
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
 type codexReasoningConfig struct {
 	Effort string `json:"effort,omitempty"`
 }
@@ -1124,6 +1348,13 @@ type codexResponsesStreamEvent struct {
 type codexResponseEventStatus struct {
 	ID    string                   `json:"id,omitempty"`
 	Error *codexResponseEventError `json:"error,omitempty"`
+	Usage *codexResponseUsage      `json:"usage,omitempty"`
+}
+
+type codexResponseUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
 }
 
 type codexResponseEventError struct {
@@ -1156,12 +1387,37 @@ type openAIChatRequest struct {
 	Messages           []openAIMessage        `json:"messages"`
 	Tools              []openAIToolDefinition `json:"tools,omitempty"`
 	ReasoningEffort    string                 `json:"reasoning_effort,omitempty"`
+	Temperature        *float64               `json:"temperature,omitempty"`
+	TopP               *float64               `json:"top_p,omitempty"`
+	MaxTokens          *int                   `json:"max_tokens,omitempty"`
+	Stop               []string               `json:"stop,omitempty"`
+	Seed               *int64                 `json:"seed,omitempty"`
+	ResponseFormat     *openAIResponseFormat  `json:"response_format,omitempty"`
 	Stream             bool                   `json:"stream,omitempty"`
+	StreamOptions      *openAIStreamOptions   `json:"stream_options,omitempty"`
 	Store              bool                   `json:"store,omitempty"`
 	PromptCacheKey     string                 `json:"prompt_cache_key,omitempty"`
 	PreviousResponseID string                 `json:"previous_response_id,omitempty"`
 }
 
+// openAIStreamOptions asks for a final usage chunk; without it streamed
+// completions carry no token counts.
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIResponseFormat struct {
+	Type       string                    `json:"type"`
+	JSONSchema *openAIResponseJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIResponseJSONSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
 type openAIMessage struct {
 	Role       string           `json:"role"`
 	Content    interface{}      `json:"content,omitempty"`
@@ -1200,10 +1456,39 @@ type openAIChatResponse struct {
 			ToolCalls []openAIToolCall `json:"tool_calls,omitempty"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *openAIUsage `json:"usage,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *openAIUsage) tokenUsage() TokenUsage {
+	if u == nil {
+		return TokenUsage{}
+	}
+	return normalizeTokenUsage(TokenUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens})
+}
+
+func (u *codexResponseUsage) tokenUsage() TokenUsage {
+	if u == nil {
+		return TokenUsage{}
+	}
+	return normalizeTokenUsage(TokenUsage{PromptTokens: u.InputTokens, CompletionTokens: u.OutputTokens, TotalTokens: u.TotalTokens})
+}
+
+func normalizeTokenUsage(u TokenUsage) TokenUsage {
+	if u.TotalTokens <= 0 {
+		u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+	return u
 }
 
 type openAIChatStreamResponse struct {
-	ID      string `json:"id,omitempty"`
+	ID      string       `json:"id,omitempty"`
+	Usage   *openAIUsage `json:"usage,omitempty"`
 	Choices []struct {
 		Delta struct {
 			Content   json.RawMessage        `json:"content"`
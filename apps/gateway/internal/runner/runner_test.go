@@ -8,10 +8,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/provider"
 )
 
@@ -112,6 +114,33 @@ func TestGenerateReplyOpenAIMissingAPIKey(t *testing.T) {
 	assertRunnerCode(t, err, ErrorCodeProviderNotConfigured)
 }
 
+func TestGenerateTurnRecordsProviderMetrics(t *testing.T) {
+	t.Parallel()
+	r := New()
+	req := domain.AgentProcessRequest{
+		Input: []domain.AgentInputMessage{{
+			Role:    "user",
+			Type:    "message",
+			Content: []domain.RuntimeContent{{Type: "text", Text: "hello"}},
+		}},
+	}
+	if _, err := r.GenerateTurn(context.Background(), req, GenerateConfig{ProviderID: ProviderDemo, Model: "metrics-demo"}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := r.GenerateTurn(context.Background(), req, GenerateConfig{ProviderID: ProviderOpenAI, Model: "metrics-openai"}, nil)
+	assertRunnerCode(t, err, ErrorCodeProviderNotConfigured)
+
+	if got := observability.ProviderTurnsTotal.Value(ProviderDemo, "metrics-demo", observability.MetricResultOK); got != 1 {
+		t.Fatalf("expected one ok demo turn, got=%v", got)
+	}
+	if got := observability.ProviderTurnsTotal.Value(ProviderOpenAI, "metrics-openai", ErrorCodeProviderNotConfigured); got != 1 {
+		t.Fatalf("expected one failed openai turn, got=%v", got)
+	}
+	if got := observability.ProviderTurnDuration.Count(ProviderOpenAI, "metrics-openai"); got != 1 {
+		t.Fatalf("expected one latency sample, got=%d", got)
+	}
+}
+
 func TestGenerateReplyOpenAIUpstreamFailure(t *testing.T) {
 	t.Parallel()
 	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -428,6 +457,161 @@ func TestGenerateReplyCodexCompatibleSuccess(t *testing.T) {
 	}
 }
 
+func TestGenerateTurnMapsGenerationParamsPerAdapter(t *testing.T) {
+	t.Parallel()
+	var chatReq map[string]interface{}
+	var responsesReq map[string]interface{}
+
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		switch r.URL.Path {
+		case "/chat/completions":
+			chatReq = req
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+		case "/responses":
+			responsesReq = req
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"type\":\"response.output_text.delta\",\"delta\":\"ok\"}\n\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mock.Close()
+
+	temperature := 0.3
+	topP := 0.9
+	maxTokens := 256
+	seed := int64(42)
+	generation := domain.GenerationParams{
+		Temperature: &temperature,
+		TopP:        &topP,
+		MaxTokens:   &maxTokens,
+		Stop:        []string{"END"},
+		Seed:        &seed,
+	}
+	// The responses API has no stop or seed; the adapter rejects them.
+	responsesGeneration := generation
+	responsesGeneration.Stop = nil
+	responsesGeneration.Seed = nil
+	req := domain.AgentProcessRequest{
+		Input: []domain.AgentInputMessage{{
+			Role:    "user",
+			Type:    "message",
+			Content: []domain.RuntimeContent{{Type: "text", Text: "hello"}},
+		}},
+	}
+
+	r := NewWithHTTPClient(mock.Client())
+	if _, err := r.GenerateTurn(context.Background(), req, GenerateConfig{
+		ProviderID: ProviderOpenAI,
+		Model:      "gpt-4o-mini",
+		APIKey:     "sk-test",
+		BaseURL:    mock.URL,
+		Generation: generation,
+	}, nil); err != nil {
+		t.Fatalf("unexpected chat completions error: %v", err)
+	}
+	if _, err := r.GenerateTurn(context.Background(), req, GenerateConfig{
+		ProviderID: ProviderCodex,
+		Model:      "gpt-5-codex",
+		APIKey:     "sk-test",
+		BaseURL:    mock.URL,
+		Generation: responsesGeneration,
+	}, nil); err != nil {
+		t.Fatalf("unexpected responses error: %v", err)
+	}
+
+	if chatReq["temperature"] != 0.3 || chatReq["top_p"] != 0.9 || chatReq["max_tokens"] != float64(256) || chatReq["seed"] != float64(42) {
+		t.Fatalf("unexpected chat completions sampling fields: %#v", chatReq)
+	}
+	if stop, _ := chatReq["stop"].([]interface{}); len(stop) != 1 || stop[0] != "END" {
+		t.Fatalf("unexpected chat completions stop: %#v", chatReq["stop"])
+	}
+	if responsesReq["temperature"] != 0.3 || responsesReq["top_p"] != 0.9 || responsesReq["max_output_tokens"] != float64(256) {
+		t.Fatalf("unexpected responses sampling fields: %#v", responsesReq)
+	}
+	for _, key := range []string{"max_tokens", "stop", "seed"} {
+		if _, exists := responsesReq[key]; exists {
+			t.Fatalf("responses request should not include %s: %#v", key, responsesReq)
+		}
+	}
+}
+
+func TestGenerateTurnMapsResponseFormatPerAdapter(t *testing.T) {
+	t.Parallel()
+	var chatReq map[string]interface{}
+	var responsesReq map[string]interface{}
+
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		switch r.URL.Path {
+		case "/chat/completions":
+			chatReq = req
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"{}"}}]}`))
+		case "/responses":
+			responsesReq = req
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"type\":\"response.output_text.delta\",\"delta\":\"{}\"}\n\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mock.Close()
+
+	req := domain.AgentProcessRequest{
+		Input: []domain.AgentInputMessage{{
+			Role:    "user",
+			Type:    "message",
+			Content: []domain.RuntimeContent{{Type: "text", Text: "hello"}},
+		}},
+		ResponseFormat: &domain.ResponseFormat{
+			Type: domain.ResponseFormatJSONSchema,
+			JSONSchema: &domain.ResponseJSONSchema{
+				Schema: map[string]interface{}{"type": "object"},
+				Strict: true,
+			},
+		},
+	}
+
+	r := NewWithHTTPClient(mock.Client())
+	if _, err := r.GenerateTurn(context.Background(), req, GenerateConfig{
+		ProviderID: ProviderOpenAI,
+		Model:      "gpt-4o-mini",
+		APIKey:     "sk-test",
+		BaseURL:    mock.URL,
+	}, nil); err != nil {
+		t.Fatalf("unexpected chat completions error: %v", err)
+	}
+	if _, err := r.GenerateTurn(context.Background(), req, GenerateConfig{
+		ProviderID: ProviderCodex,
+		Model:      "gpt-5-codex",
+		APIKey:     "sk-test",
+		BaseURL:    mock.URL,
+	}, nil); err != nil {
+		t.Fatalf("unexpected responses error: %v", err)
+	}
+
+	chatFormat, _ := chatReq["response_format"].(map[string]interface{})
+	chatSchema, _ := chatFormat["json_schema"].(map[string]interface{})
+	if chatFormat["type"] != "json_schema" || chatSchema["name"] != "response" || chatSchema["strict"] != true {
+		t.Fatalf("unexpected chat completions response_format: %#v", chatReq["response_format"])
+	}
+	text, _ := responsesReq["text"].(map[string]interface{})
+	textFormat, _ := text["format"].(map[string]interface{})
+	if textFormat["type"] != "json_schema" || textFormat["name"] != "response" || textFormat["schema"] == nil {
+		t.Fatalf("unexpected responses text format: %#v", responsesReq["text"])
+	}
+	if _, exists := responsesReq["response_format"]; exists {
+		t.Fatalf("responses request should not include response_format: %#v", responsesReq)
+	}
+}
+
 func TestGenerateTurnCodexCompatibleCapturesResponseID(t *testing.T) {
 	t.Parallel()
 	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -775,6 +959,147 @@ func TestGenerateTurnStreamOpenAISendsNativeDeltas(t *testing.T) {
 	}
 }
 
+func TestGenerateTurnOpenAIReportsUsage(t *testing.T) {
+	t.Parallel()
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/chat/completions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"chatcmpl_usage","choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`))
+	}))
+	defer mock.Close()
+
+	r := NewWithHTTPClient(mock.Client())
+	turn, err := r.GenerateTurn(context.Background(), domain.AgentProcessRequest{
+		Input: []domain.AgentInputMessage{{
+			Role:    "user",
+			Type:    "message",
+			Content: []domain.RuntimeContent{{Type: "text", Text: "hello"}},
+		}},
+	}, GenerateConfig{
+		ProviderID: ProviderOpenAI,
+		Model:      "gpt-4o-mini",
+		APIKey:     "sk-test",
+		BaseURL:    mock.URL,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if turn.Usage != (TokenUsage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}) {
+		t.Fatalf("unexpected usage: %+v", turn.Usage)
+	}
+}
+
+func TestGenerateTurnStreamOpenAIRequestsUsage(t *testing.T) {
+	t.Parallel()
+	var requestBody map[string]interface{}
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/chat/completions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":1,\"total_tokens\":10}}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer mock.Close()
+
+	r := NewWithHTTPClient(mock.Client())
+	turn, err := r.GenerateTurnStream(context.Background(), domain.AgentProcessRequest{
+		Input: []domain.AgentInputMessage{{
+			Role:    "user",
+			Type:    "message",
+			Content: []domain.RuntimeContent{{Type: "text", Text: "hello"}},
+		}},
+	}, GenerateConfig{
+		ProviderID: ProviderOpenAI,
+		Model:      "gpt-4o-mini",
+		APIKey:     "sk-test",
+		BaseURL:    mock.URL,
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	options, _ := requestBody["stream_options"].(map[string]interface{})
+	if options["include_usage"] != true {
+		t.Fatalf("expected stream_options.include_usage=true, got=%#v", requestBody["stream_options"])
+	}
+	if turn.Usage != (TokenUsage{PromptTokens: 9, CompletionTokens: 1, TotalTokens: 10}) {
+		t.Fatalf("unexpected usage: %+v", turn.Usage)
+	}
+}
+
+func TestGenerateTurnCodexCompatibleReportsUsage(t *testing.T) {
+	t.Parallel()
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/responses" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"type\":\"response.output_text.delta\",\"delta\":\"hello\"}\n\n")
+		_, _ = fmt.Fprint(w, "data: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_usage\",\"usage\":{\"input_tokens\":20,\"output_tokens\":4}}}\n\n")
+	}))
+	defer mock.Close()
+
+	r := NewWithHTTPClient(mock.Client())
+	turn, err := r.GenerateTurn(context.Background(), domain.AgentProcessRequest{
+		Input: []domain.AgentInputMessage{{
+			Role:    "user",
+			Type:    "message",
+			Content: []domain.RuntimeContent{{Type: "text", Text: "hello"}},
+		}},
+	}, GenerateConfig{
+		ProviderID: ProviderCodex,
+		Model:      "gpt-5-codex",
+		APIKey:     "sk-test",
+		BaseURL:    mock.URL,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if turn.Usage.TotalTokens != 24 || turn.Usage.PromptTokens != 20 {
+		t.Fatalf("unexpected usage: %+v", turn.Usage)
+	}
+}
+
+func TestGenerateTurnCodexCompatibleRejectsStopAndSeed(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mock.Close()
+
+	r := NewWithHTTPClient(mock.Client())
+	_, err := r.GenerateTurn(context.Background(), domain.AgentProcessRequest{
+		Input: []domain.AgentInputMessage{{
+			Role:    "user",
+			Type:    "message",
+			Content: []domain.RuntimeContent{{Type: "text", Text: "hello"}},
+		}},
+	}, GenerateConfig{
+		ProviderID: ProviderCodex,
+		Model:      "gpt-5-codex",
+		APIKey:     "sk-test",
+		BaseURL:    mock.URL,
+		Generation: domain.GenerationParams{Stop: []string{"END"}},
+	}, nil)
+	var runnerErr *RunnerError
+	if !errors.As(err, &runnerErr) || runnerErr.Code != ErrorCodeProviderNotSupported {
+		t.Fatalf("expected provider_not_supported error, got=%v", err)
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("expected no provider request, got=%d", calls.Load())
+	}
+}
+
 func TestGenerateTurnStreamOpenAIIgnoresEmptyDataHeartbeat(t *testing.T) {
 	t.Parallel()
 	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
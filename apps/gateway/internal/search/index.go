@@ -0,0 +1,529 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"nextai/apps/gateway/internal/domain"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+
+	snippetRadius = 40
+	snippetMax    = 120
+)
+
+type Query struct {
+	Text    string
+	ChatID  string
+	Channel string
+	UserID  string
+	Role    string
+	From    time.Time
+	To      time.Time
+	Offset  int
+	Limit   int
+}
+
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type Hit struct {
+	ChatID     string      `json:"chat_id"`
+	ChatName   string      `json:"chat_name"`
+	SessionID  string      `json:"session_id"`
+	UserID     string      `json:"user_id"`
+	Channel    string      `json:"channel"`
+	MessageID  string      `json:"message_id"`
+	Role       string      `json:"role"`
+	CreatedAt  string      `json:"created_at,omitempty"`
+	Snippet    string      `json:"snippet"`
+	Highlights []Highlight `json:"highlights"`
+	Score      float64     `json:"score"`
+}
+
+type Result struct {
+	Query  string `json:"query"`
+	Total  int    `json:"total"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	Hits   []Hit  `json:"hits"`
+}
+
+type token struct {
+	term  string
+	start int
+	end   int
+}
+
+type document struct {
+	chatID    string
+	messageID string
+	role      string
+	createdAt string
+	text      []rune
+	terms     map[string]int
+}
+
+type chatEntry struct {
+	spec domain.ChatSpec
+	docs []string
+}
+
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string]struct{}
+	chats    map[string]*chatEntry
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     map[string]*document{},
+		postings: map[string]map[string]struct{}{},
+		chats:    map[string]*chatEntry{},
+	}
+}
+
+// Sync reconciles every chat history with the index.
+func (idx *Index) Sync(chats map[string]domain.ChatSpec, histories map[string][]domain.RuntimeMessage) {
+	changed := make([]string, 0, len(chats))
+	for chatID := range chats {
+		changed = append(changed, chatID)
+	}
+	idx.Update(chats, histories, changed)
+}
+
+// Update refreshes chat metadata and indexes new chats, but only re-reads the
+// histories of the changed chats, so a store write costs O(chats) rather than
+// O(messages).
+func (idx *Index) Update(chats map[string]domain.ChatSpec, histories map[string][]domain.RuntimeMessage, changed []string) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for chatID := range idx.chats {
+		if _, ok := chats[chatID]; !ok {
+			idx.removeChatLocked(chatID)
+		}
+	}
+	for chatID, spec := range chats {
+		entry, ok := idx.chats[chatID]
+		if !ok {
+			entry = &chatEntry{}
+			idx.chats[chatID] = entry
+			idx.reindexChatLocked(entry, chatID, histories[chatID])
+		}
+		entry.spec = spec
+	}
+	for _, chatID := range changed {
+		if entry, ok := idx.chats[chatID]; ok {
+			idx.reindexChatLocked(entry, chatID, histories[chatID])
+		}
+	}
+}
+
+func (idx *Index) Search(q Query) Result {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	offset := q.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	result := Result{Query: q.Text, Offset: offset, Limit: limit, Hits: []Hit{}}
+	if idx == nil {
+		return result
+	}
+	terms := QueryTerms(q.Text)
+	if len(terms) == 0 {
+		return result
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	candidates := idx.intersectLocked(terms)
+	total := float64(len(idx.docs))
+	hits := make([]Hit, 0, len(candidates))
+	for _, key := range candidates {
+		doc := idx.docs[key]
+		entry := idx.chats[doc.chatID]
+		if entry == nil || !matchesFilters(q, entry.spec, doc) {
+			continue
+		}
+		score := 0.0
+		for _, term := range terms {
+			df := float64(len(idx.postings[term]))
+			score += float64(doc.terms[term]) * math.Log(1+total/df)
+		}
+		snippet, highlights := buildSnippet(doc.text, terms)
+		hits = append(hits, Hit{
+			ChatID:     doc.chatID,
+			ChatName:   entry.spec.Name,
+			SessionID:  entry.spec.SessionID,
+			UserID:     entry.spec.UserID,
+			Channel:    entry.spec.Channel,
+			MessageID:  doc.messageID,
+			Role:       doc.role,
+			CreatedAt:  doc.createdAt,
+			Snippet:    snippet,
+			Highlights: highlights,
+			Score:      math.Round(score*1000) / 1000,
+		})
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].CreatedAt != hits[j].CreatedAt {
+			return hits[i].CreatedAt > hits[j].CreatedAt
+		}
+		if hits[i].ChatID != hits[j].ChatID {
+			return hits[i].ChatID < hits[j].ChatID
+		}
+		return hits[i].MessageID < hits[j].MessageID
+	})
+
+	result.Total = len(hits)
+	if offset >= len(hits) {
+		return result
+	}
+	end := offset + limit
+	if end > len(hits) {
+		end = len(hits)
+	}
+	result.Hits = hits[offset:end]
+	return result
+}
+
+func (idx *Index) reindexChatLocked(entry *chatEntry, chatID string, history []domain.RuntimeMessage) {
+	stale := make(map[string]struct{}, len(entry.docs))
+	for _, key := range entry.docs {
+		stale[key] = struct{}{}
+	}
+	docs := make([]string, 0, len(history))
+	for i, msg := range history {
+		messageID := messageKey(msg, i)
+		key := docKey(chatID, messageID)
+		if doc, ok := idx.docs[key]; ok {
+			if _, owned := stale[key]; owned && doc.role == msg.Role && doc.createdAt == msg.CreatedAt && string(doc.text) == messageText(msg) {
+				delete(stale, key)
+				docs = append(docs, key)
+				continue
+			}
+			idx.removeDocLocked(key)
+		}
+		delete(stale, key)
+		if idx.addDocLocked(key, chatID, messageID, msg) {
+			docs = append(docs, key)
+		}
+	}
+	for key := range stale {
+		idx.removeDocLocked(key)
+	}
+	entry.docs = docs
+}
+
+func (idx *Index) addDocLocked(key, chatID, messageID string, msg domain.RuntimeMessage) bool {
+	text := messageText(msg)
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	runes := []rune(text)
+	terms := map[string]int{}
+	for _, tok := range tokenize(runes) {
+		terms[tok.term]++
+	}
+	if len(terms) == 0 {
+		return false
+	}
+	idx.docs[key] = &document{
+		chatID:    chatID,
+		messageID: messageID,
+		role:      msg.Role,
+		createdAt: msg.CreatedAt,
+		text:      runes,
+		terms:     terms,
+	}
+	for term := range terms {
+		posting, ok := idx.postings[term]
+		if !ok {
+			posting = map[string]struct{}{}
+			idx.postings[term] = posting
+		}
+		posting[key] = struct{}{}
+	}
+	return true
+}
+
+func (idx *Index) removeDocLocked(key string) {
+	doc, ok := idx.docs[key]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		posting := idx.postings[term]
+		delete(posting, key)
+		if len(posting) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.docs, key)
+}
+
+func (idx *Index) removeChatLocked(chatID string) {
+	entry, ok := idx.chats[chatID]
+	if !ok {
+		return
+	}
+	for _, key := range entry.docs {
+		idx.removeDocLocked(key)
+	}
+	delete(idx.chats, chatID)
+}
+
+func (idx *Index) intersectLocked(terms []string) []string {
+	var smallest map[string]struct{}
+	for _, term := range terms {
+		posting, ok := idx.postings[term]
+		if !ok {
+			return nil
+		}
+		if smallest == nil || len(posting) < len(smallest) {
+			smallest = posting
+		}
+	}
+	out := make([]string, 0, len(smallest))
+	for key := range smallest {
+		matched := true
+		for _, term := range terms {
+			if _, ok := idx.postings[term][key]; !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+func matchesFilters(q Query, spec domain.ChatSpec, doc *document) bool {
+	if q.ChatID != "" && doc.chatID != q.ChatID {
+		return false
+	}
+	if q.Channel != "" && spec.Channel != q.Channel {
+		return false
+	}
+	if q.UserID != "" && spec.UserID != q.UserID {
+		return false
+	}
+	if q.Role != "" && !strings.EqualFold(doc.role, q.Role) {
+		return false
+	}
+	if q.From.IsZero() && q.To.IsZero() {
+		return true
+	}
+	stamp := doc.createdAt
+	if stamp == "" {
+		stamp = spec.UpdatedAt
+	}
+	at, err := time.Parse(time.RFC3339, stamp)
+	if err != nil {
+		return false
+	}
+	if !q.From.IsZero() && at.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !at.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+func buildSnippet(text []rune, terms []string) (string, []Highlight) {
+	wanted := make(map[string]struct{}, len(terms))
+	for _, term := range terms {
+		wanted[term] = struct{}{}
+	}
+	ranges := []Highlight{}
+	for _, tok := range tokenize(text) {
+		if _, ok := wanted[tok.term]; !ok {
+			continue
+		}
+		if n := len(ranges); n > 0 && tok.start <= ranges[n-1].End {
+			if tok.end > ranges[n-1].End {
+				ranges[n-1].End = tok.end
+			}
+			continue
+		}
+		ranges = append(ranges, Highlight{Start: tok.start, End: tok.end})
+	}
+
+	start := 0
+	if len(ranges) > 0 && ranges[0].Start > snippetRadius {
+		start = ranges[0].Start - snippetRadius
+	}
+	end := start + snippetMax
+	if end > len(text) {
+		end = len(text)
+	}
+	prefix := ""
+	if start > 0 {
+		prefix = "…"
+	}
+	suffix := ""
+	if end < len(text) {
+		suffix = "…"
+	}
+	shift := len([]rune(prefix)) - start
+	highlights := make([]Highlight, 0, len(ranges))
+	for _, item := range ranges {
+		if item.Start < start || item.End > end {
+			continue
+		}
+		highlights = append(highlights, Highlight{Start: item.Start + shift, End: item.End + shift})
+	}
+	snippet := prefix + strings.ReplaceAll(string(text[start:end]), "\n", " ") + suffix
+	return snippet, highlights
+}
+
+func Terms(text string) map[string]struct{} {
+	out := map[string]struct{}{}
+	for _, tok := range tokenize([]rune(text)) {
+		out[tok.term] = struct{}{}
+	}
+	return out
+}
+
+func QueryTerms(text string) []string {
+	runes := []rune(text)
+	seen := map[string]struct{}{}
+	out := []string{}
+	add := func(term string) {
+		if _, ok := seen[term]; ok {
+			return
+		}
+		seen[term] = struct{}{}
+		out = append(out, term)
+	}
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case isCJKRune(r):
+			j := i
+			for j < len(runes) && isCJKRune(runes[j]) {
+				j++
+			}
+			if j-i == 1 {
+				add(string(runes[i]))
+			}
+			for k := i; k+1 < j; k++ {
+				add(string(runes[k : k+2]))
+			}
+			i = j
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) && !isCJKRune(runes[j]) {
+				j++
+			}
+			add(strings.ToLower(string(runes[i:j])))
+			i = j
+		default:
+			i++
+		}
+	}
+	return out
+}
+
+func tokenize(runes []rune) []token {
+	out := []token{}
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case isCJKRune(r):
+			j := i
+			for j < len(runes) && isCJKRune(runes[j]) {
+				j++
+			}
+			for k := i; k < j; k++ {
+				out = append(out, token{term: string(runes[k]), start: k, end: k + 1})
+				if k+1 < j {
+					out = append(out, token{term: string(runes[k : k+2]), start: k, end: k + 2})
+				}
+			}
+			i = j
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) && !isCJKRune(runes[j]) {
+				j++
+			}
+			out = append(out, token{term: strings.ToLower(string(runes[i:j])), start: i, end: j})
+			i = j
+		default:
+			i++
+		}
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func isCJKRune(r rune) bool {
+	switch {
+	case r >= 0x3400 && r <= 0x4DBF:
+		return true
+	case r >= 0x4E00 && r <= 0x9FFF:
+		return true
+	case r >= 0xF900 && r <= 0xFAFF:
+		return true
+	case r >= 0x3040 && r <= 0x30FF:
+		return true
+	case r >= 0xAC00 && r <= 0xD7AF:
+		return true
+	default:
+		return false
+	}
+}
+
+func messageText(msg domain.RuntimeMessage) string {
+	parts := make([]string, 0, len(msg.Content))
+	for _, part := range msg.Content {
+		if text := strings.TrimSpace(part.Text); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func messageKey(msg domain.RuntimeMessage, position int) string {
+	if id := strings.TrimSpace(msg.ID); id != "" {
+		return id
+	}
+	return "#" + strconv.Itoa(position)
+}
+
+func docKey(chatID, messageID string) string {
+	return chatID + "\x00" + messageID
+}
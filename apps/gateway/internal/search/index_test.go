@@ -0,0 +1,125 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+)
+
+func textMessage(id, role, text, createdAt string) domain.RuntimeMessage {
+	return domain.RuntimeMessage{
+		ID:        id,
+		Role:      role,
+		Type:      "message",
+		CreatedAt: createdAt,
+		Content:   []domain.RuntimeContent{{Type: "text", Text: text}},
+	}
+}
+
+func TestSearchMatchesLatinAndCJKTerms(t *testing.T) {
+	idx := NewIndex()
+	idx.Sync(map[string]domain.ChatSpec{
+		"c1": {ID: "c1", Channel: "console", UserID: "u1"},
+		"c2": {ID: "c2", Channel: "qq", UserID: "u2"},
+	}, map[string][]domain.RuntimeMessage{
+		"c1": {
+			textMessage("m1", "user", "Deploy the Gateway to staging", "2026-01-01T10:00:00Z"),
+			textMessage("m2", "assistant", "今天天气很好，适合部署网关", "2026-01-02T10:00:00Z"),
+		},
+		"c2": {textMessage("m3", "user", "gateway logs are noisy", "2026-02-01T10:00:00Z")},
+	})
+
+	result := idx.Search(Query{Text: "GATEWAY"})
+	if result.Total != 2 {
+		t.Fatalf("expected 2 hits, got %+v", result)
+	}
+	result = idx.Search(Query{Text: "部署网关"})
+	if result.Total != 1 || result.Hits[0].MessageID != "m2" {
+		t.Fatalf("unexpected cjk result: %+v", result)
+	}
+	hit := result.Hits[0]
+	runes := []rune(hit.Snippet)
+	if len(hit.Highlights) != 1 || string(runes[hit.Highlights[0].Start:hit.Highlights[0].End]) != "部署网关" {
+		t.Fatalf("unexpected highlights: %+v", hit)
+	}
+	if result := idx.Search(Query{Text: "网"}); result.Total != 1 {
+		t.Fatalf("expected single cjk rune to match, got %+v", result)
+	}
+	if result := idx.Search(Query{Text: "gateway", Channel: "qq"}); result.Total != 1 || result.Hits[0].ChatID != "c2" {
+		t.Fatalf("unexpected channel filter result: %+v", result)
+	}
+	from, _ := time.Parse(time.RFC3339, "2026-01-15T00:00:00Z")
+	if result := idx.Search(Query{Text: "gateway", From: from}); result.Total != 1 || result.Hits[0].MessageID != "m3" {
+		t.Fatalf("unexpected date filter result: %+v", result)
+	}
+	if result := idx.Search(Query{Text: "gateway staging"}); result.Total != 1 || result.Hits[0].MessageID != "m1" {
+		t.Fatalf("expected AND semantics, got %+v", result)
+	}
+	page := idx.Search(Query{Text: "gateway", Limit: 1, Offset: 1})
+	if page.Total != 2 || len(page.Hits) != 1 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+}
+
+func TestSyncUpdatesIndexIncrementally(t *testing.T) {
+	idx := NewIndex()
+	chats := map[string]domain.ChatSpec{"c1": {ID: "c1"}}
+	histories := map[string][]domain.RuntimeMessage{
+		"c1": {textMessage("m1", "user", "alpha", "")},
+	}
+	idx.Sync(chats, histories)
+
+	histories["c1"] = append(histories["c1"], textMessage("m2", "assistant", "beta", ""))
+	idx.Sync(chats, histories)
+	if result := idx.Search(Query{Text: "beta"}); result.Total != 1 {
+		t.Fatalf("expected appended message indexed, got %+v", result)
+	}
+
+	histories["c1"] = []domain.RuntimeMessage{textMessage("m1", "user", "gamma", ""), histories["c1"][1]}
+	idx.Sync(chats, histories)
+	if result := idx.Search(Query{Text: "alpha"}); result.Total != 0 {
+		t.Fatalf("expected edited text removed, got %+v", result)
+	}
+	if result := idx.Search(Query{Text: "gamma"}); result.Total != 1 {
+		t.Fatalf("expected edited text indexed, got %+v", result)
+	}
+
+	idx.Sync(map[string]domain.ChatSpec{}, map[string][]domain.RuntimeMessage{})
+	if result := idx.Search(Query{Text: "beta"}); result.Total != 0 {
+		t.Fatalf("expected deleted chat removed, got %+v", result)
+	}
+	if len(idx.docs) != 0 || len(idx.postings) != 0 {
+		t.Fatalf("expected empty index, docs=%d postings=%d", len(idx.docs), len(idx.postings))
+	}
+}
+
+func TestUpdateOnlyRereadsChangedHistories(t *testing.T) {
+	idx := NewIndex()
+	chats := map[string]domain.ChatSpec{"c1": {ID: "c1"}, "c2": {ID: "c2"}}
+	histories := map[string][]domain.RuntimeMessage{
+		"c1": {textMessage("m1", "user", "alpha", "")},
+		"c2": {textMessage("m2", "user", "beta", "")},
+	}
+	idx.Update(chats, histories, nil)
+	if result := idx.Search(Query{Text: "beta"}); result.Total != 1 {
+		t.Fatalf("expected new chats indexed without change marks, got %+v", result)
+	}
+
+	histories["c1"] = append(histories["c1"], textMessage("m3", "assistant", "gamma", ""))
+	histories["c2"] = []domain.RuntimeMessage{textMessage("m2", "user", "delta", "")}
+	idx.Update(chats, histories, []string{"c1"})
+	if result := idx.Search(Query{Text: "gamma"}); result.Total != 1 {
+		t.Fatalf("expected changed chat reindexed, got %+v", result)
+	}
+	if result := idx.Search(Query{Text: "beta"}); result.Total != 1 {
+		t.Fatalf("expected unchanged chat left alone, got %+v", result)
+	}
+
+	chats["c2"] = domain.ChatSpec{ID: "c2", Name: "renamed"}
+	idx.Update(chats, histories, []string{"c2"})
+	result := idx.Search(Query{Text: "delta"})
+	if result.Total != 1 || result.Hits[0].ChatName != "renamed" {
+		t.Fatalf("expected marked chat reindexed with new spec, got %+v", result)
+	}
+}
@@ -0,0 +1,185 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	SealedPrefix = "enc:v1:"
+	Redacted     = "__redacted__"
+
+	masterKeySize = 32
+	keyIDLen      = 8
+)
+
+var (
+	ErrMasterKeyRequired = errors.New("secrets_master_key_required")
+	ErrMasterKeyMismatch = errors.New("secrets_master_key_mismatch")
+	ErrMalformedSecret   = errors.New("secrets_malformed_value")
+)
+
+type Cipher struct {
+	key []byte
+	id  string
+}
+
+func NewCipher(masterKey []byte) (*Cipher, error) {
+	if len(masterKey) != masterKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", masterKeySize, len(masterKey))
+	}
+	sum := sha256.Sum256(masterKey)
+	return &Cipher{
+		key: append([]byte(nil), masterKey...),
+		id:  hex.EncodeToString(sum[:])[:keyIDLen],
+	}, nil
+}
+
+func ParseMasterKey(raw string) ([]byte, error) {
+	raw = strings.TrimSpace(raw)
+	if decoded, err := hex.DecodeString(raw); err == nil && len(decoded) == masterKeySize {
+		return decoded, nil
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(raw); err == nil && len(decoded) == masterKeySize {
+			return decoded, nil
+		}
+	}
+	return nil, fmt.Errorf("master key must be %d bytes encoded as hex or base64", masterKeySize)
+}
+
+func LoadCipher(value, file string) (*Cipher, error) {
+	value = strings.TrimSpace(value)
+	file = strings.TrimSpace(file)
+	if value == "" && file != "" {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read master key file: %w", err)
+		}
+		value = strings.TrimSpace(string(body))
+	}
+	if value == "" {
+		return nil, nil
+	}
+	key, err := ParseMasterKey(value)
+	if err != nil {
+		return nil, err
+	}
+	return NewCipher(key)
+}
+
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, SealedPrefix)
+}
+
+func (c *Cipher) KeyID() string {
+	if c == nil {
+		return ""
+	}
+	return c.id
+}
+
+func (c *Cipher) Seal(plaintext string) (string, error) {
+	if c == nil {
+		return "", ErrMasterKeyRequired
+	}
+	dataKey := make([]byte, masterKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	payload, err := sealBytes(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := sealBytes(c.key, dataKey)
+	if err != nil {
+		return "", err
+	}
+	return SealedPrefix + c.id + ":" +
+		base64.RawURLEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+func (c *Cipher) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	if c == nil {
+		return "", ErrMasterKeyRequired
+	}
+	parts := strings.Split(strings.TrimPrefix(value, SealedPrefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformedSecret
+	}
+	if parts[0] != c.id {
+		return "", fmt.Errorf("%w: value sealed with key %s, configured key is %s", ErrMasterKeyMismatch, parts[0], c.id)
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformedSecret
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformedSecret
+	}
+	dataKey, err := openBytes(c.key, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := openBytes(dataKey, payload)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func sealBytes(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openBytes(key, sealed []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformedSecret
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedSecret, err)
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
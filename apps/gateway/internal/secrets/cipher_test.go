@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpenRoundTripAndKeyMismatch(t *testing.T) {
+	t.Parallel()
+
+	first := mustCipher(t)
+	second := mustCipher(t)
+
+	sealed, err := first.Seal("sk-secret")
+	if err != nil {
+		t.Fatalf("seal failed: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "sk-secret") {
+		t.Fatalf("unexpected sealed value: %s", sealed)
+	}
+	opened, err := first.Open(sealed)
+	if err != nil || opened != "sk-secret" {
+		t.Fatalf("open got=%q err=%v", opened, err)
+	}
+	if _, err := second.Open(sealed); !errors.Is(err, ErrMasterKeyMismatch) {
+		t.Fatalf("expected key mismatch, got=%v", err)
+	}
+	if _, err := (*Cipher)(nil).Open(sealed); !errors.Is(err, ErrMasterKeyRequired) {
+		t.Fatalf("expected master key required, got=%v", err)
+	}
+	if plain, err := (*Cipher)(nil).Open("plain"); err != nil || plain != "plain" {
+		t.Fatalf("expected plaintext passthrough, got=%q err=%v", plain, err)
+	}
+}
+
+func TestLoadCipherAcceptsHexAndBase64(t *testing.T) {
+	t.Parallel()
+
+	if c, err := LoadCipher("", ""); c != nil || err != nil {
+		t.Fatalf("expected no cipher, got=%v err=%v", c, err)
+	}
+	hexKey := strings.Repeat("ab", 32)
+	fromHex, err := LoadCipher(hexKey, "")
+	if err != nil {
+		t.Fatalf("hex key failed: %v", err)
+	}
+	generated, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	fromBase64, err := LoadCipher(generated, "")
+	if err != nil {
+		t.Fatalf("base64 key failed: %v", err)
+	}
+	if fromHex.KeyID() == fromBase64.KeyID() {
+		t.Fatalf("expected distinct key ids")
+	}
+	if _, err := LoadCipher("short", ""); err == nil {
+		t.Fatal("expected short key to be rejected")
+	}
+}
+
+func mustCipher(t *testing.T) *Cipher {
+	t.Helper()
+	key, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadCipher(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,25 @@
+package secrets
+
+import "strings"
+
+func IsSensitiveFieldName(name string) bool {
+	trimmed := strings.TrimSpace(name)
+	if trimmed == "" {
+		return false
+	}
+	if strings.EqualFold(trimmed, "api_key") {
+		return true
+	}
+	upper := strings.ToUpper(trimmed)
+	return strings.HasSuffix(upper, "_KEY") ||
+		strings.HasSuffix(upper, "_TOKEN") ||
+		strings.HasSuffix(upper, "_SECRET")
+}
+
+func IsSensitiveHeaderName(name string) bool {
+	trimmed := strings.TrimSpace(name)
+	if strings.EqualFold(trimmed, "Authorization") || strings.EqualFold(trimmed, "Proxy-Authorization") {
+		return true
+	}
+	return IsSensitiveFieldName(strings.ReplaceAll(trimmed, "-", "_"))
+}
@@ -86,6 +86,9 @@ func (s RepoStateStore) WriteConversations(fn func(state *ports.ConversationsAgg
 		}
 		state.Chats = aggregate.Chats
 		state.Histories = aggregate.Histories
+		for chatID := range aggregate.Histories {
+			state.MarkHistoryChanged(chatID)
+		}
 		return nil
 	})
 }
@@ -158,3 +161,33 @@ func (s RepoStateStore) WriteCron(fn func(state *ports.CronAggregate) error) err
 		return nil
 	})
 }
+
+func (s RepoStateStore) ReadAuth(fn func(state ports.AuthAggregate)) {
+	if s.Store == nil || fn == nil {
+		return
+	}
+	s.Store.Read(func(state *repo.State) {
+		fn(ports.AuthAggregate{
+			APIKeys: state.APIKeys,
+		})
+	})
+}
+
+func (s RepoStateStore) WriteAuth(fn func(state *ports.AuthAggregate) error) error {
+	if s.Store == nil {
+		return errors.New("state store is unavailable")
+	}
+	return s.Store.Write(func(state *repo.State) error {
+		if fn == nil {
+			return nil
+		}
+		aggregate := ports.AuthAggregate{
+			APIKeys: state.APIKeys,
+		}
+		if err := fn(&aggregate); err != nil {
+			return err
+		}
+		state.APIKeys = aggregate.APIKeys
+		return nil
+	})
+}
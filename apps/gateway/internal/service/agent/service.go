@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"strconv"
 	"strings"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/runner"
 	"nextai/apps/gateway/internal/service/ports"
 )
@@ -23,6 +26,7 @@ type ProcessParams struct {
 	EffectiveInput    []domain.AgentInputMessage
 	GenerateConfig    runner.GenerateConfig
 	ToolDefinitions   []runner.ToolDefinition
+	DisableTools      bool
 	PromptMode        string
 	CollaborationMode string
 	HasToolCall       bool
@@ -33,8 +37,11 @@ type ProcessParams struct {
 
 type ProcessResult struct {
 	Reply              string
+	Parsed             interface{}
 	Events             []domain.AgentEvent
 	ProviderResponseID string
+	// Usage sums provider-reported token usage across all steps of the turn.
+	Usage runner.TokenUsage
 }
 
 type ProcessError struct {
@@ -55,6 +62,10 @@ type Dependencies struct {
 	Runner      ports.AgentRunner
 	ToolRuntime ports.AgentToolRuntime
 	ErrorMapper ports.AgentErrorMapper
+	Logger      *slog.Logger
+	// TraceSink receives the recorded trace of every provider-backed turn when
+	// the trace store is enabled.
+	TraceSink func(trace domain.TurnTrace)
 }
 
 type Service struct {
@@ -69,7 +80,7 @@ func (s *Service) Process(
 	ctx context.Context,
 	params ProcessParams,
 	emit func(evt domain.AgentEvent),
-) (ProcessResult, *ProcessError) {
+) (result ProcessResult, processErr *ProcessError) {
 	if s == nil {
 		return ProcessResult{}, &ProcessError{
 			Status:  500,
@@ -85,9 +96,52 @@ func (s *Service) Process(
 		}
 	}
 
+	ctx, turnSpan := observability.StartSpan(ctx, "agent.turn", observability.WithAttributes(
+		"agent.session_id", params.Request.SessionID,
+		"agent.channel", params.Request.Channel,
+		"agent.prompt_mode", params.PromptMode,
+		"agent.streaming", params.Streaming,
+	))
+	ctx = observability.WithLogFields(ctx,
+		observability.LogKeySessionID, params.Request.SessionID,
+		observability.LogKeyUserID, params.Request.UserID,
+		observability.LogKeyChannel, params.Request.Channel,
+		observability.LogKeyTurnID, turnSpan.SpanContext().SpanID.String(),
+	)
+	logger := observability.LoggerOrDefault(s.deps.Logger)
+	logger.DebugContext(ctx, "agent turn started", "prompt_mode", params.PromptMode, "streaming", params.Streaming)
+	stepCtx := ctx
+	var stepSpan *observability.Span
+	var traceRecorder *turnTraceRecorder
+	beginStep := func(step int) {
+		stepSpan.End()
+		stepCtx, stepSpan = observability.StartSpan(ctx, "agent.step", observability.WithAttributes("agent.step", step))
+		stepCtx = observability.WithLogFields(stepCtx, observability.LogKeyStep, step)
+	}
+	defer func() {
+		if processErr != nil {
+			turnSpan.SetAttributes("agent.error_code", processErr.Code)
+			turnSpan.SetStatus(observability.SpanStatusError, processErr.Message)
+			stepSpan.SetStatus(observability.SpanStatusError, processErr.Message)
+			logger.WarnContext(stepCtx, "agent turn failed", "code", processErr.Code, "status", processErr.Status, "err", processErr.Message)
+		} else {
+			logger.DebugContext(stepCtx, "agent turn completed", "reply_chars", len(result.Reply))
+		}
+		stepSpan.End()
+		turnSpan.End()
+		if traceRecorder != nil {
+			s.deps.TraceSink(traceRecorder.finish(result.Reply, processErr))
+		}
+	}()
+
 	reply := ""
 	events := make([]domain.AgentEvent, 0, 12)
 	appendEvent := func(evt domain.AgentEvent) {
+		span := stepSpan
+		if span == nil {
+			span = turnSpan
+		}
+		evt.Meta = withTraceMeta(evt.Meta, turnSpan, span)
 		events = append(events, evt)
 		if emit != nil {
 			emit(evt)
@@ -98,7 +152,9 @@ func (s *Service) Process(
 		replyChunkSize = 12
 	}
 	toolDefinitions := params.ToolDefinitions
-	if len(toolDefinitions) == 0 {
+	if params.DisableTools {
+		toolDefinitions = nil
+	} else if len(toolDefinitions) == 0 {
 		toolDefinitions = s.deps.ToolRuntime.ListToolDefinitions(params.PromptMode)
 	}
 	appendReplyDeltas := func(step int, text string) {
@@ -129,6 +185,7 @@ func (s *Service) Process(
 		toolInput = enrichNativeToolInput(execName, toolInput, params.Request, params.PromptMode, params.CollaborationMode, fmt.Sprintf("direct_tool_call_%d", step))
 		eventToolInput := normalizeToolCallEventInput(execName, safeMap(params.RequestedToolCall.Input), toolInput)
 
+		beginStep(step)
 		appendEvent(domain.AgentEvent{Type: "step_started", Step: step})
 		appendEvent(domain.AgentEvent{
 			Type: "tool_call",
@@ -138,7 +195,8 @@ func (s *Service) Process(
 				Input: eventToolInput,
 			},
 		})
-		toolReply, err := s.deps.ToolRuntime.ExecuteToolCall(ctx, params.PromptMode, execName, toolInput)
+		toolCtx := observability.WithLogFields(stepCtx, observability.LogKeyTool, execName)
+		toolReply, err := s.deps.ToolRuntime.ExecuteToolCall(toolCtx, params.PromptMode, execName, toolInput)
 		if err != nil {
 			status, code, message := s.deps.ErrorMapper.MapToolError(err)
 			return ProcessResult{}, &ProcessError{Status: status, Code: code, Message: message}
@@ -158,27 +216,58 @@ func (s *Service) Process(
 		return ProcessResult{Reply: reply, Events: events}, nil
 	}
 
+	if s.deps.TraceSink != nil {
+		traceRecorder = newTurnTraceRecorder(turnSpan, params, toolDefinitions)
+	}
 	workflowInput := cloneAgentInputMessages(params.EffectiveInput)
 	generateConfig := params.GenerateConfig
 	providerResponseID := strings.TrimSpace(generateConfig.PreviousResponseID)
 	step := 1
+	responseFormat := params.Request.ResponseFormat
+	structuredOutput := structuredOutputEnabled(responseFormat)
+	structuredRetries := 0
+	var parsed interface{}
+	var usage runner.TokenUsage
+	if structuredOutput {
+		workflowInput = withStructuredOutputInstruction(workflowInput, responseFormat)
+	}
 
 	for {
+		beginStep(step)
+		traceRecorder.beginStep(step, workflowInput)
 		appendEvent(domain.AgentEvent{Type: "step_started", Step: step})
+		runCtx := traceRecorder.withContext(stepCtx)
 		turnReq := params.Request
 		turnReq.Input = workflowInput
 
 		stepHadStreamingDelta := false
+		// With response_format, deltas are held back until the reply passes
+		// validation so clients never see an attempt that gets retried.
+		bufferedDeltas := []string{}
+		flushDeltas := func() {
+			for _, delta := range bufferedDeltas {
+				appendEvent(domain.AgentEvent{
+					Type:  "assistant_delta",
+					Step:  step,
+					Delta: delta,
+				})
+			}
+			bufferedDeltas = bufferedDeltas[:0]
+		}
 		var (
 			turn   runner.TurnResult
 			runErr error
 		)
 		if params.Streaming {
-			turn, runErr = s.deps.Runner.GenerateTurnStream(ctx, turnReq, generateConfig, toolDefinitions, func(delta string) {
+			turn, runErr = s.deps.Runner.GenerateTurnStream(runCtx, turnReq, generateConfig, toolDefinitions, func(delta string) {
 				if delta == "" {
 					return
 				}
 				stepHadStreamingDelta = true
+				if structuredOutput {
+					bufferedDeltas = append(bufferedDeltas, delta)
+					return
+				}
 				appendEvent(domain.AgentEvent{
 					Type:  "assistant_delta",
 					Step:  step,
@@ -186,10 +275,12 @@ func (s *Service) Process(
 				})
 			})
 		} else {
-			turn, runErr = s.deps.Runner.GenerateTurn(ctx, turnReq, generateConfig, toolDefinitions)
+			turn, runErr = s.deps.Runner.GenerateTurn(runCtx, turnReq, generateConfig, toolDefinitions)
 		}
+		traceRecorder.finishStep(turn, runErr)
 		if runErr != nil {
 			if recoveredCall, recovered := s.deps.ToolRuntime.RecoverInvalidProviderToolCall(runErr, step); recovered {
+				flushDeltas()
 				appendEvent(domain.AgentEvent{
 					Type: "tool_call",
 					Step: step,
@@ -246,6 +337,9 @@ func (s *Service) Process(
 				Details: buildRunnerErrorDetails(runErr),
 			}
 		}
+		usage.PromptTokens += turn.Usage.PromptTokens
+		usage.CompletionTokens += turn.Usage.CompletionTokens
+		usage.TotalTokens += turn.Usage.TotalTokens
 		if responseID := strings.TrimSpace(turn.ResponseID); responseID != "" {
 			providerResponseID = responseID
 			generateConfig.PreviousResponseID = responseID
@@ -253,6 +347,48 @@ func (s *Service) Process(
 
 		if len(turn.ToolCalls) == 0 {
 			reply = strings.TrimSpace(turn.Text)
+			if structuredOutput {
+				value, validationErrs := parseStructuredReply(reply, responseFormat)
+				if len(validationErrs) > 0 {
+					if structuredRetries >= maxStructuredOutputRetries {
+						return ProcessResult{}, &ProcessError{
+							Status:  http.StatusBadGateway,
+							Code:    structuredOutputInvalidCode,
+							Message: "model reply does not match response_format",
+							Details: map[string]interface{}{
+								"errors":   validationErrs,
+								"attempts": structuredRetries + 1,
+								"reply":    summarizeAgentEventText(reply),
+							},
+						}
+					}
+					structuredRetries++
+					appendEvent(domain.AgentEvent{
+						Type: structuredOutputInvalidCode,
+						Step: step,
+						Meta: map[string]interface{}{
+							"errors":  validationErrs,
+							"attempt": structuredRetries,
+						},
+					})
+					workflowInput = append(workflowInput,
+						domain.AgentInputMessage{
+							Role:    "assistant",
+							Type:    "message",
+							Content: []domain.RuntimeContent{{Type: "text", Text: reply}},
+						},
+						domain.AgentInputMessage{
+							Role:    "user",
+							Type:    "message",
+							Content: []domain.RuntimeContent{{Type: "text", Text: buildStructuredOutputFeedback(validationErrs)}},
+						},
+					)
+					step++
+					continue
+				}
+				parsed = value
+				flushDeltas()
+			}
 			if reply == "" {
 				reply = "(empty reply)"
 			}
@@ -263,10 +399,17 @@ func (s *Service) Process(
 			if providerResponseID != "" {
 				completed.Meta = map[string]interface{}{"provider_response_id": providerResponseID}
 			}
+			if parsed != nil {
+				if completed.Meta == nil {
+					completed.Meta = map[string]interface{}{}
+				}
+				completed.Meta["parsed"] = parsed
+			}
 			appendEvent(completed)
 			break
 		}
 
+		flushDeltas()
 		assistantMessage := domain.AgentInputMessage{
 			Role:     "assistant",
 			Type:     "message",
@@ -299,8 +442,10 @@ func (s *Service) Process(
 					Input: eventToolInput,
 				},
 			})
-			toolReply, toolErr := s.deps.ToolRuntime.ExecuteToolCall(ctx, params.PromptMode, execName, execInput)
+			toolCtx := observability.WithLogFields(stepCtx, observability.LogKeyTool, execName)
+			toolReply, toolErr := s.deps.ToolRuntime.ExecuteToolCall(toolCtx, params.PromptMode, execName, execInput)
 			if toolErr != nil {
+				logger.WarnContext(toolCtx, "tool call failed", "err", toolErr)
 				toolReply = s.deps.ToolRuntime.FormatToolErrorFeedback(toolErr)
 				appendEvent(domain.AgentEvent{
 					Type: "tool_result",
@@ -344,7 +489,21 @@ func (s *Service) Process(
 		step++
 	}
 
-	return ProcessResult{Reply: reply, Events: events, ProviderResponseID: providerResponseID}, nil
+	return ProcessResult{Reply: reply, Parsed: parsed, Events: events, ProviderResponseID: providerResponseID, Usage: usage}, nil
+}
+
+func withTraceMeta(meta map[string]interface{}, turnSpan, span *observability.Span) map[string]interface{} {
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return meta
+	}
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+	meta["trace_id"] = sc.TraceID.String()
+	meta["span_id"] = sc.SpanID.String()
+	meta["turn_id"] = turnSpan.SpanContext().SpanID.String()
+	return meta
 }
 
 func (s *Service) validateDependencies() error {
@@ -845,7 +1004,7 @@ func enrichNativeToolInput(
 	switch strings.ToLower(strings.TrimSpace(name)) {
 	case "self_ops":
 		return enrichSelfOpsToolInput(out, req)
-	case "spawn_agent", "send_input", "resume_agent", "wait", "close_agent", "update_plan", "request_user_input":
+	case "spawn_agent", "send_input", "resume_agent", "wait", "close_agent", "update_plan", "request_user_input", "schedule_reminder":
 		out[toolInputMetaSessionIDKey] = strings.TrimSpace(req.SessionID)
 		out[toolInputMetaUserIDKey] = strings.TrimSpace(req.UserID)
 		out[toolInputMetaChannelKey] = strings.TrimSpace(req.Channel)
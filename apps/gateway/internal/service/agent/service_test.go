@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/runner"
 	"nextai/apps/gateway/internal/service/adapters"
 )
@@ -643,3 +644,249 @@ func TestProcessSpawnAgentToolCallAutoInjectsRequestScope(t *testing.T) {
 		t.Fatalf("unexpected process error: %+v", processErr)
 	}
 }
+
+func TestProcessStructuredOutputRetriesUntilSchemaMatches(t *testing.T) {
+	t.Parallel()
+
+	replies := []string{"sure, here you go", "```json\n{\"title\":\"ok\",\"score\":\"high\"}\n```", "{\"title\":\"ok\",\"score\":3}"}
+	calls := 0
+	var lastInput []domain.AgentInputMessage
+	svc := NewService(Dependencies{
+		Runner: adapters.AgentRunner{
+			GenerateTurnFunc: func(_ context.Context, req domain.AgentProcessRequest, _ runner.GenerateConfig, _ []runner.ToolDefinition) (runner.TurnResult, error) {
+				lastInput = req.Input
+				reply := replies[calls]
+				calls++
+				return runner.TurnResult{Text: reply}, nil
+			},
+		},
+		ToolRuntime: adapters.AgentToolRuntime{
+			ListToolDefinitionsFunc: func(string) []runner.ToolDefinition { return nil },
+		},
+		ErrorMapper: adapters.AgentErrorMapper{
+			MapRunnerErrorFunc: func(err error) (int, string, string) { return http.StatusBadGateway, "runner_error", err.Error() },
+		},
+	})
+
+	format, err := NormalizeResponseFormat(&domain.ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &domain.ResponseJSONSchema{
+			Name: "rating",
+			Schema: map[string]interface{}{
+				"type":                 "object",
+				"required":             []interface{}{"title", "score"},
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"title": map[string]interface{}{"type": "string"},
+					"score": map[string]interface{}{"type": "integer", "minimum": float64(1)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected format error: %v", err)
+	}
+	result, processErr := svc.Process(context.Background(), ProcessParams{
+		Request:        domain.AgentProcessRequest{ResponseFormat: format},
+		EffectiveInput: []domain.AgentInputMessage{{Role: "user", Type: "message", Content: []domain.RuntimeContent{{Type: "text", Text: "rate it"}}}},
+	}, nil)
+	if processErr != nil {
+		t.Fatalf("unexpected process error: %+v", processErr)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 runner calls, got=%d", calls)
+	}
+	parsed, ok := result.Parsed.(map[string]interface{})
+	if !ok || parsed["score"] != float64(3) || parsed["title"] != "ok" {
+		t.Fatalf("unexpected parsed value: %#v", result.Parsed)
+	}
+	invalidEvents := 0
+	for _, evt := range result.Events {
+		if evt.Type == "structured_output_invalid" {
+			invalidEvents++
+		}
+	}
+	if invalidEvents != 2 {
+		t.Fatalf("expected 2 structured_output_invalid events, got=%d", invalidEvents)
+	}
+	if len(lastInput) == 0 || lastInput[0].Role != "system" || !strings.Contains(lastInput[0].Content[0].Text, "JSON Schema") {
+		t.Fatalf("expected schema instruction as leading system message, got=%#v", lastInput)
+	}
+	feedback := lastInput[len(lastInput)-1]
+	if feedback.Role != "user" || !strings.Contains(feedback.Content[0].Text, "$.score") {
+		t.Fatalf("expected schema feedback message, got=%#v", feedback)
+	}
+}
+
+func TestProcessStructuredOutputFailsAfterRetries(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	svc := NewService(Dependencies{
+		Runner: adapters.AgentRunner{
+			GenerateTurnFunc: func(context.Context, domain.AgentProcessRequest, runner.GenerateConfig, []runner.ToolDefinition) (runner.TurnResult, error) {
+				calls++
+				return runner.TurnResult{Text: "not json"}, nil
+			},
+		},
+		ToolRuntime: adapters.AgentToolRuntime{
+			ListToolDefinitionsFunc: func(string) []runner.ToolDefinition { return nil },
+		},
+		ErrorMapper: adapters.AgentErrorMapper{
+			MapRunnerErrorFunc: func(err error) (int, string, string) { return http.StatusBadGateway, "runner_error", err.Error() },
+		},
+	})
+
+	_, processErr := svc.Process(context.Background(), ProcessParams{
+		Request:        domain.AgentProcessRequest{ResponseFormat: &domain.ResponseFormat{Type: "json_object"}},
+		EffectiveInput: []domain.AgentInputMessage{{Role: "user", Type: "message", Content: []domain.RuntimeContent{{Type: "text", Text: "hi"}}}},
+	}, nil)
+	if processErr == nil {
+		t.Fatal("expected structured output error")
+	}
+	if processErr.Status != http.StatusBadGateway || processErr.Code != "structured_output_invalid" {
+		t.Fatalf("unexpected process error: %+v", processErr)
+	}
+	if calls != maxStructuredOutputRetries+1 {
+		t.Fatalf("unexpected runner calls: %d", calls)
+	}
+}
+
+func TestNormalizeResponseFormatRejectsInvalidSchema(t *testing.T) {
+	t.Parallel()
+
+	if format, err := NormalizeResponseFormat(&domain.ResponseFormat{Type: "text"}); err != nil || format != nil {
+		t.Fatalf("expected text format to be dropped, got=%#v err=%v", format, err)
+	}
+	if _, err := NormalizeResponseFormat(&domain.ResponseFormat{Type: "json_schema"}); err == nil {
+		t.Fatal("expected missing schema to fail")
+	}
+	if _, err := NormalizeResponseFormat(&domain.ResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &domain.ResponseJSONSchema{Schema: map[string]interface{}{"type": "map"}},
+	}); err == nil {
+		t.Fatal("expected unknown schema type to fail")
+	}
+	if _, err := NormalizeResponseFormat(&domain.ResponseFormat{Type: "yaml"}); err == nil {
+		t.Fatal("expected unsupported format type to fail")
+	}
+}
+
+func TestNormalizeResponseFormatRejectsUnsupportedKeywords(t *testing.T) {
+	t.Parallel()
+
+	for _, schema := range []map[string]interface{}{
+		{"$ref": "#/$defs/item"},
+		{"type": "object", "$defs": map[string]interface{}{"item": map[string]interface{}{"type": "string"}}},
+		{"type": "object", "properties": map[string]interface{}{"name": map[string]interface{}{"type": "string", "format": "email"}}},
+		{"type": "object", "additionalProperties": map[string]interface{}{"patternProperties": map[string]interface{}{}}},
+	} {
+		if _, err := NormalizeResponseFormat(&domain.ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &domain.ResponseJSONSchema{Schema: schema},
+		}); err == nil || !strings.Contains(err.Error(), "is not supported") {
+			t.Fatalf("expected unsupported keyword error for %v, got=%v", schema, err)
+		}
+	}
+	if _, err := NormalizeResponseFormat(&domain.ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &domain.ResponseJSONSchema{Schema: map[string]interface{}{
+			"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "t", "description": "d", "type": "object",
+		}},
+	}); err != nil {
+		t.Fatalf("expected annotations to be accepted, got=%v", err)
+	}
+	if errs := validateJSONSchema("x", map[string]interface{}{"$ref": "#/x"}, "$"); len(errs) == 0 {
+		t.Fatal("expected validator to refuse unsupported keywords")
+	}
+}
+
+func TestProcessStructuredOutputStreamsOnlyValidatedAttempt(t *testing.T) {
+	t.Parallel()
+
+	replies := []string{"not json", `{"ok":true}`}
+	calls := 0
+	svc := NewService(Dependencies{
+		Runner: adapters.AgentRunner{
+			GenerateTurnStreamFunc: func(_ context.Context, _ domain.AgentProcessRequest, _ runner.GenerateConfig, _ []runner.ToolDefinition, onDelta func(string)) (runner.TurnResult, error) {
+				reply := replies[calls]
+				calls++
+				onDelta(reply)
+				return runner.TurnResult{Text: reply}, nil
+			},
+		},
+		ToolRuntime: adapters.AgentToolRuntime{
+			ListToolDefinitionsFunc: func(string) []runner.ToolDefinition { return nil },
+		},
+		ErrorMapper: adapters.AgentErrorMapper{
+			MapRunnerErrorFunc: func(err error) (int, string, string) { return http.StatusBadGateway, "runner_error", err.Error() },
+		},
+	})
+
+	emitted := []string{}
+	_, processErr := svc.Process(context.Background(), ProcessParams{
+		Request:        domain.AgentProcessRequest{ResponseFormat: &domain.ResponseFormat{Type: "json_object"}},
+		Streaming:      true,
+		EffectiveInput: []domain.AgentInputMessage{{Role: "user", Type: "message", Content: []domain.RuntimeContent{{Type: "text", Text: "hi"}}}},
+	}, func(evt domain.AgentEvent) {
+		if evt.Type == "assistant_delta" {
+			emitted = append(emitted, evt.Delta)
+		}
+	})
+	if processErr != nil {
+		t.Fatalf("unexpected process error: %+v", processErr)
+	}
+	if strings.Join(emitted, "") != `{"ok":true}` {
+		t.Fatalf("expected only the validated reply to stream, got=%q", emitted)
+	}
+}
+
+func TestProcessPropagatesTraceContextToRunnerToolsAndEvents(t *testing.T) {
+	t.Parallel()
+
+	traceID := observability.TraceIDFromRequestID("req-trace-propagation")
+	ctx := observability.ContextWithRemoteSpanContext(context.Background(), observability.SpanContext{TraceID: traceID})
+	seen := map[string]string{}
+	turns := 0
+	svc := NewService(Dependencies{
+		Runner: adapters.AgentRunner{
+			GenerateTurnFunc: func(runCtx context.Context, _ domain.AgentProcessRequest, _ runner.GenerateConfig, _ []runner.ToolDefinition) (runner.TurnResult, error) {
+				turns++
+				seen["runner"] = observability.TraceIDFromContext(runCtx)
+				if turns == 1 {
+					return runner.TurnResult{ToolCalls: []runner.ToolCall{{ID: "call_1", Name: "shell", Arguments: map[string]interface{}{"cmd": "true"}}}}, nil
+				}
+				return runner.TurnResult{Text: "done"}, nil
+			},
+		},
+		ToolRuntime: adapters.AgentToolRuntime{
+			ListToolDefinitionsFunc: func(string) []runner.ToolDefinition { return nil },
+			ExecuteToolCallFunc: func(toolCtx context.Context, _ string, _ string, _ map[string]interface{}) (string, error) {
+				seen["tool"] = observability.TraceIDFromContext(toolCtx)
+				return "ok", nil
+			},
+		},
+		ErrorMapper: adapters.AgentErrorMapper{
+			MapToolErrorFunc:   func(err error) (int, string, string) { return http.StatusBadRequest, "tool_error", err.Error() },
+			MapRunnerErrorFunc: func(err error) (int, string, string) { return http.StatusBadGateway, "runner_error", err.Error() },
+		},
+	})
+
+	result, processErr := svc.Process(ctx, ProcessParams{ReplyChunkSize: 32}, nil)
+	if processErr != nil {
+		t.Fatalf("unexpected process error: %+v", processErr)
+	}
+	if seen["runner"] != traceID.String() || seen["tool"] != traceID.String() {
+		t.Fatalf("expected trace %s to reach runner and tools, got=%v", traceID, seen)
+	}
+	stepSpans := map[interface{}]struct{}{}
+	for _, evt := range result.Events {
+		if evt.Meta["trace_id"] != traceID.String() {
+			t.Fatalf("event %s missing trace_id: %#v", evt.Type, evt.Meta)
+		}
+		stepSpans[evt.Meta["span_id"]] = struct{}{}
+	}
+	if len(stepSpans) != 2 {
+		t.Fatalf("expected one span per step, got=%d", len(stepSpans))
+	}
+}
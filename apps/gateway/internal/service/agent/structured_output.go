@@ -0,0 +1,552 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"nextai/apps/gateway/internal/domain"
+)
+
+const (
+	maxStructuredOutputRetries   = 2
+	structuredOutputInvalidCode  = "structured_output_invalid"
+	structuredOutputMaxErrorList = 8
+)
+
+func NormalizeResponseFormat(in *domain.ResponseFormat) (*domain.ResponseFormat, error) {
+	if in == nil {
+		return nil, nil
+	}
+	formatType := strings.ToLower(strings.TrimSpace(in.Type))
+	switch formatType {
+	case "", domain.ResponseFormatText:
+		return nil, nil
+	case domain.ResponseFormatJSONObject:
+		return &domain.ResponseFormat{Type: domain.ResponseFormatJSONObject}, nil
+	case domain.ResponseFormatJSONSchema:
+		if in.JSONSchema == nil || len(in.JSONSchema.Schema) == 0 {
+			return nil, errors.New("response_format.json_schema.schema is required")
+		}
+		if err := checkSchemaDefinition(in.JSONSchema.Schema, "schema"); err != nil {
+			return nil, err
+		}
+		return &domain.ResponseFormat{
+			Type: domain.ResponseFormatJSONSchema,
+			JSONSchema: &domain.ResponseJSONSchema{
+				Name:        strings.TrimSpace(in.JSONSchema.Name),
+				Description: strings.TrimSpace(in.JSONSchema.Description),
+				Schema:      in.JSONSchema.Schema,
+				Strict:      in.JSONSchema.Strict,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported response_format.type %q", in.Type)
+	}
+}
+
+func structuredOutputEnabled(format *domain.ResponseFormat) bool {
+	if format == nil {
+		return false
+	}
+	return format.Type == domain.ResponseFormatJSONObject || format.Type == domain.ResponseFormatJSONSchema
+}
+
+func withStructuredOutputInstruction(input []domain.AgentInputMessage, format *domain.ResponseFormat) []domain.AgentInputMessage {
+	instruction := buildStructuredOutputInstruction(format)
+	if instruction == "" {
+		return input
+	}
+	message := domain.AgentInputMessage{
+		Role:    "system",
+		Type:    "message",
+		Content: []domain.RuntimeContent{{Type: "text", Text: instruction}},
+	}
+	insertAt := 0
+	for insertAt < len(input) && strings.EqualFold(strings.TrimSpace(input[insertAt].Role), "system") {
+		insertAt++
+	}
+	out := make([]domain.AgentInputMessage, 0, len(input)+1)
+	out = append(out, input[:insertAt]...)
+	out = append(out, message)
+	out = append(out, input[insertAt:]...)
+	return out
+}
+
+func buildStructuredOutputInstruction(format *domain.ResponseFormat) string {
+	if !structuredOutputEnabled(format) {
+		return ""
+	}
+	if format.Type == domain.ResponseFormatJSONObject || format.JSONSchema == nil {
+		return "Respond with a single valid JSON object only. Do not wrap it in markdown fences and do not add any text before or after it."
+	}
+	schema, err := json.Marshal(format.JSONSchema.Schema)
+	if err != nil {
+		return "Respond with a single valid JSON value only."
+	}
+	lines := []string{
+		"Respond with a single JSON value that validates against the following JSON Schema.",
+		"Do not wrap it in markdown fences and do not add any text before or after it.",
+	}
+	if description := strings.TrimSpace(format.JSONSchema.Description); description != "" {
+		lines = append(lines, "Purpose: "+description)
+	}
+	lines = append(lines, "JSON Schema:", string(schema))
+	return strings.Join(lines, "\n")
+}
+
+func buildStructuredOutputFeedback(errs []string) string {
+	return "Your previous reply did not satisfy the required response format:\n- " +
+		strings.Join(errs, "\n- ") +
+		"\nReply again with only the corrected JSON."
+}
+
+func parseStructuredReply(reply string, format *domain.ResponseFormat) (interface{}, []string) {
+	parsed, err := decodeStructuredReply(reply)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	if format.Type == domain.ResponseFormatJSONObject || format.JSONSchema == nil {
+		if _, ok := parsed.(map[string]interface{}); !ok {
+			return nil, []string{"reply must be a JSON object"}
+		}
+		return parsed, nil
+	}
+	errs := validateJSONSchema(parsed, format.JSONSchema.Schema, "$")
+	if len(errs) > structuredOutputMaxErrorList {
+		errs = errs[:structuredOutputMaxErrorList]
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return parsed, nil
+}
+
+func decodeStructuredReply(reply string) (interface{}, error) {
+	text := strings.TrimSpace(reply)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```JSON")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+		text = strings.TrimSpace(text)
+	}
+	if text == "" {
+		return nil, errors.New("reply is empty")
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(text), &parsed); err == nil {
+		return parsed, nil
+	}
+	candidate := extractFirstJSONValue(text)
+	if candidate == "" {
+		return nil, errors.New("reply is not valid JSON")
+	}
+	if err := json.Unmarshal([]byte(candidate), &parsed); err != nil {
+		return nil, fmt.Errorf("reply is not valid JSON: %v", err)
+	}
+	return parsed, nil
+}
+
+func extractFirstJSONValue(text string) string {
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return ""
+	}
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return text[start : i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// supportedSchemaKeywords lists what validateJSONSchema enforces plus
+// annotations that never affect validation. Anything else, notably $ref and
+// $defs, is rejected so a schema cannot silently validate less than it says.
+var supportedSchemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true,
+	"allOf": true, "anyOf": true, "oneOf": true,
+	"title": true, "description": true, "default": true, "examples": true,
+	"$schema": true, "$comment": true,
+}
+
+func unsupportedSchemaKeyword(schema map[string]interface{}) string {
+	keys := make([]string, 0, len(schema))
+	for key := range schema {
+		if !supportedSchemaKeywords[key] {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
+
+func checkSchemaDefinition(schema map[string]interface{}, path string) error {
+	if keyword := unsupportedSchemaKeyword(schema); keyword != "" {
+		return fmt.Errorf("%s.%s is not supported", path, keyword)
+	}
+	if rawType, ok := schema["type"]; ok {
+		switch value := rawType.(type) {
+		case string:
+			if !knownSchemaType(value) {
+				return fmt.Errorf("%s.type %q is not supported", path, value)
+			}
+		case []interface{}:
+			for _, item := range value {
+				name, ok := item.(string)
+				if !ok || !knownSchemaType(name) {
+					return fmt.Errorf("%s.type contains an unsupported entry", path)
+				}
+			}
+		default:
+			return fmt.Errorf("%s.type must be a string or an array of strings", path)
+		}
+	}
+	if rawPattern, ok := schema["pattern"]; ok {
+		pattern, ok := rawPattern.(string)
+		if !ok {
+			return fmt.Errorf("%s.pattern must be a string", path)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s.pattern is invalid: %v", path, err)
+		}
+	}
+	if rawProperties, ok := schema["properties"]; ok {
+		properties, ok := rawProperties.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.properties must be an object", path)
+		}
+		for name, rawProperty := range properties {
+			property, ok := rawProperty.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s.properties.%s must be an object", path, name)
+			}
+			if err := checkSchemaDefinition(property, path+".properties."+name); err != nil {
+				return err
+			}
+		}
+	}
+	if rawItems, ok := schema["items"]; ok {
+		items, ok := rawItems.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.items must be an object", path)
+		}
+		if err := checkSchemaDefinition(items, path+".items"); err != nil {
+			return err
+		}
+	}
+	switch additional := schema["additionalProperties"].(type) {
+	case nil, bool:
+	case map[string]interface{}:
+		if err := checkSchemaDefinition(additional, path+".additionalProperties"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s.additionalProperties must be a boolean or an object", path)
+	}
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		rawList, ok := schema[key]
+		if !ok {
+			continue
+		}
+		list, ok := rawList.([]interface{})
+		if !ok || len(list) == 0 {
+			return fmt.Errorf("%s.%s must be a non-empty array", path, key)
+		}
+		for idx, rawItem := range list {
+			item, ok := rawItem.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s.%s[%d] must be an object", path, key, idx)
+			}
+			if err := checkSchemaDefinition(item, fmt.Sprintf("%s.%s[%d]", path, key, idx)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func knownSchemaType(name string) bool {
+	switch name {
+	case "object", "array", "string", "number", "integer", "boolean", "null":
+		return true
+	default:
+		return false
+	}
+}
+
+func validateJSONSchema(value interface{}, schema map[string]interface{}, path string) []string {
+	if len(schema) == 0 {
+		return nil
+	}
+	if keyword := unsupportedSchemaKeyword(schema); keyword != "" {
+		return []string{fmt.Sprintf("%s: schema keyword %q is not supported", path, keyword)}
+	}
+	errs := []string{}
+	if rawType, ok := schema["type"]; ok {
+		types := schemaTypes(rawType)
+		if len(types) > 0 && !matchesAnySchemaType(value, types) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeName(value))}
+		}
+	}
+	if rawEnum, ok := schema["enum"].([]interface{}); ok {
+		matched := false
+		for _, item := range rawEnum {
+			if jsonValuesEqual(value, item) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Sprintf("%s: value is not one of the allowed enum values", path))
+		}
+	}
+	if rawConst, ok := schema["const"]; ok && !jsonValuesEqual(value, rawConst) {
+		errs = append(errs, fmt.Sprintf("%s: value does not match const", path))
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, validateSchemaObject(typed, schema, path)...)
+	case []interface{}:
+		errs = append(errs, validateSchemaArray(typed, schema, path)...)
+	case string:
+		length := utf8.RuneCountInString(typed)
+		if minLength, ok := schemaNumber(schema, "minLength"); ok && float64(length) < minLength {
+			errs = append(errs, fmt.Sprintf("%s: string is shorter than %g", path, minLength))
+		}
+		if maxLength, ok := schemaNumber(schema, "maxLength"); ok && float64(length) > maxLength {
+			errs = append(errs, fmt.Sprintf("%s: string is longer than %g", path, maxLength))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(typed) {
+				errs = append(errs, fmt.Sprintf("%s: string does not match pattern %q", path, pattern))
+			}
+		}
+	case float64:
+		if minimum, ok := schemaNumber(schema, "minimum"); ok && typed < minimum {
+			errs = append(errs, fmt.Sprintf("%s: value must be >= %g", path, minimum))
+		}
+		if maximum, ok := schemaNumber(schema, "maximum"); ok && typed > maximum {
+			errs = append(errs, fmt.Sprintf("%s: value must be <= %g", path, maximum))
+		}
+	}
+
+	if rawAll, ok := schema["allOf"].([]interface{}); ok {
+		for _, rawItem := range rawAll {
+			if item, ok := rawItem.(map[string]interface{}); ok {
+				errs = append(errs, validateJSONSchema(value, item, path)...)
+			}
+		}
+	}
+	if rawAny, ok := schema["anyOf"].([]interface{}); ok {
+		if countMatchingSchemas(value, rawAny, path) == 0 {
+			errs = append(errs, fmt.Sprintf("%s: value does not match any allowed schema", path))
+		}
+	}
+	if rawOne, ok := schema["oneOf"].([]interface{}); ok {
+		if countMatchingSchemas(value, rawOne, path) != 1 {
+			errs = append(errs, fmt.Sprintf("%s: value must match exactly one schema", path))
+		}
+	}
+	return errs
+}
+
+func validateSchemaObject(value map[string]interface{}, schema map[string]interface{}, path string) []string {
+	errs := []string{}
+	properties, _ := schema["properties"].(map[string]interface{})
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, rawName := range required {
+			name, ok := rawName.(string)
+			if !ok {
+				continue
+			}
+			if _, exists := value[name]; !exists {
+				errs = append(errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+	}
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "." + key
+		if rawProperty, ok := properties[key]; ok {
+			if property, ok := rawProperty.(map[string]interface{}); ok {
+				errs = append(errs, validateJSONSchema(value[key], property, childPath)...)
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				errs = append(errs, fmt.Sprintf("%s: unexpected property", childPath))
+			}
+		case map[string]interface{}:
+			errs = append(errs, validateJSONSchema(value[key], additional, childPath)...)
+		}
+	}
+	return errs
+}
+
+func validateSchemaArray(value []interface{}, schema map[string]interface{}, path string) []string {
+	errs := []string{}
+	if minItems, ok := schemaNumber(schema, "minItems"); ok && float64(len(value)) < minItems {
+		errs = append(errs, fmt.Sprintf("%s: array must contain at least %g items", path, minItems))
+	}
+	if maxItems, ok := schemaNumber(schema, "maxItems"); ok && float64(len(value)) > maxItems {
+		errs = append(errs, fmt.Sprintf("%s: array must contain at most %g items", path, maxItems))
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for idx, item := range value {
+			errs = append(errs, validateJSONSchema(item, items, fmt.Sprintf("%s[%d]", path, idx))...)
+		}
+	}
+	return errs
+}
+
+func countMatchingSchemas(value interface{}, schemas []interface{}, path string) int {
+	count := 0
+	for _, rawItem := range schemas {
+		item, ok := rawItem.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if len(validateJSONSchema(value, item, path)) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func schemaTypes(raw interface{}) []string {
+	switch value := raw.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		out := make([]string, 0, len(value))
+		for _, item := range value {
+			if name, ok := item.(string); ok {
+				out = append(out, name)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func matchesAnySchemaType(value interface{}, types []string) bool {
+	for _, name := range types {
+		if matchesSchemaType(value, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesSchemaType(value interface{}, name string) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return false
+	}
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return "unknown"
+	}
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	switch value := schema[key].(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	default:
+		return 0, false
+	}
+}
+
+func jsonValuesEqual(a, b interface{}) bool {
+	left, errLeft := json.Marshal(a)
+	right, errRight := json.Marshal(b)
+	if errLeft != nil || errRight != nil {
+		return false
+	}
+	return string(left) == string(right)
+}
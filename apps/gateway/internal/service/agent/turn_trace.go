@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+	"nextai/apps/gateway/internal/runner"
+)
+
+const (
+	TurnTraceStatusSucceeded = "succeeded"
+	TurnTraceStatusFailed    = "failed"
+)
+
+// turnTraceRecorder collects the per-step provider inputs and raw exchanges of
+// one turn for the opt-in trace store. All methods are no-ops on a nil recorder.
+type turnTraceRecorder struct {
+	mu    sync.Mutex
+	trace domain.TurnTrace
+}
+
+func newTurnTraceRecorder(turnSpan *observability.Span, params ProcessParams, tools []runner.ToolDefinition) *turnTraceRecorder {
+	sc := turnSpan.SpanContext()
+	trace := domain.TurnTrace{
+		ID:             sc.SpanID.String(),
+		TraceID:        sc.TraceID.String(),
+		SessionID:      params.Request.SessionID,
+		UserID:         params.Request.UserID,
+		Channel:        params.Request.Channel,
+		PromptMode:     params.PromptMode,
+		ProviderID:     strings.TrimSpace(params.GenerateConfig.ProviderID),
+		Model:          strings.TrimSpace(params.GenerateConfig.Model),
+		ResponseFormat: params.Request.ResponseFormat,
+		StartedAt:      time.Now().UTC().Format(time.RFC3339Nano),
+		Tools:          make([]domain.TurnTraceTool, 0, len(tools)),
+		Steps:          []domain.TurnTraceStep{},
+	}
+	for _, tool := range tools {
+		trace.Tools = append(trace.Tools, domain.TurnTraceTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		})
+	}
+	return &turnTraceRecorder{trace: trace}
+}
+
+func (r *turnTraceRecorder) withContext(ctx context.Context) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return runner.WithExchangeRecorder(ctx, r.recordExchange)
+}
+
+func (r *turnTraceRecorder) beginStep(step int, input []domain.AgentInputMessage) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trace.Steps = append(r.trace.Steps, domain.TurnTraceStep{Step: step, Input: cloneAgentInputMessages(input)})
+}
+
+func (r *turnTraceRecorder) recordExchange(exchange domain.ProviderExchange) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.trace.Steps) == 0 {
+		return
+	}
+	last := &r.trace.Steps[len(r.trace.Steps)-1]
+	last.Exchanges = append(last.Exchanges, exchange)
+}
+
+func (r *turnTraceRecorder) finishStep(turn runner.TurnResult, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.trace.Steps) == 0 {
+		return
+	}
+	last := &r.trace.Steps[len(r.trace.Steps)-1]
+	if err != nil {
+		last.Error = observability.RedactSecrets(err.Error())
+		return
+	}
+	last.Text = turn.Text
+	last.ToolCalls = turnTraceToolCalls(turn.ToolCalls)
+}
+
+func (r *turnTraceRecorder) finish(reply string, processErr *ProcessError) domain.TurnTrace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	trace := r.trace
+	trace.FinishedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	trace.Status = TurnTraceStatusSucceeded
+	trace.Reply = reply
+	if processErr != nil {
+		trace.Status = TurnTraceStatusFailed
+		trace.ErrorCode = processErr.Code
+		trace.Error = observability.RedactSecrets(processErr.Message)
+	}
+	return trace
+}
+
+func turnTraceToolCalls(calls []runner.ToolCall) []domain.TurnTraceToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]domain.TurnTraceToolCall, 0, len(calls))
+	for _, call := range calls {
+		out = append(out, domain.TurnTraceToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+	}
+	return out
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+
+	"nextai/apps/gateway/internal/domain"
 )
 
 const (
@@ -291,6 +293,46 @@ func ParsePromptModeFromBizParams(
 	return mode, true, nil
 }
 
+func ParseGenerationParamsFromBizParams(bizParams map[string]interface{}, key string) (*domain.GenerationParams, error) {
+	if len(bizParams) == 0 {
+		return nil, nil
+	}
+	raw, ok := bizParams[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	params, err := DecodeGenerationParams(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return params, nil
+}
+
+func DecodeGenerationParams(raw interface{}) (*domain.GenerationParams, error) {
+	switch value := raw.(type) {
+	case nil:
+		return nil, nil
+	case domain.GenerationParams:
+		return &value, nil
+	case *domain.GenerationParams:
+		return value, nil
+	case map[string]interface{}:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		decoder := json.NewDecoder(strings.NewReader(string(encoded)))
+		decoder.DisallowUnknownFields()
+		var params domain.GenerationParams
+		if err := decoder.Decode(&params); err != nil {
+			return nil, err
+		}
+		return &params, nil
+	default:
+		return nil, errors.New("must be an object")
+	}
+}
+
 func ResolvePromptModeFromChatMeta(
 	meta map[string]interface{},
 	metaKey string,
@@ -521,6 +563,9 @@ var baseToolDefinitionBuildSpecs = []toolDefinitionBuildSpec{
 	{
 		Name: "self_ops",
 	},
+	{
+		Name: "schedule_reminder",
+	},
 	{
 		Name:       "spawn_agent",
 		PromptMode: promptModeCodex,
@@ -0,0 +1,496 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+	"nextai/apps/gateway/internal/repo"
+	"nextai/apps/gateway/internal/service/ports"
+)
+
+const (
+	KeyPrefix = "nxk_"
+
+	keySecretBytes    = 24
+	keyIDBytes        = 6
+	keyDisplayLen     = len(KeyPrefix) + 8
+	keyLabelMaxRunes  = 80
+	usageFileName     = "api-key-usage.json"
+	usageDayLayout    = "2006-01-02"
+	usageMonthLayout  = "2006-01"
+	invalidAPIKeyCode = "invalid_api_key"
+)
+
+var (
+	ErrKeyNotFound   = errors.New("api_key_not_found")
+	ErrInvalidKey    = errors.New("api_key_invalid")
+	ErrKeyExpired    = errors.New("api_key_expired")
+	ErrQuotaExceeded = errors.New("api_key_quota_exceeded")
+	ErrScopeDenied   = errors.New("api_key_scope_denied")
+)
+
+type ValidationError struct {
+	Code    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e == nil {
+		return ""
+	}
+	return e.Message
+}
+
+type Caller struct {
+	Scopes []string
+	UserID string
+}
+
+type Dependencies struct {
+	Store   ports.StateStore
+	DataDir string
+	Logger  *slog.Logger
+}
+
+type Service struct {
+	deps Dependencies
+
+	usageMu     sync.Mutex
+	usage       map[string]domain.APIKeyUsage
+	usageLoaded bool
+}
+
+func NewService(deps Dependencies) *Service {
+	return &Service{deps: deps}
+}
+
+func (s *Service) log() *slog.Logger {
+	return observability.LoggerOrDefault(s.deps.Logger)
+}
+
+func (s *Service) Enabled() bool {
+	if s.validateStore() != nil {
+		return false
+	}
+	enabled := false
+	s.deps.Store.ReadAuth(func(st ports.AuthAggregate) {
+		enabled = len(st.APIKeys) > 0
+	})
+	return enabled
+}
+
+func (s *Service) List() ([]domain.APIKeyView, error) {
+	if err := s.validateStore(); err != nil {
+		return nil, err
+	}
+	specs := []domain.APIKeySpec{}
+	s.deps.Store.ReadAuth(func(st ports.AuthAggregate) {
+		for _, record := range st.APIKeys {
+			specs = append(specs, record.APIKeySpec)
+		}
+	})
+	sort.Slice(specs, func(i, j int) bool {
+		if specs[i].CreatedAt == specs[j].CreatedAt {
+			return specs[i].ID < specs[j].ID
+		}
+		return specs[i].CreatedAt < specs[j].CreatedAt
+	})
+	out := make([]domain.APIKeyView, 0, len(specs))
+	for _, spec := range specs {
+		out = append(out, s.view(spec))
+	}
+	return out, nil
+}
+
+func (s *Service) Get(id string) (domain.APIKeyView, error) {
+	if err := s.validateStore(); err != nil {
+		return domain.APIKeyView{}, err
+	}
+	var record repo.APIKeyRecord
+	found := false
+	s.deps.Store.ReadAuth(func(st ports.AuthAggregate) {
+		record, found = st.APIKeys[id]
+	})
+	if !found {
+		return domain.APIKeyView{}, ErrKeyNotFound
+	}
+	return s.view(record.APIKeySpec), nil
+}
+
+func (s *Service) Create(req domain.APIKeyCreateRequest, caller Caller, now time.Time) (domain.APIKeyCreateResponse, error) {
+	if err := s.validateStore(); err != nil {
+		return domain.APIKeyCreateResponse{}, err
+	}
+	spec := domain.APIKeySpec{
+		Label:     req.Label,
+		Scopes:    req.Scopes,
+		UserID:    req.UserID,
+		ExpiresAt: req.ExpiresAt,
+		Quota:     req.Quota,
+	}
+	if err := normalizeSpec(&spec, now, true); err != nil {
+		return domain.APIKeyCreateResponse{}, err
+	}
+	if err := authorizeCaller(caller, spec); err != nil {
+		return domain.APIKeyCreateResponse{}, err
+	}
+
+	secret, err := randomHex(keySecretBytes)
+	if err != nil {
+		return domain.APIKeyCreateResponse{}, err
+	}
+	keyID, err := randomHex(keyIDBytes)
+	if err != nil {
+		return domain.APIKeyCreateResponse{}, err
+	}
+	raw := KeyPrefix + secret
+	stamp := now.UTC().Format(time.RFC3339)
+	spec.ID = "key-" + keyID
+	spec.Prefix = raw[:keyDisplayLen]
+	spec.CreatedAt = stamp
+	spec.UpdatedAt = stamp
+	if err := s.deps.Store.WriteAuth(func(st *ports.AuthAggregate) error {
+		if st.APIKeys == nil {
+			st.APIKeys = map[string]repo.APIKeyRecord{}
+		}
+		st.APIKeys[spec.ID] = repo.APIKeyRecord{APIKeySpec: spec, KeyHash: hashKey(raw)}
+		return nil
+	}); err != nil {
+		return domain.APIKeyCreateResponse{}, err
+	}
+	return domain.APIKeyCreateResponse{APIKeyView: domain.APIKeyView{APIKeySpec: spec}, Key: raw}, nil
+}
+
+func (s *Service) Update(id string, req domain.APIKeyUpdateRequest, caller Caller, now time.Time) (domain.APIKeyView, error) {
+	if err := s.validateStore(); err != nil {
+		return domain.APIKeyView{}, err
+	}
+	var updated domain.APIKeySpec
+	if err := s.deps.Store.WriteAuth(func(st *ports.AuthAggregate) error {
+		record, ok := st.APIKeys[id]
+		if !ok {
+			return ErrKeyNotFound
+		}
+		if err := authorizeCaller(caller, record.APIKeySpec); err != nil {
+			return err
+		}
+		spec := record.APIKeySpec
+		if req.Label != nil {
+			spec.Label = *req.Label
+		}
+		if req.Scopes != nil {
+			spec.Scopes = req.Scopes
+		}
+		if req.UserID != nil {
+			spec.UserID = *req.UserID
+		}
+		if req.ExpiresAt != nil {
+			spec.ExpiresAt = req.ExpiresAt
+			if strings.TrimSpace(*req.ExpiresAt) == "" {
+				spec.ExpiresAt = nil
+			}
+		}
+		if req.Quota != nil {
+			spec.Quota = req.Quota
+		}
+		if req.Disabled != nil {
+			spec.Disabled = *req.Disabled
+		}
+		if err := normalizeSpec(&spec, now, req.ExpiresAt != nil); err != nil {
+			return err
+		}
+		if err := authorizeCaller(caller, spec); err != nil {
+			return err
+		}
+		spec.UpdatedAt = now.UTC().Format(time.RFC3339)
+		record.APIKeySpec = spec
+		st.APIKeys[id] = record
+		updated = spec
+		return nil
+	}); err != nil {
+		return domain.APIKeyView{}, err
+	}
+	return s.view(updated), nil
+}
+
+func (s *Service) Delete(id string, caller Caller) error {
+	if err := s.validateStore(); err != nil {
+		return err
+	}
+	if err := s.deps.Store.WriteAuth(func(st *ports.AuthAggregate) error {
+		record, ok := st.APIKeys[id]
+		if !ok {
+			return ErrKeyNotFound
+		}
+		if err := authorizeCaller(caller, record.APIKeySpec); err != nil {
+			return err
+		}
+		delete(st.APIKeys, id)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+	if s.loadUsageLocked() == nil {
+		if _, ok := s.usage[id]; ok {
+			delete(s.usage, id)
+			_ = s.saveUsageLocked()
+		}
+	}
+	return nil
+}
+
+func (s *Service) Authenticate(raw string, now time.Time) (domain.APIKeySpec, error) {
+	if err := s.validateStore(); err != nil {
+		return domain.APIKeySpec{}, err
+	}
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, KeyPrefix) {
+		return domain.APIKeySpec{}, ErrInvalidKey
+	}
+	hash := []byte(hashKey(raw))
+	var spec domain.APIKeySpec
+	found := false
+	s.deps.Store.ReadAuth(func(st ports.AuthAggregate) {
+		for _, record := range st.APIKeys {
+			if subtle.ConstantTimeCompare(hash, []byte(record.KeyHash)) == 1 {
+				spec = record.APIKeySpec
+				found = true
+				return
+			}
+		}
+	})
+	if !found || spec.Disabled {
+		return domain.APIKeySpec{}, ErrInvalidKey
+	}
+	if spec.ExpiresAt != nil {
+		expiresAt, err := time.Parse(time.RFC3339, *spec.ExpiresAt)
+		if err != nil || !now.Before(expiresAt) {
+			return domain.APIKeySpec{}, ErrKeyExpired
+		}
+	}
+	if err := s.recordUsage(spec, now); err != nil {
+		return domain.APIKeySpec{}, err
+	}
+	return spec, nil
+}
+
+func (s *Service) recordUsage(spec domain.APIKeySpec, now time.Time) error {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+	if err := s.loadUsageLocked(); err != nil {
+		s.log().Warn("api key usage load failed, starting from empty counters", "err", err)
+		s.usage = map[string]domain.APIKeyUsage{}
+		s.usageLoaded = true
+	}
+	usage := rollUsage(s.usage[spec.ID], now)
+	if spec.Quota != nil {
+		if spec.Quota.DailyRequests > 0 && usage.DayRequests >= spec.Quota.DailyRequests {
+			return fmt.Errorf("%w: daily limit %d reached", ErrQuotaExceeded, spec.Quota.DailyRequests)
+		}
+		if spec.Quota.MonthlyRequests > 0 && usage.MonthRequests >= spec.Quota.MonthlyRequests {
+			return fmt.Errorf("%w: monthly limit %d reached", ErrQuotaExceeded, spec.Quota.MonthlyRequests)
+		}
+	}
+	usage.DayRequests++
+	usage.MonthRequests++
+	usage.TotalRequests++
+	stamp := now.UTC().Format(time.RFC3339)
+	usage.LastUsedAt = &stamp
+	s.usage[spec.ID] = usage
+	if err := s.saveUsageLocked(); err != nil {
+		s.log().Error("api key usage persist failed", "key_id", spec.ID, "err", err)
+	}
+	return nil
+}
+
+func (s *Service) view(spec domain.APIKeySpec) domain.APIKeyView {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+	usage := domain.APIKeyUsage{}
+	if err := s.loadUsageLocked(); err == nil {
+		usage = rollUsage(s.usage[spec.ID], time.Now())
+	}
+	return domain.APIKeyView{APIKeySpec: spec, Usage: usage}
+}
+
+func rollUsage(usage domain.APIKeyUsage, now time.Time) domain.APIKeyUsage {
+	day := now.UTC().Format(usageDayLayout)
+	month := now.UTC().Format(usageMonthLayout)
+	if usage.Day != day {
+		usage.Day = day
+		usage.DayRequests = 0
+	}
+	if usage.Month != month {
+		usage.Month = month
+		usage.MonthRequests = 0
+	}
+	return usage
+}
+
+func (s *Service) loadUsageLocked() error {
+	if s.usageLoaded {
+		return nil
+	}
+	s.usage = map[string]domain.APIKeyUsage{}
+	if s.deps.DataDir == "" {
+		s.usageLoaded = true
+		return nil
+	}
+	body, err := os.ReadFile(s.usagePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.usageLoaded = true
+			return nil
+		}
+		return err
+	}
+	if err := json.Unmarshal(body, &s.usage); err != nil {
+		return fmt.Errorf("decode api key usage: %w", err)
+	}
+	if s.usage == nil {
+		s.usage = map[string]domain.APIKeyUsage{}
+	}
+	s.usageLoaded = true
+	return nil
+}
+
+func (s *Service) saveUsageLocked() error {
+	if s.deps.DataDir == "" {
+		return nil
+	}
+	body, err := json.MarshalIndent(s.usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.usagePath() + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.usagePath())
+}
+
+func (s *Service) usagePath() string {
+	return filepath.Join(s.deps.DataDir, usageFileName)
+}
+
+func normalizeSpec(spec *domain.APIKeySpec, now time.Time, checkExpiry bool) error {
+	spec.Label = strings.TrimSpace(spec.Label)
+	if spec.Label == "" {
+		return &ValidationError{Code: invalidAPIKeyCode, Message: "label is required"}
+	}
+	if len([]rune(spec.Label)) > keyLabelMaxRunes {
+		return &ValidationError{Code: invalidAPIKeyCode, Message: fmt.Sprintf("label must be at most %d characters", keyLabelMaxRunes)}
+	}
+	scopes, err := normalizeScopes(spec.Scopes)
+	if err != nil {
+		return &ValidationError{Code: invalidAPIKeyCode, Message: err.Error()}
+	}
+	spec.Scopes = scopes
+	spec.UserID = strings.TrimSpace(spec.UserID)
+	if spec.ExpiresAt != nil {
+		expiresAt, err := time.Parse(time.RFC3339, strings.TrimSpace(*spec.ExpiresAt))
+		if err != nil {
+			return &ValidationError{Code: invalidAPIKeyCode, Message: "expires_at must be RFC3339"}
+		}
+		if checkExpiry && !expiresAt.After(now) {
+			return &ValidationError{Code: invalidAPIKeyCode, Message: "expires_at must be in the future"}
+		}
+		normalized := expiresAt.UTC().Format(time.RFC3339)
+		spec.ExpiresAt = &normalized
+	}
+	if spec.Quota != nil {
+		if spec.Quota.DailyRequests < 0 || spec.Quota.MonthlyRequests < 0 {
+			return &ValidationError{Code: invalidAPIKeyCode, Message: "quota values must be >= 0"}
+		}
+		if spec.Quota.DailyRequests == 0 && spec.Quota.MonthlyRequests == 0 {
+			spec.Quota = nil
+		} else {
+			quota := *spec.Quota
+			spec.Quota = &quota
+		}
+	}
+	return nil
+}
+
+func normalizeScopes(in []string) ([]string, error) {
+	requested := map[string]struct{}{}
+	for _, raw := range in {
+		scope := strings.ToLower(strings.TrimSpace(raw))
+		if !knownScope(scope) {
+			return nil, fmt.Errorf("unsupported scope=%q", raw)
+		}
+		requested[scope] = struct{}{}
+	}
+	if len(requested) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	out := make([]string, 0, len(requested))
+	for _, scope := range domain.APIKeyScopes {
+		if _, ok := requested[scope]; ok {
+			out = append(out, scope)
+		}
+	}
+	return out, nil
+}
+
+func knownScope(scope string) bool {
+	for _, item := range domain.APIKeyScopes {
+		if item == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func authorizeCaller(caller Caller, spec domain.APIKeySpec) error {
+	held := map[string]struct{}{}
+	for _, scope := range caller.Scopes {
+		held[scope] = struct{}{}
+	}
+	for _, scope := range spec.Scopes {
+		if _, ok := held[scope]; !ok {
+			return fmt.Errorf("%w: caller does not hold scope %q", ErrScopeDenied, scope)
+		}
+	}
+	if caller.UserID != "" && spec.UserID != caller.UserID {
+		return fmt.Errorf("%w: caller is bound to user_id %q", ErrScopeDenied, caller.UserID)
+	}
+	return nil
+}
+
+func hashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (s *Service) validateStore() error {
+	if s == nil || s.deps.Store == nil {
+		return errors.New("state store is unavailable")
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package apikeys
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/repo"
+	"nextai/apps/gateway/internal/service/adapters"
+)
+
+func TestCreateStoresHashAndAuthenticatesWithinQuota(t *testing.T) {
+	t.Parallel()
+
+	svc, store := newTestService(t)
+	now := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	created, err := svc.Create(domain.APIKeyCreateRequest{
+		Label:  "ci",
+		Scopes: []string{"cron", "chat", "chat"},
+		UserID: "u-1",
+		Quota:  &domain.APIKeyQuota{DailyRequests: 2},
+	}, Caller{Scopes: domain.APIKeyScopes}, now)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, KeyPrefix) || !strings.HasPrefix(created.Key, created.Prefix) {
+		t.Fatalf("unexpected key=%q prefix=%q", created.Key, created.Prefix)
+	}
+	if got := strings.Join(created.Scopes, ","); got != "chat,cron" {
+		t.Fatalf("scopes=%s", got)
+	}
+	store.Read(func(state *repo.State) {
+		record := state.APIKeys[created.ID]
+		if record.KeyHash == "" || record.KeyHash == created.Key {
+			t.Fatalf("expected hashed key, got=%q", record.KeyHash)
+		}
+	})
+	if !svc.Enabled() {
+		t.Fatal("expected registry to be enabled")
+	}
+
+	for i := 0; i < 2; i++ {
+		spec, err := svc.Authenticate(created.Key, now)
+		if err != nil {
+			t.Fatalf("authenticate #%d failed: %v", i, err)
+		}
+		if spec.UserID != "u-1" {
+			t.Fatalf("user_id=%s", spec.UserID)
+		}
+	}
+	if _, err := svc.Authenticate(created.Key, now); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected quota exceeded, got=%v", err)
+	}
+	if _, err := svc.Authenticate(created.Key, now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("expected daily counter reset, got=%v", err)
+	}
+	if _, err := svc.Authenticate(KeyPrefix+"unknown", now); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected invalid key, got=%v", err)
+	}
+
+	reloaded := NewService(Dependencies{Store: svc.deps.Store, DataDir: svc.deps.DataDir})
+	view, err := reloaded.Get(created.ID)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if view.Usage.TotalRequests != 3 {
+		t.Fatalf("expected persisted usage, got=%+v", view.Usage)
+	}
+}
+
+func TestAuthenticateRejectsExpiredAndDisabledKeys(t *testing.T) {
+	t.Parallel()
+
+	svc, _ := newTestService(t)
+	now := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(time.Hour).Format(time.RFC3339)
+	created, err := svc.Create(domain.APIKeyCreateRequest{
+		Label:     "temp",
+		Scopes:    []string{"chat"},
+		ExpiresAt: &expiresAt,
+	}, Caller{Scopes: domain.APIKeyScopes}, now)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := svc.Authenticate(created.Key, now.Add(2*time.Hour)); !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("expected expired, got=%v", err)
+	}
+
+	disabled := true
+	if _, err := svc.Update(created.ID, domain.APIKeyUpdateRequest{Disabled: &disabled}, Caller{Scopes: domain.APIKeyScopes}, now); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if _, err := svc.Authenticate(created.Key, now); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected disabled key to be invalid, got=%v", err)
+	}
+}
+
+func TestCreateRejectsScopesTheCallerDoesNotHold(t *testing.T) {
+	t.Parallel()
+
+	svc, _ := newTestService(t)
+	now := time.Now().UTC()
+	caller := Caller{Scopes: []string{"admin:write", "chat"}, UserID: "u-1"}
+
+	_, err := svc.Create(domain.APIKeyCreateRequest{Label: "x", Scopes: []string{"chat", "tools:execute"}, UserID: "u-1"}, caller, now)
+	if !errors.Is(err, ErrScopeDenied) {
+		t.Fatalf("expected scope denied, got=%v", err)
+	}
+	_, err = svc.Create(domain.APIKeyCreateRequest{Label: "x", Scopes: []string{"chat"}, UserID: "u-2"}, caller, now)
+	if !errors.Is(err, ErrScopeDenied) {
+		t.Fatalf("expected user binding denied, got=%v", err)
+	}
+	_, err = svc.Create(domain.APIKeyCreateRequest{Label: "x", Scopes: []string{"root"}}, Caller{Scopes: domain.APIKeyScopes}, now)
+	validation := (*ValidationError)(nil)
+	if !errors.As(err, &validation) || validation.Code != "invalid_api_key" {
+		t.Fatalf("expected invalid_api_key, got=%v", err)
+	}
+}
+
+func newTestService(t *testing.T) (*Service, *repo.Store) {
+	t.Helper()
+
+	dir := t.TempDir()
+	store, err := repo.NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store failed: %v", err)
+	}
+	return NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+	}), store
+}
@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+	"nextai/apps/gateway/internal/secrets"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+
+	maxChangeValueLen = 256
+	maxChanges        = 200
+)
+
+// Diff compares two snapshots leaf by leaf. Nested objects are flattened into
+// dotted paths, arrays are compared as whole values, and values under
+// sensitive keys are replaced with secrets.Redacted.
+func Diff(before, after map[string]interface{}) []domain.AuditChange {
+	flatBefore := map[string]interface{}{}
+	flatAfter := map[string]interface{}{}
+	flatten("", before, flatBefore)
+	flatten("", after, flatAfter)
+
+	paths := make([]string, 0, len(flatBefore)+len(flatAfter))
+	for path := range flatBefore {
+		paths = append(paths, path)
+	}
+	for path := range flatAfter {
+		if _, ok := flatBefore[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	changes := []domain.AuditChange{}
+	for _, path := range paths {
+		oldValue, hadOld := flatBefore[path]
+		newValue, hasNew := flatAfter[path]
+		change := domain.AuditChange{Path: path}
+		switch {
+		case hadOld && !hasNew:
+			change.Op = ChangeRemoved
+			change.Before = displayValue(path, oldValue)
+		case !hadOld && hasNew:
+			change.Op = ChangeAdded
+			change.After = displayValue(path, newValue)
+		case !reflect.DeepEqual(oldValue, newValue):
+			change.Op = ChangeChanged
+			change.Before = displayValue(path, oldValue)
+			change.After = displayValue(path, newValue)
+		default:
+			continue
+		}
+		if len(changes) == maxChanges {
+			changes = append(changes, domain.AuditChange{Path: "...", Op: "truncated"})
+			break
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func flatten(prefix string, value interface{}, out map[string]interface{}) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		if prefix != "" {
+			out[prefix] = value
+		}
+		return
+	}
+	if len(object) == 0 && prefix != "" {
+		out[prefix] = object
+		return
+	}
+	for key, item := range object {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		flatten(path, item, out)
+	}
+}
+
+func displayValue(path string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if isSensitivePath(path) {
+		return secrets.Redacted
+	}
+	switch typed := value.(type) {
+	case string:
+		return truncate(observability.RedactSecrets(typed))
+	case bool, float64:
+		return typed
+	default:
+		body, err := json.Marshal(typed)
+		if err != nil {
+			return nil
+		}
+		return truncate(observability.RedactSecrets(string(body)))
+	}
+}
+
+func isSensitivePath(path string) bool {
+	segments := strings.Split(path, ".")
+	last := segments[len(segments)-1]
+	switch strings.ToLower(last) {
+	case "secret", "password", "token", "key_hash":
+		return true
+	}
+	if len(segments) >= 2 && strings.EqualFold(segments[len(segments)-2], "headers") {
+		return secrets.IsSensitiveHeaderName(last)
+	}
+	return secrets.IsSensitiveFieldName(last)
+}
+
+func truncate(text string) string {
+	if len(text) <= maxChangeValueLen {
+		return text
+	}
+	cut := maxChangeValueLen
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
+}
@@ -0,0 +1,228 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+)
+
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+
+	logFileName = "audit.jsonl"
+)
+
+type Dependencies struct {
+	DataDir string
+	Now     func() time.Time
+	Logger  *slog.Logger
+}
+
+// Service appends audit entries to <data_dir>/audit.jsonl. The file is only ever
+// appended to; queries and exports scan it in order.
+type Service struct {
+	deps Dependencies
+	mu   sync.Mutex
+}
+
+type Filter struct {
+	ActorType string
+	KeyID     string
+	UserID    string
+	SessionID string
+	Action    string
+	// Target matches entries whose target starts with the given prefix.
+	Target string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+func NewService(deps Dependencies) *Service {
+	if deps.Now == nil {
+		deps.Now = time.Now
+	}
+	return &Service{deps: deps}
+}
+
+func (s *Service) path() string {
+	return filepath.Join(s.deps.DataDir, logFileName)
+}
+
+// Record appends entry and logs failures; auditing must not fail the mutation
+// it describes.
+func (s *Service) Record(entry domain.AuditEntry) {
+	if _, err := s.Append(entry); err != nil {
+		observability.LoggerOrDefault(s.deps.Logger).Error("audit append failed", "action", entry.Action, "target", entry.Target, "err", err)
+	}
+}
+
+func (s *Service) Append(entry domain.AuditEntry) (domain.AuditEntry, error) {
+	if entry.ID == "" {
+		id, err := newEntryID()
+		if err != nil {
+			return domain.AuditEntry{}, err
+		}
+		entry.ID = id
+	}
+	if entry.Time == "" {
+		entry.Time = s.deps.Now().UTC().Format(time.RFC3339Nano)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return domain.AuditEntry{}, err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.deps.DataDir, 0o755); err != nil {
+		return domain.AuditEntry{}, err
+	}
+	file, err := os.OpenFile(s.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return domain.AuditEntry{}, err
+	}
+	if _, err := file.Write(line); err != nil {
+		_ = file.Close()
+		return domain.AuditEntry{}, err
+	}
+	return entry, file.Close()
+}
+
+// Query returns the newest matching entries first, capped at filter.Limit.
+func (s *Service) Query(filter Filter) ([]domain.AuditEntry, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+	out := []domain.AuditEntry{}
+	err := s.scan(filter, func(entry domain.AuditEntry, _ []byte) error {
+		out = append(out, entry)
+		if len(out) > limit {
+			out = out[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// Export writes every matching entry as JSONL in the order it was recorded.
+func (s *Service) Export(w io.Writer, filter Filter) error {
+	return s.scan(filter, func(_ domain.AuditEntry, line []byte) error {
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		_, err := w.Write([]byte{'\n'})
+		return err
+	})
+}
+
+func (s *Service) scan(filter Filter, fn func(entry domain.AuditEntry, line []byte) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.Open(s.path())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry domain.AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		if !filter.matches(entry) {
+			continue
+		}
+		if err := fn(entry, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (f Filter) matches(entry domain.AuditEntry) bool {
+	if f.ActorType != "" && entry.Actor.Type != f.ActorType {
+		return false
+	}
+	if f.KeyID != "" && entry.Actor.KeyID != f.KeyID {
+		return false
+	}
+	if f.UserID != "" && entry.Actor.UserID != f.UserID {
+		return false
+	}
+	if f.SessionID != "" && entry.Actor.SessionID != f.SessionID {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.Target != "" && !strings.HasPrefix(entry.Target, f.Target) {
+		return false
+	}
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		at, err := time.Parse(time.RFC3339Nano, entry.Time)
+		if err != nil {
+			return false
+		}
+		if !f.Since.IsZero() && at.Before(f.Since) {
+			return false
+		}
+		if !f.Until.IsZero() && !at.Before(f.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a stable digest of a snapshot; encoding/json sorts map keys.
+func Hash(snapshot map[string]interface{}) string {
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func newEntryID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "aud_" + hex.EncodeToString(buf), nil
+}
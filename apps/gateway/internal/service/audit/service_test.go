@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/secrets"
+)
+
+func TestAppendQueryAndExport(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	svc := NewService(Dependencies{DataDir: t.TempDir(), Now: func() time.Time { return now }})
+	for i, action := range []string{"put-envs", "configure-provider", "put-envs"} {
+		now = now.Add(time.Minute)
+		actor := domain.AuditActor{Type: domain.AuditActorAPIKey, KeyID: "key-a"}
+		if i == 1 {
+			actor = domain.AuditActor{Type: domain.AuditActorAgent, SessionID: "s-1"}
+		}
+		entry, err := svc.Append(domain.AuditEntry{Actor: actor, Action: action, Target: "/envs"})
+		if err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+		if !strings.HasPrefix(entry.ID, "aud_") || entry.Time == "" {
+			t.Fatalf("expected id and time assigned, got=%+v", entry)
+		}
+	}
+
+	items, err := svc.Query(Filter{Action: "put-envs"})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(items) != 2 || items[0].Time <= items[1].Time {
+		t.Fatalf("expected two entries newest first, got=%+v", items)
+	}
+	items, _ = svc.Query(Filter{SessionID: "s-1"})
+	if len(items) != 1 || items[0].Action != "configure-provider" {
+		t.Fatalf("session filter mismatch: %+v", items)
+	}
+	items, _ = svc.Query(Filter{Limit: 1})
+	if len(items) != 1 || items[0].Time != "2026-05-01T08:03:00Z" {
+		t.Fatalf("limit should keep newest entry, got=%+v", items)
+	}
+	items, _ = svc.Query(Filter{Since: time.Date(2026, 5, 1, 8, 2, 0, 0, time.UTC)})
+	if len(items) != 2 {
+		t.Fatalf("since filter mismatch: %+v", items)
+	}
+
+	var out bytes.Buffer
+	if err := svc.Export(&out, Filter{KeyID: "key-a"}); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "08:01:00") {
+		t.Fatalf("unexpected export: %s", out.String())
+	}
+}
+
+func TestDiffRedactsSensitiveValues(t *testing.T) {
+	t.Parallel()
+
+	before := map[string]interface{}{
+		"providers": map[string]interface{}{
+			"openai": map[string]interface{}{
+				"api_key":  "sk-old-value-123456",
+				"base_url": "https://a.example",
+				"headers":  map[string]interface{}{"X-Api-Key": "h1", "X-Org": "o1"},
+			},
+		},
+		"envs": map[string]interface{}{"REGION": "eu", "GONE": "x"},
+	}
+	after := map[string]interface{}{
+		"providers": map[string]interface{}{
+			"openai": map[string]interface{}{
+				"api_key":  "sk-new-value-123456",
+				"base_url": "https://b.example",
+				"headers":  map[string]interface{}{"X-Api-Key": "h2", "X-Org": "o2"},
+			},
+		},
+		"envs": map[string]interface{}{"REGION": "eu", "SEARCH_API_TOKEN": "tok"},
+	}
+	if Hash(before) == Hash(after) || Hash(before) != Hash(before) {
+		t.Fatal("hash must be stable and change with content")
+	}
+	changes := Diff(before, after)
+	byPath := map[string]domain.AuditChange{}
+	for _, change := range changes {
+		byPath[change.Path] = change
+	}
+	if len(changes) != 6 {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	for _, path := range []string{"providers.openai.api_key", "providers.openai.headers.X-Api-Key"} {
+		if change := byPath[path]; change.Op != ChangeChanged || change.Before != secrets.Redacted || change.After != secrets.Redacted {
+			t.Fatalf("expected redacted change at %s, got=%+v", path, change)
+		}
+	}
+	if change := byPath["providers.openai.headers.X-Org"]; change.Before != "o1" || change.After != "o2" {
+		t.Fatalf("expected plain header change, got=%+v", change)
+	}
+	if change := byPath["envs.SEARCH_API_TOKEN"]; change.Op != ChangeAdded || change.After != secrets.Redacted {
+		t.Fatalf("expected redacted added env, got=%+v", change)
+	}
+	if change := byPath["envs.GONE"]; change.Op != ChangeRemoved || change.Before != "x" {
+		t.Fatalf("expected removed env, got=%+v", change)
+	}
+}
@@ -0,0 +1,232 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cronv3 "github.com/robfig/cron/v3"
+
+	"nextai/apps/gateway/internal/domain"
+)
+
+const (
+	scheduleTypeInterval = "interval"
+	scheduleTypeCron     = "cron"
+	scheduleTypeOnce     = "once"
+
+	calendarMaxSteps = 1024
+)
+
+var calendarWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+type blackoutWindow struct {
+	start    int
+	end      int
+	weekdays map[time.Weekday]struct{}
+}
+
+type scheduleCalendar struct {
+	loc           *time.Location
+	windows       []blackoutWindow
+	dates         map[string]struct{}
+	annualDates   map[string]struct{}
+	hasExclusions bool
+}
+
+func scheduleLocation(schedule domain.CronScheduleSpec) (*time.Location, error) {
+	tz := strings.TrimSpace(schedule.Timezone)
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule.timezone=%q", schedule.Timezone)
+	}
+	return loc, nil
+}
+
+func newScheduleCalendar(schedule domain.CronScheduleSpec) (*scheduleCalendar, error) {
+	loc, err := scheduleLocation(schedule)
+	if err != nil {
+		return nil, err
+	}
+	calendar := &scheduleCalendar{
+		loc:         loc,
+		dates:       map[string]struct{}{},
+		annualDates: map[string]struct{}{},
+	}
+	for idx, raw := range schedule.Blackouts {
+		window, err := parseBlackoutWindow(raw)
+		if err != nil {
+			return nil, fmt.Errorf("schedule.blackouts[%d]: %w", idx, err)
+		}
+		calendar.windows = append(calendar.windows, window)
+	}
+	for _, raw := range schedule.Holidays {
+		day := strings.TrimSpace(raw)
+		if _, err := time.Parse("2006-01-02", day); err == nil {
+			calendar.dates[day] = struct{}{}
+			continue
+		}
+		if _, err := time.Parse("01-02", day); err == nil {
+			calendar.annualDates[day] = struct{}{}
+			continue
+		}
+		return nil, fmt.Errorf("invalid schedule.holidays entry %q (want YYYY-MM-DD or MM-DD)", raw)
+	}
+	calendar.hasExclusions = len(calendar.windows) > 0 || len(calendar.dates) > 0 || len(calendar.annualDates) > 0
+	return calendar, nil
+}
+
+func parseBlackoutWindow(raw domain.CronBlackoutWindow) (blackoutWindow, error) {
+	start, err := parseClockMinutes(raw.Start)
+	if err != nil {
+		return blackoutWindow{}, fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := parseClockMinutes(raw.End)
+	if err != nil {
+		return blackoutWindow{}, fmt.Errorf("invalid end: %w", err)
+	}
+	if start == end {
+		return blackoutWindow{}, errors.New("start and end must differ")
+	}
+	window := blackoutWindow{start: start, end: end}
+	if len(raw.Weekdays) > 0 {
+		window.weekdays = map[time.Weekday]struct{}{}
+		for _, name := range raw.Weekdays {
+			day, ok := calendarWeekdays[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				return blackoutWindow{}, fmt.Errorf("unsupported weekday %q", name)
+			}
+			window.weekdays[day] = struct{}{}
+		}
+	}
+	return window, nil
+}
+
+func parseClockMinutes(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "24:00" {
+		return 24 * 60, nil
+	}
+	parsed, err := time.Parse("15:04", raw)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", raw)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+func (w blackoutWindow) appliesOn(day time.Weekday) bool {
+	if len(w.weekdays) == 0 {
+		return true
+	}
+	_, ok := w.weekdays[day]
+	return ok
+}
+
+func (w blackoutWindow) blockedUntil(local time.Time) (time.Time, bool) {
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	minute := local.Hour()*60 + local.Minute()
+	if w.start < w.end {
+		if minute >= w.start && minute < w.end && w.appliesOn(local.Weekday()) {
+			return midnight.Add(time.Duration(w.end) * time.Minute), true
+		}
+		return time.Time{}, false
+	}
+	if minute >= w.start && w.appliesOn(local.Weekday()) {
+		return midnight.AddDate(0, 0, 1).Add(time.Duration(w.end) * time.Minute), true
+	}
+	if minute < w.end && w.appliesOn(midnight.AddDate(0, 0, -1).Weekday()) {
+		return midnight.Add(time.Duration(w.end) * time.Minute), true
+	}
+	return time.Time{}, false
+}
+
+func (c *scheduleCalendar) isHoliday(local time.Time) bool {
+	if _, ok := c.dates[local.Format("2006-01-02")]; ok {
+		return true
+	}
+	_, ok := c.annualDates[local.Format("01-02")]
+	return ok
+}
+
+func (c *scheduleCalendar) blockedUntil(t time.Time) (time.Time, bool) {
+	local := t.In(c.loc)
+	if c.isHoliday(local) {
+		return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, c.loc), true
+	}
+	for _, window := range c.windows {
+		if until, blocked := window.blockedUntil(local); blocked {
+			return until, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (c *scheduleCalendar) nextAllowed(t time.Time) (time.Time, error) {
+	if c == nil || !c.hasExclusions {
+		return t, nil
+	}
+	for i := 0; i < calendarMaxSteps; i++ {
+		until, blocked := c.blockedUntil(t)
+		if !blocked {
+			return t.UTC(), nil
+		}
+		t = until
+	}
+	return time.Time{}, errors.New("schedule blackouts and holidays leave no runnable time")
+}
+
+func (c *scheduleCalendar) nextAllowedOccurrence(schedule cronv3.Schedule, t time.Time) (time.Time, error) {
+	if c == nil || !c.hasExclusions {
+		return t, nil
+	}
+	for i := 0; i < calendarMaxSteps; i++ {
+		allowed, err := c.nextAllowed(t)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if allowed.Equal(t) {
+			return t.UTC(), nil
+		}
+		t = schedule.Next(allowed.In(c.loc).Add(-time.Second))
+		if t.IsZero() {
+			break
+		}
+	}
+	return time.Time{}, errors.New("schedule blackouts and holidays leave no runnable time")
+}
+
+func parseOnceRunAt(schedule domain.CronScheduleSpec) (time.Time, error) {
+	raw := strings.TrimSpace(schedule.RunAt)
+	if raw == "" {
+		return time.Time{}, errors.New("schedule.run_at is required for once jobs")
+	}
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid schedule.run_at=%q (want RFC3339)", raw)
+	}
+	return at.UTC(), nil
+}
+
+func validateScheduleSpec(schedule *domain.CronScheduleSpec) error {
+	schedule.Type = strings.ToLower(strings.TrimSpace(schedule.Type))
+	schedule.RunAt = strings.TrimSpace(schedule.RunAt)
+	if schedule.Type == scheduleTypeOnce {
+		if _, err := parseOnceRunAt(*schedule); err != nil {
+			return err
+		}
+	}
+	_, err := newScheduleCalendar(*schedule)
+	return err
+}
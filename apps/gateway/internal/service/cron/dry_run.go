@@ -0,0 +1,240 @@
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/service/ports"
+)
+
+const (
+	PreviewDefaultCount = 5
+	PreviewMaxCount     = 50
+
+	dryRunDefaultHTTPStatus = "200"
+)
+
+var dryRunTriggerTypes = map[string]struct{}{
+	TriggerSchedule:    {},
+	TriggerManual:      {},
+	TriggerMessage:     {},
+	TriggerSystemEvent: {},
+	TriggerWebhook:     {},
+}
+
+func (s *Service) PreviewSchedule(jobID string, count int, now time.Time) (domain.CronSchedulePreview, error) {
+	if err := s.validateStore(); err != nil {
+		return domain.CronSchedulePreview{}, err
+	}
+	var job domain.CronJobSpec
+	var state domain.CronJobState
+	found := false
+	s.deps.Store.ReadCron(func(st ports.CronAggregate) {
+		job, found = st.Jobs[jobID]
+		state = st.States[jobID]
+	})
+	if !found {
+		return domain.CronSchedulePreview{}, ErrJobNotFound
+	}
+	if count <= 0 {
+		count = PreviewDefaultCount
+	}
+	if count > PreviewMaxCount {
+		count = PreviewMaxCount
+	}
+	loc, err := scheduleLocation(job.Schedule)
+	if err != nil {
+		return domain.CronSchedulePreview{}, &ValidationError{Code: "invalid_cron_schedule", Message: err.Error()}
+	}
+
+	preview := domain.CronSchedulePreview{JobID: jobID, Timezone: loc.String(), NextRuns: []string{}}
+	var current *string
+	if state.NextRunAt != nil {
+		if scheduled, err := time.Parse(time.RFC3339, *state.NextRunAt); err == nil && scheduled.After(now) {
+			current = state.NextRunAt
+		}
+	}
+	cursor := now
+	for len(preview.NextRuns) < count {
+		next, _, err := ResolveNextRunAt(job, current, cursor)
+		if err != nil {
+			return domain.CronSchedulePreview{}, &ValidationError{Code: "invalid_cron_schedule", Message: err.Error()}
+		}
+		if next.IsZero() || (current != nil && !next.After(cursor)) {
+			break
+		}
+		preview.NextRuns = append(preview.NextRuns, next.In(loc).Format(time.RFC3339))
+		nextText := next.UTC().Format(time.RFC3339)
+		current = &nextText
+		cursor = next
+	}
+	return preview, nil
+}
+
+type dryRunContextKey struct{}
+
+type dryRunStubs struct {
+	outputs map[string]string
+}
+
+func withDryRun(ctx context.Context, outputs map[string]string) context.Context {
+	return context.WithValue(ctx, dryRunContextKey{}, &dryRunStubs{outputs: outputs})
+}
+
+func dryRunFromContext(ctx context.Context) *dryRunStubs {
+	stubs, _ := ctx.Value(dryRunContextKey{}).(*dryRunStubs)
+	return stubs
+}
+
+func (s *Service) DryRunJob(jobID string, req domain.CronDryRunRequest) (*domain.CronWorkflowExecution, error) {
+	if err := s.validateStore(); err != nil {
+		return nil, err
+	}
+	var job domain.CronJobSpec
+	found := false
+	s.deps.Store.ReadCron(func(st ports.CronAggregate) {
+		job, found = st.Jobs[jobID]
+	})
+	if !found {
+		return nil, ErrJobNotFound
+	}
+	if taskType(job) != taskTypeWorkflow {
+		return nil, &ValidationError{Code: "cron_dry_run_unsupported", Message: "dry-run is only supported for task_type=workflow"}
+	}
+	event, err := dryRunTriggerEvent(job, req.Trigger)
+	if err != nil {
+		return nil, &ValidationError{Code: "invalid_cron_dry_run", Message: err.Error()}
+	}
+	job = event.replyTarget(job)
+
+	runtime := runtimeSpec(job)
+	ctx, cancel := context.WithTimeout(
+		withDryRun(withTriggerEvent(context.Background(), event), req.Outputs),
+		time.Duration(runtime.TimeoutSeconds)*time.Second,
+	)
+	defer cancel()
+	execution, err := s.executeWorkflowTask(ctx, job)
+	if execution == nil {
+		return nil, &ValidationError{Code: "invalid_cron_workflow", Message: err.Error()}
+	}
+	execution.DryRun = true
+	return execution, nil
+}
+
+func dryRunTriggerEvent(job domain.CronJobSpec, trigger *domain.CronDryRunTrigger) (TriggerEvent, error) {
+	if trigger == nil {
+		return TriggerEvent{Type: TriggerManual}, nil
+	}
+	event := TriggerEvent{
+		Type:      strings.ToLower(strings.TrimSpace(trigger.Type)),
+		Event:     strings.ToLower(strings.TrimSpace(trigger.Event)),
+		Source:    strings.TrimSpace(trigger.Source),
+		Channel:   strings.ToLower(strings.TrimSpace(trigger.Channel)),
+		UserID:    strings.TrimSpace(trigger.UserID),
+		SessionID: strings.TrimSpace(trigger.SessionID),
+		Text:      trigger.Text,
+		Error:     trigger.Error,
+		Body:      trigger.Body,
+	}
+	if event.Type == "" {
+		event.Type = TriggerManual
+	}
+	if _, ok := dryRunTriggerTypes[event.Type]; !ok {
+		return TriggerEvent{}, fmt.Errorf("unsupported trigger.type=%q", trigger.Type)
+	}
+	if event.Type == TriggerMessage {
+		for _, spec := range job.Triggers {
+			if matched, ok := matchTrigger(spec, event); ok {
+				return matched, nil
+			}
+		}
+	}
+	return event, nil
+}
+
+func workflowNodeSideEffectFree(nodeType string) bool {
+	switch normalizeWorkflowNodeType(nodeType) {
+	case workflowNodeStart, workflowNodeIf, workflowNodeJoin:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *dryRunStubs) execute(ctx context.Context, job domain.CronJobSpec, node domain.CronWorkflowNode) (workflowNodeRunResult, error) {
+	preview, err := dryRunNodePreview(job, node, WorkflowVars(ctx))
+	if err != nil {
+		return workflowNodeRunResult{Stubbed: true}, err
+	}
+	result := workflowNodeRunResult{Stubbed: true, Preview: truncateWorkflowOutput(preview)}
+	if node.OutputVar == "" {
+		return result, nil
+	}
+	output := d.outputs[node.ID]
+	result.Output = &output
+	if normalizeWorkflowNodeType(node.Type) == workflowNodeHTTP {
+		status, ok := d.outputs[node.ID+workflowHTTPStatusVarSuffix]
+		if !ok {
+			status = dryRunDefaultHTTPStatus
+		}
+		result.Vars = map[string]string{"status": status}
+	}
+	return result, nil
+}
+
+func dryRunNodePreview(job domain.CronJobSpec, node domain.CronWorkflowNode, vars map[string]string) (string, error) {
+	switch normalizeWorkflowNodeType(node.Type) {
+	case workflowNodeText:
+		text, err := renderWorkflowTemplate(node.Text, vars)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("send to channel %s: %s", strings.ToLower(resolveDispatchChannel(job)), strings.TrimSpace(text)), nil
+	case workflowNodeAgent:
+		prompt, err := renderWorkflowTemplate(node.Prompt, vars)
+		if err != nil {
+			return "", err
+		}
+		return "agent prompt: " + strings.TrimSpace(prompt), nil
+	case workflowNodeHTTP:
+		rawURL, err := renderWorkflowTemplate(node.URL, vars)
+		if err != nil {
+			return "", err
+		}
+		rawURL = strings.TrimSpace(rawURL)
+		if err := validateWorkflowHTTPURL(rawURL); err != nil {
+			return "", err
+		}
+		body, err := renderWorkflowTemplate(node.Body, vars)
+		if err != nil {
+			return "", err
+		}
+		method := strings.ToUpper(strings.TrimSpace(node.Method))
+		if method == "" {
+			method = http.MethodGet
+		}
+		if body == "" {
+			return method + " " + rawURL, nil
+		}
+		return method + " " + rawURL + "\n" + body, nil
+	case workflowNodeTool:
+		rendered, err := renderWorkflowTemplateValue(node.ToolInput, vars)
+		if err != nil {
+			return "", err
+		}
+		input, err := json.Marshal(rendered)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("tool %s %s", strings.ToLower(strings.TrimSpace(node.Tool)), input), nil
+	case workflowNodeDelay:
+		return fmt.Sprintf("wait %ds", node.DelaySeconds), nil
+	default:
+		return fmt.Sprintf("custom node %s not executed", node.Type), nil
+	}
+}
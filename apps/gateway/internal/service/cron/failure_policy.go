@@ -0,0 +1,335 @@
+package cron
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+)
+
+const (
+	RetryClassTimeout  = "timeout"
+	RetryClassChannel  = "channel"
+	RetryClassAgent    = "agent"
+	RetryClassWorkflow = "workflow"
+	RetryClassAny      = "any"
+
+	retryMaxAttempts              = 10
+	retryDefaultBackoffMultiplier = 2
+	retryMaxBackoffMultiplier     = 10
+	retryDefaultMaxBackoffSeconds = 300
+	retryMaxBackoffSeconds        = 3600
+
+	failureNotifyMaxThreshold = 1000
+	failureNotifyTimeout      = 10 * time.Second
+	failureNoticeKindFailed   = "failed"
+	failureNoticeKindMisfire  = "misfire"
+)
+
+var defaultRetryClasses = []string{RetryClassTimeout, RetryClassChannel, RetryClassAgent}
+
+var retryClasses = map[string]struct{}{
+	RetryClassTimeout:  {},
+	RetryClassChannel:  {},
+	RetryClassAgent:    {},
+	RetryClassWorkflow: {},
+	RetryClassAny:      {},
+}
+
+type runError struct {
+	Class string
+	Err   error
+}
+
+func (e *runError) Error() string {
+	if e == nil || e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func (e *runError) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	return e.Err
+}
+
+func classifyRunError(err error) string {
+	var classified *runError
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+	var chErr *channelError
+	if errors.As(err, &chErr) {
+		return RetryClassChannel
+	}
+	return ""
+}
+
+func validateRuntimeSpec(runtime *domain.CronRuntimeSpec) error {
+	if retry := runtime.Retry; retry != nil {
+		if retry.MaxAttempts < 1 || retry.MaxAttempts > retryMaxAttempts {
+			return fmt.Errorf("retry.max_attempts must be between 1 and %d", retryMaxAttempts)
+		}
+		if retry.BackoffSeconds < 0 || retry.BackoffSeconds > retryMaxBackoffSeconds {
+			return fmt.Errorf("retry.backoff_seconds must be between 0 and %d", retryMaxBackoffSeconds)
+		}
+		if retry.BackoffMultiplier != 0 && (retry.BackoffMultiplier < 1 || retry.BackoffMultiplier > retryMaxBackoffMultiplier) {
+			return fmt.Errorf("retry.backoff_multiplier must be between 1 and %d", retryMaxBackoffMultiplier)
+		}
+		if retry.MaxBackoffSeconds < 0 || retry.MaxBackoffSeconds > retryMaxBackoffSeconds {
+			return fmt.Errorf("retry.max_backoff_seconds must be between 0 and %d", retryMaxBackoffSeconds)
+		}
+		classes := make([]string, 0, len(retry.RetryOn))
+		seen := map[string]struct{}{}
+		for _, raw := range retry.RetryOn {
+			class := strings.ToLower(strings.TrimSpace(raw))
+			if _, ok := retryClasses[class]; !ok {
+				return fmt.Errorf("unsupported retry.retry_on class=%q", raw)
+			}
+			if _, ok := seen[class]; ok {
+				continue
+			}
+			seen[class] = struct{}{}
+			classes = append(classes, class)
+		}
+		retry.RetryOn = classes
+	}
+
+	if notify := runtime.OnFailure; notify != nil {
+		notify.Channel = strings.ToLower(strings.TrimSpace(notify.Channel))
+		notify.WebhookURL = strings.TrimSpace(notify.WebhookURL)
+		if notify.WebhookURL != "" {
+			if err := validateWorkflowHTTPURL(notify.WebhookURL); err != nil {
+				return fmt.Errorf("on_failure.webhook_url: %w", err)
+			}
+		}
+		if notify.AfterConsecutiveFailures < 0 || notify.AfterConsecutiveFailures > failureNotifyMaxThreshold {
+			return fmt.Errorf("on_failure.after_consecutive_failures must be between 0 and %d", failureNotifyMaxThreshold)
+		}
+		dispatch := domain.CronDispatchSpec{Channel: notify.Channel, Target: notify.Target}
+		if err := validateDispatchSpec(&dispatch); err != nil {
+			return fmt.Errorf("on_failure: %w", err)
+		}
+		notify.Target = dispatch.Target
+	}
+	return nil
+}
+
+type retryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+	multiplier  float64
+	maxBackoff  time.Duration
+	classes     []string
+}
+
+func resolveRetryPolicy(runtime domain.CronRuntimeSpec) retryPolicy {
+	policy := retryPolicy{maxAttempts: 1}
+	retry := runtime.Retry
+	if retry == nil || retry.MaxAttempts <= 1 {
+		return policy
+	}
+	policy.maxAttempts = retry.MaxAttempts
+	if policy.maxAttempts > retryMaxAttempts {
+		policy.maxAttempts = retryMaxAttempts
+	}
+	policy.backoff = time.Duration(retry.BackoffSeconds) * time.Second
+	policy.multiplier = retry.BackoffMultiplier
+	if policy.multiplier < 1 {
+		policy.multiplier = retryDefaultBackoffMultiplier
+	}
+	maxBackoff := retry.MaxBackoffSeconds
+	if maxBackoff <= 0 {
+		maxBackoff = retryDefaultMaxBackoffSeconds
+	}
+	policy.maxBackoff = time.Duration(maxBackoff) * time.Second
+	policy.classes = retry.RetryOn
+	if len(policy.classes) == 0 {
+		policy.classes = defaultRetryClasses
+	}
+	return policy
+}
+
+func (p retryPolicy) retryable(err error) bool {
+	class := classifyRunError(err)
+	for _, allowed := range p.classes {
+		if allowed == RetryClassAny || (class != "" && allowed == class) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p retryPolicy) delay(attempt int) time.Duration {
+	if p.backoff <= 0 {
+		return 0
+	}
+	delay := time.Duration(float64(p.backoff) * math.Pow(p.multiplier, float64(attempt-1)))
+	if delay > p.maxBackoff || delay <= 0 {
+		return p.maxBackoff
+	}
+	return delay
+}
+
+func (p retryPolicy) budget(timeout time.Duration) time.Duration {
+	total := time.Duration(p.maxAttempts) * timeout
+	for attempt := 1; attempt < p.maxAttempts; attempt++ {
+		total += p.delay(attempt)
+	}
+	return total
+}
+
+// waitRetryBackoff reports false when stop closed before the delay elapsed.
+func waitRetryBackoff(stop <-chan struct{}, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+type failureNotice struct {
+	Kind                string `json:"kind"`
+	JobID               string `json:"job_id"`
+	JobName             string `json:"job_name"`
+	RunID               string `json:"run_id,omitempty"`
+	Error               string `json:"error"`
+	Attempts            int    `json:"attempts,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	At                  string `json:"at"`
+}
+
+func (n failureNotice) text() string {
+	label := "failed"
+	if n.Kind == failureNoticeKindMisfire {
+		label = "missed its schedule"
+	}
+	lines := []string{
+		fmt.Sprintf("Cron job %q (%s) %s: %s", n.JobName, n.JobID, label, n.Error),
+		fmt.Sprintf("consecutive_failures=%d", n.ConsecutiveFailures),
+	}
+	if n.Attempts > 0 {
+		lines[1] += fmt.Sprintf(" attempts=%d", n.Attempts)
+	}
+	if n.RunID != "" {
+		lines[1] += " run_id=" + n.RunID
+	}
+	return strings.Join(lines, "\n")
+}
+
+func shouldNotifyFailure(runtime domain.CronRuntimeSpec, consecutiveFailures int) bool {
+	notify := runtime.OnFailure
+	if notify == nil || consecutiveFailures <= 0 {
+		return false
+	}
+	threshold := notify.AfterConsecutiveFailures
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return consecutiveFailures%threshold == 0
+}
+
+func (s *Service) notifyFailure(ctx context.Context, job domain.CronJobSpec, notice failureNotice) {
+	notify := job.Runtime.OnFailure
+	if notify == nil {
+		return
+	}
+	recorder := runRecorderFromContext(ctx)
+	if notify.WebhookURL != "" {
+		if err := s.sendFailureWebhook(ctx, *notify, notice); err != nil {
+			recorder.logf(runLogLevelError, "failure notification webhook failed: %v", err)
+			s.log().ErrorContext(ctx, "cron failure notification webhook failed", observability.LogKeyJobID, job.ID, "err", err)
+		} else {
+			recorder.logf(runLogLevelInfo, "failure notification sent webhook")
+		}
+	}
+	if notify.WebhookURL != "" && notify.Channel == "" {
+		return
+	}
+	channelName, err := s.sendFailureChannel(ctx, job, *notify, notice)
+	if err != nil {
+		recorder.logf(runLogLevelError, "failure notification channel=%s failed: %v", channelName, err)
+		s.log().ErrorContext(ctx, "cron failure notification failed", observability.LogKeyChannel, channelName, observability.LogKeyJobID, job.ID, "err", err)
+		return
+	}
+	recorder.logf(runLogLevelInfo, "failure notification sent channel=%s", channelName)
+}
+
+func (s *Service) sendFailureWebhook(ctx context.Context, notify domain.CronFailureNotifySpec, notice failureNotice) error {
+	payload := struct {
+		failureNotice
+		Text string `json:"text"`
+	}{failureNotice: notice, Text: notice.text()}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	requestCtx, cancel := context.WithTimeout(ctx, failureNotifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(requestCtx, http.MethodPost, notify.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range notify.WebhookHeaders {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Service) sendFailureChannel(
+	ctx context.Context,
+	job domain.CronJobSpec,
+	notify domain.CronFailureNotifySpec,
+	notice failureNotice,
+) (string, error) {
+	target := job
+	if notify.Channel != "" {
+		target.Dispatch = domain.CronDispatchSpec{Channel: notify.Channel, Target: notify.Target}
+	}
+	channelName := strings.ToLower(resolveDispatchChannel(target))
+	if s.deps.ChannelResolver == nil {
+		return channelName, errors.New("cron channel resolver is unavailable")
+	}
+	channelPlugin, channelCfg, resolvedChannelName, err := s.deps.ChannelResolver.ResolveChannel(channelName)
+	if err != nil {
+		return channelName, err
+	}
+	target = ResolveDispatchTarget(target, resolvedChannelName)
+	if resolvedChannelName == qqChannelName {
+		channelCfg = withQQDispatchTarget(channelCfg, target.Dispatch.Target)
+	}
+	requestCtx, cancel := context.WithTimeout(ctx, failureNotifyTimeout)
+	defer cancel()
+	if err := channelPlugin.SendText(requestCtx, target.Dispatch.Target.UserID, target.Dispatch.Target.SessionID, notice.text(), channelCfg); err != nil {
+		return resolvedChannelName, err
+	}
+	return resolvedChannelName, nil
+}
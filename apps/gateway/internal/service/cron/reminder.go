@@ -0,0 +1,141 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/service/ports"
+)
+
+const (
+	ReminderSource            = "schedule_reminder"
+	DefaultReminderMaxPerUser = 20
+	ReminderMaxLead           = 366 * 24 * time.Hour
+
+	reminderMetaSourceKey = "source"
+	reminderMetaOwnerKey  = "owner_user_id"
+	reminderJobIDPrefix   = "reminder-"
+	reminderNameMaxRunes  = 40
+	reminderTextMaxRunes  = 2000
+)
+
+var ErrReminderQuotaExceeded = errors.New("cron_reminder_quota_exceeded")
+
+type ReminderRequest struct {
+	Text      string
+	RunAt     time.Time
+	Timezone  string
+	Channel   string
+	UserID    string
+	SessionID string
+}
+
+func (s *Service) CreateReminder(req ReminderRequest, now time.Time) (domain.CronJobSpec, error) {
+	if err := s.validateStore(); err != nil {
+		return domain.CronJobSpec{}, err
+	}
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return domain.CronJobSpec{}, errors.New("reminder text is required")
+	}
+	if len([]rune(text)) > reminderTextMaxRunes {
+		return domain.CronJobSpec{}, fmt.Errorf("reminder text must be at most %d characters", reminderTextMaxRunes)
+	}
+	userID := strings.TrimSpace(req.UserID)
+	if userID == "" {
+		return domain.CronJobSpec{}, errors.New("reminder requires the current user_id")
+	}
+	if !req.RunAt.After(now) {
+		return domain.CronJobSpec{}, fmt.Errorf("reminder time %s is not in the future", req.RunAt.UTC().Format(time.RFC3339))
+	}
+	if req.RunAt.Sub(now) > ReminderMaxLead {
+		return domain.CronJobSpec{}, errors.New("reminder time must be within one year")
+	}
+
+	channelName := strings.ToLower(strings.TrimSpace(req.Channel))
+	if channelName == "" {
+		channelName = "console"
+	}
+	job := domain.CronJobSpec{
+		ID:       reminderJobIDPrefix + strings.TrimPrefix(newRunID(), "run-"),
+		Name:     "Reminder: " + truncateRunes(text, reminderNameMaxRunes),
+		Enabled:  true,
+		Schedule: domain.CronScheduleSpec{Type: scheduleTypeOnce, RunAt: req.RunAt.UTC().Format(time.RFC3339), Timezone: strings.TrimSpace(req.Timezone)},
+		TaskType: taskTypeText,
+		Text:     "Reminder: " + text,
+		Dispatch: domain.CronDispatchSpec{
+			Type:    "channel",
+			Channel: channelName,
+			Target:  DispatchTargetFromSession(channelName, userID, req.SessionID),
+			Mode:    dispatchModeText,
+		},
+		Meta: map[string]interface{}{
+			reminderMetaSourceKey: ReminderSource,
+			reminderMetaOwnerKey:  userID,
+		},
+	}
+	if code, err := s.validateJobSpec(&job); err != nil {
+		return domain.CronJobSpec{}, &ValidationError{Code: code, Message: err.Error()}
+	}
+
+	limit := s.deps.ReminderMaxPerUser
+	if limit <= 0 {
+		limit = DefaultReminderMaxPerUser
+	}
+	if err := s.deps.Store.WriteCron(func(st *ports.CronAggregate) error {
+		active := 0
+		for _, existing := range st.Jobs {
+			if existing.Enabled && reminderOwner(existing) == userID {
+				active++
+			}
+		}
+		if active >= limit {
+			return fmt.Errorf("%w: user %q already has %d pending reminders", ErrReminderQuotaExceeded, userID, active)
+		}
+		st.Jobs[job.ID] = job
+		st.States[job.ID] = alignStateForMutation(job, domain.CronJobState{}, now)
+		return nil
+	}); err != nil {
+		return domain.CronJobSpec{}, err
+	}
+	return job, nil
+}
+
+func reminderOwner(job domain.CronJobSpec) string {
+	if source, _ := job.Meta[reminderMetaSourceKey].(string); source != ReminderSource {
+		return ""
+	}
+	owner, _ := job.Meta[reminderMetaOwnerKey].(string)
+	return owner
+}
+
+func DispatchTargetFromSession(channelName, userID, sessionID string) domain.CronDispatchTarget {
+	target := domain.CronDispatchTarget{
+		UserID:    strings.TrimSpace(userID),
+		SessionID: strings.TrimSpace(sessionID),
+	}
+	if channelName != qqChannelName {
+		return target
+	}
+	parts := strings.Split(target.SessionID, ":")
+	if len(parts) < 3 || parts[0] != qqChannelName {
+		return target
+	}
+	switch parts[1] {
+	case "c2c", "group", "guild":
+		target.TargetType = parts[1]
+		target.TargetID = parts[2]
+	}
+	return target
+}
+
+func truncateRunes(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit]) + "..."
+}
@@ -0,0 +1,308 @@
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+)
+
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+
+	DefaultRunHistoryLimit  = 100
+	DefaultRunHistoryMaxAge = 30 * 24 * time.Hour
+
+	cronRunsDirName     = "cron-runs"
+	runLogMaxEntries    = 200
+	runLogLevelInfo     = "info"
+	runLogLevelError    = "error"
+	defaultRunListLimit = 20
+	maxRunListLimit     = 200
+)
+
+var ErrRunNotFound = errors.New("cron_run_not_found")
+
+type RunQuery struct {
+	Status string
+	Offset int
+	Limit  int
+}
+
+type runHistory struct {
+	mu     sync.Mutex
+	dir    string
+	limit  int
+	maxAge time.Duration
+}
+
+func newRunHistory(dataDir string, limit int, maxAge time.Duration) *runHistory {
+	if strings.TrimSpace(dataDir) == "" {
+		return nil
+	}
+	if limit <= 0 {
+		limit = DefaultRunHistoryLimit
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultRunHistoryMaxAge
+	}
+	return &runHistory{
+		dir:    filepath.Join(dataDir, cronRunsDirName),
+		limit:  limit,
+		maxAge: maxAge,
+	}
+}
+
+func (h *runHistory) path(jobID string) string {
+	return filepath.Join(h.dir, encodeJobID(jobID)+".json")
+}
+
+func (h *runHistory) save(record domain.CronRunRecord) error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records, err := h.load(record.JobID)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range records {
+		if records[i].RunID == record.RunID {
+			records[i] = record
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		records = append([]domain.CronRunRecord{record}, records...)
+	}
+	return h.write(record.JobID, h.prune(records, time.Now().UTC()))
+}
+
+func (h *runHistory) list(jobID string) ([]domain.CronRunRecord, error) {
+	if h == nil {
+		return nil, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records, err := h.load(jobID)
+	if err != nil {
+		return nil, err
+	}
+	return h.prune(records, time.Now().UTC()), nil
+}
+
+func (h *runHistory) remove(jobID string) error {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return removeIfExists(h.path(jobID))
+}
+
+func (h *runHistory) load(jobID string) ([]domain.CronRunRecord, error) {
+	body, err := os.ReadFile(h.path(jobID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var records []domain.CronRunRecord
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, fmt.Errorf("decode cron run history failed: %w", err)
+	}
+	return records, nil
+}
+
+func (h *runHistory) write(jobID string, records []domain.CronRunRecord) error {
+	if err := os.MkdirAll(h.dir, 0o755); err != nil {
+		return err
+	}
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	target := h.path(jobID)
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+func (h *runHistory) prune(records []domain.CronRunRecord, now time.Time) []domain.CronRunRecord {
+	cutoff := now.Add(-h.maxAge)
+	out := make([]domain.CronRunRecord, 0, len(records))
+	for _, record := range records {
+		if len(out) >= h.limit {
+			break
+		}
+		if startedAt, err := time.Parse(time.RFC3339Nano, record.StartedAt); err == nil && startedAt.Before(cutoff) {
+			continue
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+type runRecorder struct {
+	mu      sync.Mutex
+	runID   string
+	logs    []domain.CronRunLogEntry
+	replies []string
+	// completed keeps workflow node results across retry attempts so nodes
+	// that already delivered are not run again.
+	completed map[string]workflowNodeRunResult
+}
+
+type runRecorderContextKey struct{}
+
+func withRunRecorder(ctx context.Context, recorder *runRecorder) context.Context {
+	return context.WithValue(ctx, runRecorderContextKey{}, recorder)
+}
+
+func runRecorderFromContext(ctx context.Context) *runRecorder {
+	recorder, _ := ctx.Value(runRecorderContextKey{}).(*runRecorder)
+	return recorder
+}
+
+func (r *runRecorder) logf(level, format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.logs) >= runLogMaxEntries {
+		return
+	}
+	r.logs = append(r.logs, domain.CronRunLogEntry{
+		At:      nowISO(),
+		Level:   level,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *runRecorder) addReply(text string) {
+	if r == nil {
+		return
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replies = append(r.replies, text)
+}
+
+func (r *runRecorder) completedNode(nodeID string) (workflowNodeRunResult, bool) {
+	if r == nil {
+		return workflowNodeRunResult{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result, ok := r.completed[nodeID]
+	return result, ok
+}
+
+func (r *runRecorder) recordCompletedNode(nodeID string, result workflowNodeRunResult) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.completed == nil {
+		r.completed = map[string]workflowNodeRunResult{}
+	}
+	r.completed[nodeID] = result
+}
+
+func (r *runRecorder) apply(record *domain.CronRunRecord) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record.Logs = append([]domain.CronRunLogEntry{}, r.logs...)
+	record.Reply = strings.Join(r.replies, "\n\n")
+}
+
+func (s *Service) ListRuns(jobID string, query RunQuery) (domain.CronRunList, error) {
+	if _, err := s.GetJob(jobID); err != nil {
+		return domain.CronRunList{}, err
+	}
+	records, err := s.history.list(jobID)
+	if err != nil {
+		return domain.CronRunList{}, err
+	}
+
+	status := strings.ToLower(strings.TrimSpace(query.Status))
+	filtered := make([]domain.CronRunRecord, 0, len(records))
+	for _, record := range records {
+		if status != "" && record.Status != status {
+			continue
+		}
+		record.Nodes = nil
+		record.Logs = nil
+		filtered = append(filtered, record)
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultRunListLimit
+	}
+	if limit > maxRunListLimit {
+		limit = maxRunListLimit
+	}
+	offset := query.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	out := domain.CronRunList{Runs: []domain.CronRunRecord{}, Total: len(filtered), Offset: offset, Limit: limit}
+	if offset < len(filtered) {
+		end := offset + limit
+		if end > len(filtered) {
+			end = len(filtered)
+		}
+		out.Runs = filtered[offset:end]
+	}
+	return out, nil
+}
+
+func (s *Service) GetRun(jobID, runID string) (domain.CronRunRecord, error) {
+	if _, err := s.GetJob(jobID); err != nil {
+		return domain.CronRunRecord{}, err
+	}
+	records, err := s.history.list(jobID)
+	if err != nil {
+		return domain.CronRunRecord{}, err
+	}
+	runID = strings.TrimSpace(runID)
+	for _, record := range records {
+		if record.RunID == runID {
+			return record, nil
+		}
+	}
+	return domain.CronRunRecord{}, ErrRunNotFound
+}
+
+func (s *Service) saveRun(record domain.CronRunRecord) {
+	if err := s.history.save(record); err != nil {
+		s.log().Error("cron run history save failed", observability.LogKeyJobID, record.JobID, observability.LogKeyRunID, record.RunID, "err", err)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -19,6 +21,7 @@ import (
 	cronv3 "github.com/robfig/cron/v3"
 
 	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
 	"nextai/apps/gateway/internal/service/ports"
 )
 
@@ -28,6 +31,9 @@ const (
 	statusRunning   = "running"
 	statusSucceeded = "succeeded"
 	statusFailed    = "failed"
+	statusSkipped   = "skipped"
+
+	cronOutcomeMisfire = "misfire"
 
 	taskTypeText     = "text"
 	taskTypeWorkflow = "workflow"
@@ -37,18 +43,45 @@ const (
 	workflowNodeText  = "text_event"
 	workflowNodeDelay = "delay"
 	workflowNodeIf    = "if_event"
+	workflowNodeAgent = "agent_prompt"
+	workflowNodeHTTP  = "http_request"
+	workflowNodeTool  = "tool_call"
+	workflowNodeJoin  = "join"
+
+	workflowBranchTrue  = "true"
+	workflowBranchFalse = "false"
+
+	workflowHTTPDefaultTimeoutSeconds = 10
+	workflowHTTPMaxTimeoutSeconds     = 300
+	workflowHTTPMaxBodyBytes          = 1 << 20
+	workflowHTTPStatusVarSuffix       = "_status"
+	workflowNodeOutputPreviewRunes    = 1000
 
 	workflowNodeExecutionSkipped = "skipped"
 
 	cronLeaseDirName = "cron-leases"
 	qqChannelName    = "qq"
+
+	dispatchModeAgent = "agent"
+	dispatchModeText  = "text"
 )
 
 var ErrJobNotFound = errors.New("cron_job_not_found")
 var ErrMaxConcurrencyReached = errors.New("cron_max_concurrency_reached")
 var ErrDefaultProtected = errors.New("cron_default_protected")
 
-var workflowIfConditionPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*(==|!=)\s*(?:"([^"]*)"|'([^']*)'|(\S+))\s*$`)
+var workflowIfConditionPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)(?:\s*(==|!=)|\s+(contains|not_contains))\s*(?:"([^"]*)"|'([^']*)'|(\S+))\s*$`)
+var workflowTemplatePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+var workflowVarNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+var workflowHTTPMethods = map[string]struct{}{
+	http.MethodGet:    {},
+	http.MethodHead:   {},
+	http.MethodPost:   {},
+	http.MethodPut:    {},
+	http.MethodPatch:  {},
+	http.MethodDelete: {},
+}
 
 var workflowIfAllowedFields = map[string]struct{}{
 	"job_id":     {},
@@ -57,6 +90,17 @@ var workflowIfAllowedFields = map[string]struct{}{
 	"user_id":    {},
 	"session_id": {},
 	"task_type":  {},
+
+	"trigger_type":       {},
+	"trigger_event":      {},
+	"trigger_source":     {},
+	"trigger_channel":    {},
+	"trigger_user_id":    {},
+	"trigger_session_id": {},
+	"trigger_text":       {},
+	"trigger_match":      {},
+	"trigger_error":      {},
+	"trigger_body":       {},
 }
 
 type ValidationError struct {
@@ -99,22 +143,37 @@ func (e *channelError) Unwrap() error {
 type TaskExecutor func(ctx context.Context, job domain.CronJobSpec) (handled bool, err error)
 
 type Dependencies struct {
-	Store                   ports.StateStore
-	DataDir                 string
-	ChannelResolver         ports.ChannelResolver
-	ExecuteConsoleAgentTask func(ctx context.Context, job domain.CronJobSpec, text string) error
-	ExecuteTask             TaskExecutor
+	Store              ports.StateStore
+	DataDir            string
+	ChannelResolver    ports.ChannelResolver
+	ExecuteAgentTask   func(ctx context.Context, job domain.CronJobSpec, channel string, text string) (string, error)
+	ExecuteAgentPrompt func(ctx context.Context, job domain.CronJobSpec, prompt AgentPrompt) (string, error)
+	ExecuteToolCall    func(ctx context.Context, name string, input map[string]interface{}) (string, error)
+	ExecuteTask        TaskExecutor
+	RunHistoryLimit    int
+	RunHistoryMaxAge   time.Duration
+	ReminderMaxPerUser int
+	EmitEvent          func(event TriggerEvent)
+	Logger             *slog.Logger
+	// Stop is closed on shutdown to abandon pending retry backoffs.
+	Stop <-chan struct{}
 }
 
 type Service struct {
 	deps         Dependencies
 	nodeHandlers map[string]CronNodeHandler
+	history      *runHistory
+}
+
+func (s *Service) log() *slog.Logger {
+	return observability.LoggerOrDefault(s.deps.Logger)
 }
 
 func NewService(deps Dependencies) *Service {
 	svc := &Service{
 		deps:         deps,
 		nodeHandlers: map[string]CronNodeHandler{},
+		history:      newRunHistory(deps.DataDir, deps.RunHistoryLimit, deps.RunHistoryMaxAge),
 	}
 	svc.registerDefaultWorkflowNodeHandlers()
 	return svc
@@ -179,6 +238,9 @@ func (s *Service) UpdateJob(jobID string, job domain.CronJobSpec) (domain.CronJo
 	if err := s.validateStore(); err != nil {
 		return domain.CronJobSpec{}, err
 	}
+	s.deps.Store.ReadCron(func(st ports.CronAggregate) {
+		inheritWebhookSecret(&job, st.Jobs[jobID])
+	})
 	if code, err := s.validateJobSpec(&job); err != nil {
 		return domain.CronJobSpec{}, &ValidationError{Code: code, Message: err.Error()}
 	}
@@ -218,6 +280,11 @@ func (s *Service) DeleteJob(jobID string) (bool, error) {
 	}); err != nil {
 		return false, err
 	}
+	if deleted {
+		if err := s.history.remove(jobID); err != nil {
+			s.log().Warn("cron run history cleanup failed", observability.LogKeyJobID, jobID, "err", err)
+		}
+	}
 	return deleted, nil
 }
 
@@ -273,6 +340,9 @@ func (s *Service) SchedulerTick(now time.Time) ([]string, error) {
 
 	stateUpdates := map[string]domain.CronJobState{}
 	dueJobIDs := make([]string, 0)
+	completed := map[string]struct{}{}
+	misfires := map[string]domain.CronJobSpec{}
+	notices := map[string]failureNotice{}
 	s.deps.Store.ReadCron(func(st ports.CronAggregate) {
 		for id, job := range st.Jobs {
 			current := st.States[id]
@@ -296,14 +366,34 @@ func (s *Service) SchedulerTick(now time.Time) ([]string, error) {
 				continue
 			}
 
-			nextRun := nextRunAt.Format(time.RFC3339)
-			next.NextRunAt = &nextRun
+			if nextRunAt.IsZero() {
+				next.NextRunAt = nil
+			} else {
+				nextRun := nextRunAt.Format(time.RFC3339)
+				next.NextRunAt = &nextRun
+			}
 			next.LastError = nil
+			if dueAt != nil && scheduleType(job) == scheduleTypeOnce {
+				completed[id] = struct{}{}
+			}
 			if dueAt != nil && MisfireExceeded(dueAt, runtimeSpec(job), now) {
 				failed := statusFailed
 				msg := fmt.Sprintf("misfire skipped: scheduled_at=%s", dueAt.Format(time.RFC3339))
 				next.LastStatus = &failed
 				next.LastError = &msg
+				next.ConsecutiveFailures++
+				observability.CronRunsTotal.Inc(id, cronOutcomeMisfire)
+				if shouldNotifyFailure(job.Runtime, next.ConsecutiveFailures) {
+					misfires[id] = job
+					notices[id] = failureNotice{
+						Kind:                failureNoticeKindMisfire,
+						JobID:               job.ID,
+						JobName:             job.Name,
+						Error:               msg,
+						ConsecutiveFailures: next.ConsecutiveFailures,
+						At:                  now.UTC().Format(time.RFC3339Nano),
+					}
+				}
 				dueAt = nil
 			}
 			if !stateEqual(current, next) {
@@ -325,14 +415,32 @@ func (s *Service) SchedulerTick(now time.Time) ([]string, error) {
 			}
 			st.States[id] = next
 		}
+		for id := range completed {
+			if job, ok := st.Jobs[id]; ok {
+				job.Enabled = false
+				st.Jobs[id] = job
+			}
+		}
 		return nil
 	}); err != nil {
 		return nil, err
 	}
+	for id, job := range misfires {
+		go s.notifyFailure(context.Background(), job, notices[id])
+	}
 	return dueJobIDs, nil
 }
 
 func (s *Service) ExecuteJob(jobID string) error {
+	return s.ExecuteJobWithTrigger(jobID, TriggerManual)
+}
+
+func (s *Service) ExecuteJobWithTrigger(jobID, trigger string) error {
+	return s.ExecuteJobWithEvent(jobID, TriggerEvent{Type: trigger})
+}
+
+func (s *Service) ExecuteJobWithEvent(jobID string, event TriggerEvent) error {
+	trigger := event.Type
 	if err := s.validateStore(); err != nil {
 		return err
 	}
@@ -346,27 +454,40 @@ func (s *Service) ExecuteJob(jobID string) error {
 		return ErrJobNotFound
 	}
 
+	recorder := &runRecorder{runID: newRunID()}
+	run := domain.CronRunRecord{
+		RunID:     recorder.runID,
+		JobID:     jobID,
+		Trigger:   trigger,
+		Status:    statusRunning,
+		StartedAt: nowISO(),
+	}
+	runStarted := time.Now()
+
 	runtime := runtimeSpec(job)
 	slot, acquired, err := s.tryAcquireSlot(jobID, runtime)
 	if err != nil {
 		return err
 	}
 	if !acquired {
-		if err := s.markExecutionSkipped(jobID, fmt.Sprintf("max_concurrency limit reached (%d)", runtime.MaxConcurrency)); err != nil {
+		message := fmt.Sprintf("max_concurrency limit reached (%d)", runtime.MaxConcurrency)
+		if err := s.markExecutionSkipped(jobID, message); err != nil {
 			return err
 		}
+		recorder.logf(runLogLevelError, "run skipped: %s", message)
+		s.finishRun(run, recorder, runStarted, statusSkipped, &message, nil)
 		return ErrMaxConcurrencyReached
 	}
 	defer s.releaseSlot(slot)
 
-	startedAt := nowISO()
+	startedAt := run.StartedAt
 	running := statusRunning
 	if err := s.deps.Store.WriteCron(func(st *ports.CronAggregate) error {
 		target, ok := st.Jobs[jobID]
 		if !ok {
 			return ErrJobNotFound
 		}
-		job = target
+		job = event.replyTarget(target)
 		state := normalizePausedState(st.States[jobID])
 		state.LastRunAt = &startedAt
 		state.LastStatus = &running
@@ -376,12 +497,27 @@ func (s *Service) ExecuteJob(jobID string) error {
 	}); err != nil {
 		return err
 	}
+	recorder.logf(runLogLevelInfo, "run started trigger=%s task_type=%s", trigger, taskType(job))
+	s.saveRun(run)
 
-	execCtx, cancel := context.WithTimeout(context.Background(), time.Duration(runtime.TimeoutSeconds)*time.Second)
-	defer cancel()
-	lastExecution, execErr := s.executeTask(execCtx, job)
-	if errors.Is(execErr, context.DeadlineExceeded) {
-		execErr = fmt.Errorf("cron execution timeout after %ds", runtime.TimeoutSeconds)
+	policy := resolveRetryPolicy(runtime)
+	var lastExecution *domain.CronWorkflowExecution
+	var execErr error
+	for attempt := 1; ; attempt++ {
+		run.Attempts = attempt
+		if attempt > 1 {
+			recorder.logf(runLogLevelInfo, "attempt %d/%d started", attempt, policy.maxAttempts)
+		}
+		lastExecution, execErr = s.executeAttempt(recorder, event, job, runtime)
+		if execErr == nil || attempt >= policy.maxAttempts || !policy.retryable(execErr) {
+			break
+		}
+		delay := policy.delay(attempt)
+		recorder.logf(runLogLevelError, "attempt %d/%d failed: %s; retrying in %s", attempt, policy.maxAttempts, execErr.Error(), delay)
+		if !waitRetryBackoff(s.deps.Stop, delay) {
+			recorder.logf(runLogLevelError, "retry abandoned: service stopping")
+			break
+		}
 	}
 
 	finalStatus := statusSucceeded
@@ -390,7 +526,11 @@ func (s *Service) ExecuteJob(jobID string) error {
 		finalStatus = statusFailed
 		msg := execErr.Error()
 		finalErr = &msg
+		recorder.logf(runLogLevelError, "run failed: %s", msg)
+	} else {
+		recorder.logf(runLogLevelInfo, "run succeeded")
 	}
+	consecutiveFailures := 0
 	if err := s.deps.Store.WriteCron(func(st *ports.CronAggregate) error {
 		if _, ok := st.Jobs[jobID]; !ok {
 			return nil
@@ -399,15 +539,90 @@ func (s *Service) ExecuteJob(jobID string) error {
 		state.LastStatus = &finalStatus
 		state.LastError = finalErr
 		state.LastExecution = lastExecution
+		if execErr != nil {
+			state.ConsecutiveFailures++
+		} else {
+			state.ConsecutiveFailures = 0
+		}
+		consecutiveFailures = state.ConsecutiveFailures
 		st.States[jobID] = state
 		return nil
 	}); err != nil {
 		return err
 	}
+	if execErr != nil && shouldNotifyFailure(runtime, consecutiveFailures) {
+		s.notifyFailure(withRunRecorder(context.Background(), recorder), job, failureNotice{
+			Kind:                failureNoticeKindFailed,
+			JobID:               job.ID,
+			JobName:             job.Name,
+			RunID:               run.RunID,
+			Error:               *finalErr,
+			Attempts:            run.Attempts,
+			ConsecutiveFailures: consecutiveFailures,
+			At:                  nowISO(),
+		})
+	}
+	if execErr != nil && trigger != TriggerSystemEvent {
+		s.emitRunFailureEvents(job, *finalErr, execErr)
+	}
+	s.finishRun(run, recorder, runStarted, finalStatus, finalErr, lastExecution)
 
 	return execErr
 }
 
+func (s *Service) executeAttempt(
+	recorder *runRecorder,
+	event TriggerEvent,
+	job domain.CronJobSpec,
+	runtime domain.CronRuntimeSpec,
+) (*domain.CronWorkflowExecution, error) {
+	// Runs act with the scopes of whoever saved the job. Jobs saved before
+	// scopes were recorded (the default job, reminders, older state) carry
+	// none and keep running without a principal, as they always have.
+	baseCtx := context.Background()
+	if job.CreatorScopes != nil {
+		baseCtx = observability.WithPrincipal(baseCtx, observability.Principal{Scopes: job.CreatorScopes})
+	}
+	baseCtx = withTriggerEvent(withRunRecorder(baseCtx, recorder), event)
+	baseCtx, span := observability.StartSpan(baseCtx, "cron.run", observability.WithAttributes(
+		"cron.job_id", job.ID,
+		"cron.run_id", recorder.runID,
+		"cron.trigger_type", event.Type,
+	))
+	defer span.End()
+	baseCtx = observability.WithLogFields(baseCtx, observability.LogKeyJobID, job.ID, observability.LogKeyRunID, recorder.runID)
+	execCtx, cancel := context.WithTimeout(baseCtx, time.Duration(runtime.TimeoutSeconds)*time.Second)
+	defer cancel()
+	execution, err := s.executeTask(execCtx, job)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = &runError{Class: RetryClassTimeout, Err: fmt.Errorf("cron execution timeout after %ds", runtime.TimeoutSeconds)}
+	}
+	span.SetError(err)
+	return execution, err
+}
+
+func (s *Service) finishRun(
+	run domain.CronRunRecord,
+	recorder *runRecorder,
+	started time.Time,
+	status string,
+	errText *string,
+	execution *domain.CronWorkflowExecution,
+) {
+	finishedAt := nowISO()
+	run.Status = status
+	run.Error = errText
+	run.FinishedAt = &finishedAt
+	run.DurationMS = time.Since(started).Milliseconds()
+	if execution != nil {
+		run.Nodes = execution.Nodes
+	}
+	recorder.apply(&run)
+	s.saveRun(run)
+	observability.CronRunsTotal.Inc(run.JobID, status)
+	observability.CronRunDuration.ObserveDuration(started, run.JobID)
+}
+
 func (s *Service) executeTask(ctx context.Context, job domain.CronJobSpec) (*domain.CronWorkflowExecution, error) {
 	if s.deps.ExecuteTask != nil {
 		handled, err := s.deps.ExecuteTask(ctx, job)
@@ -431,6 +646,9 @@ func (s *Service) executeTask(ctx context.Context, job domain.CronJobSpec) (*dom
 		return nil, s.executeTextTask(ctx, job, text)
 	case taskTypeWorkflow:
 		execution, err := s.executeWorkflowTask(ctx, job)
+		if err != nil && ctx.Err() == nil {
+			err = &runError{Class: RetryClassWorkflow, Err: err}
+		}
 		return execution, err
 	default:
 		return nil, fmt.Errorf("unsupported cron task_type=%q", job.TaskType)
@@ -439,21 +657,35 @@ func (s *Service) executeTask(ctx context.Context, job domain.CronJobSpec) (*dom
 
 func (s *Service) executeTextTask(ctx context.Context, job domain.CronJobSpec, text string) error {
 	channelName := strings.ToLower(resolveDispatchChannel(job))
-	if channelName == qqChannelName {
-		return errors.New("cron dispatch channel \"qq\" is inbound-only; use channel \"console\" to persist chat history")
-	}
 	if s.deps.ChannelResolver == nil {
 		return errors.New("cron channel resolver is unavailable")
 	}
 	channelPlugin, channelCfg, resolvedChannelName, err := s.deps.ChannelResolver.ResolveChannel(channelName)
 	if err != nil {
-		return err
+		return &channelError{Err: err}
 	}
-	if resolvedChannelName == "console" {
-		if s.deps.ExecuteConsoleAgentTask == nil {
-			return errors.New("cron console agent executor is unavailable")
+	job = ResolveDispatchTarget(job, resolvedChannelName)
+	if resolveDispatchMode(job, resolvedChannelName) == dispatchModeAgent {
+		if resolvedChannelName != "console" && !channelSupportsProactive(channelPlugin) {
+			return fmt.Errorf("cron dispatch channel %q cannot send proactive messages", resolvedChannelName)
+		}
+		if s.deps.ExecuteAgentTask == nil {
+			return errors.New("cron agent executor is unavailable")
 		}
-		return s.deps.ExecuteConsoleAgentTask(ctx, job, text)
+		if resolvedChannelName == qqChannelName && strings.TrimSpace(job.Dispatch.Target.SessionID) == "" {
+			return fmt.Errorf("cron agent dispatch to qq target_type=%q requires dispatch target user_id or session_id", job.Dispatch.Target.TargetType)
+		}
+		recorder := runRecorderFromContext(ctx)
+		recorder.logf(runLogLevelInfo, "agent dispatch channel=%s session_id=%s", resolvedChannelName, job.Dispatch.Target.SessionID)
+		reply, err := s.deps.ExecuteAgentTask(ctx, job, resolvedChannelName, text)
+		if err != nil {
+			return &runError{Class: RetryClassAgent, Err: err}
+		}
+		recorder.addReply(reply)
+		return nil
+	}
+	if resolvedChannelName == qqChannelName {
+		channelCfg = withQQDispatchTarget(channelCfg, job.Dispatch.Target)
 	}
 	if err := channelPlugin.SendText(ctx, job.Dispatch.Target.UserID, job.Dispatch.Target.SessionID, text, channelCfg); err != nil {
 		return &channelError{
@@ -461,33 +693,207 @@ func (s *Service) executeTextTask(ctx context.Context, job domain.CronJobSpec, t
 			Err:     err,
 		}
 	}
+	recorder := runRecorderFromContext(ctx)
+	recorder.logf(runLogLevelInfo, "text dispatch channel=%s chars=%d", resolvedChannelName, len([]rune(text)))
+	recorder.addReply(text)
+	return nil
+}
+
+func validateDispatchSpec(dispatch *domain.CronDispatchSpec) error {
+	dispatch.Mode = strings.ToLower(strings.TrimSpace(dispatch.Mode))
+	switch dispatch.Mode {
+	case "", dispatchModeAgent, dispatchModeText:
+	default:
+		return fmt.Errorf("unsupported dispatch mode=%q", dispatch.Mode)
+	}
+	dispatch.Target.TargetType = strings.ToLower(strings.TrimSpace(dispatch.Target.TargetType))
+	switch dispatch.Target.TargetType {
+	case "", "c2c", "group", "guild":
+	default:
+		return fmt.Errorf("unsupported dispatch target_type=%q", dispatch.Target.TargetType)
+	}
+	dispatch.Target.TargetID = strings.TrimSpace(dispatch.Target.TargetID)
+	dispatch.Target.UserID = strings.TrimSpace(dispatch.Target.UserID)
+	if strings.EqualFold(strings.TrimSpace(dispatch.Channel), qqChannelName) {
+		if dispatch.Target.TargetType != "" && dispatch.Target.TargetType != "c2c" && dispatch.Target.TargetID == "" {
+			return fmt.Errorf("dispatch target_id is required for qq target_type=%q", dispatch.Target.TargetType)
+		}
+		if dispatch.Target.TargetType != "" && dispatch.Target.TargetType != "c2c" && dispatch.Mode != dispatchModeText &&
+			dispatch.Target.UserID == "" && strings.TrimSpace(dispatch.Target.SessionID) == "" {
+			return fmt.Errorf("dispatch user_id is required for qq target_type=%q agent dispatch", dispatch.Target.TargetType)
+		}
+	}
 	return nil
 }
 
+func resolveDispatchMode(job domain.CronJobSpec, channelName string) string {
+	switch strings.ToLower(strings.TrimSpace(job.Dispatch.Mode)) {
+	case dispatchModeAgent:
+		return dispatchModeAgent
+	case dispatchModeText:
+		if channelName != "console" {
+			return dispatchModeText
+		}
+	}
+	if channelName == "console" || channelName == qqChannelName {
+		return dispatchModeAgent
+	}
+	return dispatchModeText
+}
+
+func channelSupportsProactive(channel ports.Channel) bool {
+	proactive, ok := channel.(ports.ProactiveChannel)
+	return ok && proactive.SupportsProactive()
+}
+
+func ResolveDispatchTarget(job domain.CronJobSpec, channelName string) domain.CronJobSpec {
+	if channelName != qqChannelName {
+		return job
+	}
+	target := &job.Dispatch.Target
+	target.TargetType = strings.ToLower(strings.TrimSpace(target.TargetType))
+	if target.TargetType == "" {
+		target.TargetType = "c2c"
+	}
+	target.TargetID = strings.TrimSpace(target.TargetID)
+	target.UserID = strings.TrimSpace(target.UserID)
+	if target.TargetType == "c2c" {
+		if target.TargetID == "" {
+			target.TargetID = target.UserID
+		}
+		if target.UserID == "" {
+			target.UserID = target.TargetID
+		}
+	}
+	// Inbound group and guild sessions are keyed by the sending member, so
+	// the session is only derived once user_id names that member.
+	if strings.TrimSpace(target.SessionID) == "" && target.TargetID != "" && target.UserID != "" {
+		if target.TargetType == "c2c" {
+			target.SessionID = fmt.Sprintf("qq:c2c:%s", target.TargetID)
+		} else {
+			target.SessionID = fmt.Sprintf("qq:%s:%s:%s", target.TargetType, target.TargetID, target.UserID)
+		}
+	}
+	return job
+}
+
+func withQQDispatchTarget(cfg map[string]interface{}, target domain.CronDispatchTarget) map[string]interface{} {
+	if strings.TrimSpace(target.TargetType) == "" && strings.TrimSpace(target.TargetID) == "" {
+		return cfg
+	}
+	merged := make(map[string]interface{}, len(cfg)+2)
+	for key, value := range cfg {
+		merged[key] = value
+	}
+	if targetType := strings.TrimSpace(target.TargetType); targetType != "" {
+		merged["target_type"] = targetType
+	}
+	if targetID := strings.TrimSpace(target.TargetID); targetID != "" {
+		merged["target_id"] = targetID
+	}
+	return merged
+}
+
 func (s *Service) executeWorkflowTask(ctx context.Context, job domain.CronJobSpec) (*domain.CronWorkflowExecution, error) {
 	plan, err := s.buildWorkflowPlan(job.Workflow)
 	if err != nil {
 		return nil, fmt.Errorf("invalid cron workflow: %w", err)
 	}
 
+	runID := newRunID()
+	recorder := runRecorderFromContext(ctx)
+	if recorder != nil {
+		runID = recorder.runID
+	}
+	ctx = withWorkflowRunID(ctx, runID)
 	startedAt := nowISO()
 	execution := &domain.CronWorkflowExecution{
-		RunID:       newRunID(),
+		RunID:       runID,
 		StartedAt:   startedAt,
 		HadFailures: false,
 		Nodes:       make([]domain.CronWorkflowNodeExecution, 0, len(plan.Order)),
 	}
 
+	type nodeOutcome struct {
+		node    domain.CronWorkflowNode
+		step    domain.CronWorkflowNodeExecution
+		result  workflowNodeRunResult
+		err     error
+		resumed bool
+	}
+
+	vars := workflowIfContext(job)
+	for name, value := range triggerEventFromContext(ctx).vars() {
+		vars[name] = value
+	}
+	pending := make(map[string]int, len(plan.NodeByID))
+	activated := map[string]bool{}
+	for id := range plan.NodeByID {
+		pending[id] = len(plan.InEdges[id])
+	}
+	ready := []string{}
+	resolve := func(node domain.CronWorkflowNode, follow func(edge domain.CronWorkflowEdge) bool) {
+		for _, edge := range plan.OutEdges[node.ID] {
+			if follow != nil && follow(edge) {
+				activated[edge.Target] = true
+			}
+			pending[edge.Target]--
+			if pending[edge.Target] == 0 {
+				ready = append(ready, edge.Target)
+			}
+		}
+	}
+	resolve(plan.NodeByID[plan.StartID], func(domain.CronWorkflowEdge) bool { return true })
+
+	outcomes := make(chan nodeOutcome)
+	running := 0
+	stopAll := false
 	var firstErr error
-	for idx, node := range plan.Order {
-		step := domain.CronWorkflowNodeExecution{
-			NodeID:          node.ID,
-			NodeType:        node.Type,
-			ContinueOnError: node.ContinueOnError,
-			StartedAt:       nowISO(),
+	for {
+		for len(ready) > 0 {
+			node := plan.NodeByID[ready[0]]
+			ready = ready[1:]
+			if stopAll || !activated[node.ID] {
+				recorder.logf(runLogLevelInfo, "node %s (%s) skipped", node.ID, node.Type)
+				skippedAt := nowISO()
+				execution.Nodes = append(execution.Nodes, domain.CronWorkflowNodeExecution{
+					NodeID:          node.ID,
+					NodeType:        node.Type,
+					Status:          workflowNodeExecutionSkipped,
+					ContinueOnError: node.ContinueOnError,
+					StartedAt:       skippedAt,
+					FinishedAt:      &skippedAt,
+				})
+				resolve(node, nil)
+				continue
+			}
+			step := domain.CronWorkflowNodeExecution{
+				NodeID:          node.ID,
+				NodeType:        node.Type,
+				ContinueOnError: node.ContinueOnError,
+				StartedAt:       nowISO(),
+			}
+			running++
+			if result, ok := recorder.completedNode(node.ID); ok {
+				recorder.logf(runLogLevelInfo, "node %s (%s) succeeded in an earlier attempt, reusing its result", node.ID, node.Type)
+				go func(node domain.CronWorkflowNode, step domain.CronWorkflowNodeExecution) {
+					outcomes <- nodeOutcome{node: node, step: step, result: result, resumed: true}
+				}(node, step)
+				continue
+			}
+			recorder.logf(runLogLevelInfo, "node %s (%s) started", node.ID, node.Type)
+			go func(nodeCtx context.Context, node domain.CronWorkflowNode, step domain.CronWorkflowNodeExecution) {
+				result, err := s.executeWorkflowNode(nodeCtx, job, node)
+				outcomes <- nodeOutcome{node: node, step: step, result: result, err: err}
+			}(withWorkflowVars(ctx, copyWorkflowVars(vars)), node, step)
+		}
+		if running == 0 {
+			break
 		}
 
-		runResult, runErr := s.executeWorkflowNode(ctx, job, node)
+		outcome := <-outcomes
+		running--
+		node, step, runResult, runErr := outcome.node, outcome.step, outcome.result, outcome.err
 		finishedAt := nowISO()
 		step.FinishedAt = &finishedAt
 		if runErr != nil {
@@ -498,30 +904,32 @@ func (s *Service) executeWorkflowTask(ctx context.Context, job domain.CronJobSpe
 			if firstErr == nil {
 				firstErr = fmt.Errorf("workflow node %s failed: %w", node.ID, runErr)
 			}
+			recorder.logf(runLogLevelError, "node %s (%s) failed: %s", node.ID, node.Type, errText)
 		} else {
 			step.Status = statusSucceeded
+			step.Resumed = outcome.resumed
+			if !outcome.resumed {
+				recorder.recordCompletedNode(node.ID, runResult)
+				recorder.logf(runLogLevelInfo, "node %s (%s) succeeded%s", node.ID, node.Type, workflowBranchLogSuffix(runResult.Branch))
+			}
 		}
+		step.Branch = runResult.Branch
+		step.Stubbed = runResult.Stubbed
+		step.Preview = runResult.Preview
+		step.Outputs = applyWorkflowNodeOutputs(vars, node, runResult)
 		execution.Nodes = append(execution.Nodes, step)
 
 		forceStop := runErr != nil && (errors.Is(runErr, context.Canceled) || errors.Is(runErr, context.DeadlineExceeded))
-		shouldStop := runResult.Stop || (runErr != nil && (!node.ContinueOnError || forceStop))
-		if !shouldStop {
-			continue
+		if forceStop {
+			stopAll = true
 		}
-		for j := idx + 1; j < len(plan.Order); j++ {
-			skippedNode := plan.Order[j]
-			skippedAt := nowISO()
-			skipped := domain.CronWorkflowNodeExecution{
-				NodeID:          skippedNode.ID,
-				NodeType:        skippedNode.Type,
-				Status:          workflowNodeExecutionSkipped,
-				ContinueOnError: skippedNode.ContinueOnError,
-				StartedAt:       skippedAt,
-				FinishedAt:      &skippedAt,
-			}
-			execution.Nodes = append(execution.Nodes, skipped)
+		if runResult.Stop || (runErr != nil && (!node.ContinueOnError || forceStop)) {
+			resolve(node, nil)
+			continue
 		}
-		break
+		resolve(node, func(edge domain.CronWorkflowEdge) bool {
+			return workflowEdgeFollowed(node, edge, runResult.Branch)
+		})
 	}
 
 	finishedAt := nowISO()
@@ -529,20 +937,64 @@ func (s *Service) executeWorkflowTask(ctx context.Context, job domain.CronJobSpe
 	return execution, firstErr
 }
 
+func workflowBranchLogSuffix(branch string) string {
+	if branch == "" {
+		return ""
+	}
+	return " branch=" + branch
+}
+
+func workflowEdgeFollowed(source domain.CronWorkflowNode, edge domain.CronWorkflowEdge, branch string) bool {
+	condition := edge.Condition
+	if condition == "" && source.Type == workflowNodeIf {
+		condition = workflowBranchTrue
+	}
+	return condition == "" || condition == branch
+}
+
 type workflowNodeRunResult struct {
-	Stop bool
+	Stop    bool
+	Branch  string
+	Output  *string
+	Vars    map[string]string
+	Stubbed bool
+	Preview string
 }
 
 func (s *Service) executeWorkflowNode(ctx context.Context, job domain.CronJobSpec, node domain.CronWorkflowNode) (workflowNodeRunResult, error) {
+	if stubs := dryRunFromContext(ctx); stubs != nil && !workflowNodeSideEffectFree(node.Type) {
+		return stubs.execute(ctx, job, node)
+	}
 	handler, ok := s.resolveCronNodeHandler(node.Type)
 	if !ok {
 		return workflowNodeRunResult{}, fmt.Errorf("unsupported workflow node type=%q", node.Type)
 	}
 	result, err := handler.Execute(ctx, job, node)
 	if err != nil {
-		return workflowNodeRunResult{}, err
+		return workflowNodeRunResult{Output: result.Output, Vars: result.Vars}, err
 	}
-	return workflowNodeRunResult{Stop: result.Stop}, nil
+	return workflowNodeRunResult{Stop: result.Stop, Branch: result.Branch, Output: result.Output, Vars: result.Vars}, nil
+}
+
+func applyWorkflowNodeOutputs(vars map[string]string, node domain.CronWorkflowNode, result workflowNodeRunResult) map[string]string {
+	if node.OutputVar == "" || (result.Output == nil && len(result.Vars) == 0) {
+		return nil
+	}
+	recorded := map[string]string{}
+	if result.Output != nil {
+		vars[node.OutputVar] = *result.Output
+		recorded[node.OutputVar] = truncateWorkflowOutput(*result.Output)
+	}
+	for suffix, value := range result.Vars {
+		name := node.OutputVar + "_" + suffix
+		vars[name] = value
+		recorded[name] = truncateWorkflowOutput(value)
+	}
+	return recorded
+}
+
+func truncateWorkflowOutput(value string) string {
+	return truncateRunes(value, workflowNodeOutputPreviewRunes)
 }
 
 func executeWorkflowDelay(ctx context.Context, seconds int) error {
@@ -577,37 +1029,41 @@ func parseWorkflowIfCondition(raw string) (workflowIfCondition, error) {
 	}
 	parts := workflowIfConditionPattern.FindStringSubmatch(condition)
 	if len(parts) == 0 {
-		return workflowIfCondition{}, errors.New("if_condition must match `<field> == <value>` or `<field> != <value>`")
+		return workflowIfCondition{}, errors.New("if_condition must match `<field> ==|!=|contains|not_contains <value>`")
 	}
 	field := strings.ToLower(strings.TrimSpace(parts[1]))
-	if _, ok := workflowIfAllowedFields[field]; !ok {
-		return workflowIfCondition{}, fmt.Errorf("if_condition field %q is unsupported", field)
+	operator := parts[2]
+	if operator == "" {
+		operator = parts[3]
 	}
-	value := parts[3]
+	value := parts[4]
 	if value == "" {
-		value = parts[4]
+		value = parts[5]
 	}
 	if value == "" {
-		value = parts[5]
+		value = parts[6]
 	}
-	return workflowIfCondition{Field: field, Operator: parts[2], Value: value}, nil
+	return workflowIfCondition{Field: field, Operator: operator, Value: value}, nil
 }
 
-func evaluateWorkflowIfCondition(raw string, job domain.CronJobSpec) (bool, error) {
+func evaluateWorkflowIfCondition(raw string, vars map[string]string) (bool, error) {
 	condition, err := parseWorkflowIfCondition(raw)
 	if err != nil {
 		return false, err
 	}
-	ctx := workflowIfContext(job)
-	left, ok := ctx[condition.Field]
+	left, ok := vars[condition.Field]
 	if !ok {
-		return false, fmt.Errorf("if_condition field %q is unsupported", condition.Field)
+		return false, fmt.Errorf("if_condition variable %q is undefined", condition.Field)
 	}
 	switch condition.Operator {
 	case "==":
 		return left == condition.Value, nil
 	case "!=":
 		return left != condition.Value, nil
+	case "contains":
+		return strings.Contains(left, condition.Value), nil
+	case "not_contains":
+		return !strings.Contains(left, condition.Value), nil
 	default:
 		return false, fmt.Errorf("if_condition operator %q is unsupported", condition.Operator)
 	}
@@ -624,6 +1080,31 @@ func workflowIfContext(job domain.CronJobSpec) map[string]string {
 	}
 }
 
+func renderWorkflowTemplate(raw string, vars map[string]string) (string, error) {
+	var missing string
+	out := workflowTemplatePattern.ReplaceAllStringFunc(raw, func(match string) string {
+		name := strings.ToLower(workflowTemplatePattern.FindStringSubmatch(match)[1])
+		value, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("workflow variable %q is undefined", missing)
+	}
+	return out, nil
+}
+
+func workflowTemplateVars(raw string) []string {
+	matches := workflowTemplatePattern.FindAllStringSubmatch(raw, -1)
+	out := make([]string, 0, len(matches))
+	for _, match := range matches {
+		out = append(out, strings.ToLower(match[1]))
+	}
+	return out
+}
+
 func BuildBizParams(job domain.CronJobSpec) map[string]interface{} {
 	jobID := strings.TrimSpace(job.ID)
 	jobName := strings.TrimSpace(job.Name)
@@ -649,6 +1130,20 @@ func (s *Service) validateJobSpec(job *domain.CronJobSpec) (string, error) {
 	if job.ID == "" || job.Name == "" {
 		return "invalid_cron_task_type", errors.New("id and name are required")
 	}
+	if err := validateDispatchSpec(&job.Dispatch); err != nil {
+		return "invalid_cron_dispatch", err
+	}
+	if err := validateScheduleSpec(&job.Schedule); err != nil {
+		return "invalid_cron_schedule", err
+	}
+	if err := validateRuntimeSpec(&job.Runtime); err != nil {
+		return "invalid_cron_runtime", err
+	}
+	triggers, err := validateTriggerSpecs(job.Triggers)
+	if err != nil {
+		return "invalid_cron_trigger", err
+	}
+	job.Triggers = triggers
 
 	switch taskType(*job) {
 	case taskTypeText:
@@ -692,7 +1187,8 @@ type workflowPlan struct {
 	Workflow domain.CronWorkflowSpec
 	StartID  string
 	NodeByID map[string]domain.CronWorkflowNode
-	NextByID map[string]string
+	OutEdges map[string][]domain.CronWorkflowEdge
+	InEdges  map[string][]domain.CronWorkflowEdge
 	Order    []domain.CronWorkflowNode
 }
 
@@ -726,6 +1222,14 @@ func buildWorkflowPlanWithNodeSupport(
 		node.Title = strings.TrimSpace(node.Title)
 		node.Text = strings.TrimSpace(node.Text)
 		node.IfCondition = strings.TrimSpace(node.IfCondition)
+		node.Prompt = strings.TrimSpace(node.Prompt)
+		node.ProviderID = strings.TrimSpace(node.ProviderID)
+		node.Model = strings.TrimSpace(node.Model)
+		node.PromptMode = strings.TrimSpace(node.PromptMode)
+		node.Method = strings.ToUpper(strings.TrimSpace(node.Method))
+		node.URL = strings.TrimSpace(node.URL)
+		node.Tool = strings.ToLower(strings.TrimSpace(node.Tool))
+		node.OutputVar = strings.ToLower(strings.TrimSpace(node.OutputVar))
 
 		if node.ID == "" {
 			return nil, errors.New("workflow node id is required")
@@ -737,39 +1241,56 @@ func buildWorkflowPlanWithNodeSupport(
 			return nil, fmt.Errorf("workflow node %s has unsupported type=%q", node.ID, node.Type)
 		}
 
+		node = retainWorkflowNodeFields(node)
 		switch node.Type {
 		case workflowNodeStart:
-			node.ContinueOnError = false
-			node.DelaySeconds = 0
-			node.Text = ""
-			node.IfCondition = ""
 			if startID != "" {
 				return nil, errors.New("workflow requires exactly one start node")
 			}
 			startID = node.ID
 		case workflowNodeText:
-			node.DelaySeconds = 0
-			node.IfCondition = ""
 			if node.Text == "" {
 				return nil, fmt.Errorf("workflow node %s requires non-empty text", node.ID)
 			}
 		case workflowNodeDelay:
-			node.Text = ""
-			node.IfCondition = ""
 			if node.DelaySeconds < 0 {
 				return nil, fmt.Errorf("workflow node %s delay_seconds must be greater than or equal to 0", node.ID)
 			}
 		case workflowNodeIf:
-			node.Text = ""
-			node.DelaySeconds = 0
 			if _, err := parseWorkflowIfCondition(node.IfCondition); err != nil {
 				return nil, fmt.Errorf("workflow node %s if_condition invalid: %w", node.ID, err)
 			}
+		case workflowNodeAgent:
+			if node.Prompt == "" {
+				return nil, fmt.Errorf("workflow node %s requires non-empty prompt", node.ID)
+			}
+			if (node.ProviderID == "") != (node.Model == "") {
+				return nil, fmt.Errorf("workflow node %s provider_id and model must be set together", node.ID)
+			}
+		case workflowNodeHTTP:
+			if node.Method == "" {
+				node.Method = http.MethodGet
+			}
+			if err := validateWorkflowHTTPNode(node); err != nil {
+				return nil, fmt.Errorf("workflow node %s %w", node.ID, err)
+			}
+		case workflowNodeTool:
+			if node.Tool == "" {
+				return nil, fmt.Errorf("workflow node %s requires tool", node.ID)
+			}
 		default:
 			if supportsNodeType == nil {
 				return nil, fmt.Errorf("workflow node %s has unsupported type=%q", node.ID, node.Type)
 			}
 		}
+		if node.OutputVar != "" {
+			if !workflowVarNamePattern.MatchString(node.OutputVar) {
+				return nil, fmt.Errorf("workflow node %s output_var must match [a-z_][a-z0-9_]*", node.ID)
+			}
+			if _, reserved := workflowIfAllowedFields[node.OutputVar]; reserved {
+				return nil, fmt.Errorf("workflow node %s output_var %q is reserved", node.ID, node.OutputVar)
+			}
+		}
 
 		nodeByID[node.ID] = node
 		normalizedNodes = append(normalizedNodes, node)
@@ -780,9 +1301,9 @@ func buildWorkflowPlanWithNodeSupport(
 	}
 
 	edgeIDSet := map[string]struct{}{}
-	nextByID := map[string]string{}
-	inDegree := map[string]int{}
-	outDegree := map[string]int{}
+	edgePairs := map[string]string{}
+	outEdges := map[string][]domain.CronWorkflowEdge{}
+	inEdges := map[string][]domain.CronWorkflowEdge{}
 	normalizedEdges := make([]domain.CronWorkflowEdge, 0, len(workflow.Edges))
 
 	for _, rawEdge := range workflow.Edges {
@@ -790,6 +1311,7 @@ func buildWorkflowPlanWithNodeSupport(
 		edge.ID = strings.TrimSpace(edge.ID)
 		edge.Source = strings.TrimSpace(edge.Source)
 		edge.Target = strings.TrimSpace(edge.Target)
+		edge.Condition = strings.ToLower(strings.TrimSpace(edge.Condition))
 
 		if edge.ID == "" {
 			return nil, errors.New("workflow edge id is required")
@@ -805,62 +1327,85 @@ func buildWorkflowPlanWithNodeSupport(
 		if edge.Source == edge.Target {
 			return nil, fmt.Errorf("workflow edge %s cannot link node to itself", edge.ID)
 		}
-		if _, ok := nodeByID[edge.Source]; !ok {
+		source, ok := nodeByID[edge.Source]
+		if !ok {
 			return nil, fmt.Errorf("workflow edge %s source not found: %s", edge.ID, edge.Source)
 		}
-		if _, ok := nodeByID[edge.Target]; !ok {
+		target, ok := nodeByID[edge.Target]
+		if !ok {
 			return nil, fmt.Errorf("workflow edge %s target not found: %s", edge.ID, edge.Target)
 		}
-
-		outDegree[edge.Source]++
-		if outDegree[edge.Source] > 1 {
-			return nil, fmt.Errorf("workflow node %s has more than one outgoing edge", edge.Source)
+		pair := edge.Source + "->" + edge.Target
+		if existing, exists := edgePairs[pair]; exists {
+			return nil, fmt.Errorf("workflow edges %s and %s link the same nodes", existing, edge.ID)
+		}
+		edgePairs[pair] = edge.ID
+		if edge.Condition != "" {
+			if source.Type != workflowNodeIf {
+				return nil, fmt.Errorf("workflow edge %s condition is only allowed on if_event edges", edge.ID)
+			}
+			if edge.Condition != workflowBranchTrue && edge.Condition != workflowBranchFalse {
+				return nil, fmt.Errorf("workflow edge %s condition must be true or false", edge.ID)
+			}
 		}
-		inDegree[edge.Target]++
-		if inDegree[edge.Target] > 1 {
-			return nil, fmt.Errorf("workflow node %s has more than one incoming edge", edge.Target)
+		if target.Type == workflowNodeStart {
+			return nil, errors.New("workflow start node cannot have incoming edge")
 		}
-		nextByID[edge.Source] = edge.Target
+		if len(inEdges[edge.Target]) > 0 && target.Type != workflowNodeJoin {
+			return nil, fmt.Errorf("workflow node %s has more than one incoming edge; merge branches with a join node", edge.Target)
+		}
+
+		outEdges[edge.Source] = append(outEdges[edge.Source], edge)
+		inEdges[edge.Target] = append(inEdges[edge.Target], edge)
 		normalizedEdges = append(normalizedEdges, edge)
 	}
 
-	if inDegree[startID] > 0 {
-		return nil, errors.New("workflow start node cannot have incoming edge")
-	}
-	if outDegree[startID] == 0 {
+	if len(outEdges[startID]) == 0 {
 		return nil, errors.New("workflow start node must connect to at least one executable node")
 	}
 
 	reachable := map[string]bool{startID: true}
-	order := make([]domain.CronWorkflowNode, 0, len(nodeByID)-1)
-	cursor := startID
-	for {
-		nextID, ok := nextByID[cursor]
-		if !ok {
-			break
+	queue := []string{startID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range outEdges[current] {
+			if !reachable[edge.Target] {
+				reachable[edge.Target] = true
+				queue = append(queue, edge.Target)
+			}
 		}
-		if reachable[nextID] {
-			return nil, errors.New("workflow graph must be acyclic")
+	}
+	for _, node := range normalizedNodes {
+		if node.Type != workflowNodeStart && !reachable[node.ID] {
+			return nil, fmt.Errorf("workflow node %s is not reachable from start", node.ID)
 		}
-		reachable[nextID] = true
-		nextNode := nodeByID[nextID]
-		if nextNode.Type == workflowNodeStart {
-			return nil, errors.New("workflow start node cannot be targeted by execution path")
+	}
+
+	remaining := make(map[string]int, len(normalizedNodes))
+	for _, node := range normalizedNodes {
+		remaining[node.ID] = len(inEdges[node.ID])
+	}
+	sorted := []string{startID}
+	order := make([]domain.CronWorkflowNode, 0, len(nodeByID)-1)
+	for i := 0; i < len(sorted); i++ {
+		for _, edge := range outEdges[sorted[i]] {
+			remaining[edge.Target]--
+			if remaining[edge.Target] == 0 {
+				sorted = append(sorted, edge.Target)
+				order = append(order, nodeByID[edge.Target])
+			}
 		}
-		order = append(order, nextNode)
-		cursor = nextID
+	}
+	if len(sorted) != len(nodeByID) {
+		return nil, errors.New("workflow graph must be acyclic")
 	}
 
 	if len(order) == 0 {
 		return nil, errors.New("workflow requires at least one executable node")
 	}
-	for nodeID, node := range nodeByID {
-		if node.Type == workflowNodeStart {
-			continue
-		}
-		if !reachable[nodeID] {
-			return nil, fmt.Errorf("workflow node %s is not reachable from start", nodeID)
-		}
+	if err := validateWorkflowVariableRefs(order, inEdges, nodeByID); err != nil {
+		return nil, err
 	}
 
 	var viewport *domain.CronWorkflowViewport
@@ -881,11 +1426,172 @@ func buildWorkflowPlanWithNodeSupport(
 		},
 		StartID:  startID,
 		NodeByID: nodeByID,
-		NextByID: nextByID,
+		OutEdges: outEdges,
+		InEdges:  inEdges,
 		Order:    order,
 	}, nil
 }
 
+func retainWorkflowNodeFields(node domain.CronWorkflowNode) domain.CronWorkflowNode {
+	out := domain.CronWorkflowNode{
+		ID:              node.ID,
+		Type:            node.Type,
+		Title:           node.Title,
+		X:               node.X,
+		Y:               node.Y,
+		ContinueOnError: node.ContinueOnError,
+	}
+	switch node.Type {
+	case workflowNodeStart:
+		out.ContinueOnError = false
+	case workflowNodeText:
+		out.Text = node.Text
+	case workflowNodeDelay:
+		out.DelaySeconds = node.DelaySeconds
+	case workflowNodeIf:
+		out.IfCondition = node.IfCondition
+	case workflowNodeJoin:
+	case workflowNodeAgent:
+		out.Prompt = node.Prompt
+		out.ProviderID = node.ProviderID
+		out.Model = node.Model
+		out.PromptMode = node.PromptMode
+		out.OutputVar = node.OutputVar
+	case workflowNodeHTTP:
+		out.Method = node.Method
+		out.URL = node.URL
+		out.Headers = node.Headers
+		out.Body = node.Body
+		out.TimeoutSeconds = node.TimeoutSeconds
+		out.OutputVar = node.OutputVar
+	case workflowNodeTool:
+		out.Tool = node.Tool
+		out.ToolInput = node.ToolInput
+		out.OutputVar = node.OutputVar
+	default:
+		return node
+	}
+	return out
+}
+
+func validateWorkflowHTTPNode(node domain.CronWorkflowNode) error {
+	if _, ok := workflowHTTPMethods[node.Method]; !ok {
+		return fmt.Errorf("method %q is unsupported", node.Method)
+	}
+	if node.URL == "" {
+		return errors.New("requires url")
+	}
+	if !workflowTemplatePattern.MatchString(node.URL) {
+		if err := validateWorkflowHTTPURL(node.URL); err != nil {
+			return err
+		}
+	}
+	if node.TimeoutSeconds < 0 || node.TimeoutSeconds > workflowHTTPMaxTimeoutSeconds {
+		return fmt.Errorf("timeout_seconds must be between 0 and %d", workflowHTTPMaxTimeoutSeconds)
+	}
+	for key := range node.Headers {
+		if strings.TrimSpace(key) == "" {
+			return errors.New("header names must be non-empty")
+		}
+	}
+	return nil
+}
+
+func validateWorkflowHTTPURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("url %q must be an absolute http(s) URL", raw)
+	}
+	return nil
+}
+
+func workflowNodeOutputVars(node domain.CronWorkflowNode) []string {
+	if node.OutputVar == "" {
+		return nil
+	}
+	if node.Type == workflowNodeHTTP {
+		return []string{node.OutputVar, node.OutputVar + workflowHTTPStatusVarSuffix}
+	}
+	return []string{node.OutputVar}
+}
+
+func workflowNodeTemplates(node domain.CronWorkflowNode) []string {
+	switch node.Type {
+	case workflowNodeText:
+		return []string{node.Text}
+	case workflowNodeAgent:
+		return []string{node.Prompt}
+	case workflowNodeHTTP:
+		out := []string{node.URL, node.Body}
+		for _, value := range node.Headers {
+			out = append(out, value)
+		}
+		return out
+	case workflowNodeTool:
+		out := []string{}
+		collectWorkflowTemplateStrings(node.ToolInput, &out)
+		return out
+	default:
+		return nil
+	}
+}
+
+func collectWorkflowTemplateStrings(value interface{}, out *[]string) {
+	switch item := value.(type) {
+	case string:
+		*out = append(*out, item)
+	case map[string]interface{}:
+		for _, child := range item {
+			collectWorkflowTemplateStrings(child, out)
+		}
+	case []interface{}:
+		for _, child := range item {
+			collectWorkflowTemplateStrings(child, out)
+		}
+	}
+}
+
+func validateWorkflowVariableRefs(
+	order []domain.CronWorkflowNode,
+	inEdges map[string][]domain.CronWorkflowEdge,
+	nodeByID map[string]domain.CronWorkflowNode,
+) error {
+	definedByID := map[string]map[string]struct{}{}
+	for _, node := range order {
+		defined := map[string]struct{}{}
+		for field := range workflowIfAllowedFields {
+			defined[field] = struct{}{}
+		}
+		for _, edge := range inEdges[node.ID] {
+			for name := range definedByID[edge.Source] {
+				defined[name] = struct{}{}
+			}
+			for _, name := range workflowNodeOutputVars(nodeByID[edge.Source]) {
+				defined[name] = struct{}{}
+			}
+		}
+		definedByID[node.ID] = defined
+
+		refs := []string{}
+		for _, template := range workflowNodeTemplates(node) {
+			refs = append(refs, workflowTemplateVars(template)...)
+		}
+		if node.Type == workflowNodeIf {
+			condition, err := parseWorkflowIfCondition(node.IfCondition)
+			if err != nil {
+				return fmt.Errorf("workflow node %s if_condition invalid: %w", node.ID, err)
+			}
+			refs = append(refs, condition.Field)
+		}
+		for _, name := range refs {
+			if _, ok := defined[name]; !ok {
+				return fmt.Errorf("workflow node %s references undefined variable %q", node.ID, name)
+			}
+		}
+	}
+	return nil
+}
+
 func alignStateForMutation(job domain.CronJobSpec, state domain.CronJobState, now time.Time) domain.CronJobState {
 	if !jobSchedulable(job, state) {
 		state.NextRunAt = nil
@@ -899,9 +1605,13 @@ func alignStateForMutation(job domain.CronJobSpec, state domain.CronJobState, no
 		return state
 	}
 
+	state.LastError = nil
+	if nextRunAt.IsZero() {
+		state.NextRunAt = nil
+		return state
+	}
 	nextRunAtText := nextRunAt.Format(time.RFC3339)
 	state.NextRunAt = &nextRunAtText
-	state.LastError = nil
 	return state
 }
 
@@ -917,7 +1627,8 @@ func stateEqual(a, b domain.CronJobState) bool {
 		stringPtrEqual(a.LastRunAt, b.LastRunAt) &&
 		stringPtrEqual(a.LastStatus, b.LastStatus) &&
 		stringPtrEqual(a.LastError, b.LastError) &&
-		a.Paused == b.Paused
+		a.Paused == b.Paused &&
+		a.ConsecutiveFailures == b.ConsecutiveFailures
 }
 
 func stringPtrEqual(a, b *string) bool {
@@ -953,7 +1664,7 @@ func (s *Service) tryAcquireSlot(jobID string, runtime domain.CronRuntimeSpec) (
 	}
 
 	now := time.Now().UTC()
-	ttl := time.Duration(runtime.TimeoutSeconds)*time.Second + 30*time.Second
+	ttl := resolveRetryPolicy(runtime).budget(time.Duration(runtime.TimeoutSeconds)*time.Second) + 30*time.Second
 	if ttl < 30*time.Second {
 		ttl = 30 * time.Second
 	}
@@ -1014,14 +1725,14 @@ func (s *Service) releaseSlot(slot *leaseHandle) {
 		return
 	}
 	if err != nil {
-		log.Printf("release cron lease read failed: path=%s err=%v", slot.Path, err)
+		s.log().Warn("release cron lease read failed", "path", slot.Path, "err", err)
 		return
 	}
 
 	var lease leaseSlot
 	if err := json.Unmarshal(body, &lease); err != nil {
 		if rmErr := removeIfExists(slot.Path); rmErr != nil {
-			log.Printf("release cron lease cleanup failed: path=%s err=%v", slot.Path, rmErr)
+			s.log().Warn("release cron lease cleanup failed", "path", slot.Path, "err", rmErr)
 		}
 		return
 	}
@@ -1029,7 +1740,7 @@ func (s *Service) releaseSlot(slot *leaseHandle) {
 		return
 	}
 	if err := os.Remove(slot.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
-		log.Printf("release cron lease failed: path=%s err=%v", slot.Path, err)
+		s.log().Warn("release cron lease failed", "path", slot.Path, "err", err)
 	}
 }
 
@@ -1116,13 +1827,13 @@ func runtimeSpec(job domain.CronJobSpec) domain.CronRuntimeSpec {
 func scheduleType(job domain.CronJobSpec) string {
 	t := strings.ToLower(strings.TrimSpace(job.Schedule.Type))
 	if t == "" {
-		return "interval"
+		return scheduleTypeInterval
 	}
 	return t
 }
 
 func interval(job domain.CronJobSpec) (time.Duration, error) {
-	if scheduleType(job) != "interval" {
+	if scheduleType(job) != scheduleTypeInterval {
 		return 0, fmt.Errorf("unsupported schedule.type=%q", job.Schedule.Type)
 	}
 
@@ -1145,21 +1856,40 @@ func interval(job domain.CronJobSpec) (time.Duration, error) {
 }
 
 func ResolveNextRunAt(job domain.CronJobSpec, current *string, now time.Time) (time.Time, *time.Time, error) {
+	calendar, err := newScheduleCalendar(job.Schedule)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
 	switch scheduleType(job) {
-	case "interval":
+	case scheduleTypeInterval:
 		iv, err := interval(job)
 		if err != nil {
 			return time.Time{}, nil, err
 		}
 		next, dueAt := resolveIntervalNextRunAt(current, iv, now)
-		return next, dueAt, nil
-	case "cron":
+		next, err = calendar.nextAllowed(next)
+		return next, dueAt, err
+	case scheduleTypeCron:
 		schedule, loc, err := expression(job)
 		if err != nil {
 			return time.Time{}, nil, err
 		}
 		next, dueAt := resolveExpressionNextRunAt(current, schedule, loc, now)
+		next, err = calendar.nextAllowedOccurrence(schedule, next)
+		return next, dueAt, err
+	case scheduleTypeOnce:
+		runAt, err := parseOnceRunAt(job.Schedule)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		if current == nil {
+			next, err := calendar.nextAllowed(runAt)
+			return next, nil, err
+		}
+		next, dueAt := resolveOnceNextRunAt(*current, runAt, now)
 		return next, dueAt, nil
+	case scheduleTypeManual:
+		return time.Time{}, nil, nil
 	default:
 		return time.Time{}, nil, fmt.Errorf("unsupported schedule.type=%q", job.Schedule.Type)
 	}
@@ -1171,13 +1901,9 @@ func expression(job domain.CronJobSpec) (cronv3.Schedule, *time.Location, error)
 		return nil, nil, errors.New("schedule.cron is required for cron jobs")
 	}
 
-	loc := time.UTC
-	if tz := strings.TrimSpace(job.Schedule.Timezone); tz != "" {
-		nextLoc, err := time.LoadLocation(tz)
-		if err != nil {
-			return nil, nil, fmt.Errorf("invalid schedule.timezone=%q", job.Schedule.Timezone)
-		}
-		loc = nextLoc
+	loc, err := scheduleLocation(job.Schedule)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	parser := cronv3.NewParser(cronv3.SecondOptional | cronv3.Minute | cronv3.Hour | cronv3.Dom | cronv3.Month | cronv3.Dow | cronv3.Descriptor)
@@ -1188,6 +1914,17 @@ func expression(job domain.CronJobSpec) (cronv3.Schedule, *time.Location, error)
 	return schedule, loc, nil
 }
 
+func resolveOnceNextRunAt(current string, runAt time.Time, now time.Time) (time.Time, *time.Time) {
+	parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(current))
+	if err != nil {
+		parsed = runAt
+	}
+	if parsed.After(now) {
+		return parsed, nil
+	}
+	return time.Time{}, &parsed
+}
+
 func resolveIntervalNextRunAt(current *string, interval time.Duration, now time.Time) (time.Time, *time.Time) {
 	next := now.Add(interval)
 	if current == nil {
@@ -2,15 +2,21 @@ package cron
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"nextai/apps/gateway/internal/domain"
 	"nextai/apps/gateway/internal/repo"
 	"nextai/apps/gateway/internal/service/adapters"
+	"nextai/apps/gateway/internal/service/ports"
 )
 
 func TestExecuteJobSuccessUpdatesState(t *testing.T) {
@@ -242,3 +248,1267 @@ func (h stubCronNodeHandler) Execute(
 	}
 	return h.execute(ctx, job, node)
 }
+
+type stubCronChannel struct {
+	proactive bool
+	sent      []string
+	cfg       map[string]interface{}
+}
+
+func (c *stubCronChannel) SendText(_ context.Context, _, _ string, text string, cfg map[string]interface{}) error {
+	c.sent = append(c.sent, text)
+	c.cfg = cfg
+	return nil
+}
+
+type stubProactiveCronChannel struct {
+	stubCronChannel
+}
+
+func (c *stubProactiveCronChannel) SupportsProactive() bool {
+	return c.proactive
+}
+
+func TestExecuteTextTaskRoutesQQJobsThroughAgent(t *testing.T) {
+	store, dir := newTestStore(t)
+	if err := store.Write(func(st *repo.State) error {
+		st.CronJobs["job-qq"] = domain.CronJobSpec{
+			ID:       "job-qq",
+			Name:     "job-qq",
+			TaskType: "text",
+			Text:     "daily digest",
+			Schedule: domain.CronScheduleSpec{Type: "interval", Cron: "60s"},
+			Dispatch: domain.CronDispatchSpec{
+				Channel: "qq",
+				Target:  domain.CronDispatchTarget{TargetType: "group", TargetID: "g-1", UserID: "u-1"},
+			},
+			Runtime: domain.CronRuntimeSpec{MaxConcurrency: 1, TimeoutSeconds: 5},
+		}
+		st.CronStates["job-qq"] = domain.CronJobState{}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	qq := &stubProactiveCronChannel{stubCronChannel{proactive: true}}
+	var gotJob domain.CronJobSpec
+	var gotChannel string
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return qq, map[string]interface{}{}, name, nil
+			},
+		},
+		ExecuteAgentTask: func(_ context.Context, job domain.CronJobSpec, channel string, _ string) (string, error) {
+			gotJob = job
+			gotChannel = channel
+			return "", nil
+		},
+	})
+
+	if err := svc.ExecuteJob("job-qq"); err != nil {
+		t.Fatalf("execute job failed: %v", err)
+	}
+	target := gotJob.Dispatch.Target
+	if gotChannel != "qq" || target.UserID != "u-1" || target.SessionID != "qq:group:g-1:u-1" {
+		t.Fatalf("unexpected agent dispatch channel=%q target=%+v", gotChannel, target)
+	}
+
+	if err := store.Write(func(st *repo.State) error {
+		job := st.CronJobs["job-qq"]
+		job.Dispatch.Mode = "text"
+		st.CronJobs["job-qq"] = job
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ExecuteJob("job-qq"); err != nil {
+		t.Fatalf("execute text job failed: %v", err)
+	}
+	if len(qq.sent) != 1 || qq.sent[0] != "daily digest" || qq.cfg["target_type"] != "group" || qq.cfg["target_id"] != "g-1" {
+		t.Fatalf("unexpected direct send sent=%v cfg=%v", qq.sent, qq.cfg)
+	}
+}
+
+func TestValidateDispatchSpecRequiresQQGroupMemberForAgentMode(t *testing.T) {
+	dispatch := domain.CronDispatchSpec{
+		Channel: "qq",
+		Target:  domain.CronDispatchTarget{TargetType: "group", TargetID: "g-1"},
+	}
+	if err := validateDispatchSpec(&dispatch); err == nil || !strings.Contains(err.Error(), "user_id") {
+		t.Fatalf("expected missing user_id error, got=%v", err)
+	}
+	dispatch.Mode = dispatchModeText
+	if err := validateDispatchSpec(&dispatch); err != nil {
+		t.Fatalf("text dispatch needs no member: %v", err)
+	}
+}
+
+func TestExecuteTextTaskRejectsAgentModeOnNonProactiveChannel(t *testing.T) {
+	store, dir := newTestStore(t)
+	seedTestJob(t, store, "job-agent", domain.CronRuntimeSpec{MaxConcurrency: 1, TimeoutSeconds: 5})
+	if err := store.Write(func(st *repo.State) error {
+		job := st.CronJobs["job-agent"]
+		job.Dispatch.Channel = "custom"
+		job.Dispatch.Mode = "agent"
+		st.CronJobs["job-agent"] = job
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return &stubCronChannel{}, map[string]interface{}{}, name, nil
+			},
+		},
+		ExecuteAgentTask: func(context.Context, domain.CronJobSpec, string, string) (string, error) {
+			t.Fatal("agent executor should not run")
+			return "", nil
+		},
+	})
+
+	err := svc.ExecuteJob("job-agent")
+	if err == nil || !strings.Contains(err.Error(), "cannot send proactive messages") {
+		t.Fatalf("expected proactive capability error, got=%v", err)
+	}
+}
+
+func TestExecuteWorkflowTaskThreadsAgentPromptOutput(t *testing.T) {
+	webhook := &stubCronChannel{}
+	var gotPrompt AgentPrompt
+	svc := NewService(Dependencies{
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return webhook, map[string]interface{}{}, name, nil
+			},
+		},
+		ExecuteAgentPrompt: func(_ context.Context, _ domain.CronJobSpec, prompt AgentPrompt) (string, error) {
+			gotPrompt = prompt
+			return " disk check: ERROR on /var ", nil
+		},
+	})
+	job := domain.CronJobSpec{
+		ID:       "job-wf",
+		Name:     "job-wf",
+		TaskType: "workflow",
+		Dispatch: domain.CronDispatchSpec{Channel: "webhook"},
+		Workflow: &domain.CronWorkflowSpec{
+			Version: "v1",
+			Nodes: []domain.CronWorkflowNode{
+				{ID: "start", Type: "start"},
+				{ID: "check", Type: "agent_prompt", Prompt: "check disks for {{job_name}}", ProviderID: "openai", Model: "gpt-4o-mini", OutputVar: "Summary"},
+				{ID: "gate", Type: "if_event", IfCondition: `summary contains "ERROR"`},
+				{ID: "notify", Type: "text_event", Text: "alert: {{ summary }}"},
+			},
+			Edges: []domain.CronWorkflowEdge{
+				{ID: "e1", Source: "start", Target: "check"},
+				{ID: "e2", Source: "check", Target: "gate"},
+				{ID: "e3", Source: "gate", Target: "notify"},
+			},
+		},
+	}
+	if code, err := svc.validateJobSpec(&job); err != nil {
+		t.Fatalf("validate job failed: code=%s err=%v", code, err)
+	}
+
+	execution, err := svc.executeWorkflowTask(context.Background(), job)
+	if err != nil {
+		t.Fatalf("execute workflow failed: %v", err)
+	}
+	if gotPrompt.NodeID != "check" || gotPrompt.Prompt != "check disks for job-wf" || gotPrompt.Model != "gpt-4o-mini" {
+		t.Fatalf("unexpected agent prompt: %+v", gotPrompt)
+	}
+	if len(webhook.sent) != 1 || webhook.sent[0] != "alert: disk check: ERROR on /var" {
+		t.Fatalf("unexpected dispatched text: %v", webhook.sent)
+	}
+	if len(execution.Nodes) != 3 || execution.Nodes[2].Status != statusSucceeded {
+		t.Fatalf("unexpected execution: %+v", execution.Nodes)
+	}
+}
+
+func TestExecuteWorkflowTaskScopesAgentPromptToRun(t *testing.T) {
+	var runIDs []string
+	svc := NewService(Dependencies{
+		ExecuteAgentPrompt: func(_ context.Context, _ domain.CronJobSpec, prompt AgentPrompt) (string, error) {
+			runIDs = append(runIDs, prompt.RunID)
+			return "ok", nil
+		},
+	})
+	job := domain.CronJobSpec{
+		ID:       "job-wf",
+		TaskType: "workflow",
+		Workflow: &domain.CronWorkflowSpec{
+			Version: "v1",
+			Nodes: []domain.CronWorkflowNode{
+				{ID: "start", Type: "start"},
+				{ID: "check", Type: "agent_prompt", Prompt: "check disks"},
+			},
+			Edges: []domain.CronWorkflowEdge{{ID: "e1", Source: "start", Target: "check"}},
+		},
+	}
+	var executions []string
+	for i := 0; i < 2; i++ {
+		execution, err := svc.executeWorkflowTask(context.Background(), job)
+		if err != nil {
+			t.Fatalf("execute workflow failed: %v", err)
+		}
+		executions = append(executions, execution.RunID)
+	}
+	if len(runIDs) != 2 || runIDs[0] != executions[0] || runIDs[1] != executions[1] || runIDs[0] == runIDs[1] {
+		t.Fatalf("expected each run to pass its own run id, got=%v executions=%v", runIDs, executions)
+	}
+}
+
+func TestBuildWorkflowPlanRejectsUndefinedVariable(t *testing.T) {
+	svc := NewService(Dependencies{})
+	_, err := svc.buildWorkflowPlan(&domain.CronWorkflowSpec{
+		Version: "v1",
+		Nodes: []domain.CronWorkflowNode{
+			{ID: "start", Type: "start"},
+			{ID: "gate", Type: "if_event", IfCondition: `summary contains "ERROR"`},
+			{ID: "check", Type: "agent_prompt", Prompt: "check", OutputVar: "summary"},
+		},
+		Edges: []domain.CronWorkflowEdge{
+			{ID: "e1", Source: "start", Target: "gate"},
+			{ID: "e2", Source: "gate", Target: "check"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), `undefined variable "summary"`) {
+		t.Fatalf("expected undefined variable error, got=%v", err)
+	}
+}
+
+func TestExecuteWorkflowTaskHTTPRequestFeedsToolCall(t *testing.T) {
+	var gotAuth, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		payload, _ := io.ReadAll(r.Body)
+		gotBody = string(payload)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("maintenance"))
+	}))
+	defer server.Close()
+
+	var gotTool string
+	var gotInput map[string]interface{}
+	svc := NewService(Dependencies{
+		ExecuteToolCall: func(_ context.Context, name string, input map[string]interface{}) (string, error) {
+			gotTool = name
+			gotInput = input
+			return "diagnosed", nil
+		},
+	})
+	job := domain.CronJobSpec{
+		ID:       "job-http",
+		Name:     "job-http",
+		TaskType: "workflow",
+		Workflow: &domain.CronWorkflowSpec{
+			Version: "v1",
+			Nodes: []domain.CronWorkflowNode{
+				{ID: "start", Type: "start"},
+				{
+					ID:        "probe",
+					Type:      "http_request",
+					Method:    "post",
+					URL:       server.URL + "/health",
+					Headers:   map[string]string{"Authorization": "Bearer {{job_id}}"},
+					Body:      `{"job":"{{job_name}}"}`,
+					OutputVar: "api",
+				},
+				{ID: "gate", Type: "if_event", IfCondition: `api_status != "200"`},
+				{
+					ID:        "diagnose",
+					Type:      "tool_call",
+					Tool:      "Shell",
+					ToolInput: map[string]interface{}{"command": "echo {{api_status}} {{api}}"},
+					OutputVar: "diagnosis",
+				},
+			},
+			Edges: []domain.CronWorkflowEdge{
+				{ID: "e1", Source: "start", Target: "probe"},
+				{ID: "e2", Source: "probe", Target: "gate"},
+				{ID: "e3", Source: "gate", Target: "diagnose"},
+			},
+		},
+	}
+	if code, err := svc.validateJobSpec(&job); err != nil {
+		t.Fatalf("validate job failed: code=%s err=%v", code, err)
+	}
+
+	execution, err := svc.executeWorkflowTask(context.Background(), job)
+	if err != nil {
+		t.Fatalf("execute workflow failed: %v", err)
+	}
+	if gotAuth != "Bearer job-http" || gotBody != `{"job":"job-http"}` {
+		t.Fatalf("unexpected request auth=%q body=%q", gotAuth, gotBody)
+	}
+	if gotTool != "shell" || gotInput["command"] != "echo 503 maintenance" {
+		t.Fatalf("unexpected tool call name=%q input=%v", gotTool, gotInput)
+	}
+	probe := execution.Nodes[0]
+	if probe.Outputs["api_status"] != "503" || probe.Outputs["api"] != "maintenance" {
+		t.Fatalf("unexpected probe outputs: %v", probe.Outputs)
+	}
+	if execution.Nodes[2].Outputs["diagnosis"] != "diagnosed" {
+		t.Fatalf("unexpected tool outputs: %v", execution.Nodes[2].Outputs)
+	}
+}
+
+func TestExecuteWorkflowTaskHTTPRequestTimeoutContinues(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	svc := NewService(Dependencies{})
+	svc.RegisterCronNodeHandler(&httpRequestWorkflowNodeHandler{client: &http.Client{}})
+	job := domain.CronJobSpec{
+		ID:       "job-timeout",
+		Name:     "job-timeout",
+		TaskType: "workflow",
+		Workflow: &domain.CronWorkflowSpec{
+			Version: "v1",
+			Nodes: []domain.CronWorkflowNode{
+				{ID: "start", Type: "start"},
+				{ID: "probe", Type: "http_request", URL: server.URL, TimeoutSeconds: 1, OutputVar: "api", ContinueOnError: true},
+				{ID: "gate", Type: "if_event", IfCondition: `api_status == "0"`},
+			},
+			Edges: []domain.CronWorkflowEdge{
+				{ID: "e1", Source: "start", Target: "probe"},
+				{ID: "e2", Source: "probe", Target: "gate"},
+			},
+		},
+	}
+	if code, err := svc.validateJobSpec(&job); err != nil {
+		t.Fatalf("validate job failed: code=%s err=%v", code, err)
+	}
+
+	execution, err := svc.executeWorkflowTask(context.Background(), job)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got=%v", err)
+	}
+	if len(execution.Nodes) != 2 || execution.Nodes[0].Status != statusFailed || execution.Nodes[1].Status != statusSucceeded {
+		t.Fatalf("unexpected execution: %+v", execution.Nodes)
+	}
+}
+
+func TestExecuteWorkflowTaskFollowsIfBranchesIntoJoin(t *testing.T) {
+	channel := &stubCronChannel{}
+	svc := NewService(Dependencies{
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return channel, map[string]interface{}{}, name, nil
+			},
+		},
+	})
+	job := domain.CronJobSpec{
+		ID:       "job-branch",
+		Name:     "job-branch",
+		TaskType: "workflow",
+		Dispatch: domain.CronDispatchSpec{Channel: "webhook"},
+		Workflow: &domain.CronWorkflowSpec{
+			Version: "v1",
+			Nodes: []domain.CronWorkflowNode{
+				{ID: "start", Type: "start"},
+				{ID: "gate", Type: "if_event", IfCondition: `job_id == "job-other"`},
+				{ID: "yes", Type: "text_event", Text: "matched"},
+				{ID: "no", Type: "text_event", Text: "not matched"},
+				{ID: "merge", Type: "join"},
+				{ID: "done", Type: "text_event", Text: "done"},
+			},
+			Edges: []domain.CronWorkflowEdge{
+				{ID: "e1", Source: "start", Target: "gate"},
+				{ID: "e2", Source: "gate", Target: "yes", Condition: "true"},
+				{ID: "e3", Source: "gate", Target: "no", Condition: "FALSE"},
+				{ID: "e4", Source: "yes", Target: "merge"},
+				{ID: "e5", Source: "no", Target: "merge"},
+				{ID: "e6", Source: "merge", Target: "done"},
+			},
+		},
+	}
+	if code, err := svc.validateJobSpec(&job); err != nil {
+		t.Fatalf("validate job failed: code=%s err=%v", code, err)
+	}
+
+	execution, err := svc.executeWorkflowTask(context.Background(), job)
+	if err != nil {
+		t.Fatalf("execute workflow failed: %v", err)
+	}
+	if strings.Join(channel.sent, "|") != "not matched|done" {
+		t.Fatalf("unexpected dispatched texts: %v", channel.sent)
+	}
+	statuses := map[string]string{}
+	for _, step := range execution.Nodes {
+		statuses[step.NodeID] = step.Status
+		if step.NodeID == "gate" && step.Branch != "false" {
+			t.Fatalf("expected gate branch=false, got=%q", step.Branch)
+		}
+	}
+	if len(execution.Nodes) != 5 || statuses["yes"] != workflowNodeExecutionSkipped || statuses["merge"] != statusSucceeded {
+		t.Fatalf("unexpected execution: %+v", execution.Nodes)
+	}
+}
+
+func TestExecuteWorkflowTaskRunsParallelBranchesIndependently(t *testing.T) {
+	svc := NewService(Dependencies{})
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	svc.RegisterCronNodeHandler(stubCronNodeHandler{
+		nodeType: "rendezvous",
+		execute: func(context.Context, domain.CronJobSpec, domain.CronWorkflowNode) (CronNodeResult, error) {
+			arrived.Done()
+			done := make(chan struct{})
+			go func() {
+				arrived.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return CronNodeResult{}, nil
+			case <-time.After(2 * time.Second):
+				return CronNodeResult{}, errors.New("branches did not run in parallel")
+			}
+		},
+	})
+	ran := map[string]bool{}
+	var ranMu sync.Mutex
+	svc.RegisterCronNodeHandler(stubCronNodeHandler{
+		nodeType: "record",
+		execute: func(_ context.Context, _ domain.CronJobSpec, node domain.CronWorkflowNode) (CronNodeResult, error) {
+			ranMu.Lock()
+			ran[node.ID] = true
+			ranMu.Unlock()
+			if node.Title == "fail" {
+				return CronNodeResult{}, errors.New("boom")
+			}
+			return CronNodeResult{}, nil
+		},
+	})
+
+	execution, err := svc.executeWorkflowTask(context.Background(), domain.CronJobSpec{
+		ID:       "job-parallel",
+		TaskType: "workflow",
+		Workflow: &domain.CronWorkflowSpec{
+			Version: "v1",
+			Nodes: []domain.CronWorkflowNode{
+				{ID: "start", Type: "start"},
+				{ID: "a1", Type: "rendezvous"},
+				{ID: "b1", Type: "rendezvous"},
+				{ID: "a2", Type: "record", Title: "fail"},
+				{ID: "a3", Type: "record"},
+				{ID: "merge", Type: "join"},
+				{ID: "after", Type: "record"},
+			},
+			Edges: []domain.CronWorkflowEdge{
+				{ID: "e1", Source: "start", Target: "a1"},
+				{ID: "e2", Source: "start", Target: "b1"},
+				{ID: "e3", Source: "a1", Target: "a2"},
+				{ID: "e4", Source: "a2", Target: "a3"},
+				{ID: "e5", Source: "a3", Target: "merge"},
+				{ID: "e6", Source: "b1", Target: "merge"},
+				{ID: "e7", Source: "merge", Target: "after"},
+			},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "workflow node a2 failed") {
+		t.Fatalf("expected a2 failure, got=%v", err)
+	}
+	statuses := map[string]string{}
+	for _, step := range execution.Nodes {
+		statuses[step.NodeID] = step.Status
+	}
+	if statuses["a1"] != statusSucceeded || statuses["b1"] != statusSucceeded {
+		t.Fatalf("expected parallel branches to succeed: %v", statuses)
+	}
+	if statuses["a3"] != workflowNodeExecutionSkipped || ran["a3"] {
+		t.Fatalf("expected failed branch to stop: %v", statuses)
+	}
+	if statuses["merge"] != statusSucceeded || !ran["after"] {
+		t.Fatalf("expected join to continue with surviving branch: %v", statuses)
+	}
+}
+
+func TestBuildWorkflowPlanRejectsCycleThroughJoin(t *testing.T) {
+	svc := NewService(Dependencies{})
+	_, err := svc.buildWorkflowPlan(&domain.CronWorkflowSpec{
+		Version: "v1",
+		Nodes: []domain.CronWorkflowNode{
+			{ID: "start", Type: "start"},
+			{ID: "merge", Type: "join"},
+			{ID: "loop", Type: "delay"},
+		},
+		Edges: []domain.CronWorkflowEdge{
+			{ID: "e1", Source: "start", Target: "merge"},
+			{ID: "e2", Source: "merge", Target: "loop"},
+			{ID: "e3", Source: "loop", Target: "merge"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "acyclic") {
+		t.Fatalf("expected cycle rejection, got=%v", err)
+	}
+}
+
+func TestExecuteJobRecordsRunHistoryWithRetention(t *testing.T) {
+	store, dir := newTestStore(t)
+	seedTestJob(t, store, "job-history", domain.CronRuntimeSpec{MaxConcurrency: 1, TimeoutSeconds: 5})
+	if err := store.Write(func(st *repo.State) error {
+		job := st.CronJobs["job-history"]
+		job.Dispatch.Channel = "webhook"
+		st.CronJobs["job-history"] = job
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	fail := false
+	webhook := &stubCronChannel{}
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				if fail {
+					return nil, nil, "", errors.New("channel offline")
+				}
+				return webhook, map[string]interface{}{}, name, nil
+			},
+		},
+		RunHistoryLimit: 2,
+	})
+
+	if err := svc.ExecuteJobWithTrigger("job-history", TriggerSchedule); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	fail = true
+	if err := svc.ExecuteJob("job-history"); err == nil {
+		t.Fatal("expected second run to fail")
+	}
+	if err := svc.ExecuteJob("job-history"); err == nil {
+		t.Fatal("expected third run to fail")
+	}
+
+	list, err := svc.ListRuns("job-history", RunQuery{})
+	if err != nil {
+		t.Fatalf("list runs failed: %v", err)
+	}
+	if list.Total != 2 || len(list.Runs) != 2 {
+		t.Fatalf("expected retention to keep 2 runs, got=%+v", list)
+	}
+	latest := list.Runs[0]
+	if latest.Status != statusFailed || latest.Trigger != TriggerManual || latest.Logs != nil {
+		t.Fatalf("unexpected latest run summary: %+v", latest)
+	}
+
+	run, err := svc.GetRun("job-history", latest.RunID)
+	if err != nil {
+		t.Fatalf("get run failed: %v", err)
+	}
+	if run.Error == nil || !strings.Contains(*run.Error, "channel offline") || len(run.Logs) < 2 || run.FinishedAt == nil {
+		t.Fatalf("unexpected run detail: %+v", run)
+	}
+
+	fail = false
+	if err := svc.ExecuteJobWithTrigger("job-history", TriggerSchedule); err != nil {
+		t.Fatalf("fourth run failed: %v", err)
+	}
+	succeeded, err := svc.ListRuns("job-history", RunQuery{Status: "succeeded"})
+	if err != nil {
+		t.Fatalf("list succeeded runs failed: %v", err)
+	}
+	if succeeded.Total != 1 || succeeded.Runs[0].Trigger != TriggerSchedule || succeeded.Runs[0].Reply != "hello" {
+		t.Fatalf("unexpected succeeded runs: %+v", succeeded)
+	}
+	if _, err := svc.GetRun("job-history", "missing"); !errors.Is(err, ErrRunNotFound) {
+		t.Fatalf("expected ErrRunNotFound, got=%v", err)
+	}
+
+	if _, err := svc.DeleteJob("job-history"); err != nil {
+		t.Fatalf("delete job failed: %v", err)
+	}
+	if _, err := os.Stat(svc.history.path("job-history")); !os.IsNotExist(err) {
+		t.Fatalf("expected run history to be removed, stat err=%v", err)
+	}
+}
+
+type flakyCronChannel struct {
+	mu       sync.Mutex
+	failures int
+	sent     []string
+}
+
+func (c *flakyCronChannel) SendText(_ context.Context, _, _ string, text string, _ map[string]interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures != 0 {
+		c.failures--
+		return errors.New("upstream unavailable")
+	}
+	c.sent = append(c.sent, text)
+	return nil
+}
+
+func TestExecuteJobRetriesAndNotifiesAfterConsecutiveFailures(t *testing.T) {
+	store, dir := newTestStore(t)
+	notices := make(chan map[string]interface{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode notification failed: %v", err)
+		}
+		payload["auth"] = r.Header.Get("X-Token")
+		notices <- payload
+	}))
+	defer server.Close()
+
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+	})
+	if _, err := svc.CreateJob(domain.CronJobSpec{
+		ID:       "job-bad-retry",
+		Name:     "job-bad-retry",
+		TaskType: "text",
+		Text:     "hello",
+		Runtime:  domain.CronRuntimeSpec{Retry: &domain.CronRetryPolicy{MaxAttempts: 2, RetryOn: []string{"sometimes"}}},
+	}); err == nil || !strings.Contains(err.Error(), "retry_on") {
+		t.Fatalf("expected invalid retry_on error, got=%v", err)
+	}
+
+	seedTestJob(t, store, "job-retry", domain.CronRuntimeSpec{
+		MaxConcurrency: 1,
+		TimeoutSeconds: 5,
+		Retry:          &domain.CronRetryPolicy{MaxAttempts: 3},
+		OnFailure: &domain.CronFailureNotifySpec{
+			WebhookURL:               server.URL,
+			WebhookHeaders:           map[string]string{"X-Token": "secret"},
+			AfterConsecutiveFailures: 2,
+		},
+	})
+	if err := store.Write(func(st *repo.State) error {
+		job := st.CronJobs["job-retry"]
+		job.Dispatch.Channel = "webhook"
+		st.CronJobs["job-retry"] = job
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	channel := &flakyCronChannel{failures: 2}
+	svc = NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return channel, map[string]interface{}{}, name, nil
+			},
+		},
+	})
+
+	if err := svc.ExecuteJob("job-retry"); err != nil {
+		t.Fatalf("expected third attempt to succeed, got=%v", err)
+	}
+	list, err := svc.ListRuns("job-retry", RunQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Runs[0].Attempts != 3 || list.Runs[0].Status != statusSucceeded || len(channel.sent) != 1 {
+		t.Fatalf("unexpected retried run=%+v sent=%v", list.Runs[0], channel.sent)
+	}
+
+	channel.failures = -1
+	for i := 0; i < 2; i++ {
+		if err := svc.ExecuteJob("job-retry"); err == nil {
+			t.Fatalf("expected run %d to fail", i)
+		}
+	}
+	state := readState(t, store, "job-retry")
+	if state.ConsecutiveFailures != 2 {
+		t.Fatalf("expected 2 consecutive failures, got=%d", state.ConsecutiveFailures)
+	}
+	if len(notices) != 1 {
+		t.Fatalf("expected one notification after the second failure, got=%d", len(notices))
+	}
+	notice := <-notices
+	if notice["kind"] != "failed" || notice["consecutive_failures"] != float64(2) || notice["attempts"] != float64(3) || notice["auth"] != "secret" {
+		t.Fatalf("unexpected notification payload: %+v", notice)
+	}
+	if text, _ := notice["text"].(string); !strings.Contains(text, "failed to dispatch cron job") {
+		t.Fatalf("expected error summary in notification text, got=%q", text)
+	}
+
+	channel.failures = 0
+	if err := svc.ExecuteJob("job-retry"); err != nil {
+		t.Fatalf("expected recovery run to succeed, got=%v", err)
+	}
+	if state := readState(t, store, "job-retry"); state.ConsecutiveFailures != 0 {
+		t.Fatalf("expected consecutive failures reset, got=%d", state.ConsecutiveFailures)
+	}
+}
+
+type failOnceCronChannel struct {
+	mu     sync.Mutex
+	failOn string
+	sent   []string
+}
+
+func (c *failOnceCronChannel) SendText(_ context.Context, _, _ string, text string, _ map[string]interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if text == c.failOn {
+		c.failOn = ""
+		return errors.New("upstream unavailable")
+	}
+	c.sent = append(c.sent, text)
+	return nil
+}
+
+func TestExecuteJobRetryResumesWorkflowAfterSucceededNodes(t *testing.T) {
+	store, dir := newTestStore(t)
+	seedTestJob(t, store, "job-resume", domain.CronRuntimeSpec{
+		MaxConcurrency: 1,
+		TimeoutSeconds: 5,
+		Retry:          &domain.CronRetryPolicy{MaxAttempts: 2, RetryOn: []string{RetryClassWorkflow}},
+	})
+	if err := store.Write(func(st *repo.State) error {
+		job := st.CronJobs["job-resume"]
+		job.TaskType = "workflow"
+		job.Dispatch.Channel = "webhook"
+		job.Workflow = &domain.CronWorkflowSpec{
+			Version: "v1",
+			Nodes: []domain.CronWorkflowNode{
+				{ID: "start", Type: "start"},
+				{ID: "first", Type: "text_event", Text: "first"},
+				{ID: "second", Type: "text_event", Text: "second"},
+			},
+			Edges: []domain.CronWorkflowEdge{
+				{ID: "e1", Source: "start", Target: "first"},
+				{ID: "e2", Source: "first", Target: "second"},
+			},
+		}
+		st.CronJobs["job-resume"] = job
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	channel := &failOnceCronChannel{failOn: "second"}
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return channel, map[string]interface{}{}, name, nil
+			},
+		},
+	})
+
+	if err := svc.ExecuteJob("job-resume"); err != nil {
+		t.Fatalf("expected retry to succeed, got=%v", err)
+	}
+	if strings.Join(channel.sent, "|") != "first|second" {
+		t.Fatalf("expected succeeded node to be sent once, got=%v", channel.sent)
+	}
+	state := readState(t, store, "job-resume")
+	resumed := map[string]bool{}
+	for _, step := range state.LastExecution.Nodes {
+		resumed[step.NodeID] = step.Resumed
+	}
+	if !resumed["first"] || resumed["second"] {
+		t.Fatalf("unexpected resumed nodes: %+v", state.LastExecution.Nodes)
+	}
+}
+
+func TestExecuteJobAbandonsRetryBackoffOnStop(t *testing.T) {
+	store, dir := newTestStore(t)
+	seedTestJob(t, store, "job-stop", domain.CronRuntimeSpec{
+		MaxConcurrency: 1,
+		TimeoutSeconds: 5,
+		Retry:          &domain.CronRetryPolicy{MaxAttempts: 3, BackoffSeconds: 3600},
+	})
+	stop := make(chan struct{})
+	channel := &flakyCronChannel{failures: -1}
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+		Stop:    stop,
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return channel, map[string]interface{}{}, name, nil
+			},
+		},
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- svc.ExecuteJob("job-stop") }()
+	close(stop)
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected abandoned run to report the last attempt error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry backoff did not observe stop")
+	}
+}
+
+func TestSchedulerTickNotifiesOnMisfire(t *testing.T) {
+	store, dir := newTestStore(t)
+	seedTestJob(t, store, "job-misfire", domain.CronRuntimeSpec{
+		MaxConcurrency:      1,
+		TimeoutSeconds:      5,
+		MisfireGraceSeconds: 1,
+		OnFailure: &domain.CronFailureNotifySpec{
+			Channel: "console",
+			Target:  domain.CronDispatchTarget{UserID: "ops", SessionID: "alerts"},
+		},
+	})
+	now := time.Now().UTC()
+	dueAt := now.Add(-10 * time.Minute).Format(time.RFC3339)
+	if err := store.Write(func(st *repo.State) error {
+		job := st.CronJobs["job-misfire"]
+		job.Enabled = true
+		st.CronJobs["job-misfire"] = job
+		st.CronStates["job-misfire"] = domain.CronJobState{NextRunAt: &dueAt}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := make(chan string, 1)
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return channelFunc(func(userID, sessionID, text string) {
+					sent <- name + "|" + userID + "|" + sessionID + "|" + text
+				}), map[string]interface{}{}, name, nil
+			},
+		},
+	})
+
+	due, err := svc.SchedulerTick(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 0 {
+		t.Fatalf("expected misfired job not to run, got=%v", due)
+	}
+	select {
+	case got := <-sent:
+		if !strings.HasPrefix(got, "console|ops|alerts|") || !strings.Contains(got, "missed its schedule") {
+			t.Fatalf("unexpected misfire notification: %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("misfire notification was not sent")
+	}
+	if state := readState(t, store, "job-misfire"); state.ConsecutiveFailures != 1 {
+		t.Fatalf("expected misfire to count as a failure, got=%d", state.ConsecutiveFailures)
+	}
+}
+
+type channelFunc func(userID, sessionID, text string)
+
+func (f channelFunc) SendText(_ context.Context, userID, sessionID, text string, _ map[string]interface{}) error {
+	f(userID, sessionID, text)
+	return nil
+}
+
+func TestSchedulerTickRunsOnceJobThenDisablesIt(t *testing.T) {
+	store, dir := newTestStore(t)
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+	})
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	if _, err := svc.CreateJob(domain.CronJobSpec{
+		ID:       "job-once-bad",
+		Name:     "job-once-bad",
+		Enabled:  true,
+		TaskType: "text",
+		Text:     "hi",
+		Schedule: domain.CronScheduleSpec{Type: "once", RunAt: "tomorrow 9am"},
+	}); err == nil || !strings.Contains(err.Error(), "run_at") {
+		t.Fatalf("expected invalid run_at error, got=%v", err)
+	}
+
+	runAt := now.Add(time.Hour).Format(time.RFC3339)
+	seedTestJob(t, store, "job-once", domain.CronRuntimeSpec{})
+	if err := store.Write(func(st *repo.State) error {
+		job := st.CronJobs["job-once"]
+		job.Enabled = true
+		job.Schedule = domain.CronScheduleSpec{Type: "once", RunAt: runAt}
+		st.CronJobs["job-once"] = job
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if due, err := svc.SchedulerTick(now); err != nil || len(due) != 0 {
+		t.Fatalf("expected no due jobs before run_at, due=%v err=%v", due, err)
+	}
+	if state := readState(t, store, "job-once"); state.NextRunAt == nil || *state.NextRunAt != runAt {
+		t.Fatalf("expected next_run_at=%s, got=%v", runAt, state.NextRunAt)
+	}
+	due, err := svc.SchedulerTick(now.Add(time.Hour + time.Second))
+	if err != nil || len(due) != 1 || due[0] != "job-once" {
+		t.Fatalf("expected once job to be due, due=%v err=%v", due, err)
+	}
+	view, err := svc.GetJob("job-once")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Spec.Enabled || view.State.NextRunAt != nil {
+		t.Fatalf("expected once job to be disabled after firing, got spec.enabled=%v next=%v", view.Spec.Enabled, view.State.NextRunAt)
+	}
+	if due, _ := svc.SchedulerTick(now.Add(2 * time.Hour)); len(due) != 0 {
+		t.Fatalf("expected once job not to fire again, got=%v", due)
+	}
+}
+
+func TestResolveNextRunAtSkipsBlackoutsAndHolidays(t *testing.T) {
+	job := domain.CronJobSpec{
+		Schedule: domain.CronScheduleSpec{
+			Type:     "cron",
+			Cron:     "0 * * * *",
+			Timezone: "Asia/Shanghai",
+			Blackouts: []domain.CronBlackoutWindow{
+				{Start: "22:00", End: "08:00"},
+				{Start: "12:00", End: "14:00", Weekdays: []string{"mon"}},
+			},
+			Holidays: []string{"2026-03-03"},
+		},
+	}
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	cases := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{now: time.Date(2026, 3, 1, 22, 30, 0, 0, loc), want: time.Date(2026, 3, 2, 8, 0, 0, 0, loc)},
+		{now: time.Date(2026, 3, 2, 11, 30, 0, 0, loc), want: time.Date(2026, 3, 2, 14, 0, 0, 0, loc)},
+		{now: time.Date(2026, 3, 2, 21, 30, 0, 0, loc), want: time.Date(2026, 3, 4, 8, 0, 0, 0, loc)},
+	}
+	for _, tc := range cases {
+		next, _, err := ResolveNextRunAt(job, nil, tc.now)
+		if err != nil {
+			t.Fatalf("resolve next run failed: %v", err)
+		}
+		if !next.Equal(tc.want) {
+			t.Fatalf("now=%s expected next=%s, got=%s", tc.now, tc.want, next.In(loc))
+		}
+	}
+
+	store, dir := newTestStore(t)
+	svc := NewService(Dependencies{Store: adapters.NewRepoStateStore(store), DataDir: dir})
+	job.Schedule.Holidays = []string{"13-01"}
+	if _, err := svc.CreateJob(domain.CronJobSpec{ID: "bad", Name: "bad", TaskType: "text", Text: "x", Schedule: job.Schedule}); err == nil || !strings.Contains(err.Error(), "holidays") {
+		t.Fatalf("expected invalid holiday error, got=%v", err)
+	}
+}
+
+func TestCreateReminderTargetsChatAndEnforcesQuota(t *testing.T) {
+	store, dir := newTestStore(t)
+	svc := NewService(Dependencies{
+		Store:              adapters.NewRepoStateStore(store),
+		DataDir:            dir,
+		ReminderMaxPerUser: 2,
+	})
+	now := time.Now().UTC()
+
+	job, err := svc.CreateReminder(ReminderRequest{
+		Text:      "stand-up meeting",
+		RunAt:     now.Add(time.Hour),
+		Channel:   "qq",
+		UserID:    "u-1",
+		SessionID: "qq:group:g-1:u-1",
+	}, now)
+	if err != nil {
+		t.Fatalf("create reminder failed: %v", err)
+	}
+	target := job.Dispatch.Target
+	if scheduleType(job) != scheduleTypeOnce || !job.Enabled || job.Dispatch.Channel != "qq" ||
+		target.TargetType != "group" || target.TargetID != "g-1" || target.SessionID != "qq:group:g-1:u-1" {
+		t.Fatalf("unexpected reminder job: %+v", job)
+	}
+	if state := readState(t, store, job.ID); state.NextRunAt == nil {
+		t.Fatal("expected reminder next_run_at to be set")
+	}
+
+	if _, err := svc.CreateReminder(ReminderRequest{Text: "late", RunAt: now.Add(-time.Minute), UserID: "u-1"}, now); err == nil {
+		t.Fatal("expected past reminder to be rejected")
+	}
+	if _, err := svc.CreateReminder(ReminderRequest{Text: "second", RunAt: now.Add(2 * time.Hour), UserID: "u-1"}, now); err != nil {
+		t.Fatalf("second reminder failed: %v", err)
+	}
+	if _, err := svc.CreateReminder(ReminderRequest{Text: "third", RunAt: now.Add(3 * time.Hour), UserID: "u-1"}, now); !errors.Is(err, ErrReminderQuotaExceeded) {
+		t.Fatalf("expected quota error, got=%v", err)
+	}
+	if _, err := svc.CreateReminder(ReminderRequest{Text: "other user", RunAt: now.Add(3 * time.Hour), UserID: "u-2"}, now); err != nil {
+		t.Fatalf("expected quota to be per user, got=%v", err)
+	}
+}
+
+func TestMessageTriggerRunsWorkflowWithTriggerVars(t *testing.T) {
+	store, dir := newTestStore(t)
+	var sent []string
+	var sentTo string
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return channelFunc(func(userID, sessionID, text string) {
+					sent = append(sent, text)
+					sentTo = name + "/" + userID + "/" + sessionID
+				}), map[string]interface{}{}, name, nil
+			},
+		},
+	})
+	if _, err := svc.CreateJob(domain.CronJobSpec{
+		ID:       "job-bad-trigger",
+		Name:     "job-bad-trigger",
+		TaskType: "text",
+		Text:     "hi",
+		Schedule: domain.CronScheduleSpec{Type: "manual"},
+		Triggers: []domain.CronTriggerSpec{{Type: "message", Pattern: "(unclosed"}},
+	}); err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Fatalf("expected invalid pattern error, got=%v", err)
+	}
+
+	job, err := svc.CreateJob(domain.CronJobSpec{
+		ID:       "job-deploy",
+		Name:     "job-deploy",
+		Enabled:  true,
+		TaskType: "workflow",
+		Schedule: domain.CronScheduleSpec{Type: "manual"},
+		Dispatch: domain.CronDispatchSpec{Mode: "text"},
+		Triggers: []domain.CronTriggerSpec{
+			{Type: "message", Channel: "Webhook", Pattern: `deploy (\w+)`},
+			{Type: "webhook"},
+		},
+		Workflow: &domain.CronWorkflowSpec{
+			Version: "v1",
+			Nodes: []domain.CronWorkflowNode{
+				{ID: "start", Type: "start"},
+				{ID: "ack", Type: "text_event", Text: "{{trigger_type}}: {{trigger_match}} from {{trigger_user_id}}"},
+			},
+			Edges: []domain.CronWorkflowEdge{{ID: "e1", Source: "start", Target: "ack"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("create job failed: %v", err)
+	}
+	secret := job.Triggers[1].Secret
+	if len(secret) < triggerSecretMinLen {
+		t.Fatalf("expected generated webhook secret, got=%q", secret)
+	}
+	if state := readState(t, store, "job-deploy"); state.NextRunAt != nil {
+		t.Fatalf("expected manual job to have no next_run_at, got=%v", *state.NextRunAt)
+	}
+
+	message := TriggerEvent{Type: TriggerMessage, Channel: "console", UserID: "u1", SessionID: "s1", Text: "please deploy api now"}
+	if matched, _ := svc.MatchTriggers(message); len(matched) != 0 {
+		t.Fatalf("expected channel filter to reject console message, got=%+v", matched)
+	}
+	message.Channel = "webhook"
+	matched, err := svc.MatchTriggers(message)
+	if err != nil || len(matched) != 1 || matched[0].Event.Match != "deploy api" {
+		t.Fatalf("unexpected matches=%+v err=%v", matched, err)
+	}
+	if err := svc.ExecuteJobWithEvent(matched[0].JobID, matched[0].Event); err != nil {
+		t.Fatalf("execute triggered job failed: %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "message: deploy api from u1" || sentTo != "webhook/u1/s1" {
+		t.Fatalf("unexpected reply sent=%v to=%s", sent, sentTo)
+	}
+	list, err := svc.ListRuns("job-deploy", RunQuery{})
+	if err != nil || len(list.Runs) != 1 || list.Runs[0].Trigger != TriggerMessage {
+		t.Fatalf("unexpected runs=%+v err=%v", list.Runs, err)
+	}
+
+	job.Triggers = append([]domain.CronTriggerSpec(nil), job.Triggers...)
+	job.Triggers[1].Secret = ""
+	updated, err := svc.UpdateJob("job-deploy", job)
+	if err != nil || updated.Triggers[1].Secret != secret {
+		t.Fatalf("expected update to keep webhook secret, got=%+v err=%v", updated.Triggers, err)
+	}
+	if _, err := svc.ResolveWebhookTrigger("job-deploy", "wrong-secret-value", nil); !errors.Is(err, ErrTriggerNotFound) {
+		t.Fatalf("expected wrong secret to be rejected, got=%v", err)
+	}
+	event, err := svc.ResolveWebhookTrigger("job-deploy", secret, []byte(`{"ref":"main"}`))
+	if err != nil || event.Type != TriggerWebhook || event.vars()["trigger_body"] != `{"ref":"main"}` {
+		t.Fatalf("unexpected webhook event=%+v err=%v", event, err)
+	}
+}
+
+func TestCronFailureEmitsSystemEventForOtherJobs(t *testing.T) {
+	store, dir := newTestStore(t)
+	var emitted []TriggerEvent
+	svc := NewService(Dependencies{
+		Store:     adapters.NewRepoStateStore(store),
+		DataDir:   dir,
+		EmitEvent: func(event TriggerEvent) { emitted = append(emitted, event) },
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return &flakyCronChannel{failures: -1}, map[string]interface{}{}, name, nil
+			},
+		},
+	})
+	seedTestJob(t, store, "job-flaky", domain.CronRuntimeSpec{MaxConcurrency: 1, TimeoutSeconds: 5})
+	if err := store.Write(func(st *repo.State) error {
+		job := st.CronJobs["job-flaky"]
+		job.Dispatch.Channel = "webhook"
+		st.CronJobs["job-flaky"] = job
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateJob(domain.CronJobSpec{
+		ID:       "job-watcher",
+		Name:     "job-watcher",
+		Enabled:  true,
+		TaskType: "text",
+		Text:     "a cron job failed",
+		Schedule: domain.CronScheduleSpec{Type: "manual"},
+		Dispatch: domain.CronDispatchSpec{Channel: "webhook"},
+		Triggers: []domain.CronTriggerSpec{{Type: "event", Event: "cron_failure"}},
+	}); err != nil {
+		t.Fatalf("create watcher failed: %v", err)
+	}
+
+	if err := svc.ExecuteJob("job-flaky"); err == nil {
+		t.Fatal("expected flaky job to fail")
+	}
+	if len(emitted) != 2 || emitted[0].Event != EventCronFailure || emitted[1].Event != EventDeliveryDeadLetter || emitted[1].Text != "hello" {
+		t.Fatalf("unexpected emitted events=%+v", emitted)
+	}
+	matched, err := svc.MatchTriggers(emitted[0])
+	if err != nil || len(matched) != 1 || matched[0].JobID != "job-watcher" {
+		t.Fatalf("unexpected matches=%+v err=%v", matched, err)
+	}
+
+	emitted = nil
+	if err := svc.ExecuteJobWithEvent("job-watcher", matched[0].Event); err == nil {
+		t.Fatal("expected watcher run to fail")
+	}
+	if len(emitted) != 0 {
+		t.Fatalf("expected event-triggered failure not to emit events, got=%+v", emitted)
+	}
+	matched, _ = svc.MatchTriggers(TriggerEvent{Type: TriggerSystemEvent, Event: EventCronFailure, Source: "job-watcher"})
+	if len(matched) != 0 {
+		t.Fatalf("expected job not to trigger on its own failure, got=%+v", matched)
+	}
+}
+
+func TestPreviewScheduleListsNextFireTimesInJobTimezone(t *testing.T) {
+	store, dir := newTestStore(t)
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+	})
+	if _, err := svc.CreateJob(domain.CronJobSpec{
+		ID:       "job-weekday",
+		Name:     "job-weekday",
+		TaskType: "text",
+		Text:     "standup",
+		Schedule: domain.CronScheduleSpec{Type: "cron", Cron: "0 9 * * *", Timezone: "Asia/Shanghai", Holidays: []string{"2026-03-03"}},
+	}); err != nil {
+		t.Fatalf("create job failed: %v", err)
+	}
+
+	now := time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC)
+	preview, err := svc.PreviewSchedule("job-weekday", 3, now)
+	if err != nil {
+		t.Fatalf("preview failed: %v", err)
+	}
+	want := []string{"2026-03-04T09:00:00+08:00", "2026-03-05T09:00:00+08:00", "2026-03-06T09:00:00+08:00"}
+	if preview.Timezone != "Asia/Shanghai" || strings.Join(preview.NextRuns, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected preview=%+v", preview)
+	}
+	if _, err := svc.PreviewSchedule("missing", 3, now); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected not found, got=%v", err)
+	}
+}
+
+func TestDryRunJobStubsSideEffectNodes(t *testing.T) {
+	store, dir := newTestStore(t)
+	webhook := &stubCronChannel{}
+	toolCalls := 0
+	svc := NewService(Dependencies{
+		Store:   adapters.NewRepoStateStore(store),
+		DataDir: dir,
+		ChannelResolver: adapters.ChannelResolver{
+			ResolveChannelFunc: func(name string) (ports.Channel, map[string]interface{}, string, error) {
+				return webhook, map[string]interface{}{}, name, nil
+			},
+		},
+		ExecuteToolCall: func(context.Context, string, map[string]interface{}) (string, error) {
+			toolCalls++
+			return "", nil
+		},
+	})
+	if _, err := svc.CreateJob(domain.CronJobSpec{
+		ID:       "job-dry",
+		Name:     "job-dry",
+		TaskType: "workflow",
+		Schedule: domain.CronScheduleSpec{Type: "manual"},
+		Dispatch: domain.CronDispatchSpec{Channel: "webhook"},
+		Workflow: &domain.CronWorkflowSpec{
+			Version: "v1",
+			Nodes: []domain.CronWorkflowNode{
+				{ID: "start", Type: "start"},
+				{ID: "fetch", Type: "http_request", Method: "POST", URL: "https://example.com/{{job_id}}", Body: "{{trigger_text}}", OutputVar: "api"},
+				{ID: "gate", Type: "if_event", IfCondition: `api contains "down"`},
+				{ID: "page", Type: "tool_call", Tool: "shell", ToolInput: map[string]interface{}{"command": "echo {{api}}"}},
+				{ID: "notify", Type: "text_event", Text: "api is {{api}}"},
+			},
+			Edges: []domain.CronWorkflowEdge{
+				{ID: "e1", Source: "start", Target: "fetch"},
+				{ID: "e2", Source: "fetch", Target: "gate"},
+				{ID: "e3", Source: "gate", Target: "page", Condition: "true"},
+				{ID: "e4", Source: "gate", Target: "notify", Condition: "false"},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("create job failed: %v", err)
+	}
+
+	execution, err := svc.DryRunJob("job-dry", domain.CronDryRunRequest{
+		Outputs: map[string]string{"fetch": "api down"},
+		Trigger: &domain.CronDryRunTrigger{Type: "webhook", Text: "ping"},
+	})
+	if err != nil {
+		t.Fatalf("dry-run failed: %v", err)
+	}
+	if !execution.DryRun || len(webhook.sent) != 0 || toolCalls != 0 {
+		t.Fatalf("expected side effects to be stubbed, execution=%+v sent=%v tools=%d", execution, webhook.sent, toolCalls)
+	}
+	steps := map[string]domain.CronWorkflowNodeExecution{}
+	for _, step := range execution.Nodes {
+		steps[step.NodeID] = step
+	}
+	if !steps["fetch"].Stubbed || steps["fetch"].Preview != "POST https://example.com/job-dry\nping" || steps["fetch"].Outputs["api_status"] != "200" {
+		t.Fatalf("unexpected fetch step=%+v", steps["fetch"])
+	}
+	if steps["gate"].Stubbed || steps["gate"].Branch != "true" {
+		t.Fatalf("unexpected gate step=%+v", steps["gate"])
+	}
+	if steps["page"].Preview != `tool shell {"command":"echo api down"}` || steps["notify"].Status != workflowNodeExecutionSkipped {
+		t.Fatalf("unexpected branch steps page=%+v notify=%+v", steps["page"], steps["notify"])
+	}
+	if list, _ := svc.ListRuns("job-dry", RunQuery{}); len(list.Runs) != 0 {
+		t.Fatalf("expected dry-run not to record history, got=%+v", list.Runs)
+	}
+
+	seedTestJob(t, store, "job-text", domain.CronRuntimeSpec{})
+	var validation *ValidationError
+	if _, err := svc.DryRunJob("job-text", domain.CronDryRunRequest{}); !errors.As(err, &validation) || validation.Code != "cron_dry_run_unsupported" {
+		t.Fatalf("expected unsupported dry-run for text jobs, got=%v", err)
+	}
+}
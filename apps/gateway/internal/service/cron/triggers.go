@@ -0,0 +1,325 @@
+package cron
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/service/ports"
+)
+
+const (
+	TriggerMessage     = "message"
+	TriggerSystemEvent = "event"
+	TriggerWebhook     = "webhook"
+
+	EventProviderFailure    = "provider_failure"
+	EventCronFailure        = "cron_failure"
+	EventDeliveryDeadLetter = "delivery_dead_letter"
+
+	scheduleTypeManual = "manual"
+
+	triggerMaxPerJob        = 16
+	triggerPatternMaxLen    = 512
+	triggerSecretMinLen     = 16
+	webhookSignaturePrefix  = "sha256="
+	triggerSecretBytes      = 24
+	triggerVarMaxRunes      = 8000
+	triggerWebhookBodyLimit = 1 << 20
+)
+
+var ErrTriggerNotFound = errors.New("cron_trigger_not_found")
+var ErrTriggerInactive = errors.New("cron_trigger_inactive")
+
+var triggerEvents = map[string]struct{}{
+	EventProviderFailure:    {},
+	EventCronFailure:        {},
+	EventDeliveryDeadLetter: {},
+}
+
+type TriggerEvent struct {
+	Type      string
+	Event     string
+	Source    string
+	Channel   string
+	UserID    string
+	SessionID string
+	Text      string
+	Match     string
+	Error     string
+	Body      string
+}
+
+type TriggeredJob struct {
+	JobID string
+	Event TriggerEvent
+}
+
+type triggerEventContextKey struct{}
+
+func withTriggerEvent(ctx context.Context, event TriggerEvent) context.Context {
+	return context.WithValue(ctx, triggerEventContextKey{}, event)
+}
+
+func triggerEventFromContext(ctx context.Context) TriggerEvent {
+	event, _ := ctx.Value(triggerEventContextKey{}).(TriggerEvent)
+	return event
+}
+
+func (e TriggerEvent) vars() map[string]string {
+	return map[string]string{
+		"trigger_type":       e.Type,
+		"trigger_event":      e.Event,
+		"trigger_source":     e.Source,
+		"trigger_channel":    e.Channel,
+		"trigger_user_id":    e.UserID,
+		"trigger_session_id": e.SessionID,
+		"trigger_text":       truncateRunes(e.Text, triggerVarMaxRunes),
+		"trigger_match":      e.Match,
+		"trigger_error":      e.Error,
+		"trigger_body":       truncateRunes(e.Body, triggerVarMaxRunes),
+	}
+}
+
+func (e TriggerEvent) replyTarget(job domain.CronJobSpec) domain.CronJobSpec {
+	target := job.Dispatch.Target
+	if e.Type != TriggerMessage || e.Channel == "" || target.UserID != "" || target.SessionID != "" || target.TargetID != "" {
+		return job
+	}
+	job.Dispatch.Channel = e.Channel
+	job.Dispatch.Target = DispatchTargetFromSession(e.Channel, e.UserID, e.SessionID)
+	return job
+}
+
+func validateTriggerSpecs(triggers []domain.CronTriggerSpec) ([]domain.CronTriggerSpec, error) {
+	if len(triggers) == 0 {
+		return nil, nil
+	}
+	if len(triggers) > triggerMaxPerJob {
+		return nil, fmt.Errorf("at most %d triggers are allowed", triggerMaxPerJob)
+	}
+	out := make([]domain.CronTriggerSpec, 0, len(triggers))
+	webhooks := 0
+	for idx, raw := range triggers {
+		trigger := domain.CronTriggerSpec{
+			Type:    strings.ToLower(strings.TrimSpace(raw.Type)),
+			Channel: strings.ToLower(strings.TrimSpace(raw.Channel)),
+		}
+		switch trigger.Type {
+		case TriggerMessage:
+			trigger.Keyword = strings.TrimSpace(raw.Keyword)
+			trigger.Pattern = strings.TrimSpace(raw.Pattern)
+			if trigger.Keyword == "" && trigger.Pattern == "" {
+				return nil, fmt.Errorf("triggers[%d]: keyword or pattern is required for message triggers", idx)
+			}
+			if len(trigger.Pattern) > triggerPatternMaxLen {
+				return nil, fmt.Errorf("triggers[%d]: pattern must be at most %d characters", idx, triggerPatternMaxLen)
+			}
+			if trigger.Pattern != "" {
+				if _, err := regexp.Compile(trigger.Pattern); err != nil {
+					return nil, fmt.Errorf("triggers[%d]: invalid pattern: %w", idx, err)
+				}
+			}
+		case TriggerSystemEvent:
+			trigger.Event = strings.ToLower(strings.TrimSpace(raw.Event))
+			if _, ok := triggerEvents[trigger.Event]; !ok {
+				return nil, fmt.Errorf("triggers[%d]: unsupported event=%q", idx, raw.Event)
+			}
+		case TriggerWebhook:
+			webhooks++
+			if webhooks > 1 {
+				return nil, errors.New("only one webhook trigger is allowed per job")
+			}
+			trigger.Channel = ""
+			trigger.Secret = strings.TrimSpace(raw.Secret)
+			if trigger.Secret == "" {
+				trigger.Secret = newTriggerSecret()
+			}
+			if len(trigger.Secret) < triggerSecretMinLen {
+				return nil, fmt.Errorf("triggers[%d]: secret must be at least %d characters", idx, triggerSecretMinLen)
+			}
+		default:
+			return nil, fmt.Errorf("triggers[%d]: unsupported type=%q", idx, raw.Type)
+		}
+		out = append(out, trigger)
+	}
+	return out, nil
+}
+
+func newTriggerSecret() string {
+	buf := make([]byte, triggerSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return strings.TrimPrefix(newRunID(), "run-") + strings.TrimPrefix(newRunID(), "run-")
+	}
+	return hex.EncodeToString(buf)
+}
+
+func webhookSecret(job domain.CronJobSpec) string {
+	for _, trigger := range job.Triggers {
+		if strings.ToLower(strings.TrimSpace(trigger.Type)) == TriggerWebhook {
+			return trigger.Secret
+		}
+	}
+	return ""
+}
+
+func inheritWebhookSecret(job *domain.CronJobSpec, existing domain.CronJobSpec) {
+	secret := webhookSecret(existing)
+	if secret == "" {
+		return
+	}
+	for idx, trigger := range job.Triggers {
+		if strings.ToLower(strings.TrimSpace(trigger.Type)) == TriggerWebhook && strings.TrimSpace(trigger.Secret) == "" {
+			job.Triggers[idx].Secret = secret
+		}
+	}
+}
+
+func (s *Service) MatchTriggers(event TriggerEvent) ([]TriggeredJob, error) {
+	if err := s.validateStore(); err != nil {
+		return nil, err
+	}
+	matched := []TriggeredJob{}
+	s.deps.Store.ReadCron(func(st ports.CronAggregate) {
+		for id, job := range st.Jobs {
+			if len(job.Triggers) == 0 || !jobSchedulable(job, normalizePausedState(st.States[id])) {
+				continue
+			}
+			if event.Type == TriggerSystemEvent && event.Event == EventCronFailure && event.Source == id {
+				continue
+			}
+			for _, trigger := range job.Triggers {
+				out, ok := matchTrigger(trigger, event)
+				if ok {
+					matched = append(matched, TriggeredJob{JobID: id, Event: out})
+					break
+				}
+			}
+		}
+	})
+	return matched, nil
+}
+
+func matchTrigger(trigger domain.CronTriggerSpec, event TriggerEvent) (TriggerEvent, bool) {
+	if trigger.Type != event.Type {
+		return event, false
+	}
+	if trigger.Channel != "" && trigger.Channel != event.Channel {
+		return event, false
+	}
+	switch trigger.Type {
+	case TriggerMessage:
+		text := strings.TrimSpace(event.Text)
+		if text == "" {
+			return event, false
+		}
+		if trigger.Keyword != "" {
+			if !strings.Contains(strings.ToLower(text), strings.ToLower(trigger.Keyword)) {
+				return event, false
+			}
+			event.Match = trigger.Keyword
+		}
+		if trigger.Pattern != "" {
+			pattern, err := regexp.Compile(trigger.Pattern)
+			if err != nil {
+				return event, false
+			}
+			loc := pattern.FindStringIndex(text)
+			if loc == nil {
+				return event, false
+			}
+			event.Match = text[loc[0]:loc[1]]
+		}
+		return event, true
+	case TriggerSystemEvent:
+		return event, trigger.Event == event.Event
+	default:
+		return event, false
+	}
+}
+
+// ResolveWebhookTrigger authenticates a webhook call by its shared secret.
+func (s *Service) ResolveWebhookTrigger(jobID, secret string, body []byte) (TriggerEvent, error) {
+	return s.resolveWebhookTrigger(jobID, body, func(expected string) bool {
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.TrimSpace(secret))) == 1
+	})
+}
+
+// ResolveSignedWebhookTrigger authenticates a webhook call by an HMAC-SHA256
+// of the body keyed with the secret, given as "sha256=<hex>".
+func (s *Service) ResolveSignedWebhookTrigger(jobID, signature string, body []byte) (TriggerEvent, error) {
+	given, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signature), webhookSignaturePrefix))
+	return s.resolveWebhookTrigger(jobID, body, func(expected string) bool {
+		return err == nil && hmac.Equal(given, WebhookSignature(expected, body))
+	})
+}
+
+func WebhookSignature(secret string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func (s *Service) resolveWebhookTrigger(jobID string, body []byte, verify func(expected string) bool) (TriggerEvent, error) {
+	if err := s.validateStore(); err != nil {
+		return TriggerEvent{}, err
+	}
+	var job domain.CronJobSpec
+	var state domain.CronJobState
+	found := false
+	s.deps.Store.ReadCron(func(st ports.CronAggregate) {
+		job, found = st.Jobs[jobID]
+		state = st.States[jobID]
+	})
+	expected := webhookSecret(job)
+	if !found || expected == "" || !verify(expected) {
+		return TriggerEvent{}, ErrTriggerNotFound
+	}
+	if !jobSchedulable(job, normalizePausedState(state)) {
+		return TriggerEvent{}, ErrTriggerInactive
+	}
+	if len(body) > triggerWebhookBodyLimit {
+		body = body[:triggerWebhookBodyLimit]
+	}
+	return TriggerEvent{Type: TriggerWebhook, Source: jobID, Body: string(body)}, nil
+}
+
+func (s *Service) emitSystemEvent(event TriggerEvent) {
+	if s.deps.EmitEvent == nil {
+		return
+	}
+	event.Type = TriggerSystemEvent
+	s.deps.EmitEvent(event)
+}
+
+func (s *Service) emitRunFailureEvents(job domain.CronJobSpec, message string, err error) {
+	channelName := strings.ToLower(resolveDispatchChannel(job))
+	s.emitSystemEvent(TriggerEvent{
+		Event:     EventCronFailure,
+		Source:    job.ID,
+		Channel:   channelName,
+		UserID:    job.Dispatch.Target.UserID,
+		SessionID: job.Dispatch.Target.SessionID,
+		Error:     message,
+	})
+	if classifyRunError(err) != RetryClassChannel {
+		return
+	}
+	s.emitSystemEvent(TriggerEvent{
+		Event:     EventDeliveryDeadLetter,
+		Source:    job.ID,
+		Channel:   channelName,
+		UserID:    job.Dispatch.Target.UserID,
+		SessionID: job.Dispatch.Target.SessionID,
+		Text:      job.Text,
+		Error:     message,
+	})
+}
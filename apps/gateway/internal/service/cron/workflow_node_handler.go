@@ -3,13 +3,60 @@ package cron
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"nextai/apps/gateway/internal/domain"
 )
 
 type CronNodeResult struct {
-	Stop bool
+	Stop   bool
+	Branch string
+	Output *string
+	Vars   map[string]string
+}
+
+type AgentPrompt struct {
+	RunID      string
+	NodeID     string
+	Prompt     string
+	ProviderID string
+	Model      string
+	PromptMode string
+}
+
+type workflowVarsContextKey struct{}
+
+func withWorkflowVars(ctx context.Context, vars map[string]string) context.Context {
+	return context.WithValue(ctx, workflowVarsContextKey{}, vars)
+}
+
+func WorkflowVars(ctx context.Context) map[string]string {
+	vars, _ := ctx.Value(workflowVarsContextKey{}).(map[string]string)
+	return copyWorkflowVars(vars)
+}
+
+type workflowRunIDContextKey struct{}
+
+func withWorkflowRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, workflowRunIDContextKey{}, runID)
+}
+
+func workflowRunID(ctx context.Context) string {
+	runID, _ := ctx.Value(workflowRunIDContextKey{}).(string)
+	return runID
+}
+
+func copyWorkflowVars(vars map[string]string) map[string]string {
+	out := make(map[string]string, len(vars))
+	for key, value := range vars {
+		out[key] = value
+	}
+	return out
 }
 
 type CronNodeHandler interface {
@@ -33,13 +80,56 @@ func (h *textWorkflowNodeHandler) Execute(
 	if h == nil || h.executeTextTask == nil {
 		return CronNodeResult{}, errors.New("cron text node executor is unavailable")
 	}
-	text := strings.TrimSpace(node.Text)
+	text, err := renderWorkflowTemplate(node.Text, WorkflowVars(ctx))
+	if err != nil {
+		return CronNodeResult{}, err
+	}
+	text = strings.TrimSpace(text)
 	if text == "" {
 		return CronNodeResult{}, errors.New("workflow text_event requires non-empty text")
 	}
 	return CronNodeResult{}, h.executeTextTask(ctx, job, text)
 }
 
+type agentPromptWorkflowNodeHandler struct {
+	executeAgentPrompt func(ctx context.Context, job domain.CronJobSpec, prompt AgentPrompt) (string, error)
+}
+
+func (h *agentPromptWorkflowNodeHandler) Type() string {
+	return workflowNodeAgent
+}
+
+func (h *agentPromptWorkflowNodeHandler) Execute(
+	ctx context.Context,
+	job domain.CronJobSpec,
+	node domain.CronWorkflowNode,
+) (CronNodeResult, error) {
+	if h == nil || h.executeAgentPrompt == nil {
+		return CronNodeResult{}, errors.New("cron agent prompt executor is unavailable")
+	}
+	prompt, err := renderWorkflowTemplate(node.Prompt, WorkflowVars(ctx))
+	if err != nil {
+		return CronNodeResult{}, err
+	}
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return CronNodeResult{}, errors.New("workflow agent_prompt requires non-empty prompt")
+	}
+	reply, err := h.executeAgentPrompt(ctx, job, AgentPrompt{
+		RunID:      workflowRunID(ctx),
+		NodeID:     node.ID,
+		Prompt:     prompt,
+		ProviderID: node.ProviderID,
+		Model:      node.Model,
+		PromptMode: node.PromptMode,
+	})
+	if err != nil {
+		return CronNodeResult{}, err
+	}
+	reply = strings.TrimSpace(reply)
+	return CronNodeResult{Output: &reply}, nil
+}
+
 type delayWorkflowNodeHandler struct{}
 
 func (h *delayWorkflowNodeHandler) Type() string {
@@ -54,8 +144,149 @@ func (h *delayWorkflowNodeHandler) Execute(
 	return CronNodeResult{}, executeWorkflowDelay(ctx, node.DelaySeconds)
 }
 
+type httpRequestWorkflowNodeHandler struct {
+	client *http.Client
+}
+
+func (h *httpRequestWorkflowNodeHandler) Type() string {
+	return workflowNodeHTTP
+}
+
+func (h *httpRequestWorkflowNodeHandler) Execute(
+	ctx context.Context,
+	_ domain.CronJobSpec,
+	node domain.CronWorkflowNode,
+) (CronNodeResult, error) {
+	vars := WorkflowVars(ctx)
+	rawURL, err := renderWorkflowTemplate(node.URL, vars)
+	if err != nil {
+		return CronNodeResult{}, err
+	}
+	rawURL = strings.TrimSpace(rawURL)
+	if err := validateWorkflowHTTPURL(rawURL); err != nil {
+		return CronNodeResult{}, err
+	}
+	body, err := renderWorkflowTemplate(node.Body, vars)
+	if err != nil {
+		return CronNodeResult{}, err
+	}
+	method := strings.ToUpper(strings.TrimSpace(node.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	timeout := time.Duration(node.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = workflowHTTPDefaultTimeoutSeconds * time.Second
+	}
+	requestCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(requestCtx, method, rawURL, reader)
+	if err != nil {
+		return CronNodeResult{}, fmt.Errorf("build http_request failed: %w", err)
+	}
+	for key, value := range node.Headers {
+		rendered, err := renderWorkflowTemplate(value, vars)
+		if err != nil {
+			return CronNodeResult{}, err
+		}
+		req.Header.Set(strings.TrimSpace(key), rendered)
+	}
+
+	client := http.DefaultClient
+	if h != nil && h.client != nil {
+		client = h.client
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		empty := ""
+		failed := CronNodeResult{Output: &empty, Vars: map[string]string{"status": "0"}}
+		if ctx.Err() == nil && errors.Is(requestCtx.Err(), context.DeadlineExceeded) {
+			return failed, fmt.Errorf("http_request %s %s timed out after %s", method, rawURL, timeout)
+		}
+		return failed, fmt.Errorf("http_request %s %s failed: %w", method, rawURL, err)
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, workflowHTTPMaxBodyBytes))
+	if err != nil {
+		empty := ""
+		return CronNodeResult{Output: &empty, Vars: map[string]string{"status": strconv.Itoa(resp.StatusCode)}}, fmt.Errorf("read http_request response failed: %w", err)
+	}
+	text := string(payload)
+	return CronNodeResult{Output: &text, Vars: map[string]string{"status": strconv.Itoa(resp.StatusCode)}}, nil
+}
+
+type toolCallWorkflowNodeHandler struct {
+	executeToolCall func(ctx context.Context, name string, input map[string]interface{}) (string, error)
+}
+
+func (h *toolCallWorkflowNodeHandler) Type() string {
+	return workflowNodeTool
+}
+
+func (h *toolCallWorkflowNodeHandler) Execute(
+	ctx context.Context,
+	_ domain.CronJobSpec,
+	node domain.CronWorkflowNode,
+) (CronNodeResult, error) {
+	if h == nil || h.executeToolCall == nil {
+		return CronNodeResult{}, errors.New("cron tool executor is unavailable")
+	}
+	name := strings.ToLower(strings.TrimSpace(node.Tool))
+	if name == "" {
+		return CronNodeResult{}, errors.New("workflow tool_call requires tool")
+	}
+	rendered, err := renderWorkflowTemplateValue(node.ToolInput, WorkflowVars(ctx))
+	if err != nil {
+		return CronNodeResult{}, err
+	}
+	input, _ := rendered.(map[string]interface{})
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+	output, err := h.executeToolCall(ctx, name, input)
+	if err != nil {
+		return CronNodeResult{}, err
+	}
+	return CronNodeResult{Output: &output}, nil
+}
+
+func renderWorkflowTemplateValue(value interface{}, vars map[string]string) (interface{}, error) {
+	switch item := value.(type) {
+	case string:
+		return renderWorkflowTemplate(item, vars)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(item))
+		for key, child := range item {
+			rendered, err := renderWorkflowTemplateValue(child, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = rendered
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(item))
+		for _, child := range item {
+			rendered, err := renderWorkflowTemplateValue(child, vars)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, rendered)
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
 type ifWorkflowNodeHandler struct {
-	evaluateCondition func(raw string, job domain.CronJobSpec) (bool, error)
+	evaluateCondition func(raw string, vars map[string]string) (bool, error)
 }
 
 func (h *ifWorkflowNodeHandler) Type() string {
@@ -63,20 +294,30 @@ func (h *ifWorkflowNodeHandler) Type() string {
 }
 
 func (h *ifWorkflowNodeHandler) Execute(
-	_ context.Context,
-	job domain.CronJobSpec,
+	ctx context.Context,
+	_ domain.CronJobSpec,
 	node domain.CronWorkflowNode,
 ) (CronNodeResult, error) {
 	if h == nil || h.evaluateCondition == nil {
 		return CronNodeResult{}, errors.New("cron if node evaluator is unavailable")
 	}
-	matched, err := h.evaluateCondition(node.IfCondition, job)
+	matched, err := h.evaluateCondition(node.IfCondition, WorkflowVars(ctx))
 	if err != nil {
 		return CronNodeResult{}, err
 	}
 	if !matched {
-		return CronNodeResult{Stop: true}, nil
+		return CronNodeResult{Branch: workflowBranchFalse}, nil
 	}
+	return CronNodeResult{Branch: workflowBranchTrue}, nil
+}
+
+type joinWorkflowNodeHandler struct{}
+
+func (h *joinWorkflowNodeHandler) Type() string {
+	return workflowNodeJoin
+}
+
+func (h *joinWorkflowNodeHandler) Execute(context.Context, domain.CronJobSpec, domain.CronWorkflowNode) (CronNodeResult, error) {
 	return CronNodeResult{}, nil
 }
 
@@ -84,6 +325,10 @@ func (s *Service) registerDefaultWorkflowNodeHandlers() {
 	s.RegisterCronNodeHandler(&textWorkflowNodeHandler{executeTextTask: s.executeTextTask})
 	s.RegisterCronNodeHandler(&delayWorkflowNodeHandler{})
 	s.RegisterCronNodeHandler(&ifWorkflowNodeHandler{evaluateCondition: evaluateWorkflowIfCondition})
+	s.RegisterCronNodeHandler(&agentPromptWorkflowNodeHandler{executeAgentPrompt: s.executeAgentPrompt})
+	s.RegisterCronNodeHandler(&joinWorkflowNodeHandler{})
+	s.RegisterCronNodeHandler(&httpRequestWorkflowNodeHandler{})
+	s.RegisterCronNodeHandler(&toolCallWorkflowNodeHandler{executeToolCall: s.executeToolCall})
 }
 
 func (s *Service) RegisterCronNodeHandler(handler CronNodeHandler) {
@@ -120,6 +365,35 @@ func (s *Service) buildWorkflowPlan(workflow *domain.CronWorkflowSpec) (*workflo
 	return buildWorkflowPlanWithNodeSupport(workflow, s.supportsWorkflowNodeType)
 }
 
+func (s *Service) executeAgentPrompt(ctx context.Context, job domain.CronJobSpec, prompt AgentPrompt) (string, error) {
+	if s.deps.ExecuteAgentPrompt == nil {
+		return "", errors.New("cron agent prompt executor is unavailable")
+	}
+	return s.deps.ExecuteAgentPrompt(ctx, job, prompt)
+}
+
+func (s *Service) executeToolCall(ctx context.Context, name string, input map[string]interface{}) (string, error) {
+	if s.deps.ExecuteToolCall == nil {
+		return "", errors.New("cron tool executor is unavailable")
+	}
+	return s.deps.ExecuteToolCall(ctx, name, input)
+}
+
+// RequiresToolScope reports whether the job runs tools or agent turns, which
+// callers may only schedule with the tools:execute scope.
+func RequiresToolScope(job domain.CronJobSpec) bool {
+	if job.Workflow == nil {
+		return false
+	}
+	for _, node := range job.Workflow.Nodes {
+		switch normalizeWorkflowNodeType(node.Type) {
+		case workflowNodeTool, workflowNodeAgent:
+			return true
+		}
+	}
+	return false
+}
+
 func normalizeWorkflowNodeType(raw string) string {
 	return strings.ToLower(strings.TrimSpace(raw))
 }
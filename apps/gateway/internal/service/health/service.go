@@ -0,0 +1,270 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+)
+
+const (
+	KindProvider = "provider"
+	KindChannel  = "channel"
+	KindCron     = "cron"
+	KindStore    = "store"
+
+	defaultProbeTimeout = 10 * time.Second
+	maxErrorRunes       = 256
+	maxErrorBodyBytes   = 512
+)
+
+// ProviderTarget is the resolved endpoint of one enabled provider.
+type ProviderTarget struct {
+	ID      string
+	BaseURL string
+	APIKey  string
+	Headers map[string]string
+	Timeout time.Duration
+}
+
+type Dependencies struct {
+	HTTPClient *http.Client
+	Logger     *slog.Logger
+	Now        func() time.Time
+}
+
+// Record keeps the latest outcome of one component.
+type Record struct {
+	LastSuccess time.Time
+	LastFailure time.Time
+	LastError   string
+	Latency     time.Duration
+}
+
+// Healthy reports whether the most recent observation succeeded. Components
+// that were never observed count as healthy.
+func (r Record) Healthy() bool {
+	return r.LastFailure.IsZero() || r.LastSuccess.After(r.LastFailure)
+}
+
+type Service struct {
+	deps Dependencies
+
+	mu      sync.RWMutex
+	records map[string]Record
+}
+
+func NewService(deps Dependencies) *Service {
+	return &Service{deps: deps, records: map[string]Record{}}
+}
+
+func (s *Service) log() *slog.Logger {
+	return observability.LoggerOrDefault(s.deps.Logger)
+}
+
+func (s *Service) now() time.Time {
+	if s.deps.Now != nil {
+		return s.deps.Now()
+	}
+	return time.Now()
+}
+
+func (s *Service) httpClient() *http.Client {
+	if s.deps.HTTPClient != nil {
+		return s.deps.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func recordKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+// Observe records the outcome of a probe or a real operation.
+func (s *Service) Observe(kind, name string, latency time.Duration, err error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := recordKey(kind, name)
+	rec := s.records[key]
+	rec.Latency = latency
+	if err == nil {
+		rec.LastSuccess = now
+	} else {
+		rec.LastFailure = now
+		rec.LastError = truncateRunes(observability.RedactSecrets(err.Error()), maxErrorRunes)
+	}
+	s.records[key] = rec
+}
+
+func (s *Service) Get(kind, name string) (Record, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.records[recordKey(kind, name)]
+	return rec, ok
+}
+
+func (s *Service) ProviderHealthy(providerID string) bool {
+	rec, _ := s.Get(KindProvider, providerID)
+	return rec.Healthy()
+}
+
+// ProbeProviders probes every target concurrently and forgets providers that
+// are no longer enabled.
+func (s *Service) ProbeProviders(ctx context.Context, targets []ProviderTarget) {
+	keep := map[string]struct{}{}
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		keep[recordKey(KindProvider, target.ID)] = struct{}{}
+		wg.Add(1)
+		go func(target ProviderTarget) {
+			defer wg.Done()
+			started := s.now()
+			err := s.probeProvider(ctx, target)
+			s.Observe(KindProvider, target.ID, s.now().Sub(started), err)
+			if err != nil {
+				s.log().WarnContext(ctx, "provider probe failed", "provider_id", target.ID, "err", err)
+			}
+		}(target)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.records {
+		if strings.HasPrefix(key, KindProvider+":") {
+			if _, ok := keep[key]; !ok {
+				delete(s.records, key)
+			}
+		}
+	}
+}
+
+// probeProvider lists models, which every supported adapter exposes and which
+// costs no tokens.
+func (s *Service) probeProvider(ctx context.Context, target ProviderTarget) error {
+	timeout := target.Timeout
+	if timeout <= 0 || timeout > defaultProbeTimeout {
+		timeout = defaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	endpoint := strings.TrimRight(strings.TrimSpace(target.BaseURL), "/") + "/models"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("build probe request: %w", err)
+	}
+	for key, value := range target.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Authorization", "Bearer "+target.APIKey)
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("models probe returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// ProbeStore checks that dataDir accepts new files.
+func (s *Service) ProbeStore(dataDir string) error {
+	started := s.now()
+	err := writeProbeFile(dataDir)
+	s.Observe(KindStore, "state", s.now().Sub(started), err)
+	return err
+}
+
+func writeProbeFile(dataDir string) error {
+	if strings.TrimSpace(dataDir) == "" {
+		return errors.New("data dir is not configured")
+	}
+	file, err := os.CreateTemp(dataDir, ".write-probe-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	_, writeErr := file.WriteString("ok")
+	closeErr := file.Close()
+	removeErr := os.Remove(name)
+	return errors.Join(writeErr, closeErr, removeErr)
+}
+
+// Check renders a record with the default status mapping: unknown before the
+// first observation, otherwise ok or down by the latest outcome.
+func (s *Service) Check(kind, name string) domain.HealthCheck {
+	rec, ok := s.Get(kind, name)
+	check := CheckFromRecord(kind, name, rec)
+	if !ok {
+		check.Status = domain.HealthStatusUnknown
+	}
+	return check
+}
+
+func CheckFromRecord(kind, name string, rec Record) domain.HealthCheck {
+	check := domain.HealthCheck{
+		Kind:      kind,
+		Name:      name,
+		Status:    domain.HealthStatusOK,
+		LatencyMS: rec.Latency.Milliseconds(),
+	}
+	if !rec.LastSuccess.IsZero() {
+		check.LastSuccessAt = rec.LastSuccess.UTC().Format(time.RFC3339)
+	}
+	if !rec.LastFailure.IsZero() {
+		check.LastFailureAt = rec.LastFailure.UTC().Format(time.RFC3339)
+		check.LastError = rec.LastError
+	}
+	if !rec.Healthy() {
+		check.Status = domain.HealthStatusDown
+	}
+	return check
+}
+
+// Summarize derives the overall status: down when a critical check is down,
+// degraded when any check is not ok or unknown.
+func Summarize(checks []domain.HealthCheck, now time.Time) domain.HealthStatus {
+	out := domain.HealthStatus{
+		Status:    domain.HealthStatusOK,
+		Ready:     true,
+		CheckedAt: now.UTC().Format(time.RFC3339),
+		Checks:    checks,
+	}
+	for _, check := range checks {
+		switch check.Status {
+		case domain.HealthStatusDown:
+			if check.Critical {
+				out.Ready = false
+				out.Status = domain.HealthStatusDown
+			} else if out.Status == domain.HealthStatusOK {
+				out.Status = domain.HealthStatusDegraded
+			}
+		case domain.HealthStatusDegraded:
+			if out.Status == domain.HealthStatusOK {
+				out.Status = domain.HealthStatusDegraded
+			}
+		}
+	}
+	return out
+}
+
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "..."
+}
@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+)
+
+func TestProbeProvidersRecordsOutcomesAndForgetsRemovedTargets(t *testing.T) {
+	t.Parallel()
+	var gotAuth, gotHeader string
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotHeader = r.Header.Get("X-Org")
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid key sk-live-secret123456"}`))
+	}))
+	defer broken.Close()
+
+	svc := NewService(Dependencies{})
+	svc.ProbeProviders(context.Background(), []ProviderTarget{
+		{ID: "good", BaseURL: healthy.URL + "/", APIKey: "k1", Headers: map[string]string{"X-Org": "o1"}},
+		{ID: "bad", BaseURL: broken.URL, APIKey: "k2"},
+	})
+	if gotAuth != "Bearer k1" || gotHeader != "o1" {
+		t.Fatalf("unexpected probe headers: auth=%q org=%q", gotAuth, gotHeader)
+	}
+	if !svc.ProviderHealthy("good") || svc.ProviderHealthy("bad") {
+		t.Fatal("unexpected provider health")
+	}
+	check := svc.Check(KindProvider, "bad")
+	if check.Status != domain.HealthStatusDown || !strings.Contains(check.LastError, "401") || strings.Contains(check.LastError, "secret123456") {
+		t.Fatalf("unexpected check: %+v", check)
+	}
+
+	svc.ProbeProviders(context.Background(), []ProviderTarget{{ID: "good", BaseURL: healthy.URL, APIKey: "k1"}})
+	if _, ok := svc.Get(KindProvider, "bad"); ok {
+		t.Fatal("removed provider should be forgotten")
+	}
+	if check := svc.Check(KindProvider, "bad"); check.Status != domain.HealthStatusUnknown {
+		t.Fatalf("expected unknown, got=%+v", check)
+	}
+}
+
+func TestObserveRecoversAndSummarize(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	svc := NewService(Dependencies{Now: func() time.Time { return now }})
+	svc.Observe(KindChannel, "qq", time.Millisecond, errors.New("send failed"))
+	if check := svc.Check(KindChannel, "qq"); check.Status != domain.HealthStatusDown || check.LastFailureAt != "2026-03-10T12:00:00Z" {
+		t.Fatalf("unexpected check: %+v", check)
+	}
+	now = now.Add(time.Minute)
+	svc.Observe(KindChannel, "qq", time.Millisecond, nil)
+	channel := svc.Check(KindChannel, "qq")
+	if channel.Status != domain.HealthStatusOK || channel.LastError != "send failed" || channel.LastSuccessAt != "2026-03-10T12:01:00Z" {
+		t.Fatalf("expected recovery with failure history: %+v", channel)
+	}
+
+	if err := svc.ProbeStore(t.TempDir()); err != nil {
+		t.Fatalf("probe store: %v", err)
+	}
+	if err := svc.ProbeStore(""); err == nil {
+		t.Fatal("expected error for missing data dir")
+	}
+
+	degraded := Summarize([]domain.HealthCheck{
+		{Kind: KindStore, Name: "state", Status: domain.HealthStatusOK, Critical: true},
+		{Kind: KindChannel, Name: "qq", Status: domain.HealthStatusDown},
+	}, now)
+	if !degraded.Ready || degraded.Status != domain.HealthStatusDegraded {
+		t.Fatalf("unexpected summary: %+v", degraded)
+	}
+	down := Summarize([]domain.HealthCheck{
+		{Kind: KindStore, Name: "state", Status: domain.HealthStatusDown, Critical: true},
+	}, now)
+	if down.Ready || down.Status != domain.HealthStatusDown {
+		t.Fatalf("unexpected summary: %+v", down)
+	}
+}
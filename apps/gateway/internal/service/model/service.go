@@ -39,16 +39,17 @@ type Service struct {
 }
 
 type ConfigureProviderInput struct {
-	ProviderID      string
-	APIKey          *string
-	BaseURL         *string
-	DisplayName     *string
-	ReasoningEffort *string
-	Enabled         *bool
-	Store           *bool
-	Headers         *map[string]string
-	TimeoutMS       *int
-	ModelAliases    *map[string]string
+	ProviderID       string
+	APIKey           *string
+	BaseURL          *string
+	DisplayName      *string
+	ReasoningEffort  *string
+	GenerationParams *domain.GenerationParams
+	Enabled          *bool
+	Store            *bool
+	Headers          *map[string]string
+	TimeoutMS        *int
+	ModelAliases     *map[string]string
 }
 
 func NewService(deps Dependencies) *Service {
@@ -113,6 +114,19 @@ func (s *Service) ConfigureProvider(input ConfigureProviderInput) (domain.Provid
 		}
 	}
 
+	if input.GenerationParams != nil {
+		err := provider.ValidateGenerationParams(*input.GenerationParams, nil)
+		if err == nil {
+			err = provider.ValidateAdapterGenerationParams(*input.GenerationParams, provider.ResolveAdapter(providerID))
+		}
+		if err != nil {
+			return domain.ProviderInfo{}, &ValidationError{
+				Code:    "invalid_provider_config",
+				Message: err.Error(),
+			}
+		}
+	}
+
 	sanitizedAliases, aliasErr := sanitizeModelAliases(input.ModelAliases)
 	if aliasErr != nil {
 		return domain.ProviderInfo{}, &ValidationError{
@@ -137,6 +151,9 @@ func (s *Service) ConfigureProvider(input ConfigureProviderInput) (domain.Provid
 		if input.ReasoningEffort != nil {
 			setting.ReasoningEffort = sanitizedReasoningEffort
 		}
+		if input.GenerationParams != nil {
+			setting.GenerationParams = provider.NormalizeGenerationParams(input.GenerationParams)
+		}
 		if input.Enabled != nil {
 			enabled := *input.Enabled
 			setting.Enabled = &enabled
@@ -294,6 +311,7 @@ func (s *Service) buildProviderInfo(providerID string, setting repo.ProviderSett
 		APIKeyPrefix:       spec.APIKeyPrefix,
 		Models:             provider.ResolveModels(providerID, setting.ModelAliases),
 		ReasoningEffort:    setting.ReasoningEffort,
+		GenerationParams:   provider.NormalizeGenerationParams(setting.GenerationParams),
 		Store:              providerStoreEnabled(setting),
 		Headers:            sanitizeStringMap(setting.Headers),
 		TimeoutMS:          setting.TimeoutMS,
@@ -367,6 +385,7 @@ func normalizeProviderSetting(setting *repo.ProviderSetting) {
 	setting.APIKey = strings.TrimSpace(setting.APIKey)
 	setting.BaseURL = strings.TrimSpace(setting.BaseURL)
 	setting.ReasoningEffort = normalizeReasoningEffort(strings.TrimSpace(setting.ReasoningEffort))
+	setting.GenerationParams = provider.NormalizeGenerationParams(setting.GenerationParams)
 	if setting.Enabled == nil {
 		enabled := true
 		setting.Enabled = &enabled
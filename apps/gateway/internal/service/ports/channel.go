@@ -6,6 +6,11 @@ type Channel interface {
 	SendText(ctx context.Context, userID, sessionID, text string, cfg map[string]interface{}) error
 }
 
+type ProactiveChannel interface {
+	Channel
+	SupportsProactive() bool
+}
+
 type ChannelResolver interface {
 	ResolveChannel(name string) (Channel, map[string]interface{}, string, error)
 }
@@ -29,6 +29,10 @@ type CronAggregate struct {
 	States map[string]domain.CronJobState
 }
 
+type AuthAggregate struct {
+	APIKeys map[string]repo.APIKeyRecord
+}
+
 type StateStore interface {
 	ReadSettings(func(state SettingsAggregate))
 	WriteSettings(func(state *SettingsAggregate) error) error
@@ -41,4 +45,7 @@ type StateStore interface {
 
 	ReadCron(func(state CronAggregate))
 	WriteCron(func(state *CronAggregate) error) error
+
+	ReadAuth(func(state AuthAggregate))
+	WriteAuth(func(state *AuthAggregate) error) error
 }
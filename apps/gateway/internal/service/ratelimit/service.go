@@ -0,0 +1,478 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+)
+
+const (
+	stateFileName  = "rate-limits.json"
+	dayLayout      = "2006-01-02"
+	monthLayout    = "2006-01"
+	noticeCooldown = time.Minute
+
+	ReasonRateLimited = "rate_limited"
+	ReasonTokenBudget = "token_budget_exceeded"
+
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+type Dependencies struct {
+	DataDir string
+	Config  domain.RateLimitConfig
+	Logger  *slog.Logger
+	Now     func() time.Time
+	// PersistInterval batches state writes: changes are flushed at most this
+	// often and on Flush. Zero writes through on every change.
+	PersistInterval time.Duration
+}
+
+// Subject identifies who a turn is charged to. Empty fields skip their scope.
+type Subject struct {
+	UserID   string
+	Channel  string
+	APIKeyID string
+	ChatKey  string
+	// SkipRequestLimits exempts system-initiated turns such as cron jobs from
+	// the request buckets; their tokens still count against the budgets.
+	SkipRequestLimits bool
+}
+
+type Decision struct {
+	Allowed    bool
+	Reason     string
+	Scope      string
+	Key        string
+	Period     string
+	RetryAfter time.Duration
+	// Notify is false when the same limit was already reported within the
+	// last minute, so callers do not flood a channel with notices.
+	Notify bool
+}
+
+type bucketState struct {
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type budgetState struct {
+	Day         string  `json:"day"`
+	DayTokens   int     `json:"day_tokens"`
+	Month       string  `json:"month"`
+	MonthTokens int     `json:"month_tokens"`
+	TotalTokens int     `json:"total_tokens"`
+	LastUsedAt  *string `json:"last_used_at,omitempty"`
+}
+
+type persistedState struct {
+	Buckets map[string]bucketState `json:"buckets"`
+	Budgets map[string]budgetState `json:"budgets"`
+}
+
+type Service struct {
+	deps Dependencies
+	cfg  domain.RateLimitConfig
+
+	mu         sync.Mutex
+	state      persistedState
+	loaded     bool
+	notices    map[string]time.Time
+	dirty      bool
+	flushTimer *time.Timer
+}
+
+func NewService(deps Dependencies) *Service {
+	return &Service{deps: deps, cfg: normalizeConfig(deps.Config), notices: map[string]time.Time{}}
+}
+
+func normalizeConfig(cfg domain.RateLimitConfig) domain.RateLimitConfig {
+	for _, rule := range []*domain.RateLimitRule{&cfg.User, &cfg.Channel, &cfg.APIKey} {
+		if rule.PerMinute <= 0 {
+			*rule = domain.RateLimitRule{}
+			continue
+		}
+		if rule.Burst <= 0 {
+			rule.Burst = rule.PerMinute
+		}
+	}
+	for _, rule := range []*domain.TokenBudgetRule{&cfg.UserTokens, &cfg.ChatTokens} {
+		rule.Daily = max(rule.Daily, 0)
+		rule.Monthly = max(rule.Monthly, 0)
+	}
+	return cfg
+}
+
+func (s *Service) log() *slog.Logger {
+	return observability.LoggerOrDefault(s.deps.Logger)
+}
+
+func (s *Service) now() time.Time {
+	if s.deps.Now != nil {
+		return s.deps.Now()
+	}
+	return time.Now()
+}
+
+func (s *Service) Enabled() bool {
+	return s.cfg != domain.RateLimitConfig{}
+}
+
+type bucketCheck struct {
+	scope string
+	key   string
+	rule  domain.RateLimitRule
+}
+
+type budgetCheck struct {
+	scope string
+	key   string
+	rule  domain.TokenBudgetRule
+}
+
+func (s *Service) bucketChecks(subject Subject) []bucketCheck {
+	if subject.SkipRequestLimits {
+		return nil
+	}
+	candidates := []bucketCheck{
+		{scope: domain.RateLimitScopeUser, key: subject.UserID, rule: s.cfg.User},
+		{scope: domain.RateLimitScopeChannel, key: subject.Channel, rule: s.cfg.Channel},
+		{scope: domain.RateLimitScopeAPIKey, key: subject.APIKeyID, rule: s.cfg.APIKey},
+	}
+	out := make([]bucketCheck, 0, len(candidates))
+	for _, item := range candidates {
+		item.key = strings.TrimSpace(item.key)
+		if item.key != "" && item.rule.PerMinute > 0 {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (s *Service) budgetChecks(subject Subject) []budgetCheck {
+	candidates := []budgetCheck{
+		{scope: domain.RateLimitScopeUser, key: subject.UserID, rule: s.cfg.UserTokens},
+		{scope: domain.RateLimitScopeChat, key: subject.ChatKey, rule: s.cfg.ChatTokens},
+	}
+	out := make([]budgetCheck, 0, len(candidates))
+	for _, item := range candidates {
+		item.key = strings.TrimSpace(item.key)
+		if item.key != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Allow checks the token budgets and takes one request from every applicable
+// bucket. A denied request consumes nothing.
+func (s *Service) Allow(subject Subject) Decision {
+	if !s.Enabled() {
+		return Decision{Allowed: true}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	now := s.now()
+
+	for _, check := range s.budgetChecks(subject) {
+		usage := rollBudget(s.state.Budgets[stateKey(check.scope, check.key)], now)
+		if check.rule.Daily > 0 && usage.DayTokens >= check.rule.Daily {
+			next := time.Date(now.UTC().Year(), now.UTC().Month(), now.UTC().Day()+1, 0, 0, 0, 0, time.UTC)
+			return s.denyLocked(ReasonTokenBudget, check.scope, check.key, PeriodDay, next.Sub(now), now)
+		}
+		if check.rule.Monthly > 0 && usage.MonthTokens >= check.rule.Monthly {
+			next := time.Date(now.UTC().Year(), now.UTC().Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			return s.denyLocked(ReasonTokenBudget, check.scope, check.key, PeriodMonth, next.Sub(now), now)
+		}
+	}
+
+	checks := s.bucketChecks(subject)
+	refilled := make([]bucketState, len(checks))
+	for i, check := range checks {
+		bucket := refill(s.state.Buckets[stateKey(check.scope, check.key)], check.rule, now)
+		if bucket.Tokens < 1 {
+			wait := time.Duration((1 - bucket.Tokens) / float64(check.rule.PerMinute) * float64(time.Minute))
+			return s.denyLocked(ReasonRateLimited, check.scope, check.key, "", wait, now)
+		}
+		refilled[i] = bucket
+	}
+	if len(checks) == 0 {
+		return Decision{Allowed: true}
+	}
+	for i, check := range checks {
+		bucket := refilled[i]
+		bucket.Tokens--
+		s.state.Buckets[stateKey(check.scope, check.key)] = bucket
+	}
+	s.persistLocked()
+	return Decision{Allowed: true}
+}
+
+func (s *Service) denyLocked(reason, scope, key, period string, retryAfter time.Duration, now time.Time) Decision {
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	retryAfter = time.Duration(math.Ceil(retryAfter.Seconds())) * time.Second
+	noticeKey := reason + "|" + stateKey(scope, key)
+	notify := now.Sub(s.notices[noticeKey]) >= noticeCooldown
+	if notify {
+		s.notices[noticeKey] = now
+	}
+	return Decision{
+		Reason:     reason,
+		Scope:      scope,
+		Key:        key,
+		Period:     period,
+		RetryAfter: retryAfter,
+		Notify:     notify,
+	}
+}
+
+// RecordTokens charges a finished turn to the user and chat budgets.
+func (s *Service) RecordTokens(subject Subject, tokens int) {
+	if !s.Enabled() || tokens <= 0 {
+		return
+	}
+	checks := s.budgetChecks(subject)
+	if len(checks) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	now := s.now()
+	stamp := now.UTC().Format(time.RFC3339)
+	for _, check := range checks {
+		id := stateKey(check.scope, check.key)
+		usage := rollBudget(s.state.Budgets[id], now)
+		usage.DayTokens += tokens
+		usage.MonthTokens += tokens
+		usage.TotalTokens += tokens
+		usage.LastUsedAt = &stamp
+		s.state.Budgets[id] = usage
+	}
+	s.persistLocked()
+}
+
+// Flush writes pending changes now; the server calls it on shutdown.
+func (s *Service) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	if s.dirty {
+		s.saveLocked()
+	}
+}
+
+func (s *Service) persistLocked() {
+	s.dirty = true
+	if s.deps.PersistInterval <= 0 {
+		s.saveLocked()
+		return
+	}
+	if s.flushTimer == nil {
+		s.flushTimer = time.AfterFunc(s.deps.PersistInterval, s.Flush)
+	}
+}
+
+func (s *Service) Status() domain.RateLimitStatus {
+	out := domain.RateLimitStatus{
+		Config:  s.cfg,
+		Buckets: []domain.RateLimitBucket{},
+		Budgets: []domain.TokenBudgetUsage{},
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	now := s.now()
+	for id, bucket := range s.state.Buckets {
+		scope, key := splitStateKey(id)
+		rule := s.bucketRule(scope)
+		if rule.PerMinute <= 0 {
+			continue
+		}
+		bucket = refill(bucket, rule, now)
+		out.Buckets = append(out.Buckets, domain.RateLimitBucket{
+			Scope:     scope,
+			Key:       key,
+			Tokens:    math.Floor(bucket.Tokens*100) / 100,
+			Capacity:  rule.Burst,
+			UpdatedAt: bucket.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	for id, usage := range s.state.Budgets {
+		scope, key := splitStateKey(id)
+		rule := s.budgetRule(scope)
+		usage = rollBudget(usage, now)
+		out.Budgets = append(out.Budgets, domain.TokenBudgetUsage{
+			Scope:        scope,
+			Key:          key,
+			Day:          usage.Day,
+			DayTokens:    usage.DayTokens,
+			DailyLimit:   rule.Daily,
+			Month:        usage.Month,
+			MonthTokens:  usage.MonthTokens,
+			MonthlyLimit: rule.Monthly,
+			TotalTokens:  usage.TotalTokens,
+			LastUsedAt:   usage.LastUsedAt,
+		})
+	}
+	sort.Slice(out.Buckets, func(i, j int) bool {
+		if out.Buckets[i].Scope == out.Buckets[j].Scope {
+			return out.Buckets[i].Key < out.Buckets[j].Key
+		}
+		return out.Buckets[i].Scope < out.Buckets[j].Scope
+	})
+	sort.Slice(out.Budgets, func(i, j int) bool {
+		if out.Budgets[i].Scope == out.Budgets[j].Scope {
+			return out.Budgets[i].Key < out.Budgets[j].Key
+		}
+		return out.Budgets[i].Scope < out.Budgets[j].Scope
+	})
+	return out
+}
+
+func (s *Service) bucketRule(scope string) domain.RateLimitRule {
+	switch scope {
+	case domain.RateLimitScopeUser:
+		return s.cfg.User
+	case domain.RateLimitScopeChannel:
+		return s.cfg.Channel
+	case domain.RateLimitScopeAPIKey:
+		return s.cfg.APIKey
+	default:
+		return domain.RateLimitRule{}
+	}
+}
+
+func (s *Service) budgetRule(scope string) domain.TokenBudgetRule {
+	switch scope {
+	case domain.RateLimitScopeUser:
+		return s.cfg.UserTokens
+	case domain.RateLimitScopeChat:
+		return s.cfg.ChatTokens
+	default:
+		return domain.TokenBudgetRule{}
+	}
+}
+
+func refill(bucket bucketState, rule domain.RateLimitRule, now time.Time) bucketState {
+	capacity := float64(rule.Burst)
+	if bucket.UpdatedAt.IsZero() {
+		return bucketState{Tokens: capacity, UpdatedAt: now}
+	}
+	if elapsed := now.Sub(bucket.UpdatedAt); elapsed > 0 {
+		bucket.Tokens += elapsed.Minutes() * float64(rule.PerMinute)
+		bucket.UpdatedAt = now
+	}
+	if bucket.Tokens > capacity {
+		bucket.Tokens = capacity
+	}
+	return bucket
+}
+
+func rollBudget(usage budgetState, now time.Time) budgetState {
+	day := now.UTC().Format(dayLayout)
+	month := now.UTC().Format(monthLayout)
+	if usage.Day != day {
+		usage.Day = day
+		usage.DayTokens = 0
+	}
+	if usage.Month != month {
+		usage.Month = month
+		usage.MonthTokens = 0
+	}
+	return usage
+}
+
+func stateKey(scope, key string) string {
+	return scope + ":" + key
+}
+
+func splitStateKey(id string) (string, string) {
+	scope, key, _ := strings.Cut(id, ":")
+	return scope, key
+}
+
+func (s *Service) loadLocked() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	s.state = persistedState{Buckets: map[string]bucketState{}, Budgets: map[string]budgetState{}}
+	if s.deps.DataDir == "" {
+		return
+	}
+	body, err := os.ReadFile(s.statePath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			s.log().Warn("rate limit state load failed, starting from empty counters", "err", err)
+		}
+		return
+	}
+	var loaded persistedState
+	if err := json.Unmarshal(body, &loaded); err != nil {
+		s.log().Warn("rate limit state load failed, starting from empty counters", "err", fmt.Errorf("decode rate limit state: %w", err))
+		return
+	}
+	for id, bucket := range loaded.Buckets {
+		s.state.Buckets[id] = bucket
+	}
+	for id, usage := range loaded.Budgets {
+		s.state.Budgets[id] = usage
+	}
+}
+
+// saveLocked drops buckets that have refilled completely, since a missing
+// bucket starts full, and budgets whose day and month have both rolled over,
+// since they would read as zero anyway. It then writes the state atomically.
+func (s *Service) saveLocked() {
+	s.dirty = false
+	now := s.now()
+	for id, bucket := range s.state.Buckets {
+		scope, _ := splitStateKey(id)
+		rule := s.bucketRule(scope)
+		if rule.PerMinute <= 0 || refill(bucket, rule, now).Tokens >= float64(rule.Burst) {
+			delete(s.state.Buckets, id)
+		}
+	}
+	day, month := now.UTC().Format(dayLayout), now.UTC().Format(monthLayout)
+	for id, usage := range s.state.Budgets {
+		if usage.Day != day && usage.Month != month {
+			delete(s.state.Budgets, id)
+		}
+	}
+	if s.deps.DataDir == "" {
+		return
+	}
+	body, err := json.MarshalIndent(s.state, "", "  ")
+	if err == nil {
+		tmp := s.statePath() + ".tmp"
+		if err = os.WriteFile(tmp, body, 0o600); err == nil {
+			err = os.Rename(tmp, s.statePath())
+		}
+	}
+	if err != nil {
+		s.log().Error("rate limit state persist failed", "err", err)
+	}
+}
+
+func (s *Service) statePath() string {
+	return filepath.Join(s.deps.DataDir, stateFileName)
+}
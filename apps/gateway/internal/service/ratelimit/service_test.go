@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+)
+
+func newTestService(t *testing.T, dir string, cfg domain.RateLimitConfig, now *time.Time) *Service {
+	t.Helper()
+	return NewService(Dependencies{DataDir: dir, Config: cfg, Now: func() time.Time { return *now }})
+}
+
+func TestAllowTokenBucketRefillsAndPersists(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	cfg := domain.RateLimitConfig{
+		User:    domain.RateLimitRule{PerMinute: 2},
+		Channel: domain.RateLimitRule{PerMinute: 60, Burst: 10},
+	}
+	svc := newTestService(t, dir, cfg, &now)
+	subject := Subject{UserID: "u1", Channel: "qq"}
+
+	for i := 0; i < 2; i++ {
+		if d := svc.Allow(subject); !d.Allowed {
+			t.Fatalf("request %d denied: %+v", i, d)
+		}
+	}
+	denied := svc.Allow(subject)
+	if denied.Allowed || denied.Reason != ReasonRateLimited || denied.Scope != domain.RateLimitScopeUser || denied.Key != "u1" {
+		t.Fatalf("expected user bucket denial, got=%+v", denied)
+	}
+	if denied.RetryAfter != 30*time.Second || !denied.Notify {
+		t.Fatalf("unexpected retry/notify: %+v", denied)
+	}
+	if again := svc.Allow(subject); again.Allowed || again.Notify {
+		t.Fatalf("expected repeated denial without notice, got=%+v", again)
+	}
+	if d := svc.Allow(Subject{UserID: "u2", Channel: "qq"}); !d.Allowed {
+		t.Fatalf("other user should not share the bucket: %+v", d)
+	}
+
+	restarted := newTestService(t, dir, cfg, &now)
+	if d := restarted.Allow(subject); d.Allowed {
+		t.Fatal("expected bucket state to survive a restart")
+	}
+	status := restarted.Status()
+	var channelTokens float64 = -1
+	for _, bucket := range status.Buckets {
+		if bucket.Scope == domain.RateLimitScopeChannel && bucket.Key == "qq" {
+			channelTokens = bucket.Tokens
+		}
+	}
+	if channelTokens != 7 {
+		t.Fatalf("denied requests must not consume channel tokens, got=%v buckets=%+v", channelTokens, status.Buckets)
+	}
+
+	now = now.Add(30 * time.Second)
+	if d := restarted.Allow(subject); !d.Allowed {
+		t.Fatalf("expected refill after 30s, got=%+v", d)
+	}
+	if d := restarted.Allow(Subject{UserID: "u1", Channel: "qq", SkipRequestLimits: true}); !d.Allowed {
+		t.Fatalf("internal turns skip request buckets, got=%+v", d)
+	}
+}
+
+func TestTokenBudgetsRollOverByDayAndMonth(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	cfg := domain.RateLimitConfig{
+		UserTokens: domain.TokenBudgetRule{Daily: 100, Monthly: 150},
+		ChatTokens: domain.TokenBudgetRule{Daily: 1000},
+	}
+	svc := newTestService(t, dir, cfg, &now)
+	subject := Subject{UserID: "u1", ChatKey: "qq:group:g1"}
+
+	svc.RecordTokens(subject, 120)
+	denied := svc.Allow(subject)
+	if denied.Allowed || denied.Reason != ReasonTokenBudget || denied.Period != PeriodDay || denied.RetryAfter != time.Hour {
+		t.Fatalf("expected daily budget denial, got=%+v", denied)
+	}
+	if d := svc.Allow(Subject{UserID: "u2", ChatKey: "qq:group:g1"}); !d.Allowed {
+		t.Fatalf("chat budget has room, got=%+v", d)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if d := svc.Allow(subject); !d.Allowed {
+		t.Fatalf("new day and month should reset the budget, got=%+v", d)
+	}
+	svc.RecordTokens(subject, 90)
+	now = now.Add(24 * time.Hour)
+	svc.RecordTokens(subject, 70)
+
+	restarted := newTestService(t, dir, cfg, &now)
+	denied = restarted.Allow(subject)
+	if denied.Allowed || denied.Period != PeriodMonth {
+		t.Fatalf("expected monthly budget denial after restart, got=%+v", denied)
+	}
+	var user, chat domain.TokenBudgetUsage
+	for _, usage := range restarted.Status().Budgets {
+		switch usage.Scope {
+		case domain.RateLimitScopeUser:
+			if usage.Key == "u1" {
+				user = usage
+			}
+		case domain.RateLimitScopeChat:
+			chat = usage
+		}
+	}
+	if user.DayTokens != 70 || user.MonthTokens != 160 || user.TotalTokens != 280 || user.MonthlyLimit != 150 {
+		t.Fatalf("unexpected user usage: %+v", user)
+	}
+	if chat.Key != "qq:group:g1" || chat.TotalTokens != 280 || chat.DailyLimit != 1000 {
+		t.Fatalf("unexpected chat usage: %+v", chat)
+	}
+}
+
+func TestPersistIntervalBatchesWritesAndPrunesStaleBudgets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	cfg := domain.RateLimitConfig{UserTokens: domain.TokenBudgetRule{Daily: 100}}
+	svc := NewService(Dependencies{DataDir: dir, Config: cfg, Now: func() time.Time { return now }, PersistInterval: time.Hour})
+
+	svc.RecordTokens(Subject{UserID: "u1"}, 40)
+	if _, err := os.Stat(filepath.Join(dir, stateFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no write before flush, err=%v", err)
+	}
+	svc.Flush()
+	if status := newTestService(t, dir, cfg, &now).Status(); len(status.Budgets) != 1 || status.Budgets[0].DayTokens != 40 {
+		t.Fatalf("expected flushed budget, got=%+v", status.Budgets)
+	}
+
+	now = now.AddDate(0, 2, 0)
+	svc.RecordTokens(Subject{UserID: "u2"}, 10)
+	svc.Flush()
+	status := newTestService(t, dir, cfg, &now).Status()
+	if len(status.Budgets) != 1 || status.Budgets[0].Key != "u2" {
+		t.Fatalf("expected rolled-over budget to be pruned, got=%+v", status.Budgets)
+	}
+}
+
+func TestDisabledServiceAllowsEverything(t *testing.T) {
+	t.Parallel()
+	svc := NewService(Dependencies{})
+	svc.RecordTokens(Subject{UserID: "u1"}, 1_000_000)
+	if d := svc.Allow(Subject{UserID: "u1", Channel: "console"}); !d.Allowed {
+		t.Fatalf("expected allow, got=%+v", d)
+	}
+	if status := svc.Status(); len(status.Budgets) != 0 || len(status.Buckets) != 0 {
+		t.Fatalf("disabled service should not track usage: %+v", status)
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"nextai/apps/gateway/internal/domain"
 	"nextai/apps/gateway/internal/repo"
+	"nextai/apps/gateway/internal/secrets"
 	"nextai/apps/gateway/internal/service/ports"
 )
 
@@ -1050,14 +1051,14 @@ func collectSensitiveFieldsFromValue(value interface{}, fields map[string]struct
 	switch typed := value.(type) {
 	case map[string]interface{}:
 		for key, item := range typed {
-			if isSensitiveFieldName(key) {
+			if secrets.IsSensitiveFieldName(key) {
 				fields[key] = struct{}{}
 			}
 			collectSensitiveFieldsFromValue(item, fields)
 		}
 	case map[string]string:
 		for key := range typed {
-			if isSensitiveFieldName(key) {
+			if secrets.IsSensitiveFieldName(key) {
 				fields[key] = struct{}{}
 			}
 		}
@@ -1105,20 +1106,6 @@ func sortedSensitiveFieldList(fields map[string]struct{}) []string {
 	return out
 }
 
-func isSensitiveFieldName(name string) bool {
-	trimmed := strings.TrimSpace(name)
-	if trimmed == "" {
-		return false
-	}
-	if strings.EqualFold(trimmed, "api_key") {
-		return true
-	}
-	upper := strings.ToUpper(trimmed)
-	return strings.HasSuffix(upper, "_KEY") ||
-		strings.HasSuffix(upper, "_TOKEN") ||
-		strings.HasSuffix(upper, "_SECRET")
-}
-
 func cloneProviderSettings(in map[string]repo.ProviderSetting) map[string]repo.ProviderSetting {
 	if len(in) == 0 {
 		return map[string]repo.ProviderSetting{}
@@ -0,0 +1,366 @@
+package turntrace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/observability"
+	"nextai/apps/gateway/internal/runner"
+	"nextai/apps/gateway/internal/service/ports"
+)
+
+const (
+	DefaultLimit = 200
+
+	tracesDirName    = "turn-traces"
+	maxDiffLines     = 400
+	diffContextEqual = " "
+	diffRemoved      = "-"
+	diffAdded        = "+"
+)
+
+var (
+	ErrTraceNotFound = errors.New("turn_trace_not_found")
+
+	turnIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+type ValidationError struct {
+	Code    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e == nil {
+		return ""
+	}
+	return e.Message
+}
+
+type Dependencies struct {
+	DataDir string
+	Limit   int
+	Runner  ports.AgentRunner
+	// ResolveGenerateConfig builds the provider config used by Replay for the
+	// given provider and model, including credentials.
+	ResolveGenerateConfig func(providerID, model string) (runner.GenerateConfig, error)
+	Logger                *slog.Logger
+}
+
+// Service stores recorded turn traces as one JSON file per turn under
+// <data_dir>/turn-traces and replays their provider steps.
+type Service struct {
+	deps Dependencies
+	mu   sync.Mutex
+}
+
+func NewService(deps Dependencies) *Service {
+	if deps.Limit <= 0 {
+		deps.Limit = DefaultLimit
+	}
+	return &Service{deps: deps}
+}
+
+func (s *Service) dir() string {
+	return filepath.Join(s.deps.DataDir, tracesDirName)
+}
+
+func (s *Service) path(id string) string {
+	return filepath.Join(s.dir(), id+".json")
+}
+
+// Record persists trace and prunes the oldest traces beyond the limit. Errors
+// are logged rather than returned because recording must never fail a turn.
+func (s *Service) Record(trace domain.TurnTrace) {
+	if err := s.Save(trace); err != nil {
+		observability.LoggerOrDefault(s.deps.Logger).Error("turn trace save failed", observability.LogKeyTurnID, trace.ID, "err", err)
+	}
+}
+
+func (s *Service) Save(trace domain.TurnTrace) error {
+	if !turnIDPattern.MatchString(trace.ID) {
+		return fmt.Errorf("invalid turn id %q", trace.ID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir(), 0o755); err != nil {
+		return err
+	}
+	body, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	target := s.path(trace.ID)
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return err
+	}
+	return s.pruneLocked()
+}
+
+func (s *Service) pruneLocked() error {
+	entries, err := os.ReadDir(s.dir())
+	if err != nil {
+		return err
+	}
+	type traceFile struct {
+		name    string
+		modTime int64
+	}
+	files := make([]traceFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			continue
+		}
+		files = append(files, traceFile{name: entry.Name(), modTime: info.ModTime().UnixNano()})
+	}
+	if len(files) <= s.deps.Limit {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime == files[j].modTime {
+			return files[i].name < files[j].name
+		}
+		return files[i].modTime < files[j].modTime
+	})
+	for _, file := range files[:len(files)-s.deps.Limit] {
+		if err := os.Remove(filepath.Join(s.dir(), file.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) Get(id string) (domain.TurnTrace, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if !turnIDPattern.MatchString(id) {
+		return domain.TurnTrace{}, ErrTraceNotFound
+	}
+	s.mu.Lock()
+	body, err := os.ReadFile(s.path(id))
+	s.mu.Unlock()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return domain.TurnTrace{}, ErrTraceNotFound
+		}
+		return domain.TurnTrace{}, err
+	}
+	var trace domain.TurnTrace
+	if err := json.Unmarshal(body, &trace); err != nil {
+		return domain.TurnTrace{}, fmt.Errorf("decode turn trace failed: %w", err)
+	}
+	return trace, nil
+}
+
+// Replay re-runs one recorded provider step with the same input and tool
+// definitions against the requested provider/model. Tools are not executed;
+// the returned diff compares the text and tool calls of both outcomes.
+func (s *Service) Replay(ctx context.Context, id string, req domain.TurnReplayRequest) (domain.TurnReplayResult, error) {
+	trace, err := s.Get(id)
+	if err != nil {
+		return domain.TurnReplayResult{}, err
+	}
+	if len(trace.Steps) == 0 {
+		return domain.TurnReplayResult{}, &ValidationError{Code: "turn_not_replayable", Message: "turn has no recorded provider steps"}
+	}
+	stepNumber := req.Step
+	if stepNumber <= 0 {
+		stepNumber = trace.Steps[0].Step
+	}
+	var step *domain.TurnTraceStep
+	for i := range trace.Steps {
+		if trace.Steps[i].Step == stepNumber {
+			step = &trace.Steps[i]
+			break
+		}
+	}
+	if step == nil {
+		return domain.TurnReplayResult{}, &ValidationError{Code: "invalid_step", Message: fmt.Sprintf("turn has no recorded step %d", stepNumber)}
+	}
+
+	providerID := strings.TrimSpace(req.ProviderID)
+	model := strings.TrimSpace(req.Model)
+	if providerID == "" {
+		providerID = trace.ProviderID
+		if model == "" {
+			model = trace.Model
+		}
+	}
+	if model == "" {
+		return domain.TurnReplayResult{}, &ValidationError{Code: "invalid_request", Message: "model is required when provider_id is set"}
+	}
+	if s.deps.Runner == nil || s.deps.ResolveGenerateConfig == nil {
+		return domain.TurnReplayResult{}, errors.New("turn replay is not configured")
+	}
+	cfg, err := s.deps.ResolveGenerateConfig(providerID, model)
+	if err != nil {
+		return domain.TurnReplayResult{}, err
+	}
+
+	tools := make([]runner.ToolDefinition, 0, len(trace.Tools))
+	for _, tool := range trace.Tools {
+		tools = append(tools, runner.ToolDefinition{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters})
+	}
+	result := domain.TurnReplayResult{
+		TurnID: trace.ID,
+		Step:   step.Step,
+		Original: domain.TurnReplayOutcome{
+			ProviderID: trace.ProviderID,
+			Model:      trace.Model,
+			Text:       step.Text,
+			ToolCalls:  step.ToolCalls,
+			Error:      step.Error,
+		},
+		Replay: domain.TurnReplayOutcome{ProviderID: cfg.ProviderID, Model: cfg.Model},
+	}
+	var exchangesMu sync.Mutex
+	replayCtx := runner.WithExchangeRecorder(ctx, func(exchange domain.ProviderExchange) {
+		exchangesMu.Lock()
+		defer exchangesMu.Unlock()
+		result.Exchanges = append(result.Exchanges, exchange)
+	})
+	turn, runErr := s.deps.Runner.GenerateTurn(replayCtx, domain.AgentProcessRequest{
+		Input:          step.Input,
+		SessionID:      trace.SessionID,
+		UserID:         trace.UserID,
+		Channel:        trace.Channel,
+		ResponseFormat: trace.ResponseFormat,
+	}, cfg, tools)
+	if runErr != nil {
+		result.Replay.Error = observability.RedactSecrets(runErr.Error())
+	} else {
+		result.Replay.Text = turn.Text
+		result.Replay.ToolCalls = toTraceToolCalls(turn.ToolCalls)
+	}
+	result.Diff = diffOutcomes(result.Original, result.Replay)
+	return result, nil
+}
+
+func toTraceToolCalls(calls []runner.ToolCall) []domain.TurnTraceToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]domain.TurnTraceToolCall, 0, len(calls))
+	for _, call := range calls {
+		out = append(out, domain.TurnTraceToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+	}
+	return out
+}
+
+func diffOutcomes(original, replay domain.TurnReplayOutcome) domain.TurnReplayDiff {
+	diff := domain.TurnReplayDiff{}
+	if strings.TrimSpace(original.Text) != strings.TrimSpace(replay.Text) {
+		diff.TextChanged = true
+		diff.TextDiff = diffLines(original.Text, replay.Text)
+	}
+	diff.AddedToolCalls, diff.RemovedToolCalls = diffToolCalls(original.ToolCalls, replay.ToolCalls)
+	diff.ToolCallsChanged = len(diff.AddedToolCalls) > 0 || len(diff.RemovedToolCalls) > 0
+	diff.Changed = diff.TextChanged || diff.ToolCallsChanged || original.Error != replay.Error
+	return diff
+}
+
+// diffToolCalls compares calls by name and arguments, ignoring provider call ids.
+func diffToolCalls(original, replay []domain.TurnTraceToolCall) ([]string, []string) {
+	remaining := map[string]int{}
+	for _, call := range original {
+		remaining[toolCallSignature(call)]++
+	}
+	var added []string
+	for _, call := range replay {
+		signature := toolCallSignature(call)
+		if remaining[signature] > 0 {
+			remaining[signature]--
+			continue
+		}
+		added = append(added, signature)
+	}
+	var removed []string
+	for _, call := range original {
+		signature := toolCallSignature(call)
+		if remaining[signature] > 0 {
+			remaining[signature]--
+			removed = append(removed, signature)
+		}
+	}
+	return added, removed
+}
+
+func toolCallSignature(call domain.TurnTraceToolCall) string {
+	args, err := json.Marshal(call.Arguments)
+	if err != nil || call.Arguments == nil {
+		args = []byte("{}")
+	}
+	return call.Name + string(args)
+}
+
+// diffLines returns a line diff with " ", "-" and "+" prefixes. Inputs longer
+// than maxDiffLines are reported as a full replacement.
+func diffLines(before, after string) []string {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		out := make([]string, 0, len(a)+len(b))
+		for _, line := range a {
+			out = append(out, diffRemoved+line)
+		}
+		for _, line := range b {
+			out = append(out, diffAdded+line)
+		}
+		return out
+	}
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	out := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffContextEqual+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffRemoved+a[i])
+			i++
+		default:
+			out = append(out, diffAdded+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, diffRemoved+a[i])
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffAdded+b[j])
+	}
+	return out
+}
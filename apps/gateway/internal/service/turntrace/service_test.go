@@ -0,0 +1,141 @@
+package turntrace
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nextai/apps/gateway/internal/domain"
+	"nextai/apps/gateway/internal/runner"
+)
+
+type fakeRunner struct {
+	turn    runner.TurnResult
+	gotCfg  runner.GenerateConfig
+	gotReq  domain.AgentProcessRequest
+	gotTool []runner.ToolDefinition
+}
+
+func (f *fakeRunner) GenerateTurn(
+	_ context.Context,
+	req domain.AgentProcessRequest,
+	cfg runner.GenerateConfig,
+	tools []runner.ToolDefinition,
+) (runner.TurnResult, error) {
+	f.gotReq, f.gotCfg, f.gotTool = req, cfg, tools
+	return f.turn, nil
+}
+
+func (f *fakeRunner) GenerateTurnStream(
+	ctx context.Context,
+	req domain.AgentProcessRequest,
+	cfg runner.GenerateConfig,
+	tools []runner.ToolDefinition,
+	_ func(string),
+) (runner.TurnResult, error) {
+	return f.GenerateTurn(ctx, req, cfg, tools)
+}
+
+func TestSaveGetAndPrune(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	svc := NewService(Dependencies{DataDir: dir, Limit: 2})
+	ids := []string{"0000000000000001", "0000000000000002", "0000000000000003"}
+	base := time.Now().Add(-time.Hour)
+	for i, id := range ids {
+		if err := svc.Save(domain.TurnTrace{ID: id, Status: "succeeded"}); err != nil {
+			t.Fatalf("save %s failed: %v", id, err)
+		}
+		stamp := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, tracesDirName, id+".json"), stamp, stamp); err != nil {
+			t.Fatalf("chtimes failed: %v", err)
+		}
+	}
+	if err := svc.Save(domain.TurnTrace{ID: "0000000000000004"}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if _, err := svc.Get(ids[0]); !errors.Is(err, ErrTraceNotFound) {
+		t.Fatalf("expected oldest trace pruned, err=%v", err)
+	}
+	if _, err := svc.Get(ids[1]); !errors.Is(err, ErrTraceNotFound) {
+		t.Fatalf("expected second trace pruned, err=%v", err)
+	}
+	got, err := svc.Get(ids[2])
+	if err != nil || got.Status != "succeeded" {
+		t.Fatalf("get failed: trace=%+v err=%v", got, err)
+	}
+	if err := svc.Save(domain.TurnTrace{ID: "../escape"}); err == nil {
+		t.Fatal("expected invalid id to be rejected")
+	}
+	if _, err := svc.Get("../escape"); !errors.Is(err, ErrTraceNotFound) {
+		t.Fatalf("expected not found for invalid id, err=%v", err)
+	}
+}
+
+func TestReplayDiffsAgainstRecordedStep(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeRunner{turn: runner.TurnResult{
+		Text:      "line one\nline three",
+		ToolCalls: []runner.ToolCall{{ID: "call-9", Name: "shell", Arguments: map[string]interface{}{"cmd": "ls"}}},
+	}}
+	svc := NewService(Dependencies{
+		DataDir: t.TempDir(),
+		Runner:  fake,
+		ResolveGenerateConfig: func(providerID, model string) (runner.GenerateConfig, error) {
+			return runner.GenerateConfig{ProviderID: providerID, Model: model}, nil
+		},
+	})
+	input := []domain.AgentInputMessage{{Role: "user", Type: "message", Content: []domain.RuntimeContent{{Type: "text", Text: "hi"}}}}
+	if err := svc.Save(domain.TurnTrace{
+		ID:         "00000000000000aa",
+		ProviderID: "openai",
+		Model:      "gpt-4o-mini",
+		Tools:      []domain.TurnTraceTool{{Name: "shell"}},
+		Steps: []domain.TurnTraceStep{{
+			Step:      1,
+			Input:     input,
+			Text:      "line one\nline two",
+			ToolCalls: []domain.TurnTraceToolCall{{ID: "call-1", Name: "view", Arguments: map[string]interface{}{"path": "a"}}},
+		}},
+	}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	result, err := svc.Replay(context.Background(), "00000000000000aa", domain.TurnReplayRequest{ProviderID: "other", Model: "m2"})
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if fake.gotCfg.ProviderID != "other" || fake.gotCfg.Model != "m2" {
+		t.Fatalf("unexpected replay config: %+v", fake.gotCfg)
+	}
+	if len(fake.gotReq.Input) != 1 || len(fake.gotTool) != 1 || fake.gotTool[0].Name != "shell" {
+		t.Fatalf("expected recorded input and tools, req=%+v tools=%+v", fake.gotReq, fake.gotTool)
+	}
+	if !result.Diff.Changed || !result.Diff.TextChanged || !result.Diff.ToolCallsChanged {
+		t.Fatalf("expected changes in diff: %+v", result.Diff)
+	}
+	if got := strings.Join(result.Diff.TextDiff, "|"); got != " line one|-line two|+line three" {
+		t.Fatalf("text diff=%q", got)
+	}
+	if len(result.Diff.AddedToolCalls) != 1 || !strings.HasPrefix(result.Diff.AddedToolCalls[0], "shell") {
+		t.Fatalf("added tool calls=%v", result.Diff.AddedToolCalls)
+	}
+	if len(result.Diff.RemovedToolCalls) != 1 || !strings.HasPrefix(result.Diff.RemovedToolCalls[0], "view") {
+		t.Fatalf("removed tool calls=%v", result.Diff.RemovedToolCalls)
+	}
+
+	if _, err := svc.Replay(context.Background(), "00000000000000aa", domain.TurnReplayRequest{Step: 3}); err == nil {
+		t.Fatal("expected invalid step error")
+	} else if validation := (*ValidationError)(nil); !errors.As(err, &validation) || validation.Code != "invalid_step" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Replay(context.Background(), "00000000000000aa", domain.TurnReplayRequest{ProviderID: "other"}); err == nil {
+		t.Fatal("expected model required error")
+	}
+}
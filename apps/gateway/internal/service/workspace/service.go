@@ -11,6 +11,7 @@ import (
 
 	"nextai/apps/gateway/internal/domain"
 	"nextai/apps/gateway/internal/repo"
+	"nextai/apps/gateway/internal/secrets"
 	"nextai/apps/gateway/internal/service/ports"
 )
 
@@ -81,6 +82,11 @@ type ExportPayload struct {
 	Config  ExportConfig                `json:"config"`
 }
 
+const (
+	ExportSecretsRedact  = "redact"
+	ExportSecretsEncrypt = "encrypt"
+)
+
 type ImportRequest struct {
 	Mode    string        `json:"mode"`
 	Payload ExportPayload `json:"payload"`
@@ -89,6 +95,7 @@ type ImportRequest struct {
 type Dependencies struct {
 	Store             ports.StateStore
 	DataDir           string
+	Cipher            *secrets.Cipher
 	SupportedChannels map[string]struct{}
 	IsTextFilePath    func(string) bool
 	ReadTextFile      func(string) (string, string, error)
@@ -366,10 +373,26 @@ func (s *Service) DeleteFile(filePath string) (bool, error) {
 	return deleted, nil
 }
 
-func (s *Service) Export() (ExportPayload, error) {
+func (s *Service) Export(secretsMode string) (ExportPayload, error) {
 	if err := s.validateStore(); err != nil {
 		return ExportPayload{}, err
 	}
+	secretsMode = strings.ToLower(strings.TrimSpace(secretsMode))
+	if secretsMode == "" {
+		secretsMode = ExportSecretsRedact
+	}
+	if secretsMode != ExportSecretsRedact && secretsMode != ExportSecretsEncrypt {
+		return ExportPayload{}, &ValidationError{
+			Code:    "invalid_export_secrets",
+			Message: "secrets must be redact or encrypt",
+		}
+	}
+	if secretsMode == ExportSecretsEncrypt && s.deps.Cipher == nil {
+		return ExportPayload{}, &ValidationError{
+			Code:    "master_key_required",
+			Message: "secrets=encrypt requires NEXTAI_MASTER_KEY",
+		}
+	}
 
 	out := ExportPayload{
 		Version: "v1",
@@ -390,6 +413,18 @@ func (s *Service) Export() (ExportPayload, error) {
 		out.Config.Models.Providers = cloneWorkspaceProviders(st.Providers)
 		out.Config.Models.ActiveLLM = st.ActiveLLM
 	})
+	err := repo.WalkSecrets(out.Config.Envs, out.Config.Channels, out.Config.Models.Providers, false, func(_ string, value string) (string, error) {
+		if value == "" {
+			return value, nil
+		}
+		if secretsMode == ExportSecretsEncrypt {
+			return s.deps.Cipher.Seal(value)
+		}
+		return secrets.Redacted, nil
+	})
+	if err != nil {
+		return ExportPayload{}, err
+	}
 	return out, nil
 }
 
@@ -449,6 +484,9 @@ func (s *Service) Import(body []byte) error {
 	}
 
 	return s.deps.Store.WriteSettings(func(st *ports.SettingsAggregate) error {
+		if err := s.resolveImportedSecrets(st, envs, channels, providers); err != nil {
+			return err
+		}
 		st.Skills = skills
 		st.Envs = envs
 		st.Channels = channels
@@ -458,6 +496,33 @@ func (s *Service) Import(body []byte) error {
 	})
 }
 
+func (s *Service) resolveImportedSecrets(
+	current *ports.SettingsAggregate,
+	envs map[string]string,
+	channels domain.ChannelConfigMap,
+	providers map[string]repo.ProviderSetting,
+) error {
+	existing := map[string]string{}
+	currentEnvs, currentChannels, currentProviders := repo.CloneSecretFields(current.Envs, current.Channels, current.Providers)
+	_ = repo.WalkSecrets(currentEnvs, currentChannels, currentProviders, true, func(path, value string) (string, error) {
+		existing[path] = value
+		return value, nil
+	})
+	return repo.WalkSecrets(envs, channels, providers, true, func(path, value string) (string, error) {
+		if value == secrets.Redacted {
+			return existing[path], nil
+		}
+		opened, err := s.deps.Cipher.Open(value)
+		if err != nil {
+			return "", &ValidationError{
+				Code:    "invalid_secret",
+				Message: fmt.Sprintf("%s cannot be decrypted: %v", path, err),
+			}
+		}
+		return opened, nil
+	})
+}
+
 func (s *Service) validateStore() error {
 	if s == nil || s.deps.Store == nil {
 		return errors.New("workspace state store is required")
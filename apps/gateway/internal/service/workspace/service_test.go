@@ -1,6 +1,7 @@
 package workspace
 
 import (
+	"encoding/json"
 	"errors"
 	"os"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 
 	"nextai/apps/gateway/internal/domain"
 	"nextai/apps/gateway/internal/repo"
+	"nextai/apps/gateway/internal/secrets"
 	"nextai/apps/gateway/internal/service/adapters"
 )
 
@@ -139,3 +141,38 @@ func newTestStore(t *testing.T) (*repo.Store, string) {
 	}
 	return store, dir
 }
+
+func TestExportRedactsSecretsAndImportKeepsCurrentValues(t *testing.T) {
+	t.Parallel()
+
+	svc := newTestService(t, Dependencies{})
+	if err := svc.PutFile(FileEnvs, []byte(`{"OPENAI_API_KEY":"sk-env","MODE":"dev"}`)); err != nil {
+		t.Fatalf("put envs failed: %v", err)
+	}
+
+	exported, err := svc.Export("")
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if exported.Config.Envs["OPENAI_API_KEY"] != secrets.Redacted || exported.Config.Envs["MODE"] != "dev" {
+		t.Fatalf("unexpected exported envs: %+v", exported.Config.Envs)
+	}
+	if _, err := svc.Export(ExportSecretsEncrypt); err == nil {
+		t.Fatal("expected encrypt export without master key to fail")
+	}
+
+	body, err := json.Marshal(ImportRequest{Mode: "replace", Payload: exported})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Import(body); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	envs, err := svc.GetFile(FileEnvs)
+	if err != nil {
+		t.Fatalf("get envs failed: %v", err)
+	}
+	if got := envs.(map[string]string)["OPENAI_API_KEY"]; got != "sk-env" {
+		t.Fatalf("expected redacted secret to keep current value, got=%q", got)
+	}
+}